@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"house-bartender-go/internal/app"
+)
+
+const cliUsage = `usage: housebartender [command]
+
+Without a command the web server starts. Commands use the same environment as the server.
+
+  backup [-uploads=true|false] [-o file]   write a backup archive to BACKUP_DIR (and copy it to file)
+  backups                                  list the archives in BACKUP_DIR
+  restore <archive>                        restore an archive; stop the server first
+`
+
+// runCommand runs a CLI subcommand and returns the process exit code.
+func runCommand(cfg app.Config, logger *slog.Logger, args []string) int {
+	// Commands are one-shot: no background jobs.
+	cfg.BackupInterval = 0
+	cfg.MediaGCInterval = 0
+	cfg.DBCheckpointInterval = 0
+
+	switch args[0] {
+	case "backup":
+		return cmdBackup(cfg, logger, args[1:])
+	case "backups":
+		return cmdBackups(cfg, logger)
+	case "restore":
+		return cmdRestore(cfg, logger, args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+	return 2
+}
+
+func cmdBackup(cfg app.Config, logger *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	uploads := fs.Bool("uploads", cfg.BackupIncludeUploads, "include the upload directory")
+	out := fs.String("o", "", "also copy the archive to this path")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	a, err := app.New(cfg, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "init:", err)
+		return 1
+	}
+	defer a.Close()
+
+	info, err := a.Backups().Create(time.Now(), *uploads, "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "backup failed:", err)
+		return 1
+	}
+	path := filepath.Join(cfg.BackupDir, info.Name)
+	if *out != "" {
+		if err := copyFile(path, *out); err != nil {
+			fmt.Fprintln(os.Stderr, "copy failed:", err)
+			return 1
+		}
+		path = *out
+	}
+	fmt.Printf("%s (%d bytes)\n", path, info.Size)
+	return 0
+}
+
+func cmdBackups(cfg app.Config, logger *slog.Logger) int {
+	a, err := app.New(cfg, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "init:", err)
+		return 1
+	}
+	defer a.Close()
+
+	list, err := a.Backups().List()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "list failed:", err)
+		return 1
+	}
+	for _, b := range list {
+		fmt.Printf("%s\t%s\t%d\n", b.Name, b.CreatedAt.Local().Format("2006-01-02 15:04:05"), b.Size)
+	}
+	return 0
+}
+
+func cmdRestore(cfg app.Config, logger *slog.Logger, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	archive, err := filepath.Abs(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, err := os.Stat(archive); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	a, err := app.New(cfg, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "init:", err)
+		return 1
+	}
+	defer a.Close()
+
+	manifest, err := a.RestoreBackup(archive)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "restore failed:", err)
+		return 1
+	}
+	fmt.Printf("restored backup from %s (%d uploaded files)\n", manifest.CreatedAt.Local().Format("2006-01-02 15:04:05"), manifest.Uploads)
+	return 0
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -7,11 +7,14 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/catalog"
 	"house-bartender-go/internal/handlers"
 
 	"github.com/go-chi/chi/v5"
@@ -29,13 +32,41 @@ func main() {
 		DBPath:    getenv("DB_PATH", "/data/housebartender.db"),
 		UploadDir: getenv("UPLOAD_DIR", "/data/uploads"),
 
+		DBBusyTimeout:        getenvDuration("DB_BUSY_TIMEOUT", 5*time.Second),
+		DBReadConns:          getenvInt("DB_READ_CONNS", 4),
+		DBWALAutocheckpoint:  getenvInt("DB_WAL_AUTOCHECKPOINT", 1000),
+		DBCheckpointInterval: getenvDuration("DB_CHECKPOINT_INTERVAL", 5*time.Minute),
+
+		MediaGCInterval: getenvDuration("MEDIA_GC_INTERVAL", 24*time.Hour),
+		MediaGCGrace:    getenvDuration("MEDIA_GC_GRACE", 72*time.Hour),
+
+		BackupDir:            getenv("BACKUP_DIR", ""),
+		BackupInterval:       getenvDuration("BACKUP_INTERVAL", 24*time.Hour),
+		BackupKeep:           getenvInt("BACKUP_KEEP", 7),
+		BackupIncludeUploads: getenv("BACKUP_INCLUDE_UPLOADS", "true") != "false",
+
+		ShiftIdleTimeout: getenvDuration("SHIFT_IDLE_TIMEOUT", 30*time.Minute),
+
 		VAPIDPublicKey:  strings.TrimSpace(os.Getenv("VAPID_PUBLIC_KEY")),
 		VAPIDPrivateKey: strings.TrimSpace(os.Getenv("VAPID_PRIVATE_KEY")),
 		VAPIDSubject:    strings.TrimSpace(os.Getenv("VAPID_SUBJECT")),
 
+		PushNotifyAccepted: getenv("PUSH_NOTIFY_ACCEPTED", "false") == "true",
+		PushOverdueAfter:   getenvDuration("PUSH_OVERDUE_AFTER", 15*time.Minute),
+
 		BootstrapAdminEmail:    os.Getenv("BOOTSTRAP_ADMIN_EMAIL"),
 		BootstrapAdminPassword: os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"),
 		BootstrapAdminName:     os.Getenv("BOOTSTRAP_ADMIN_NAME"),
+
+		OIDCIssuer:        strings.TrimSpace(os.Getenv("OIDC_ISSUER")),
+		OIDCClientID:      strings.TrimSpace(os.Getenv("OIDC_CLIENT_ID")),
+		OIDCClientSecret:  strings.TrimSpace(os.Getenv("OIDC_CLIENT_SECRET")),
+		OIDCProviderName:  strings.TrimSpace(os.Getenv("OIDC_PROVIDER_NAME")),
+		OIDCScopes:        getenv("OIDC_SCOPES", "openid email profile"),
+		OIDCRoleClaim:     strings.TrimSpace(os.Getenv("OIDC_ROLE_CLAIM")),
+		OIDCRoleMap:       os.Getenv("OIDC_ROLE_MAP"),
+		OIDCDefaultRole:   strings.TrimSpace(os.Getenv("OIDC_DEFAULT_ROLE")),
+		OIDCAutoProvision: getenv("OIDC_AUTO_PROVISION", "false") == "true",
 	}
 
 	// Optional: allow keys as hex in env
@@ -50,6 +81,14 @@ func main() {
 		}
 	}
 
+	if cfg.BackupDir == "" {
+		cfg.BackupDir = filepath.Join(cfg.DataDir, "backups")
+	}
+
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(cfg, logger, os.Args[1:]))
+	}
+
 	a, err := app.New(cfg, logger)
 	if err != nil {
 		logger.Error("app init failed", "err", err)
@@ -57,7 +96,36 @@ func main() {
 	}
 	defer a.Close()
 
-	// Build router here (avoids app<->handlers import cycle)
+	srv := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      newRouter(a),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 60 * time.Second,
+		IdleTimeout:  90 * time.Second,
+	}
+
+	go func() {
+		logger.Info("listening", "addr", cfg.Addr, "base_url", cfg.BaseURL)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("server error", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	logger.Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+	logger.Info("shutdown complete")
+}
+
+// newRouter wires the routes. It lives here rather than in app to avoid an
+// app<->handlers import cycle.
+func newRouter(a *app.App) http.Handler {
 	r := chi.NewRouter()
 	r.Use(chimw.RealIP)
 	r.Use(chimw.RequestID)
@@ -67,6 +135,8 @@ func main() {
 	r.Use(a.MiddlewareNoCacheForHTMX)
 	r.Use(a.MiddlewareLoadCurrentUser)
 	r.Use(a.MiddlewareOnboardingGate)
+	r.Use(a.MiddlewareGuestScope)
+	r.Use(a.MiddlewareTwoFactorGate)
 
 	h := &handlers.Server{App: a}
 
@@ -74,16 +144,34 @@ func main() {
 	r.Get("/health", h.Health)
 	r.Get("/login", h.LoginGet)
 	r.Post("/login", h.LoginPost)
+	r.Get("/login/2fa", h.TwoFactorLoginGet)
+	r.Post("/login/2fa", h.TwoFactorLoginPost)
 	r.Post("/logout", h.LogoutPost)
+	r.Get("/auth/oidc/login", h.OIDCLoginGet)
+	r.Get("/auth/oidc/callback", h.OIDCCallbackGet)
 
 	r.Get("/onboarding", h.OnboardingGet)
 	r.Post("/onboarding", h.OnboardingPost)
+	r.Get("/join/{token}", h.JoinGet)
+	r.Post("/join/{token}", h.JoinPost)
+	r.Get("/reset", h.ResetGet)
+	r.Get("/reset/{code}", h.ResetCodeGet)
+	r.Post("/reset/{code}", h.ResetCodePost)
+	r.Get("/kiosk", h.KioskGet)
+	r.Post("/kiosk/guests/{id}", h.KioskGuestPost)
+	r.Get("/kiosk/pair/{code}", h.KioskPairGet)
+	r.Post("/kiosk/pair/{code}", h.KioskPairPost)
+	r.Get("/walkup", h.WalkupGet)
+	r.Post("/walkup", h.WalkupPost)
+	r.Post("/walkup/leave", h.WalkupLeavePost)
 	r.Get("/manifest.webmanifest", h.ManifestGet)
 	r.Get("/sw.js", h.ServiceWorkerGet)
 
 	// Static + uploads
 	fileServer(r, "/static", http.Dir("static"))
 	fileServer(r, "/uploads", http.Dir(a.Config().UploadDir))
+	fileServer(r, catalog.ArtPrefix, http.FS(catalog.ArtFS()))
+	r.Get(catalog.GeneratedPrefix+"/{file}", h.GeneratedCocktailImageGet)
 
 	// Authenticated common
 	r.Group(func(ar chi.Router) {
@@ -91,8 +179,21 @@ func main() {
 
 		ar.Get("/", h.UserHomeGet)
 		ar.Get("/cocktails/{id}", h.CocktailDetailGet)
-		ar.Post("/orders", h.OrderCreatePost)
+		ar.With(a.RequirePermission(app.PermOrdersPlace)).Post("/orders", h.OrderCreatePost)
 		ar.Get("/orders", h.UserOrdersGet)
+		ar.Post("/notifications/subscribe", h.PushSubscribePost)
+		ar.Post("/notifications/unsubscribe", h.PushUnsubscribePost)
+
+		ar.Get("/account", h.AccountGet)
+		ar.Post("/account/profile", h.AccountProfilePost)
+		ar.Post("/account/password", h.AccountPasswordPost)
+		ar.Get("/account/2fa", h.AccountTwoFactorGet)
+		ar.Post("/account/2fa", h.AccountTwoFactorPost)
+		ar.Get("/account/2fa/qr.svg", h.AccountTwoFactorQRGet)
+		ar.Post("/account/2fa/recovery", h.AccountRecoveryCodesPost)
+		ar.Post("/account/2fa/disable", h.AccountTwoFactorDisablePost)
+		ar.Post("/account/pin", h.AccountPINPost)
+		ar.Post("/account/pin/delete", h.AccountPINDeletePost)
 
 		ar.Get("/partials/user/cocktails", h.UserCocktailsPartialGet)
 		ar.Get("/partials/user/orders", h.UserOrdersPartialGet)
@@ -100,94 +201,149 @@ func main() {
 		ar.Get("/sse", h.SSEGet)
 	})
 
-	// Bartender (admin allowed)
+	// Staff portal; each section needs its own permission
 	r.Route("/bartender", func(br chi.Router) {
-		br.Use(a.RequireAnyRole(app.RoleBartender, app.RoleAdmin))
-
-		br.Get("/", h.BartenderDashboardGet)
-		br.Post("/duty", h.BartenderDutyPost)
-
-		br.Get("/products", h.BartenderProductsGet)
-		br.Post("/products", h.ProductCreatePost)
-		br.Get("/products/{id}/edit", h.ProductEditGet)
-		br.Post("/products/{id}/edit", h.ProductEditPost)
-		br.Post("/products/{id}/toggle", h.ProductTogglePost)
-		br.Post("/products/{id}/stock", h.ProductStockPost)
-		br.Post("/products/{id}/delete", h.ProductDeletePost)
-
-		br.Get("/cocktails", h.BartenderCocktailsGet)
-		br.Get("/cocktails/new", h.CocktailNewGet)
-		br.Post("/cocktails/new", h.CocktailNewPost)
-		br.Get("/cocktails/{id}/edit", h.CocktailEditGet)
-		br.Post("/cocktails/{id}/edit", h.CocktailEditPost)
-		br.Post("/cocktails/{id}/toggle", h.CocktailTogglePost)
-		br.Post("/cocktails/{id}/delete", h.CocktailDeletePost)
-
-		br.Get("/orders", h.BartenderOrdersGet)
-		br.Post("/orders/{id}/accept", h.OrderAcceptPost)
-		br.Post("/orders/{id}/assign", h.OrderAssignPost)
-		br.Post("/orders/{id}/complete", h.OrderCompletePost)
-		br.Post("/orders/{id}/status", h.OrderStatusPost)
-		br.Post("/orders/{id}/cancel", h.OrderCancelPost)
-
-		br.Get("/partials/products", h.BartenderProductsPartialGet)
-		br.Get("/partials/cocktails", h.BartenderCocktailsPartialGet)
-		br.Get("/partials/orders", h.BartenderOrdersPartialGet)
-
-		br.Group(func(nr chi.Router) {
-			nr.Use(a.RequireRole(app.RoleBartender))
-			nr.Post("/notifications/subscribe", h.PushSubscribePost)
-			nr.Post("/notifications/unsubscribe", h.PushUnsubscribePost)
+		br.Use(a.RequirePermission(app.PermOrdersManage, app.PermInventoryEdit, app.PermCocktailsEdit))
+
+		br.Group(func(qr chi.Router) {
+			qr.Use(a.RequirePermission(app.PermOrdersManage))
+
+			qr.Get("/", h.BartenderDashboardGet)
+			qr.Post("/duty", h.BartenderDutyPost)
+
+			qr.Get("/orders", h.BartenderOrdersGet)
+			qr.Post("/orders/{id}/accept", h.OrderAcceptPost)
+			qr.Post("/orders/{id}/assign", h.OrderAssignPost)
+			qr.Post("/orders/{id}/complete", h.OrderCompletePost)
+			qr.Post("/orders/{id}/status", h.OrderStatusPost)
+			qr.Post("/orders/{id}/cancel", h.OrderCancelPost)
+			qr.Get("/partials/orders", h.BartenderOrdersPartialGet)
+
+			qr.Post("/notifications/devices/{id}", h.PushDevicePost)
+			qr.Post("/notifications/devices/{id}/test", h.PushDeviceTestPost)
+			qr.Post("/notifications/devices/{id}/delete", h.PushDeviceDeletePost)
+		})
+
+		br.Group(func(ir chi.Router) {
+			ir.Use(a.RequirePermission(app.PermInventoryEdit))
+
+			ir.Get("/products", h.BartenderProductsGet)
+			ir.Post("/products", h.ProductCreatePost)
+			ir.Get("/products/{id}/edit", h.ProductEditGet)
+			ir.Post("/products/{id}/edit", h.ProductEditPost)
+			ir.Post("/products/{id}/toggle", h.ProductTogglePost)
+			ir.Post("/products/{id}/stock", h.ProductStockPost)
+			ir.Post("/products/{id}/delete", h.ProductDeletePost)
+			ir.Post("/products/scan", h.BarcodeScanPost)
+			ir.Get("/partials/products", h.BartenderProductsPartialGet)
+
+			ir.Get("/stocktakes", h.StocktakesGet)
+			ir.Post("/stocktakes", h.StocktakeCreatePost)
+			ir.Get("/stocktakes/{id}", h.StocktakeGet)
+			ir.Post("/stocktakes/{id}", h.StocktakePost)
+			ir.Post("/stocktakes/{id}/discard", h.StocktakeDiscardPost)
+
+			ir.Get("/makeable", h.BartenderMakeableGet)
+			ir.Get("/makeable/shopping-list", h.BartenderShoppingListGet)
+
+			ir.Get("/stations", h.BartenderStationStockGet)
+			ir.Get("/stations/{id}", h.BartenderStationStockGet)
+			ir.Post("/stations/{id}/stock/{product}", h.BartenderStationStockPost)
+		})
+
+		br.Group(func(cr chi.Router) {
+			cr.Use(a.RequirePermission(app.PermCocktailsEdit))
+
+			cr.Get("/cocktails", h.BartenderCocktailsGet)
+			cr.Get("/cocktails/new", h.CocktailNewGet)
+			cr.Post("/cocktails/new", h.CocktailNewPost)
+			cr.Get("/cocktails/{id}/edit", h.CocktailEditGet)
+			cr.Post("/cocktails/{id}/edit", h.CocktailEditPost)
+			cr.Post("/cocktails/{id}/toggle", h.CocktailTogglePost)
+			cr.Post("/cocktails/{id}/delete", h.CocktailDeletePost)
+			cr.Get("/partials/cocktails", h.BartenderCocktailsPartialGet)
 		})
 	})
 
 	r.Route("/partials/bartender", func(pr chi.Router) {
-		pr.Use(a.RequireAnyRole(app.RoleBartender, app.RoleAdmin))
-
-		pr.Get("/products", h.BartenderProductsPartialGet)
-		pr.Get("/cocktails", h.BartenderCocktailsPartialGet)
-		pr.Get("/orders", h.BartenderOrdersPartialGet)
+		pr.With(a.RequirePermission(app.PermInventoryEdit)).Get("/products", h.BartenderProductsPartialGet)
+		pr.With(a.RequirePermission(app.PermCocktailsEdit)).Get("/cocktails", h.BartenderCocktailsPartialGet)
+		pr.With(a.RequirePermission(app.PermOrdersManage)).Get("/orders", h.BartenderOrdersPartialGet)
 	})
 
 	// Admin
 	r.Route("/admin", func(ad chi.Router) {
-		ad.Use(a.RequireRole(app.RoleAdmin))
-
-		ad.Get("/users", h.AdminUsersGet)
-		ad.Post("/users", h.AdminUserCreatePost)
-		ad.Post("/users/{id}", h.AdminUserUpdatePost)
-		ad.Post("/users/{id}/toggle", h.AdminUserTogglePost)
-		ad.Post("/users/{id}/duty", h.AdminUserDutyPost)
-
-		ad.Get("/settings", h.AdminSettingsGet)
-		ad.Post("/settings/seed", h.AdminSettingsSeedPost)
-	})
-
-	srv := &http.Server{
-		Addr:         cfg.Addr,
-		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 60 * time.Second,
-		IdleTimeout:  90 * time.Second,
-	}
+		ad.Group(func(ur chi.Router) {
+			ur.Use(a.RequirePermission(app.PermUsersManage))
+
+			ur.Get("/users", h.AdminUsersGet)
+			ur.Post("/users", h.AdminUserCreatePost)
+			ur.Post("/users/{id}", h.AdminUserUpdatePost)
+			ur.Post("/users/{id}/toggle", h.AdminUserTogglePost)
+			ur.Post("/users/{id}/duty", h.AdminUserDutyPost)
+			ur.Post("/users/{id}/reset", h.AdminUserResetPost)
+			ur.Post("/users/{id}/2fa/reset", h.AdminUserTwoFactorResetPost)
+
+			ur.Get("/roles", h.AdminRolesGet)
+			ur.Post("/roles", h.AdminRoleCreatePost)
+			ur.Post("/roles/{name}", h.AdminRoleUpdatePost)
+			ur.Post("/roles/{name}/delete", h.AdminRoleDeletePost)
+			ur.Post("/roles/{name}/2fa", h.AdminRoleTwoFactorPost)
+
+			ur.Get("/invites", h.AdminInvitesGet)
+			ur.Post("/invites", h.AdminInviteCreatePost)
+			ur.Post("/invites/{id}/revoke", h.AdminInviteRevokePost)
+			ur.Get("/invites/{id}/qr.svg", h.AdminInviteQRGet)
+
+			ur.Get("/kiosks", h.AdminKiosksGet)
+			ur.Post("/kiosks", h.AdminKioskCreatePost)
+			ur.Post("/kiosks/{id}/revoke", h.AdminKioskRevokePost)
+
+			ur.Get("/walkup", h.AdminWalkupGet)
+			ur.Post("/walkup", h.AdminWalkupOpenPost)
+			ur.Post("/walkup/{id}/extend", h.AdminWalkupExtendPost)
+			ur.Post("/walkup/{id}/close", h.AdminWalkupClosePost)
+			ur.Get("/walkup/qr.svg", h.AdminWalkupQRGet)
+		})
 
-	go func() {
-		logger.Info("listening", "addr", cfg.Addr, "base_url", cfg.BaseURL)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("server error", "err", err)
-			os.Exit(1)
-		}
-	}()
+		ad.Group(func(rr chi.Router) {
+			rr.Use(a.RequirePermission(app.PermReportsView))
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+			rr.Get("/low-stock", h.AdminLowStockGet)
+			rr.Get("/audit", h.AdminAuditGet)
+			rr.Get("/audit/export", h.AdminAuditExportGet)
+			rr.Get("/shifts", h.AdminShiftsGet)
+			rr.Get("/shifts/{id}", h.AdminShiftGet)
+		})
 
-	logger.Info("shutting down")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	_ = srv.Shutdown(ctx)
-	logger.Info("shutdown complete")
+		ad.Group(func(sr chi.Router) {
+			sr.Use(a.RequirePermission(app.PermSettingsManage))
+
+			sr.Get("/settings", h.AdminSettingsGet)
+			sr.Post("/settings/seed", h.AdminSettingsSeedPost)
+			sr.Post("/settings/media-gc", h.AdminMediaGCPost)
+			sr.Post("/backups", h.AdminBackupCreatePost)
+			sr.Post("/backups/restore", h.AdminBackupRestorePost)
+			sr.Get("/backups/{name}", h.AdminBackupDownloadGet)
+
+			sr.Get("/stations", h.AdminStationsGet)
+			sr.Post("/stations", h.AdminStationCreatePost)
+			sr.Post("/stations/routes", h.AdminStationRoutesPost)
+			sr.Post("/stations/{id}", h.AdminStationRenamePost)
+			sr.Post("/stations/{id}/delete", h.AdminStationDeletePost)
+			sr.Post("/stations/{id}/bartenders", h.AdminStationBartendersPost)
+
+			sr.Get("/webhooks", h.AdminWebhooksGet)
+			sr.Post("/webhooks", h.AdminWebhookCreatePost)
+			sr.Get("/webhooks/{id}", h.AdminWebhookGet)
+			sr.Post("/webhooks/{id}", h.AdminWebhookUpdatePost)
+			sr.Post("/webhooks/{id}/secret", h.AdminWebhookSecretPost)
+			sr.Post("/webhooks/{id}/ping", h.AdminWebhookPingPost)
+			sr.Post("/webhooks/{id}/delete", h.AdminWebhookDeletePost)
+			sr.Post("/webhooks/{id}/deliveries/{delivery}/redeliver", h.AdminWebhookRedeliverPost)
+		})
+	})
+	return r
 }
 
 func getenv(k, def string) string {
@@ -198,6 +354,22 @@ func getenv(k, def string) string {
 	return v
 }
 
+func getenvInt(k string, def int) int {
+	n, err := strconv.Atoi(getenv(k, ""))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func getenvDuration(k string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(getenv(k, ""))
+	if err != nil {
+		return def
+	}
+	return d
+}
+
 func fileServer(r chi.Router, path string, root http.FileSystem) {
 	if strings.ContainsAny(path, "{}*") {
 		panic("fileServer does not permit URL params")
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+)
+
+// The app parses views/ relative to the working directory, as it does when run from the
+// repository root.
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// routeProbes is one or more routes from each permission group. An empty perm needs only a
+// signed-in user.
+var routeProbes = []struct {
+	method, path, perm string
+}{
+	{http.MethodGet, "/orders", ""},
+	{http.MethodGet, "/account", ""},
+	{http.MethodPost, "/orders", app.PermOrdersPlace},
+
+	{http.MethodGet, "/bartender/", app.PermOrdersManage},
+	{http.MethodGet, "/bartender/orders", app.PermOrdersManage},
+	{http.MethodGet, "/partials/bartender/orders", app.PermOrdersManage},
+	{http.MethodGet, "/bartender/products", app.PermInventoryEdit},
+	{http.MethodGet, "/bartender/stocktakes", app.PermInventoryEdit},
+	{http.MethodGet, "/bartender/makeable", app.PermInventoryEdit},
+	{http.MethodGet, "/bartender/stations", app.PermInventoryEdit},
+	{http.MethodGet, "/partials/bartender/products", app.PermInventoryEdit},
+	{http.MethodGet, "/bartender/cocktails", app.PermCocktailsEdit},
+	{http.MethodGet, "/partials/bartender/cocktails", app.PermCocktailsEdit},
+
+	{http.MethodGet, "/admin/users", app.PermUsersManage},
+	{http.MethodGet, "/admin/roles", app.PermUsersManage},
+	{http.MethodGet, "/admin/invites", app.PermUsersManage},
+	{http.MethodGet, "/admin/kiosks", app.PermUsersManage},
+	{http.MethodGet, "/admin/walkup", app.PermUsersManage},
+	{http.MethodGet, "/admin/low-stock", app.PermReportsView},
+	{http.MethodGet, "/admin/audit", app.PermReportsView},
+	{http.MethodGet, "/admin/shifts", app.PermReportsView},
+	{http.MethodGet, "/admin/settings", app.PermSettingsManage},
+	{http.MethodGet, "/admin/stations", app.PermSettingsManage},
+	{http.MethodGet, "/admin/webhooks", app.PermSettingsManage},
+}
+
+func newTestApp(t *testing.T) *app.App {
+	t.Helper()
+	a, err := app.New(app.Config{
+		DataDir:        t.TempDir(),
+		SessionHashKey: []byte(strings.Repeat("k", 32)),
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("app.New: %v", err)
+	}
+	t.Cleanup(func() { _ = a.Close() })
+	return a
+}
+
+func addUser(t *testing.T, a *app.App, email, role string) *db.User {
+	t.Helper()
+	id, err := a.Store().Q.CreateUser(db.CreateUserParams{Email: email, PasswordHash: "x", Role: role, DisplayName: email, IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser %s: %v", email, err)
+	}
+	u, _ := a.Store().Q.GetUserByID(id)
+	return u
+}
+
+// call sends the request through the full router, signed in as u when u is not nil.
+func call(t *testing.T, a *app.App, h http.Handler, u *db.User, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, nil)
+	if u != nil {
+		rec := httptest.NewRecorder()
+		if err := a.SetSessionUser(rec, r, u.ID); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range rec.Result().Cookies() {
+			r.AddCookie(c)
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRoutesRequireTheirPermission(t *testing.T) {
+	a := newTestApp(t)
+	h := newRouter(a)
+	admin := addUser(t, a, "admin@example.com", app.RoleAdmin)
+
+	// one account per permission, in a role holding only that permission
+	holders := map[string]*db.User{}
+	for _, p := range app.Permissions {
+		role := "ONLY_" + strings.ToUpper(strings.ReplaceAll(p.Key, ".", "_"))
+		if err := a.Store().Q.CreateRole(role, "", []string{p.Key}); err != nil {
+			t.Fatal(err)
+		}
+		holders[p.Key] = addUser(t, a, strings.ToLower(role)+"@example.com", role)
+	}
+	if err := a.ReloadRoles(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, probe := range routeProbes {
+		w := call(t, a, h, nil, probe.method, probe.path)
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+			t.Errorf("%s %s signed out: got %d %q, want a redirect to /login", probe.method, probe.path, w.Code, w.Header().Get("Location"))
+		}
+		if w := call(t, a, h, admin, probe.method, probe.path); w.Code == http.StatusForbidden {
+			t.Errorf("%s %s: admin was forbidden", probe.method, probe.path)
+		}
+		for perm, u := range holders {
+			w := call(t, a, h, u, probe.method, probe.path)
+			allowed := probe.perm == "" || probe.perm == perm
+			if allowed && w.Code == http.StatusForbidden {
+				t.Errorf("%s %s: %s was forbidden", probe.method, probe.path, perm)
+			}
+			if !allowed && w.Code != http.StatusForbidden {
+				t.Errorf("%s %s: %s got %d, want 403", probe.method, probe.path, perm, w.Code)
+			}
+		}
+	}
+}
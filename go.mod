@@ -4,11 +4,11 @@ go 1.22
 
 require (
 	github.com/go-chi/chi/v5 v5.0.12
+	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/mattn/go-sqlite3 v1.14.24
 	golang.org/x/crypto v0.31.0
+	golang.org/x/image v0.23.0
+	rsc.io/qr v0.2.0
 )
 
-require (
-	github.com/SherClockHolmes/webpush-go v1.4.0 // indirect
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-)
+require github.com/SherClockHolmes/webpush-go v1.4.0 // indirect
@@ -15,7 +15,16 @@ import (
 
 	"house-bartender-go/internal/catalog"
 	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/backup"
+	"house-bartender-go/internal/services/images"
+	"house-bartender-go/internal/services/library"
+	"house-bartender-go/internal/services/media"
+	"house-bartender-go/internal/services/oidc"
 	"house-bartender-go/internal/services/push"
+	"house-bartender-go/internal/services/shifts"
+	"house-bartender-go/internal/services/walkup"
+	"house-bartender-go/internal/services/webhooks"
 )
 
 type Config struct {
@@ -26,6 +35,27 @@ type Config struct {
 	DBPath    string
 	UploadDir string
 
+	// SQLite pools; zero values take the db package defaults.
+	DBBusyTimeout        time.Duration
+	DBReadConns          int
+	DBWALAutocheckpoint  int
+	DBCheckpointInterval time.Duration
+
+	// Unused uploads older than MediaGCGrace are deleted every MediaGCInterval (0 disables).
+	MediaGCInterval time.Duration
+	MediaGCGrace    time.Duration
+
+	// Backups are written to BackupDir every BackupInterval (0 disables); the newest
+	// BackupKeep are kept.
+	BackupDir            string
+	BackupInterval       time.Duration
+	BackupKeep           int
+	BackupIncludeUploads bool
+
+	// A bartender's shift is closed once their sessions have been idle for
+	// ShiftIdleTimeout (0 disables).
+	ShiftIdleTimeout time.Duration
+
 	SessionHashKey  []byte
 	SessionBlockKey []byte
 
@@ -33,9 +63,28 @@ type Config struct {
 	VAPIDPrivateKey string
 	VAPIDSubject    string
 
+	// PushNotifyAccepted also pushes guests a notice when their order is accepted.
+	PushNotifyAccepted bool
+	// Bartenders are alerted once about orders still open PushOverdueAfter after they were
+	// placed (0 disables).
+	PushOverdueAfter time.Duration
+
 	BootstrapAdminEmail    string
 	BootstrapAdminPassword string
 	BootstrapAdminName     string
+
+	// OpenID Connect login is offered when OIDCIssuer and OIDCClientID are set. OIDCRoleMap
+	// ("claim-value=ROLE,...") is matched against the OIDCRoleClaim claim on every login;
+	// OIDCAutoProvision creates accounts with OIDCDefaultRole for unknown users.
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCProviderName  string
+	OIDCScopes        string
+	OIDCRoleClaim     string
+	OIDCRoleMap       string
+	OIDCDefaultRole   string
+	OIDCAutoProvision bool
 }
 
 type App struct {
@@ -45,6 +94,15 @@ type App struct {
 	templates *template.Template
 	sseHub    *SSEHub
 	push      *push.Service
+	library   *library.Cache
+	media     *media.Collector
+	backups   *backup.Service
+	walkup    *walkup.Sweeper
+	shifts    *shifts.Sweeper
+	webhooks  *webhooks.Service
+	audit     *audit.Log
+	oidc      *oidc.Provider // nil unless configured
+	roles     roleCache
 
 	// Kept for backward compatibility; onboarding gating is enforced via DB in middleware.
 	needsOnboarding bool
@@ -106,7 +164,12 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		logger.Warn("SESSION_HASH_KEY_HEX not set (or too short) - generating ephemeral session key; sessions will reset on restart")
 	}
 
-	store, err := db.Open(cfg.DBPath)
+	store, err := db.Open(cfg.DBPath, db.Options{
+		BusyTimeout:        cfg.DBBusyTimeout,
+		ReadConns:          cfg.DBReadConns,
+		WALAutocheckpoint:  cfg.DBWALAutocheckpoint,
+		CheckpointInterval: cfg.DBCheckpointInterval,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
@@ -114,11 +177,20 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		_ = store.Close()
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
+	if fts, err := store.SetupSearch(); err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("setup search: %w", err)
+	} else if !fts {
+		logger.Info("full-text search unavailable (build without -tags sqlite_fts5); using LIKE search")
+	}
 
 	pushService, err := push.New(store.Q, logger, push.Config{
 		PublicKey:  strings.TrimSpace(cfg.VAPIDPublicKey),
 		PrivateKey: strings.TrimSpace(cfg.VAPIDPrivateKey),
 		Subject:    strings.TrimSpace(cfg.VAPIDSubject),
+
+		NotifyAccepted: cfg.PushNotifyAccepted,
+		OverdueAfter:   cfg.PushOverdueAfter,
 	})
 	if err != nil {
 		_ = store.Close()
@@ -126,12 +198,56 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 	}
 
 	a := &App{
-		cfg:    cfg,
-		store:  store,
-		log:    logger,
-		sseHub: NewSSEHub(logger),
-		push:   pushService,
+		cfg:     cfg,
+		store:   store,
+		log:     logger,
+		sseHub:  NewSSEHub(logger),
+		push:    pushService,
+		library: library.NewCache(store.Q),
+		media:   media.NewCollector(store.Q, logger, media.Config{Dir: cfg.UploadDir, Grace: cfg.MediaGCGrace}),
+		audit:   audit.New(store.Q, logger),
+	}
+	if err := a.ReloadRoles(); err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("load roles: %w", err)
 	}
+	if cfg.OIDCIssuer != "" && cfg.OIDCClientID != "" {
+		rules, err := oidc.ParseRoleMap(cfg.OIDCRoleMap)
+		if err != nil {
+			_ = store.Close()
+			return nil, fmt.Errorf("OIDC_ROLE_MAP: %w", err)
+		}
+		if cfg.OIDCProviderName == "" {
+			a.cfg.OIDCProviderName = "Single Sign-On"
+		}
+		if cfg.OIDCDefaultRole == "" {
+			a.cfg.OIDCDefaultRole = RoleUser
+		}
+		a.oidc = oidc.New(oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  strings.TrimRight(cfg.BaseURL, "/") + "/auth/oidc/callback",
+			Scopes:       strings.Fields(cfg.OIDCScopes),
+			RoleClaim:    cfg.OIDCRoleClaim,
+			RoleMap:      rules,
+		})
+	}
+	a.media.Start(cfg.MediaGCInterval)
+	a.backups = backup.New(store, logger, backup.Config{
+		Dir:            cfg.BackupDir,
+		UploadDir:      cfg.UploadDir,
+		Keep:           cfg.BackupKeep,
+		IncludeUploads: cfg.BackupIncludeUploads,
+	})
+	a.backups.Start(cfg.BackupInterval)
+	a.walkup = walkup.NewSweeper(store.Q, logger)
+	a.walkup.Start(walkup.SweepEvery)
+	a.shifts = shifts.NewSweeper(store.Q, cfg.ShiftIdleTimeout, logger)
+	a.shifts.Start(shifts.SweepEvery)
+	a.push.Start(push.OverdueEvery)
+	a.webhooks = webhooks.New(store.Q, logger)
+	a.webhooks.Start(webhooks.RunEvery)
 
 	// Templates
 	humanizeEnum := func(s string) string {
@@ -141,52 +257,21 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		}
 		return strings.ToUpper(s[:1]) + s[1:]
 	}
-	cocktailPlaceholders := []string{
-		"https://lh3.googleusercontent.com/aida-public/AB6AXuAsy80ghZEu-9aP4aXCjTfW0ap5I97HoZccF7aJRizrlUaTyc1NwhP4x0sRTGu9Cgk-FAbUdtpTt-wMwcquIM9czHZTfqJrfEsrwUNPo2SSJzySQvM7BllZmVRQHHftVuk7PsZwGFWcCMLNyjHBCFYKgSqzB9F8L8MHOYypATR1KGYJNdH7NeE2AKvqrtsB0Lj_Ic4_Fzh_t3kyd9tUvbsKULlWt-QLfZbFT5_DbFQo90uZUAthxeTQ-r3I1SR9lbIrfveDUVCung",
-		"https://lh3.googleusercontent.com/aida-public/AB6AXuAK7fiiCou66uYd8pXsa8pGu94tWmueX0U5FkOSmxgaG0lmlGOsJKNFYwsQGIowGBU9Ce08f4ZQP2jQrjfiY9OSkPmmi--NoPsVK0eX8CiuIzsONgZowMYGRccmq5we8a5_akJoRfjrpWDH_KiyDyPf6G7CvLffppyQ_PqIWkiQftfZsTSN9PSL7ulyeWVUZ1baV3qVvhOy2fgFP46JGoMk6N4RgoULoEunSMg_XwS3Lwm8Ur_Gdjy2k9C0-5hbFurM2qF0Pc_LmQ",
-		"https://lh3.googleusercontent.com/aida-public/AB6AXuCGxMJ3Vbn1ub7cB0g-yov0XHsB82EN_gm6WvfPze3mDMWt0Be4PrZfBP3srme5gMthARGjCM4g80FJUrWjIcr85GgLdcZY09dgEDhUvwXdTYqaHkQltOJ1Szx0R7RqiGFYPlAJSsOzNLvoP6uyt_kuFgOJHioX26lYbZRszRdgIzHxtfUZRbpkbBkfG7zQwH_1q1EZKOWLXUgAzXoTtO5caAljbUNTP9Ql6eQywxSLDl_D6qWWAlWwvaiWXv-_syp6JUs42kjjCw",
-		"https://lh3.googleusercontent.com/aida-public/AB6AXuDDXAZaNJFduvT28G8m_w2rzPjosnZahGKBv7HTEP9JD_NW3xYeah3jUjtAHKOO2mLgnneif6CxCrOZ7yOX7ntf488bAYBpBXHjYIrEu_T-FnBPdgPZm20MLQX5i3LC9LHVwmItRbf3B4WyyRBFjiamSmbhvHkD6_iqpxKCKDph43qZZ407BL-IPJZjs2aGv09r_XnSqOoXVJOEXXMr0Dv1Bnjoqc9UwMnPwUadY2iL5-nu6_meVxtVKP_foar17ueIcTOAphtdSA",
-		"https://lh3.googleusercontent.com/aida-public/AB6AXuDEKxJLXMTHIj5B1TZnM8dm0CUvNQ0_WOPoVJkvtUR5AiuPL2ochaB7S634ANaQ3oQk-9_Ncx5I8nHYfu6heX0jmHSLpwsRGAE6NJjUIxhjB4EeP40avzjVY6iRH5ZaS083jrWfD3AZmDmMJ5r6U9nNML6canj0Wp8aLA0MD9oI3rheZsXtsuXj6hMkYgVzX-RCE8t9B3KK_geFCfYpLyumzDHPKRd3QvvYAHlwXtCpBRnEJlrBzDJzbh43cLKvqBMRSaFUqeGyjA",
-		"https://lh3.googleusercontent.com/aida-public/AB6AXuBI5A0mRC9gQ3EmvDOinUJNDL_0vUr092g8b3UTtslU2aEIa-vgsV_cv-0hcUQY_6YMQpPdr51pYiDtKfSwldOiZFgUMeRnVcbnteq4qlR4NtmpbzC1ifYYWbNCpzOgd75iFH2hip3pBSMx8OnFNIHczw-fBHWfQsWjkfuisweEkTXTpuwGP9KpZ0S9cwqtRslCKAYHA6R1FTajaYtlX4Al_yEQEB9tdi_hGw19lUJJiX8-hSdly8rs_DUOXbZ9yuiwA9t8gSVlJg",
-		"https://lh3.googleusercontent.com/aida-public/AB6AXuDqAmy2uC-lW5Vd0QGvhFW0lHe-l4VHgkaM26SJyc4TjBo7Iiekt9U2gWQZKbAp0EqR05Of7Vaq0FDsDSv3bBwISxY-n-XaSdtJUYpgt0RJC5IyVUlM14dSqtY2TxPLR6RtpbERPkKLP6_zr46gjIlHqeYyd84nPF10PYMQhvUIgO64elcBXTqZGLK6RcpZu9tlz1OtzYoiylM30oIqG7NhW2u1KMV7K9HimeRksgPp_x59KSO4CEjgQYK9VQEWhKiEpZoyPl_kSA",
-	}
-	featurePlaceholderImage := "https://lh3.googleusercontent.com/aida-public/AB6AXuBHPvk-qLDXw1r7qLlHjrHFhbGlpEAxjPcnr9K5PK2uD1ef5Hr9TzQfd_bOWlwuRGX0nQXNvTCF8ftRVvOPO80TDNYNy6ioeW32rZaOMfpbZ1DVZLu1QgZIV_MXQgHfykZ6WcPbeeIx2YEYYOzt95W6mJnQ4EjFYOVl2FoL8ZbvxvGZZvGHFpdLee4hEd_36gPOBoQ2ZxoGj29TfDIwaQQb8Ggk9eay2K7u_lzHqrIbydVQIMByf8rgVPyl_WRlBig0AelLlaZ3Sw"
-	queuePlaceholderImage := "https://lh3.googleusercontent.com/aida-public/AB6AXuCruTFtZ0rFJZzQf9g4t0Comgto8fOdn1Mifi7jqYDg_YgLXrtwONsF69kgq6rwYvG9zcEID5hWm_SrwqLUWDwL5T1XemYYHFP4f34myTJ56-A_9cXmHe2M1vcwXRkr5ZClj9oUX04lsW6RDjCJ4UI_q_dnGoisvT0kkvgrjm45PfYFzv4BxOhyjvXj7D6UPnzeRz47zUM53vDVg_MAx9Wpv7Vk8w1MbcQX4ieDP-gmB7lzBpVGnqUHLcs4pkrlPzCwVjFWR6vlKg"
-	cocktailPlaceholder := func(idx int) string {
-		if len(cocktailPlaceholders) == 0 {
-			return featurePlaceholderImage
-		}
-		if idx < 0 {
-			idx = -idx
-		}
-		return cocktailPlaceholders[idx%len(cocktailPlaceholders)]
-	}
+	// Images resolve to an upload, bundled art or a generated placeholder; nothing is
+	// fetched from remote hosts, so the menu works offline.
+	featurePlaceholderImage := catalog.FeaturePlaceholderImage
+	queuePlaceholderImage := catalog.QueuePlaceholderImage
 	cocktailCardImage := func(name, imagePath string, idx int) string {
-		if imagePath = strings.TrimSpace(imagePath); imagePath != "" {
-			return imagePath
-		}
-		if imagePath = catalog.StitchCocktailImageFor(name); imagePath != "" {
-			return imagePath
-		}
-		return cocktailPlaceholder(idx)
+		return images.VariantPath(catalog.CocktailImageFor(name, imagePath), images.Card)
 	}
 	cocktailHeroImage := func(name, imagePath string) string {
-		if imagePath = strings.TrimSpace(imagePath); imagePath != "" {
-			return imagePath
-		}
-		if imagePath = catalog.StitchCocktailImageFor(name); imagePath != "" {
-			return imagePath
+		if strings.TrimSpace(name) == "" && strings.TrimSpace(imagePath) == "" {
+			return featurePlaceholderImage
 		}
-		return featurePlaceholderImage
+		return catalog.CocktailImageFor(name, imagePath)
 	}
 	orderCocktailImage := func(name, imagePath string) string {
-		if imagePath = strings.TrimSpace(imagePath); imagePath != "" {
-			return imagePath
-		}
-		if imagePath = catalog.StitchCocktailImageFor(name); imagePath != "" {
-			return imagePath
-		}
-		return queuePlaceholderImage
+		return images.VariantPath(catalog.CocktailImageFor(name, imagePath), images.Thumb)
 	}
 	cocktailAlcoholLabel := func(tags string) string {
 		tags = strings.ToLower(tags)
@@ -195,17 +280,17 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		}
 		return "Alcoholic"
 	}
-	productStatusLabel := func(stock *int64, available bool) string {
-		if stock != nil {
-			if *stock <= 0 {
+	productStatusLabel := func(p db.Product) string {
+		if p.StockCount != nil {
+			if *p.StockCount <= 0 {
 				return "Out of Stock"
 			}
-			if *stock <= 2 {
+			if p.IsLow() {
 				return "Low Stock"
 			}
 			return "Available"
 		}
-		if available {
+		if p.ComputedAvail {
 			return "Available"
 		}
 		return "Unavailable"
@@ -276,6 +361,17 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 			}
 			return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
 		},
+		"fmtDuration": func(d time.Duration) string {
+			switch {
+			case d <= 0:
+				return "—"
+			case d < time.Minute:
+				return fmt.Sprintf("%ds", int(d.Seconds()))
+			case d < time.Hour:
+				return fmt.Sprintf("%dm %02ds", int(d.Minutes()), int(d.Seconds())%60)
+			}
+			return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+		},
 		"splitCSV": func(s string) []string {
 			var out []string
 			for _, p := range strings.Split(s, ",") {
@@ -286,8 +382,24 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 			}
 			return out
 		},
+		"fmtBytes": func(n int64) string {
+			switch {
+			case n >= 1<<20:
+				return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+			case n >= 1<<10:
+				return fmt.Sprintf("%d KB", n>>10)
+			}
+			return fmt.Sprintf("%d B", n)
+		},
 		"hasPrefix":    strings.HasPrefix,
 		"humanizeEnum": humanizeEnum,
+		"can":          a.Can,
+		"roleCan":      a.RoleCan,
+		"role2FA":      a.RoleRequires2FA,
+		"homePath":     a.HomePath,
+		"userPerms": func(u *db.User) string {
+			return strings.Join(a.UserPermissions(u), " ")
+		},
 		"fmtQty": func(q *float64) string {
 			if q == nil {
 				return ""
@@ -312,7 +424,7 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 			return strings.ToUpper(string(first[0]) + string(last[0]))
 		},
 		"cocktailPlaceholderImage": func(idx int) string {
-			return cocktailPlaceholder(idx)
+			return featurePlaceholderImage
 		},
 		"cocktailCardImage": func(name, imagePath string, idx int) string {
 			return cocktailCardImage(name, imagePath, idx)
@@ -323,6 +435,12 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		"orderCocktailImage": func(name, imagePath string) string {
 			return orderCocktailImage(name, imagePath)
 		},
+		"imageThumb": func(imagePath string) string {
+			return images.VariantPath(strings.TrimSpace(imagePath), images.Thumb)
+		},
+		"imageSrcset": func(imagePath string) string {
+			return images.Srcset(strings.TrimSpace(imagePath))
+		},
 		"dashboardFeatureImage": func(name, imagePath string) string {
 			return cocktailHeroImage(name, imagePath)
 		},
@@ -357,10 +475,11 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 			}
 			return *stock - 1
 		},
-		"productStatusLabel": productStatusLabel,
-		"productUnit":        productUnit,
-		"productIcon":        productIcon,
-		"nextOrderStatus":    nextOrderStatus,
+		"modifierChoiceLabel": ModifierChoiceLabel,
+		"productStatusLabel":  productStatusLabel,
+		"productUnit":         productUnit,
+		"productIcon":         productIcon,
+		"nextOrderStatus":     nextOrderStatus,
 		"orderPrimaryLabel": func(status string) string {
 			switch strings.TrimSpace(strings.ToUpper(status)) {
 			case "PLACED":
@@ -512,12 +631,13 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 				return nil, err
 			}
 			_, err = store.Q.CreateUser(db.CreateUserParams{
-				Email:        NormalizeEmail(email),
-				PasswordHash: hash,
-				Role:         RoleAdmin,
-				DisplayName:  name,
-				IsActive:     true,
-				OnDuty:       false,
+				Email:         NormalizeEmail(email),
+				PasswordHash:  hash,
+				Role:          RoleAdmin,
+				DisplayName:   name,
+				IsActive:      true,
+				OnDuty:        false,
+				EmailVerified: true,
 			})
 			if err != nil {
 				_ = store.Close()
@@ -543,10 +663,97 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 	return a, nil
 }
 
+// ModifierChoiceLabel renders a guest modifier choice the way bartenders read it
+// on queue cards ("No Ice", "Extra Lime", "Sweetness: Less sweet").
+func ModifierChoiceLabel(kind, label, choice string) string {
+	if kind == db.ModifierOptionalIngredient {
+		switch choice {
+		case db.IngredientChoiceNone:
+			return "No " + label
+		case db.IngredientChoiceExtra:
+			return "Extra " + label
+		default:
+			return label
+		}
+	}
+	return label + ": " + choice
+}
+
+// RestoreBackup restores an archive and brings the restored database up to the current
+// schema and search index before clients are told to refresh.
+func (a *App) RestoreBackup(path string) (backup.Manifest, error) {
+	manifest, err := a.backups.Restore(path)
+	if err != nil {
+		return manifest, err
+	}
+	if err := db.Migrate(a.store.DB); err != nil {
+		return manifest, fmt.Errorf("migrate restored database: %w", err)
+	}
+	if err := a.ReloadRoles(); err != nil {
+		return manifest, fmt.Errorf("load restored roles: %w", err)
+	}
+	if _, err := a.store.SetupSearch(); err != nil {
+		return manifest, fmt.Errorf("rebuild search index: %w", err)
+	}
+	a.library.Invalidate()
+	a.sseHub.BroadcastInventory(SSEEvent{
+		Type: "inventory:updated",
+		Data: map[string]any{"ts": time.Now().Unix()},
+	})
+	a.EmitInventoryUpdated()
+	return manifest, nil
+}
+
+// EmitWebhook queues event for the webhook endpoints subscribed to it. A failure to queue
+// is logged, never passed on to the change that raised the event.
+func (a *App) EmitWebhook(event string, data any) {
+	if _, err := a.webhooks.Emit(event, data); err != nil {
+		a.log.Warn("webhook emit failed", "event", event, "err", err)
+	}
+}
+
+// EmitInventoryUpdated sends the webhooks what is out of stock after an inventory change.
+// The stock is only read when some endpoint wants it.
+func (a *App) EmitInventoryUpdated() {
+	endpoints, err := a.store.Q.ListWebhookEndpointsForEvent(webhooks.InventoryUpdated)
+	if err != nil || len(endpoints) == 0 {
+		return
+	}
+	products, err := a.store.Q.ListProducts("")
+	if err != nil {
+		a.log.Warn("webhook inventory read failed", "err", err)
+		return
+	}
+	cocktails, err := a.store.Q.ListCocktailsComputed(true)
+	if err != nil {
+		a.log.Warn("webhook inventory read failed", "err", err)
+		return
+	}
+	a.EmitWebhook(webhooks.InventoryUpdated, webhooks.InventoryData(products, len(cocktails)))
+}
+
 func (a *App) Close() error {
 	if a == nil {
 		return nil
 	}
+	if a.media != nil {
+		a.media.Stop()
+	}
+	if a.backups != nil {
+		a.backups.Stop()
+	}
+	if a.walkup != nil {
+		a.walkup.Stop()
+	}
+	if a.shifts != nil {
+		a.shifts.Stop()
+	}
+	if a.push != nil {
+		a.push.Stop()
+	}
+	if a.webhooks != nil {
+		a.webhooks.Stop()
+	}
 	if a.store != nil {
 		return a.store.Close()
 	}
@@ -557,6 +764,15 @@ func (a *App) Store() *db.Store              { return a.store }
 func (a *App) Templates() *template.Template { return a.templates }
 func (a *App) SSE() *SSEHub                  { return a.sseHub }
 func (a *App) Push() *push.Service           { return a.push }
+func (a *App) Library() *library.Cache       { return a.library }
+func (a *App) Media() *media.Collector       { return a.media }
+func (a *App) Backups() *backup.Service      { return a.backups }
+func (a *App) Walkup() *walkup.Sweeper       { return a.walkup }
+func (a *App) Shifts() *shifts.Sweeper       { return a.shifts }
+func (a *App) Webhooks() *webhooks.Service   { return a.webhooks }
+func (a *App) Audit() *audit.Log             { return a.audit }
+func (a *App) OIDC() *oidc.Provider          { return a.oidc }
 func (a *App) Config() Config                { return a.cfg }
+func (a *App) Logger() *slog.Logger          { return a.log }
 func (a *App) NeedsOnboarding() bool         { return a.needsOnboarding }
 func (a *App) ClearOnboarding()              { a.needsOnboarding = false }
@@ -11,11 +11,39 @@ import (
 	"strings"
 	"time"
 
+	"house-bartender-go/internal/services/oidc"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
 const sessionCookieName = "hb_session"
 const flashCookieName = "hb_flash"
+const oidcCookieName = "hb_oidc"
+const twoFactorCookieName = "hb_2fa"
+const kioskCookieName = "hb_kiosk"
+const kioskGuestCookieName = "hb_kiosk_guest"
+const walkupCookieName = "hb_walkup"
+
+// oidcFlowTTL bounds how long a login may sit at the identity provider.
+const oidcFlowTTL = 10 * time.Minute
+
+// twoFactorTTL bounds the gap between the password and the second factor.
+const twoFactorTTL = 5 * time.Minute
+
+// kioskDeviceTTL keeps a paired tablet paired until it is revoked, in practice.
+const kioskDeviceTTL = 365 * 24 * time.Hour
+
+// Every signed cookie is signed for one purpose, and verifies for that purpose only: all of
+// them share the session key, and several carry a user id, so a pending two-factor login
+// or a guest's cookie must not pass as a session.
+const (
+	purposeSession    = "session"
+	purposeFlash      = "flash"
+	purposeOIDC       = "oidc"
+	purposeTwoFactor  = "2fa"
+	purposeKioskGuest = "kiosk-guest"
+	purposeWalkup     = "walkup"
+)
 
 type sessionPayload struct {
 	UID   int64  `json:"uid"`
@@ -47,6 +75,16 @@ func CheckPassword(hash string, pw string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw)) == nil
 }
 
+// HashPIN hashes a kiosk PIN; check it with CheckPassword. Four digits are no secret to
+// an offline attacker, so the kiosk also throttles guesses.
+func HashPIN(pin string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
 // SetSessionUser sets a signed cookie with user id.
 func (a *App) SetSessionUser(w http.ResponseWriter, r *http.Request, userID int64) error {
 	now := time.Now()
@@ -55,7 +93,7 @@ func (a *App) SetSessionUser(w http.ResponseWriter, r *http.Request, userID int6
 		Exp:   now.Add(14 * 24 * time.Hour).Unix(),
 		Nonce: randomNonce(),
 	}
-	val, err := a.signJSON(pl)
+	val, err := a.signJSON(purposeSession, pl)
 	if err != nil {
 		return err
 	}
@@ -93,7 +131,7 @@ func (a *App) GetSessionUserID(r *http.Request) (int64, bool) {
 		return 0, false
 	}
 	var pl sessionPayload
-	if err := a.verifyJSON(c.Value, &pl); err != nil {
+	if err := a.verifyJSON(purposeSession, c.Value, &pl); err != nil {
 		return 0, false
 	}
 	if pl.UID <= 0 || pl.Exp <= 0 || time.Now().Unix() > pl.Exp {
@@ -102,25 +140,209 @@ func (a *App) GetSessionUserID(r *http.Request) (int64, bool) {
 	return pl.UID, true
 }
 
+type oidcFlowPayload struct {
+	Flow oidc.Flow `json:"f"`
+	Exp  int64     `json:"exp"`
+}
+
+// SetOIDCFlow keeps the state, nonce and PKCE verifier of a provider login in a signed
+// cookie scoped to the callback path.
+func (a *App) SetOIDCFlow(w http.ResponseWriter, f oidc.Flow) error {
+	val, err := a.signJSON(purposeOIDC, oidcFlowPayload{Flow: f, Exp: time.Now().Add(oidcFlowTTL).Unix()})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    val,
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcFlowTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   strings.HasPrefix(strings.ToLower(a.cfg.BaseURL), "https://"),
+	})
+	return nil
+}
+
+// TakeOIDCFlow returns the pending provider login and clears it, so a callback can only
+// be completed once.
+func (a *App) TakeOIDCFlow(w http.ResponseWriter, r *http.Request) (oidc.Flow, bool) {
+	http.SetCookie(w, &http.Cookie{Name: oidcCookieName, Value: "", Path: "/auth/oidc", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	c, err := r.Cookie(oidcCookieName)
+	if err != nil || c.Value == "" {
+		return oidc.Flow{}, false
+	}
+	var pl oidcFlowPayload
+	if err := a.verifyJSON(purposeOIDC, c.Value, &pl); err != nil || time.Now().Unix() > pl.Exp {
+		return oidc.Flow{}, false
+	}
+	return pl.Flow, true
+}
+
+type twoFactorPayload struct {
+	UID int64 `json:"uid"`
+	Exp int64 `json:"exp"`
+}
+
+// SetPendingLogin remembers a user who got past the first factor but still owes a code.
+// No session exists until the code is checked.
+func (a *App) SetPendingLogin(w http.ResponseWriter, userID int64) error {
+	val, err := a.signJSON(purposeTwoFactor, twoFactorPayload{UID: userID, Exp: time.Now().Add(twoFactorTTL).Unix()})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookieName,
+		Value:    val,
+		Path:     "/login/2fa",
+		MaxAge:   int(twoFactorTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   strings.HasPrefix(strings.ToLower(a.cfg.BaseURL), "https://"),
+	})
+	return nil
+}
+
+// PendingLogin returns the user id waiting on a second factor.
+func (a *App) PendingLogin(r *http.Request) (int64, bool) {
+	c, err := r.Cookie(twoFactorCookieName)
+	if err != nil || c.Value == "" {
+		return 0, false
+	}
+	var pl twoFactorPayload
+	if err := a.verifyJSON(purposeTwoFactor, c.Value, &pl); err != nil || pl.UID <= 0 || time.Now().Unix() > pl.Exp {
+		return 0, false
+	}
+	return pl.UID, true
+}
+
+func (a *App) ClearPendingLogin(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: twoFactorCookieName, Value: "", Path: "/login/2fa", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
+
+// SetKioskDevice marks this browser as a kiosk. The token is the credential; only its hash
+// is stored.
+func (a *App) SetKioskDevice(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     kioskCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(kioskDeviceTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   strings.HasPrefix(strings.ToLower(a.cfg.BaseURL), "https://"),
+	})
+}
+
+func (a *App) kioskDeviceToken(r *http.Request) string {
+	c, err := r.Cookie(kioskCookieName)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+type kioskGuestPayload struct {
+	UID int64 `json:"uid"`
+	KID int64 `json:"kid"`
+	Exp int64 `json:"exp"`
+}
+
+// SetKioskGuest signs a guest in on the kiosk for ttl. It only counts on the kiosk that
+// issued it, and only for ordering; see middlewareGuestScope.
+func (a *App) SetKioskGuest(w http.ResponseWriter, userID, kioskID int64, ttl time.Duration) error {
+	val, err := a.signJSON(purposeKioskGuest, kioskGuestPayload{UID: userID, KID: kioskID, Exp: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     kioskGuestCookieName,
+		Value:    val,
+		Path:     "/",
+		MaxAge:   int(ttl / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   strings.HasPrefix(strings.ToLower(a.cfg.BaseURL), "https://"),
+	})
+	return nil
+}
+
+func (a *App) ClearKioskGuest(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: kioskGuestCookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
+
+func (a *App) kioskGuest(r *http.Request) (kioskGuestPayload, bool) {
+	c, err := r.Cookie(kioskGuestCookieName)
+	if err != nil || c.Value == "" {
+		return kioskGuestPayload{}, false
+	}
+	var pl kioskGuestPayload
+	if err := a.verifyJSON(purposeKioskGuest, c.Value, &pl); err != nil || pl.UID <= 0 || time.Now().Unix() > pl.Exp {
+		return kioskGuestPayload{}, false
+	}
+	return pl, true
+}
+
+type walkupPayload struct {
+	UID int64 `json:"uid"`
+	EID int64 `json:"eid"`
+	Exp int64 `json:"exp"`
+}
+
+// SetWalkupGuest signs a walk-up guest in until their event ends. Like a kiosk guest, they
+// can only order; see middlewareGuestScope.
+func (a *App) SetWalkupGuest(w http.ResponseWriter, userID, eventID int64, until time.Time) error {
+	val, err := a.signJSON(purposeWalkup, walkupPayload{UID: userID, EID: eventID, Exp: until.Unix()})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     walkupCookieName,
+		Value:    val,
+		Path:     "/",
+		Expires:  until,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   strings.HasPrefix(strings.ToLower(a.cfg.BaseURL), "https://"),
+	})
+	return nil
+}
+
+func (a *App) ClearWalkupGuest(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: walkupCookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
+
+func (a *App) walkupGuest(r *http.Request) (walkupPayload, bool) {
+	c, err := r.Cookie(walkupCookieName)
+	if err != nil || c.Value == "" {
+		return walkupPayload{}, false
+	}
+	var pl walkupPayload
+	if err := a.verifyJSON(purposeWalkup, c.Value, &pl); err != nil || pl.UID <= 0 || time.Now().Unix() > pl.Exp {
+		return walkupPayload{}, false
+	}
+	return pl, true
+}
+
 /* ---------- signed cookie helpers (used by flash.go too) ---------- */
 
-func (a *App) signJSON(v any) (string, error) {
+func (a *App) signJSON(purpose string, v any) (string, error) {
 	b, err := json.Marshal(v)
 	if err != nil {
 		return "", err
 	}
 	payload := base64.RawURLEncoding.EncodeToString(b)
-	sig := a.sign(payload)
+	sig := a.sign(purpose, payload)
 	return payload + "." + sig, nil
 }
 
-func (a *App) verifyJSON(s string, out any) error {
+func (a *App) verifyJSON(purpose, s string, out any) error {
 	parts := strings.Split(s, ".")
 	if len(parts) != 2 {
 		return errors.New("bad format")
 	}
 	payload, sig := parts[0], parts[1]
-	if !a.verify(payload, sig) {
+	if !a.verify(purpose, payload, sig) {
 		return errors.New("bad signature")
 	}
 	raw, err := base64.RawURLEncoding.DecodeString(payload)
@@ -130,21 +352,25 @@ func (a *App) verifyJSON(s string, out any) error {
 	return json.Unmarshal(raw, out)
 }
 
-func (a *App) sign(payload string) string {
+// mac signs the purpose ahead of the payload; the NUL keeps the two apart.
+func (a *App) mac(purpose, payload string) []byte {
 	m := hmac.New(sha256.New, a.cfg.SessionHashKey)
+	_, _ = m.Write([]byte(purpose))
+	_, _ = m.Write([]byte{0})
 	_, _ = m.Write([]byte(payload))
-	return hex.EncodeToString(m.Sum(nil))
+	return m.Sum(nil)
+}
+
+func (a *App) sign(purpose, payload string) string {
+	return hex.EncodeToString(a.mac(purpose, payload))
 }
 
-func (a *App) verify(payload, sigHex string) bool {
+func (a *App) verify(purpose, payload, sigHex string) bool {
 	got, err := hex.DecodeString(sigHex)
 	if err != nil {
 		return false
 	}
-	m := hmac.New(sha256.New, a.cfg.SessionHashKey)
-	_, _ = m.Write([]byte(payload))
-	want := m.Sum(nil)
-	return hmac.Equal(got, want)
+	return hmac.Equal(got, a.mac(purpose, payload))
 }
 
 func randomNonce() string {
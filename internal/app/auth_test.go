@@ -0,0 +1,85 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAuthApp() *App {
+	return &App{cfg: Config{SessionHashKey: []byte(strings.Repeat("k", 32))}}
+}
+
+// issued returns the value of the cookie name that set wrote.
+func issued(t *testing.T, name string, set func(w http.ResponseWriter) error) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if err := set(w); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	t.Fatalf("no %s cookie set", name)
+	return ""
+}
+
+// withCookie is a request carrying value under name.
+func withCookie(name, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: name, Value: value})
+	return r
+}
+
+func TestPendingLoginIsNotASession(t *testing.T) {
+	a := newAuthApp()
+	pending := issued(t, twoFactorCookieName, func(w http.ResponseWriter) error { return a.SetPendingLogin(w, 1) })
+	if uid, ok := a.PendingLogin(withCookie(twoFactorCookieName, pending)); !ok || uid != 1 {
+		t.Fatalf("PendingLogin = %d, %v", uid, ok)
+	}
+	if uid, ok := a.GetSessionUserID(withCookie(sessionCookieName, pending)); ok {
+		t.Fatalf("a pending two-factor cookie passed as the session of user %d", uid)
+	}
+
+	session := issued(t, sessionCookieName, func(w http.ResponseWriter) error { return a.SetSessionUser(w, nil, 1) })
+	if uid, ok := a.GetSessionUserID(withCookie(sessionCookieName, session)); !ok || uid != 1 {
+		t.Fatalf("GetSessionUserID = %d, %v", uid, ok)
+	}
+	if _, ok := a.PendingLogin(withCookie(twoFactorCookieName, session)); ok {
+		t.Fatal("a session cookie passed as a pending two-factor login")
+	}
+}
+
+func TestKioskGuestIsNotASession(t *testing.T) {
+	a := newAuthApp()
+	guest := issued(t, kioskGuestCookieName, func(w http.ResponseWriter) error { return a.SetKioskGuest(w, 1, 7, time.Hour) })
+	if pl, ok := a.kioskGuest(withCookie(kioskGuestCookieName, guest)); !ok || pl.UID != 1 || pl.KID != 7 {
+		t.Fatalf("kioskGuest = %+v, %v", pl, ok)
+	}
+	if uid, ok := a.GetSessionUserID(withCookie(sessionCookieName, guest)); ok {
+		t.Fatalf("a kiosk guest cookie passed as the session of user %d", uid)
+	}
+	if _, ok := a.walkupGuest(withCookie(walkupCookieName, guest)); ok {
+		t.Fatal("a kiosk guest cookie passed as a walk-up guest")
+	}
+}
+
+func TestWalkupGuestIsNotASession(t *testing.T) {
+	a := newAuthApp()
+	guest := issued(t, walkupCookieName, func(w http.ResponseWriter) error {
+		return a.SetWalkupGuest(w, 1, 3, time.Now().Add(time.Hour))
+	})
+	if pl, ok := a.walkupGuest(withCookie(walkupCookieName, guest)); !ok || pl.UID != 1 || pl.EID != 3 {
+		t.Fatalf("walkupGuest = %+v, %v", pl, ok)
+	}
+	if uid, ok := a.GetSessionUserID(withCookie(sessionCookieName, guest)); ok {
+		t.Fatalf("a walk-up cookie passed as the session of user %d, outside its event", uid)
+	}
+	if _, ok := a.kioskGuest(withCookie(kioskGuestCookieName, guest)); ok {
+		t.Fatal("a walk-up cookie passed as a kiosk guest")
+	}
+}
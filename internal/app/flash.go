@@ -34,7 +34,7 @@ func (a *App) AddFlash(w http.ResponseWriter, r *http.Request, lvl FlashLevel, m
 	_ = a.readFlash(r, &fp)
 	fp.Items = append(fp.Items, Flash{Level: lvl, Message: msg})
 
-	val, err := a.signJSON(fp)
+	val, err := a.signJSON(purposeFlash, fp)
 	if err != nil {
 		return
 	}
@@ -69,7 +69,7 @@ func (a *App) readFlash(r *http.Request, out *flashPayload) error {
 	if err != nil || c.Value == "" {
 		return err
 	}
-	return a.verifyJSON(c.Value, out)
+	return a.verifyJSON(purposeFlash, c.Value, out)
 }
 
 func (a *App) clearFlash(w http.ResponseWriter) {
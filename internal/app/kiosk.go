@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/kiosk"
+)
+
+const ctxKeyKiosk ctxKey = "kiosk"
+
+// KioskSession is a guest signed in on a kiosk.
+type KioskSession struct {
+	Kiosk   *db.Kiosk
+	Expires time.Time
+}
+
+// Kiosk returns the paired kiosk this browser is, or nil.
+func (a *App) Kiosk(r *http.Request) *db.Kiosk {
+	token := a.kioskDeviceToken(r)
+	if token == "" {
+		return nil
+	}
+	k, _ := a.store.Q.GetKioskByToken(kiosk.Hash(token))
+	return k
+}
+
+// KioskSession returns the kiosk guest session of the request, or nil for ordinary ones.
+func (a *App) KioskSession(r *http.Request) *KioskSession {
+	ks, _ := r.Context().Value(ctxKeyKiosk).(*KioskSession)
+	return ks
+}
+
+// withKioskGuest loads the guest picked on this kiosk, if any, as the current user. The
+// guest cookie is worthless on any other browser, or once the kiosk is revoked.
+func (a *App) withKioskGuest(r *http.Request) *http.Request {
+	pl, ok := a.kioskGuest(r)
+	if !ok {
+		return r
+	}
+	k := a.Kiosk(r)
+	if k == nil || k.ID != pl.KID {
+		return r
+	}
+	u, err := a.store.Q.GetUserByID(pl.UID)
+	if err != nil || !a.KioskGuestAllowed(u) {
+		return r
+	}
+	ctx := context.WithValue(r.Context(), ctxKeyUser, u)
+	ctx = context.WithValue(ctx, ctxKeyKiosk, &KioskSession{Kiosk: k, Expires: time.Unix(pl.Exp, 0)})
+	return r.WithContext(ctx)
+}
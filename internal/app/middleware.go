@@ -4,8 +4,10 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/shifts"
 )
 
 type ctxKey string
@@ -20,14 +22,31 @@ func (a *App) middlewareLoadCurrentUser(next http.Handler) http.Handler {
 			if err == nil && u != nil && u.IsActive {
 				ctx := context.WithValue(r.Context(), ctxKeyUser, u)
 				r = r.WithContext(ctx)
+				if u.OnDuty {
+					a.TouchShift(u.ID)
+				}
+			}
+		} else {
+			r = a.withKioskGuest(r)
+			if a.CurrentUser(r) == nil {
+				r = a.withWalkupGuest(r)
 			}
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// TouchShift keeps the user's running shift from being closed as idle. Writes are spaced
+// out by shifts.TouchEvery; users off duty are left alone.
+func (a *App) TouchShift(userID int64) {
+	now := time.Now()
+	if err := a.store.Q.TouchShift(userID, now, now.Add(-shifts.TouchEvery)); err != nil && a.log != nil {
+		a.log.Warn("touch shift failed", "user", userID, "err", err)
+	}
+}
+
 // middlewareOnboardingGate enforces:
-// - If NO admin exists -> only /onboarding + static/uploads + /health are accessible (everything else redirects to /onboarding)
+// - If NO admin exists -> only /onboarding + static/uploads/media + /health are accessible (everything else redirects to /onboarding)
 // - If admin exists -> /onboarding is disabled (redirect to /login)
 func (a *App) middlewareOnboardingGate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +55,8 @@ func (a *App) middlewareOnboardingGate(next http.Handler) http.Handler {
 		// Always allow health & static assets
 		if path == "/health" ||
 			strings.HasPrefix(path, "/static/") ||
-			strings.HasPrefix(path, "/uploads/") {
+			strings.HasPrefix(path, "/uploads/") ||
+			strings.HasPrefix(path, "/media/") {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -72,6 +92,81 @@ func (a *App) middlewareOnboardingGate(next http.Handler) http.Handler {
 	})
 }
 
+// middlewareTwoFactorGate sends users whose role requires two-factor login, but who have
+// not enrolled yet, to the enrollment page. They can still log out.
+func (a *App) middlewareTwoFactorGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := a.CurrentUser(r)
+		path := r.URL.Path
+		if u == nil || !a.RoleRequires2FA(u.Role) ||
+			strings.HasPrefix(path, "/account/2fa") ||
+			path == "/logout" || path == "/health" ||
+			strings.HasPrefix(path, "/static/") ||
+			strings.HasPrefix(path, "/uploads/") ||
+			strings.HasPrefix(path, "/media/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if enrolled, err := a.store.Q.HasTOTP(u.ID); err == nil && enrolled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Header.Get("HX-Request") != "" {
+			w.Header().Set("HX-Redirect", "/account/2fa")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+	})
+}
+
+// guestPath lists what kiosk and walk-up guests may reach: the menu, ordering, their own
+// order history, notifications for it and the kiosk and walk-up pages.
+func guestPath(r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case path == "/" || path == "/orders" || path == "/sse" || path == "/health":
+		return true
+	case path == "/sw.js" || strings.HasPrefix(path, "/notifications/"):
+		return true
+	case path == "/kiosk" || strings.HasPrefix(path, "/kiosk/"),
+		path == "/walkup" || strings.HasPrefix(path, "/walkup/"):
+		return true
+	case strings.HasPrefix(path, "/cocktails/") && r.Method == http.MethodGet:
+		return true
+	case strings.HasPrefix(path, "/partials/user/"),
+		strings.HasPrefix(path, "/static/"),
+		strings.HasPrefix(path, "/uploads/"),
+		strings.HasPrefix(path, "/media/"):
+		return true
+	}
+	return false
+}
+
+// middlewareGuestScope keeps kiosk and walk-up guests to guestPath. Anything else returns a
+// kiosk to its picker, and a walk-up guest to the menu.
+func (a *App) middlewareGuestScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		to := ""
+		switch {
+		case a.KioskSession(r) != nil:
+			to = "/kiosk"
+		case a.WalkupGuest(r) != nil:
+			to = "/"
+		}
+		if to == "" || guestPath(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Header.Get("HX-Request") != "" {
+			w.Header().Set("HX-Redirect", to)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, to, http.StatusSeeOther)
+	})
+}
+
 func (a *App) middlewareNoCacheForHTMX(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("HX-Request") != "" {
@@ -98,3 +193,11 @@ func (a *App) MiddlewareLoadCurrentUser(next http.Handler) http.Handler {
 func (a *App) MiddlewareOnboardingGate(next http.Handler) http.Handler {
 	return a.middlewareOnboardingGate(next)
 }
+
+func (a *App) MiddlewareGuestScope(next http.Handler) http.Handler {
+	return a.middlewareGuestScope(next)
+}
+
+func (a *App) MiddlewareTwoFactorGate(next http.Handler) http.Handler {
+	return a.middlewareTwoFactorGate(next)
+}
@@ -2,14 +2,182 @@ package app
 
 import (
 	"net/http"
+	"sync"
+
+	"house-bartender-go/internal/db"
 )
 
+// Built-in roles. Other roles are defined by admins; see the roles table.
 const (
 	RoleAdmin     = "ADMIN"
 	RoleBartender = "BARTENDER"
 	RoleUser      = "USER"
 )
 
+// Permissions. Routes and templates check these rather than role names.
+const (
+	PermOrdersPlace    = "orders.place"
+	PermOrdersManage   = "orders.manage" // queue, duty and order notifications
+	PermInventoryEdit  = "inventory.edit"
+	PermCocktailsEdit  = "cocktails.edit"
+	PermReportsView    = "reports.view"
+	PermUsersManage    = "users.manage" // users, roles, invites, kiosks and walk-up ordering
+	PermSettingsManage = "settings.manage"
+)
+
+type Permission struct {
+	Key         string
+	Label       string
+	Description string
+}
+
+// Permissions lists every permission in the order the role editor shows them.
+var Permissions = []Permission{
+	{PermOrdersPlace, "Place orders", "Order drinks from the menu."},
+	{PermOrdersManage, "Manage orders", "Work the queue, go on duty and receive order notifications."},
+	{PermInventoryEdit, "Edit inventory", "Ingredients, stock, stocktakes, barcode scans and the makeable report."},
+	{PermCocktailsEdit, "Edit cocktails", "Create, edit, enable and delete recipes."},
+	{PermReportsView, "View reports", "Low-stock digest, the audit log and the shift log."},
+	{PermUsersManage, "Manage users", "Accounts, duty, roles, invites, kiosks and walk-up ordering."},
+	{PermSettingsManage, "Manage settings", "Bar stations, webhooks, catalog seed, media cleanup, backups and restore."},
+}
+
+func IsPermission(key string) bool {
+	for _, p := range Permissions {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// roleCache holds each role's permissions so checks don't hit the database on every
+// request. It is reloaded whenever roles change.
+type roleCache struct {
+	mu        sync.RWMutex
+	perms     map[string]map[string]bool
+	twoFactor map[string]bool
+}
+
+// ReloadRoles refreshes the permission cache from the roles table.
+func (a *App) ReloadRoles() error {
+	roles, err := a.store.Q.ListRoles()
+	if err != nil {
+		return err
+	}
+	perms := make(map[string]map[string]bool, len(roles))
+	twoFactor := map[string]bool{}
+	for _, r := range roles {
+		twoFactor[r.Name] = r.Require2FA
+		set := make(map[string]bool, len(r.Permissions))
+		for _, p := range r.Permissions {
+			set[p] = true
+		}
+		perms[r.Name] = set
+	}
+	a.roles.mu.Lock()
+	a.roles.perms = perms
+	a.roles.twoFactor = twoFactor
+	a.roles.mu.Unlock()
+	return nil
+}
+
+// RoleCan reports whether the role grants perm. ADMIN grants everything, including
+// permissions added after its row was written.
+func (a *App) RoleCan(role, perm string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	a.roles.mu.RLock()
+	defer a.roles.mu.RUnlock()
+	return a.roles.perms[role][perm]
+}
+
+// Can reports whether the user's role grants perm. A nil user can do nothing.
+func (a *App) Can(u *db.User, perm string) bool {
+	return u != nil && a.RoleCan(u.Role, perm)
+}
+
+// CanGrant reports whether the user holds every one of perms. A user manager can only hand
+// out permissions they hold themselves.
+func (a *App) CanGrant(u *db.User, perms []string) bool {
+	if u == nil {
+		return false
+	}
+	for _, p := range perms {
+		if !a.Can(u, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// CanAssignRole reports whether the user may put accounts in role, or change accounts and
+// roles that already hold it: they must hold every permission the role grants. Only an
+// admin may assign ADMIN, since it also grants permissions added later.
+func (a *App) CanAssignRole(u *db.User, role string) bool {
+	if u == nil {
+		return false
+	}
+	if role == RoleAdmin {
+		return u.Role == RoleAdmin
+	}
+	for _, p := range Permissions {
+		if a.RoleCan(role, p.Key) && !a.Can(u, p.Key) {
+			return false
+		}
+	}
+	return true
+}
+
+// RoleExists reports whether role is defined.
+func (a *App) RoleExists(role string) bool {
+	a.roles.mu.RLock()
+	defer a.roles.mu.RUnlock()
+	_, ok := a.roles.perms[role]
+	return ok
+}
+
+// RoleRequires2FA reports whether members of role must log in with a second factor.
+func (a *App) RoleRequires2FA(role string) bool {
+	a.roles.mu.RLock()
+	defer a.roles.mu.RUnlock()
+	return a.roles.twoFactor[role]
+}
+
+// UserPermissions returns the permission keys the user holds, in Permissions order.
+func (a *App) UserPermissions(u *db.User) []string {
+	var out []string
+	for _, p := range Permissions {
+		if a.Can(u, p.Key) {
+			out = append(out, p.Key)
+		}
+	}
+	return out
+}
+
+// HomePath is where the user lands after login: the first portal their permissions open,
+// or the guest menu.
+func (a *App) HomePath(u *db.User) string {
+	switch {
+	case u == nil:
+		return "/login"
+	case a.Can(u, PermUsersManage):
+		return "/admin/users"
+	case a.Can(u, PermOrdersManage):
+		return "/bartender"
+	case a.Can(u, PermInventoryEdit):
+		return "/bartender/products"
+	case a.Can(u, PermCocktailsEdit):
+		return "/bartender/cocktails"
+	case a.Can(u, PermReportsView):
+		return "/admin/low-stock"
+	case a.Can(u, PermSettingsManage):
+		return "/admin/settings"
+	}
+	return "/"
+}
+
 func (a *App) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if a.CurrentUser(r) == nil {
@@ -20,7 +188,8 @@ func (a *App) RequireAuth(next http.Handler) http.Handler {
 	})
 }
 
-func (a *App) RequireRole(role string) func(http.Handler) http.Handler {
+// RequirePermission lets the request through when the user holds any of perms.
+func (a *App) RequirePermission(perms ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			u := a.CurrentUser(r)
@@ -28,32 +197,27 @@ func (a *App) RequireRole(role string) func(http.Handler) http.Handler {
 				http.Redirect(w, r, "/login", http.StatusSeeOther)
 				return
 			}
-			if u.Role != role {
-				http.Error(w, "forbidden", http.StatusForbidden)
-				return
+			for _, p := range perms {
+				if a.Can(u, p) {
+					next.ServeHTTP(w, r)
+					return
+				}
 			}
-			next.ServeHTTP(w, r)
+			http.Error(w, "forbidden", http.StatusForbidden)
 		})
 	}
 }
 
-func (a *App) RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
-	set := map[string]bool{}
-	for _, r := range roles {
-		set[r] = true
-	}
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			u := a.CurrentUser(r)
-			if u == nil {
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
-				return
-			}
-			if !set[u.Role] {
-				http.Error(w, "forbidden", http.StatusForbidden)
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	}
+// GuestRoleAllowed reports whether members of role may hold an ordering-only guest session,
+// on a kiosk or walking up: the role orders, opens no staff portal, and doesn't require
+// two-factor login.
+func (a *App) GuestRoleAllowed(role string) bool {
+	u := &db.User{Role: role, IsActive: true}
+	return a.Can(u, PermOrdersPlace) && a.HomePath(u) == "/" && !a.RoleRequires2FA(role)
+}
+
+// KioskGuestAllowed reports whether a kiosk may switch to the user: an active account in a
+// guest role.
+func (a *App) KioskGuestAllowed(u *db.User) bool {
+	return u != nil && u.IsActive && a.GuestRoleAllowed(u.Role)
 }
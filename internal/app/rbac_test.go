@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"house-bartender-go/internal/db"
+)
+
+func newRBACApp(t *testing.T) *App {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"), db.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := db.Migrate(store.DB); err != nil {
+		t.Fatal(err)
+	}
+	a := &App{store: store}
+	if err := a.ReloadRoles(); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestBuiltinRolePermissions(t *testing.T) {
+	a := newRBACApp(t)
+	want := map[string][]string{
+		RoleUser:      {PermOrdersPlace},
+		RoleBartender: {PermOrdersPlace, PermOrdersManage, PermInventoryEdit, PermCocktailsEdit},
+	}
+	for _, p := range Permissions {
+		if !a.RoleCan(RoleAdmin, p.Key) {
+			t.Errorf("ADMIN lacks %s", p.Key)
+		}
+		for role, perms := range want {
+			if got := a.RoleCan(role, p.Key); got != contains(perms, p.Key) {
+				t.Errorf("RoleCan(%s, %s) = %v", role, p.Key, got)
+			}
+		}
+	}
+	if !a.RoleCan(RoleAdmin, "permission.added.later") {
+		t.Error("ADMIN should grant permissions its row doesn't list")
+	}
+	if a.RoleCan("NOBODY", PermOrdersPlace) || a.Can(nil, PermOrdersPlace) {
+		t.Error("unknown roles and nil users should grant nothing")
+	}
+}
+
+func TestRoleCacheFollowsReload(t *testing.T) {
+	a := newRBACApp(t)
+	q := a.store.Q
+	if err := q.CreateRole("HOST", "", []string{PermOrdersPlace}); err != nil {
+		t.Fatal(err)
+	}
+	if a.RoleExists("HOST") {
+		t.Fatal("the cache changed before ReloadRoles")
+	}
+	if err := a.ReloadRoles(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.RoleExists("HOST") || a.RoleCan("HOST", PermReportsView) {
+		t.Fatal("HOST should exist with orders.place only")
+	}
+	if err := q.UpdateRole("HOST", "", []string{PermOrdersPlace, PermReportsView}, PermOrdersManage); err != nil {
+		t.Fatal(err)
+	}
+	_ = a.ReloadRoles()
+	if !a.RoleCan("HOST", PermReportsView) {
+		t.Fatal("reload missed the new permission")
+	}
+	if err := q.SetRoleRequire2FA("HOST", true); err != nil {
+		t.Fatal(err)
+	}
+	_ = a.ReloadRoles()
+	if !a.RoleRequires2FA("HOST") {
+		t.Fatal("reload missed the two-factor requirement")
+	}
+}
+
+func TestCanAssignRole(t *testing.T) {
+	a := newRBACApp(t)
+	_ = a.store.Q.CreateRole("MANAGER", "", []string{PermOrdersPlace, PermOrdersManage, PermUsersManage})
+	_ = a.ReloadRoles()
+	manager := &db.User{Role: "MANAGER", IsActive: true}
+	admin := &db.User{Role: RoleAdmin, IsActive: true}
+
+	for role, want := range map[string]bool{
+		RoleUser:      true,
+		"MANAGER":     true,
+		RoleBartender: false, // inventory.edit and cocktails.edit
+		RoleAdmin:     false,
+	} {
+		if got := a.CanAssignRole(manager, role); got != want {
+			t.Errorf("manager CanAssignRole(%s) = %v, want %v", role, got, want)
+		}
+		if !a.CanAssignRole(admin, role) {
+			t.Errorf("admin cannot assign %s", role)
+		}
+	}
+	if a.CanAssignRole(nil, RoleUser) {
+		t.Error("nil user assigned a role")
+	}
+	if !a.CanGrant(manager, []string{PermOrdersPlace, PermUsersManage}) || a.CanGrant(manager, []string{PermSettingsManage}) {
+		t.Error("CanGrant should follow the manager's own permissions")
+	}
+}
+
+func TestGuestRoleAllowed(t *testing.T) {
+	a := newRBACApp(t)
+	_ = a.store.Q.CreateRole("VIP", "", []string{PermOrdersPlace})
+	_ = a.store.Q.CreateRole("VIP_2FA", "", []string{PermOrdersPlace})
+	_ = a.store.Q.SetRoleRequire2FA("VIP_2FA", true)
+	_ = a.store.Q.CreateRole("VIEWER", "", []string{PermReportsView})
+	_ = a.ReloadRoles()
+
+	for role, want := range map[string]bool{
+		RoleUser:      true,
+		"VIP":         true,
+		"VIP_2FA":     false,
+		"VIEWER":      false,
+		RoleBartender: false,
+		RoleAdmin:     false,
+	} {
+		if got := a.GuestRoleAllowed(role); got != want {
+			t.Errorf("GuestRoleAllowed(%s) = %v, want %v", role, got, want)
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	a := newRBACApp(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	serve := func(u *db.User, perms ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if u != nil {
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeyUser, u))
+		}
+		w := httptest.NewRecorder()
+		a.RequirePermission(perms...)(ok).ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve(nil, PermOrdersPlace); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+		t.Fatalf("signed out: got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	bartender := &db.User{Role: RoleBartender, IsActive: true}
+	for _, p := range Permissions {
+		w := serve(bartender, p.Key)
+		if want := a.Can(bartender, p.Key); (w.Code == http.StatusNoContent) != want {
+			t.Errorf("bartender on %s: got %d", p.Key, w.Code)
+		}
+	}
+	if w := serve(bartender, PermSettingsManage, PermInventoryEdit); w.Code != http.StatusNoContent {
+		t.Errorf("any one permission should do: got %d", w.Code)
+	}
+	if w := serve(bartender); w.Code != http.StatusForbidden {
+		t.Errorf("no permissions listed should forbid: got %d", w.Code)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -77,15 +77,21 @@ func (h *SSEHub) Broadcast(topic string, ev SSEEvent) {
 /* ---- topic helpers ---- */
 
 func TopicUser(userID int64) string { return "user:" + itoa64(userID) }
-func TopicRole(role string) string  { return "role:" + role }
 func TopicOrdersGlobal() string     { return "orders:global" }
+func TopicStation(id int64) string  { return "station:" + itoa64(id) }
 func TopicInventory() string        { return "inventory:global" }
 
+func TopicPermission(perm string) string { return "perm:" + perm }
+
 func (h *SSEHub) BroadcastUser(userID int64, ev SSEEvent)      { h.Broadcast(TopicUser(userID), ev) }
-func (h *SSEHub) BroadcastRole(role string, ev SSEEvent)       { h.Broadcast(TopicRole(role), ev) }
 func (h *SSEHub) BroadcastOrders(ev SSEEvent)                  { h.Broadcast(TopicOrdersGlobal(), ev) }
+func (h *SSEHub) BroadcastStation(id int64, ev SSEEvent)       { h.Broadcast(TopicStation(id), ev) }
 func (h *SSEHub) BroadcastInventory(ev SSEEvent)               { h.Broadcast(TopicInventory(), ev) }
 
+func (h *SSEHub) BroadcastPermission(perm string, ev SSEEvent) {
+	h.Broadcast(TopicPermission(perm), ev)
+}
+
 // small helper to avoid importing handlers for itoa64
 func itoa64(v int64) string {
 	if v == 0 {
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/walkup"
+)
+
+const ctxKeyWalkup ctxKey = "walkup"
+
+// WalkupGuest returns the walk-up guest record of the request, or nil for everyone else.
+func (a *App) WalkupGuest(r *http.Request) *db.WalkupGuest {
+	g, _ := r.Context().Value(ctxKeyWalkup).(*db.WalkupGuest)
+	return g
+}
+
+// withWalkupGuest loads the name-only guest behind the walk-up cookie, if any, as the
+// current user. It stops working as soon as their event ends or is closed, even before the
+// sweeper switches the account off.
+func (a *App) withWalkupGuest(r *http.Request) *http.Request {
+	pl, ok := a.walkupGuest(r)
+	if !ok {
+		return r
+	}
+	g, err := a.store.Q.GetWalkupGuest(pl.UID)
+	if err != nil || g == nil || g.Event.ID != pl.EID || walkup.Status(g.Event, time.Now()) != walkup.StatusOpen {
+		return r
+	}
+	u, err := a.store.Q.GetUserByID(pl.UID)
+	if err != nil || u == nil || !u.IsActive || !a.GuestRoleAllowed(u.Role) {
+		return r
+	}
+	ctx := context.WithValue(r.Context(), ctxKeyUser, u)
+	ctx = context.WithValue(ctx, ctxKeyWalkup, g)
+	return r.WithContext(ctx)
+}
@@ -0,0 +1,76 @@
+package catalog
+
+import (
+	"embed"
+	"io/fs"
+	"strings"
+	"unicode"
+)
+
+// Cocktail imagery ships inside the binary so the menu renders without internet access.
+//
+//go:embed art
+var artFiles embed.FS
+
+const (
+	// ArtPrefix is where the embedded art is served.
+	ArtPrefix = "/media/art"
+	// GeneratedPrefix is where generated placeholders are served, one per cocktail slug.
+	GeneratedPrefix = "/media/generated"
+)
+
+// ArtFS exposes the embedded art rooted at art/.
+func ArtFS() fs.FS {
+	sub, err := fs.Sub(artFiles, "art")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+func artURL(name string) string {
+	return ArtPrefix + "/" + name
+}
+
+var (
+	FeaturePlaceholderImage = artURL("placeholders/feature.svg")
+	QueuePlaceholderImage   = artURL("placeholders/queue.svg")
+)
+
+// Slug turns a cocktail name into a URL-safe file stem: "Gin & Tonic" -> "gin-tonic".
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(normalizeCocktailName(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if b.Len() == 0 {
+		return "cocktail"
+	}
+	return b.String()
+}
+
+// GeneratedImageFor is the placeholder URL for a cocktail without an upload or bundled art.
+func GeneratedImageFor(name string) string {
+	return GeneratedPrefix + "/" + Slug(name) + ".svg"
+}
+
+// CocktailImageFor resolves the image to show for a cocktail: the uploaded image first, then
+// bundled art for catalog cocktails, then a generated placeholder. All three are local.
+func CocktailImageFor(name, uploaded string) string {
+	if uploaded = strings.TrimSpace(uploaded); uploaded != "" {
+		return uploaded
+	}
+	if img := StitchCocktailImageFor(name); img != "" {
+		return img
+	}
+	return GeneratedImageFor(name)
+}
@@ -0,0 +1,60 @@
+package catalog
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestSlug(t *testing.T) {
+	for name, want := range map[string]string{
+		"Gin & Tonic":      "gin-tonic",
+		"Gin &amp; Tonic":  "gin-tonic",
+		"  Piña   Colada ": "piña-colada",
+		"Beer (Lager)":     "beer-lager",
+		"#1 Sour!":         "1-sour",
+		"MOJITO":           "mojito",
+		"!!!":              "cocktail",
+		"":                 "cocktail",
+	} {
+		if got := Slug(name); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBundledArtExists(t *testing.T) {
+	art := ArtFS()
+	for key, v := range stitchCocktailVisuals {
+		if key != normalizeCocktailName(v.Name) {
+			t.Errorf("%q is filed under %q", v.Name, key)
+		}
+		if v.Asset != Slug(v.Name) {
+			t.Errorf("%s: asset %q, want its slug %q", v.Name, v.Asset, Slug(v.Name))
+		}
+		if _, err := fs.Stat(art, "cocktails/"+v.Asset+".svg"); err != nil {
+			t.Errorf("%s: %v", v.Name, err)
+		}
+	}
+	for _, img := range []string{FeaturePlaceholderImage, QueuePlaceholderImage} {
+		if _, err := fs.Stat(art, strings.TrimPrefix(img, ArtPrefix+"/")); err != nil {
+			t.Errorf("%s: %v", img, err)
+		}
+	}
+}
+
+func TestCocktailImageForOrder(t *testing.T) {
+	const upload = "/uploads/negroni-hero.webp"
+	for _, tc := range []struct {
+		name, uploaded, want string
+	}{
+		{"Negroni", upload, upload},
+		{"House Special", upload, upload},
+		{"negroni ", "  ", ArtPrefix + "/cocktails/negroni.svg"},
+		{"House Special", "", GeneratedPrefix + "/house-special.svg"},
+	} {
+		if got := CocktailImageFor(tc.name, tc.uploaded); got != tc.want {
+			t.Errorf("CocktailImageFor(%q, %q) = %q, want %q", tc.name, tc.uploaded, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package catalog
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"strings"
+)
+
+// PlaceholderIngredient is one layer of a generated placeholder.
+type PlaceholderIngredient struct {
+	Name   string
+	Amount float64 // relative size of the layer; 0 counts as 1
+}
+
+// ingredientColors maps name fragments to layer colours. The first match wins, so more
+// specific fragments come first.
+var ingredientColors = []struct {
+	fragment string
+	color    string
+}{
+	{"ginger beer", "#d9b25f"},
+	{"ginger ale", "#e3c27a"},
+	{"tonic", "#e6f0ee"},
+	{"soda", "#edf3f4"},
+	{"cola", "#3a1c10"},
+	{"espresso", "#2b160d"},
+	{"coffee", "#3b2010"},
+	{"cranberry", "#9e1b32"},
+	{"tomato", "#a5221c"},
+	{"grapefruit", "#f3a3a0"},
+	{"orange", "#f59a2a"},
+	{"pineapple", "#f2d35b"},
+	{"coconut", "#f4ecd0"},
+	{"cream", "#f3ead8"},
+	{"lime", "#9cc65a"},
+	{"lemon", "#efd64a"},
+	{"mint", "#5aae5e"},
+	{"campari", "#b3201f"},
+	{"aperol", "#f26a1b"},
+	{"vermouth", "#7a2a14"},
+	{"prosecco", "#f1dc8c"},
+	{"champagne", "#f1dc8c"},
+	{"red wine", "#5e0f1c"},
+	{"white wine", "#efe3a6"},
+	{"wine", "#8a1c2c"},
+	{"beer", "#e8b422"},
+	{"lager", "#e8b422"},
+	{"dark rum", "#6b3414"},
+	{"rum", "#c8a46a"},
+	{"whisk", "#b0681f"},
+	{"bourbon", "#a8561c"},
+	{"brandy", "#8c4a1f"},
+	{"cognac", "#8c4a1f"},
+	{"tequila", "#eee5b8"},
+	{"mezcal", "#e0d5a0"},
+	{"gin", "#dde9f0"},
+	{"vodka", "#edf1f3"},
+	{"syrup", "#e8c98a"},
+	{"sugar", "#f6efdc"},
+	{"honey", "#e0a53a"},
+	{"bitters", "#6e1a10"},
+	{"grenadine", "#c0182e"},
+	{"water", "#e4f1f6"},
+	{"ice", "#eef6f8"},
+}
+
+// ingredientColor picks a colour for an ingredient, falling back to a stable hue derived
+// from its name.
+func ingredientColor(name string) string {
+	n := strings.ToLower(name)
+	for _, c := range ingredientColors {
+		if strings.Contains(n, c.fragment) {
+			return c.color
+		}
+	}
+	return hashColor(n, 55, 60)
+}
+
+func hashColor(s string, saturation, lightness int) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return fmt.Sprintf("hsl(%d, %d%%, %d%%)", h.Sum32()%360, saturation, lightness)
+}
+
+// PlaceholderSVG draws a glass filled with up to four layers, one per dominant ingredient
+// (largest first), over a background tinted from the cocktail name. Without ingredients the
+// glass takes a single colour derived from the name.
+func PlaceholderSVG(name string, ingredients []PlaceholderIngredient) []byte {
+	if len(ingredients) > 4 {
+		ingredients = ingredients[:4]
+	}
+	if len(ingredients) == 0 {
+		ingredients = []PlaceholderIngredient{{Name: name}}
+	}
+	total := 0.0
+	for _, ing := range ingredients {
+		total += layerAmount(ing)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 800 800" width="800" height="800">`)
+	fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(strings.TrimSpace(name)))
+	b.WriteString(`<defs><clipPath id="bowl"><path d="M250 300 Q250 470 400 470 Q550 470 550 300 Z"/></clipPath></defs>`)
+	fmt.Fprintf(&b, `<rect width="800" height="800" fill="%s"/>`, hashColor(strings.ToLower(name), 35, 90))
+	b.WriteString(`<ellipse cx="400" cy="650" rx="200" ry="20" fill="#000000" fill-opacity=".08"/>`)
+
+	// Layers stack from the bottom of the bowl (y=470) up to the rim (y=300), largest at
+	// the bottom.
+	b.WriteString(`<g clip-path="url(#bowl)">`)
+	y := 470.0
+	for _, ing := range ingredients {
+		h := 170 * layerAmount(ing) / total
+		y -= h
+		fmt.Fprintf(&b, `<rect x="240" y="%.1f" width="320" height="%.1f" fill="%s"/>`, y, h+0.5, ingredientColor(ing.Name))
+	}
+	b.WriteString(`</g>`)
+	b.WriteString(`<path d="M250 300 Q250 470 400 470 Q550 470 550 300 Z M400 470 L400 620 M320 630 Q400 605 480 630" fill="none" stroke="#2f2a26" stroke-width="6" stroke-linejoin="round" stroke-linecap="round"/>`)
+	fmt.Fprintf(&b, `<text x="400" y="730" text-anchor="middle" font-family="Georgia, serif" font-size="44" fill="#2f2a26" fill-opacity=".55">%s</text>`, html.EscapeString(initials(name)))
+	b.WriteString(`</svg>`)
+	return []byte(b.String())
+}
+
+func layerAmount(ing PlaceholderIngredient) float64 {
+	if ing.Amount <= 0 {
+		return 1
+	}
+	return ing.Amount
+}
+
+func initials(name string) string {
+	var out []rune
+	for _, w := range strings.Fields(name) {
+		for _, r := range w {
+			if r != '&' {
+				out = append(out, r)
+			}
+			break
+		}
+		if len(out) == 3 {
+			break
+		}
+	}
+	return strings.ToUpper(string(out))
+}
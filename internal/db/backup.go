@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// BackupTo writes a consistent copy of the database to path with SQLite's online backup
+// API. The copy is read from the read pool, so writers keep working while it runs.
+func (s *Store) BackupTo(path string) error {
+	dst, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	return copyDatabase(dst, s.ReadDB)
+}
+
+// RestoreFrom replaces the live database with the one at path after checking its
+// integrity. The copy goes through the writer connection, so writes queue behind it and
+// readers see the restored data once it finishes. Callers must migrate afterwards.
+func (s *Store) RestoreFrom(path string) error {
+	if err := CheckIntegrity(path); err != nil {
+		return err
+	}
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return copyDatabase(s.DB, src)
+}
+
+// CheckIntegrity opens the database at path read-only and verifies that SQLite considers it
+// intact and that it looks like a House Bartender database.
+func CheckIntegrity(path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check: %s", result)
+	}
+	for _, table := range []string{"users", "products", "cocktails", "orders"} {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?`, table).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("not a House Bartender database: missing table %q", table)
+		}
+	}
+	return nil
+}
+
+func copyDatabase(dst, src *sql.DB) error {
+	ctx := context.Background()
+	dconn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dconn.Close()
+	sconn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sconn.Close()
+
+	return dconn.Raw(func(d any) error {
+		dc, ok := d.(*sqlite3.SQLiteConn)
+		if !ok {
+			return errors.New("backup: destination is not a sqlite connection")
+		}
+		return sconn.Raw(func(s any) error {
+			sc, ok := s.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("backup: source is not a sqlite connection")
+			}
+			bk, err := dc.Backup("main", sc, "main")
+			if err != nil {
+				return err
+			}
+			// Step(-1) copies every page under a single read lock, so the copy is a
+			// consistent snapshot. It reports not-done when the destination was busy.
+			for attempt := 0; ; attempt++ {
+				done, err := bk.Step(-1)
+				if err != nil {
+					_ = bk.Close()
+					return err
+				}
+				if done {
+					break
+				}
+				if attempt == 50 {
+					_ = bk.Close()
+					return errors.New("backup: database stayed busy")
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			return bk.Finish()
+		})
+	})
+}
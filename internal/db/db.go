@@ -3,18 +3,75 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Options tunes the connection pools; zero values take the defaults.
+type Options struct {
+	BusyTimeout        time.Duration // how long a connection waits on a lock; default 5s
+	ReadConns          int           // size of the read-only pool; default 4
+	WALAutocheckpoint  int           // pages of WAL before SQLite checkpoints on commit; default 1000
+	CheckpointInterval time.Duration // periodic TRUNCATE checkpoint; 0 disables
+}
+
+func (o Options) withDefaults() Options {
+	if o.BusyTimeout <= 0 {
+		o.BusyTimeout = 5 * time.Second
+	}
+	if o.ReadConns <= 0 {
+		o.ReadConns = 4
+	}
+	if o.WALAutocheckpoint <= 0 {
+		o.WALAutocheckpoint = 1000
+	}
+	return o
+}
+
+// Store runs SQLite in WAL mode: DB is the single writer connection, used for every
+// mutation and transaction, while ReadDB is a read-only pool so reads never queue behind
+// writes or each other.
 type Store struct {
-	DB *sql.DB
-	Q  *Queries
+	DB     *sql.DB
+	ReadDB *sql.DB
+	Q      *Queries
+
+	opts Options
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	mu             sync.Mutex
+	lastCheckpoint CheckpointResult
+}
+
+// CheckpointResult is what the last periodic checkpoint reported.
+type CheckpointResult struct {
+	At           time.Time
+	Busy         bool
+	LogPages     int64
+	Checkpointed int64
+	Err          string
 }
 
-func Open(path string) (*Store, error) {
-	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path)
-	db, err := sql.Open("sqlite3", dsn)
+// PoolStats describes both pools for the admin settings page.
+type PoolStats struct {
+	JournalMode    string
+	BusyTimeout    time.Duration
+	Writer         sql.DBStats
+	Reader         sql.DBStats
+	LastCheckpoint CheckpointResult
+}
+
+func Open(path string, opts Options) (*Store, error) {
+	opts = opts.withDefaults()
+	busy := opts.BusyTimeout.Milliseconds()
+
+	// _txlock=immediate takes the write lock at BEGIN, so concurrent writers wait on the
+	// busy timeout instead of failing when a read transaction tries to upgrade.
+	writeDSN := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=%d&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate", path, busy)
+	db, err := sql.Open("sqlite3", writeDSN)
 	if err != nil {
 		return nil, err
 	}
@@ -27,9 +84,95 @@ func Open(path string) (*Store, error) {
 
 	// Ensure FK is on
 	_, _ = db.Exec(`PRAGMA foreign_keys = ON;`)
+	if _, err := db.Exec(fmt.Sprintf(`PRAGMA wal_autocheckpoint = %d;`, opts.WALAutocheckpoint)); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	readDSN := fmt.Sprintf("file:%s?mode=ro&_foreign_keys=on&_busy_timeout=%d&_query_only=true", path, busy)
+	rdb, err := sql.Open("sqlite3", readDSN)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	rdb.SetMaxOpenConns(opts.ReadConns)
+	rdb.SetMaxIdleConns(opts.ReadConns)
+	if err := rdb.Ping(); err != nil {
+		_ = rdb.Close()
+		_ = db.Close()
+		return nil, err
+	}
 
-	return &Store{DB: db, Q: &Queries{db: db}}, nil
+	s := &Store{
+		DB:     db,
+		ReadDB: rdb,
+		Q:      &Queries{db: db, rdb: rdb},
+		opts:   opts,
+		stop:   make(chan struct{}),
+	}
+	if opts.CheckpointInterval > 0 {
+		s.wg.Add(1)
+		go s.checkpointLoop(opts.CheckpointInterval)
+	}
+	return s, nil
 }
 
-func (s *Store) Close() error { return s.DB.Close() }
-func (s *Store) Ping() error  { return s.DB.Ping() }
+func (s *Store) checkpointLoop(every time.Duration) {
+	defer s.wg.Done()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			s.Checkpoint()
+		}
+	}
+}
+
+// Checkpoint copies the WAL back into the database file and truncates it. A busy result
+// means readers were still using old frames; the next checkpoint picks them up.
+func (s *Store) Checkpoint() CheckpointResult {
+	res := CheckpointResult{At: time.Now()}
+	var busy int
+	if err := s.DB.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &res.LogPages, &res.Checkpointed); err != nil {
+		res.Err = err.Error()
+	}
+	res.Busy = busy != 0
+
+	s.mu.Lock()
+	s.lastCheckpoint = res
+	s.mu.Unlock()
+	return res
+}
+
+func (s *Store) Stats() PoolStats {
+	st := PoolStats{
+		BusyTimeout: s.opts.BusyTimeout,
+		Writer:      s.DB.Stats(),
+		Reader:      s.ReadDB.Stats(),
+	}
+	_ = s.ReadDB.QueryRow(`PRAGMA journal_mode`).Scan(&st.JournalMode)
+	s.mu.Lock()
+	st.LastCheckpoint = s.lastCheckpoint
+	s.mu.Unlock()
+	return st
+}
+
+func (s *Store) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	rerr := s.ReadDB.Close()
+	if err := s.DB.Close(); err != nil {
+		return err
+	}
+	return rerr
+}
+
+func (s *Store) Ping() error {
+	if err := s.DB.Ping(); err != nil {
+		return err
+	}
+	return s.ReadDB.Ping()
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenUsesWAL(t *testing.T) {
+	s := newTestStore(t)
+	var mode string
+	if err := s.DB.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("writer journal_mode = %q, %v", mode, err)
+	}
+	if got := s.Stats().JournalMode; got != "wal" {
+		t.Fatalf("Stats().JournalMode = %q", got)
+	}
+	if got := count(t, s, `PRAGMA foreign_keys`); got != 1 {
+		t.Fatal("writer has foreign keys off")
+	}
+	var fk int
+	if err := s.ReadDB.QueryRow(`PRAGMA foreign_keys`).Scan(&fk); err != nil || fk != 1 {
+		t.Fatalf("reader foreign_keys = %d, %v", fk, err)
+	}
+}
+
+func TestReadPoolIsReadOnly(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Q.CreateStation("Patio"); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.Q.ListStations()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("read pool sees %d stations after a write, %v", len(list), err)
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO stations(name,is_default) VALUES('Roof',0)`,
+		`UPDATE stations SET name='x'`,
+		`CREATE TABLE scratch(id INTEGER)`,
+	} {
+		if _, err := s.ReadDB.Exec(stmt); err == nil {
+			t.Errorf("read pool ran %q", stmt)
+		}
+	}
+	if got := count(t, s, `SELECT COUNT(*) FROM stations WHERE name IN ('Roof','x')`); got != 0 {
+		t.Fatalf("read pool wrote %d rows", got)
+	}
+}
+
+func TestReadsDontWaitForTheWriter(t *testing.T) {
+	s := newTestStore(t)
+	tx, err := s.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`INSERT INTO stations(name,is_default) VALUES('Roof',0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan int)
+	go func() {
+		list, _ := s.Q.ListStations()
+		done <- len(list)
+	}()
+	select {
+	case n := <-done:
+		if n != 1 {
+			t.Fatalf("reader saw %d stations before the write committed", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a read waited on an open write transaction")
+	}
+}
+
+func TestOptionsAndStats(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{ReadConns: 2, BusyTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	st := s.Stats()
+	if st.Writer.MaxOpenConnections != 1 || st.Reader.MaxOpenConnections != 2 || st.BusyTimeout != time.Second {
+		t.Fatalf("Stats = %+v", st)
+	}
+	if !st.LastCheckpoint.At.IsZero() {
+		t.Fatal("checkpoint reported without the loop running")
+	}
+	var auto int
+	if err := s.DB.QueryRow(`PRAGMA wal_autocheckpoint`).Scan(&auto); err != nil || auto != 1000 {
+		t.Fatalf("wal_autocheckpoint = %d, %v", auto, err)
+	}
+	if d := (Options{}).withDefaults(); d.BusyTimeout != 5*time.Second || d.ReadConns != 4 || d.CheckpointInterval != 0 {
+		t.Fatalf("defaults = %+v", d)
+	}
+}
+
+func TestCheckpointLoopTruncatesTheWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := Open(path, Options{CheckpointInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(s.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cp := s.Stats().LastCheckpoint
+		wal, err := os.Stat(path + "-wal")
+		if !cp.At.IsZero() && cp.Err == "" && err == nil && wal.Size() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no truncating checkpoint: last %+v, wal %v", cp, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Close stops the loop
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	at := s.Stats().LastCheckpoint.At
+	time.Sleep(50 * time.Millisecond)
+	if s.Stats().LastCheckpoint.At != at {
+		t.Fatal("checkpoints continued after Close")
+	}
+}
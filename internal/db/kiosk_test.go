@@ -0,0 +1,61 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKioskPINAttemptsStopAtTheLimit(t *testing.T) {
+	s := newTestStore(t)
+	q := s.Q
+	uid, err := q.CreateUser(CreateUserParams{Email: "guest@example.com", PasswordHash: "h", Role: "USER", DisplayName: "Guest", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SetKioskPIN(uid, "hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	// twenty guesses at once get five tries between them
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := q.TakeKioskPINAttempt(uid, 5, time.Hour)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 5 {
+		t.Fatalf("%d tries allowed, want 5", allowed)
+	}
+
+	// the lockout runs from the last wrong try
+	if _, err := s.DB.Exec(`UPDATE kiosk_pins SET last_failure_at=? WHERE user_id=?`, time.Now().Add(-2*time.Hour).Unix(), uid); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := q.TakeKioskPINAttempt(uid, 5, time.Hour); !ok {
+		t.Fatal("still locked after the lockout passed")
+	}
+	if ok, _ := q.TakeKioskPINAttempt(uid, 5, time.Hour); ok {
+		t.Fatal("a wrong try after the lockout should lock again")
+	}
+
+	if err := q.ClearKioskPINFailures(uid); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := q.TakeKioskPINAttempt(uid, 5, time.Hour); !ok {
+		t.Fatal("a right PIN should clear the count")
+	}
+}
@@ -0,0 +1,43 @@
+package db
+
+import "testing"
+
+func TestMediaRecordsWhenTheLastCocktailLetsGo(t *testing.T) {
+	s := newTestStore(t)
+	q := s.Q
+	for _, stmt := range []string{
+		`INSERT INTO media(path) VALUES ('/uploads/a.png'), ('/uploads/b.png')`,
+		`INSERT INTO cocktails(id, name, image_path) VALUES (1, 'Martini', '/uploads/a.png'), (2, 'Gibson', '/uploads/a.png'), (3, 'Negroni', '/uploads/b.png')`,
+	} {
+		if _, err := s.DB.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unreferenced := func(path string) bool {
+		t.Helper()
+		m, err := q.GetMediaByPath(path)
+		if err != nil || m == nil {
+			t.Fatalf("GetMediaByPath(%s) = %v, %v", path, m, err)
+		}
+		return !m.UnreferencedAt.IsZero()
+	}
+
+	if _, err := s.DB.Exec(`UPDATE cocktails SET image_path='' WHERE id=1`); err != nil {
+		t.Fatal(err)
+	}
+	if unreferenced("/uploads/a.png") {
+		t.Fatal("marked unused while the Gibson still uses it")
+	}
+	if _, err := s.DB.Exec(`UPDATE cocktails SET image_path='/uploads/b.png' WHERE id=2`); err != nil {
+		t.Fatal(err)
+	}
+	if !unreferenced("/uploads/a.png") || unreferenced("/uploads/b.png") {
+		t.Fatal("a is no longer used and b still is")
+	}
+	if _, err := s.DB.Exec(`DELETE FROM cocktails WHERE id IN (2, 3)`); err != nil {
+		t.Fatal(err)
+	}
+	if !unreferenced("/uploads/b.png") {
+		t.Fatal("deleting the last cocktail using b should mark it")
+	}
+}
@@ -1,22 +1,166 @@
 package db
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
 
-func Migrate(db *sql.DB) error {
-	stmts := []string{
-		`PRAGMA foreign_keys = ON;`,
-
-		`CREATE TABLE IF NOT EXISTS users (
+// usersTable is the users DDL with the table name left open, so the role migration can
+// build the replacement table from the same definition.
+const usersTable = `CREATE TABLE IF NOT EXISTS %s (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			email TEXT NOT NULL UNIQUE,
 			password_hash TEXT NOT NULL,
-			role TEXT NOT NULL CHECK(role IN ('ADMIN','BARTENDER','USER')),
+			role TEXT NOT NULL,
 			display_name TEXT NOT NULL,
 			is_active INTEGER NOT NULL DEFAULT 1,
 			on_duty INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%%s','now')),
+			updated_at INTEGER NOT NULL DEFAULT (strftime('%%s','now')),
+			invite_id INTEGER NULL,
+			email_verified INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY(role) REFERENCES roles(name),
+			FOREIGN KEY(invite_id) REFERENCES invites(id) ON DELETE SET NULL
+		);`
+
+func Migrate(db *sql.DB) error {
+	stmts := []string{
+		`PRAGMA foreign_keys = ON;`,
+
+		// permissions is a comma-separated list of permission keys
+		`CREATE TABLE IF NOT EXISTS roles (
+			name TEXT PRIMARY KEY,
+			description TEXT NOT NULL DEFAULT '',
+			permissions TEXT NOT NULL DEFAULT '',
+			builtin INTEGER NOT NULL DEFAULT 0,
+			require_2fa INTEGER NOT NULL DEFAULT 0,
 			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
 			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
 		);`,
+		// the three roles that used to be hard-coded, with the permissions they implied
+		`INSERT OR IGNORE INTO roles(name,description,permissions,builtin) VALUES
+			('ADMIN','Full access, including users, roles and settings.','orders.place,orders.manage,inventory.edit,cocktails.edit,reports.view,users.manage,settings.manage',1),
+			('BARTENDER','Runs the queue and keeps inventory and recipes current.','orders.place,orders.manage,inventory.edit,cocktails.edit',1),
+			('USER','Guest: browses the menu and orders drinks.','orders.place',1);`,
+
+		fmt.Sprintf(usersTable, "users"),
+
+		// max_uses 0 means unlimited; revoked invites are kept so joined guests still
+		// show where they came from
+		`CREATE TABLE IF NOT EXISTS invites (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token TEXT NOT NULL UNIQUE,
+			event TEXT NOT NULL DEFAULT '',
+			role TEXT NOT NULL DEFAULT 'USER',
+			max_uses INTEGER NOT NULL DEFAULT 0,
+			uses INTEGER NOT NULL DEFAULT 0,
+			passwordless INTEGER NOT NULL DEFAULT 0,
+			expires_at INTEGER NOT NULL,
+			revoked_at INTEGER NULL,
+			created_by_user_id INTEGER NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(role) REFERENCES roles(name),
+			FOREIGN KEY(created_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+		);`,
+
+		// accounts signed in through an OpenID Connect provider, keyed by the provider's
+		// issuer and subject; email is what the provider last reported
+		`CREATE TABLE IF NOT EXISTS user_identities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			email TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			last_login_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			UNIQUE(issuer, subject),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		// token_hash is a SHA-256 of the code; the code itself is only ever shown once
+		`CREATE TABLE IF NOT EXISTS password_resets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at INTEGER NOT NULL,
+			used_at INTEGER NULL,
+			created_by_user_id INTEGER NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY(created_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+		);`,
+
+		// secret stays pending (confirmed_at NULL) until the user types a first code;
+		// last_step is the newest time step accepted, so each code works once
+		`CREATE TABLE IF NOT EXISTS user_totp (
+			user_id INTEGER PRIMARY KEY,
+			secret TEXT NOT NULL,
+			confirmed_at INTEGER NULL,
+			last_step INTEGER NOT NULL DEFAULT 0,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_failure_at INTEGER NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		// hashed like reset codes
+		`CREATE TABLE IF NOT EXISTS recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at INTEGER NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		// shared tablets: pair_hash is the one-time pairing link until a device claims it,
+		// token_hash the device cookie after that
+		`CREATE TABLE IF NOT EXISTS kiosks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			pair_hash TEXT NULL UNIQUE,
+			pair_expires_at INTEGER NOT NULL,
+			token_hash TEXT NULL UNIQUE,
+			paired_at INTEGER NULL,
+			last_seen_at INTEGER NULL,
+			revoked_at INTEGER NULL,
+			created_by_user_id INTEGER NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(created_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+		);`,
+		// optional PIN a guest types to switch to their account on a kiosk, bcrypt-hashed
+		`CREATE TABLE IF NOT EXISTS kiosk_pins (
+			user_id INTEGER PRIMARY KEY,
+			pin_hash TEXT NOT NULL,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_failure_at INTEGER NULL,
+			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		// walk-up ordering windows; closed_at is set when an admin ends one early
+		`CREATE TABLE IF NOT EXISTS walkup_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL DEFAULT '',
+			role TEXT NOT NULL DEFAULT 'USER',
+			ends_at INTEGER NOT NULL,
+			closed_at INTEGER NULL,
+			created_by_user_id INTEGER NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(role) REFERENCES roles(name),
+			FOREIGN KEY(created_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+		);`,
+		// the name-only accounts created during one; table_label is where to bring drinks
+		`CREATE TABLE IF NOT EXISTS walkup_guests (
+			user_id INTEGER PRIMARY KEY,
+			event_id INTEGER NOT NULL,
+			table_label TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY(event_id) REFERENCES walkup_events(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_walkup_guests_event ON walkup_guests(event_id);`,
 
 		`CREATE TABLE IF NOT EXISTS products (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -27,6 +171,9 @@ func Migrate(db *sql.DB) error {
 			notes TEXT NOT NULL DEFAULT '',
 			is_available INTEGER NOT NULL DEFAULT 1,
 			stock_count INTEGER NULL,
+			par_level INTEGER NULL,
+			reorder_level INTEGER NULL,
+			barcode TEXT NULL,
 			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
 			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
 		);`,
@@ -56,6 +203,40 @@ func Migrate(db *sql.DB) error {
 			FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE RESTRICT
 		);`,
 
+		// The default station is the main bar: its stock is the products table itself, and
+		// cocktails without a route are made there.
+		`CREATE TABLE IF NOT EXISTS stations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			is_default INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+		);`,
+		`INSERT INTO stations(name,is_default) SELECT 'Main Bar', 1 WHERE NOT EXISTS (SELECT 1 FROM stations WHERE is_default=1);`,
+		`CREATE TABLE IF NOT EXISTS station_stock (
+			station_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			is_available INTEGER NOT NULL DEFAULT 1,
+			stock_count INTEGER NULL,
+			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			PRIMARY KEY(station_id, product_id),
+			FOREIGN KEY(station_id) REFERENCES stations(id) ON DELETE CASCADE,
+			FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS station_bartenders (
+			station_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			PRIMARY KEY(station_id, user_id),
+			FOREIGN KEY(station_id) REFERENCES stations(id) ON DELETE CASCADE,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS cocktail_routes (
+			cocktail_id INTEGER PRIMARY KEY,
+			station_id INTEGER NOT NULL,
+			FOREIGN KEY(cocktail_id) REFERENCES cocktails(id) ON DELETE CASCADE,
+			FOREIGN KEY(station_id) REFERENCES stations(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_station_bartenders_user ON station_bartenders(user_id);`,
+
 		`CREATE TABLE IF NOT EXISTS orders (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			user_id INTEGER NOT NULL,
@@ -65,11 +246,14 @@ func Migrate(db *sql.DB) error {
 			location TEXT NOT NULL DEFAULT '',
 			status TEXT NOT NULL CHECK(status IN ('PLACED','ACCEPTED','IN_PROGRESS','READY','DELIVERED','CANCELLED')),
 			assigned_bartender_id INTEGER NULL,
+			station_id INTEGER NULL,
+			overdue_notified_at INTEGER NULL,
 			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
 			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
 			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
 			FOREIGN KEY(cocktail_id) REFERENCES cocktails(id) ON DELETE RESTRICT,
-			FOREIGN KEY(assigned_bartender_id) REFERENCES users(id) ON DELETE SET NULL
+			FOREIGN KEY(assigned_bartender_id) REFERENCES users(id) ON DELETE SET NULL,
+			FOREIGN KEY(station_id) REFERENCES stations(id) ON DELETE SET NULL
 		);`,
 
 		`CREATE TABLE IF NOT EXISTS order_events (
@@ -83,29 +267,248 @@ func Migrate(db *sql.DB) error {
 			FOREIGN KEY(changed_by_user_id) REFERENCES users(id) ON DELETE SET NULL
 		);`,
 
+		// A shift runs from going on duty to going off, by hand or after the bartender's
+		// sessions went idle. Orders are credited to it through order_events.
+		`CREATE TABLE IF NOT EXISTS shifts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			started_at INTEGER NOT NULL,
+			ended_at INTEGER NULL,
+			last_seen_at INTEGER NOT NULL,
+			end_reason TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open ON shifts(user_id) WHERE ended_at IS NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_shifts_started ON shifts(started_at);`,
+		// bartenders already on duty start their first shift now
+		`INSERT INTO shifts(user_id,started_at,last_seen_at)
+		SELECT id, strftime('%s','now'), strftime('%s','now') FROM users
+		WHERE on_duty=1 AND NOT EXISTS (SELECT 1 FROM shifts s WHERE s.user_id=users.id AND s.ended_at IS NULL);`,
+
 		`CREATE TABLE IF NOT EXISTS push_subscriptions (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			bartender_user_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
 			endpoint TEXT NOT NULL UNIQUE,
 			p256dh TEXT NOT NULL,
 			auth TEXT NOT NULL,
 			user_agent TEXT NOT NULL DEFAULT '',
 			device_label TEXT NOT NULL DEFAULT '',
 			enabled INTEGER NOT NULL DEFAULT 1,
+			notify_new_orders INTEGER NOT NULL DEFAULT 1,
+			notify_overdue INTEGER NOT NULL DEFAULT 1,
+			notify_low_stock INTEGER NOT NULL DEFAULT 1,
+			only_assigned INTEGER NOT NULL DEFAULT 0,
+			quiet_start TEXT NOT NULL DEFAULT '',
+			quiet_end TEXT NOT NULL DEFAULT '',
+			quiet_zone TEXT NOT NULL DEFAULT '',
 			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
 			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
 			last_seen_at INTEGER NULL,
 			last_success_at INTEGER NULL,
 			last_failure_at INTEGER NULL,
 			failure_count INTEGER NOT NULL DEFAULT 0,
-			FOREIGN KEY(bartender_user_id) REFERENCES users(id) ON DELETE CASCADE
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		// Outbound webhooks. events is a comma-separated list of event types; each event
+		// queues one delivery per subscribed endpoint, retried until it succeeds or gives up.
+		`CREATE TABLE IF NOT EXISTS webhook_endpoints (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL DEFAULT '',
+			is_enabled INTEGER NOT NULL DEFAULT 1,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+		);`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			endpoint_id INTEGER NOT NULL,
+			event_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'PENDING' CHECK(status IN ('PENDING','DELIVERED','FAILED')),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			response_status INTEGER NOT NULL DEFAULT 0,
+			response_body TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, created_at);`,
+
+		`CREATE TABLE IF NOT EXISTS cocktail_modifiers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			cocktail_id INTEGER NOT NULL,
+			kind TEXT NOT NULL CHECK(kind IN ('OPTIONAL_INGREDIENT','GARNISH','SWEETNESS','STRENGTH','GLASS')),
+			label TEXT NOT NULL,
+			options TEXT NOT NULL DEFAULT '',
+			product_id INTEGER NULL,
+			sort_order INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY(cocktail_id) REFERENCES cocktails(id) ON DELETE CASCADE,
+			FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE RESTRICT
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS order_modifiers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			label TEXT NOT NULL,
+			choice TEXT NOT NULL,
+			is_default INTEGER NOT NULL DEFAULT 0,
+			product_id INTEGER NULL,
+			FOREIGN KEY(order_id) REFERENCES orders(id) ON DELETE CASCADE,
+			FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE SET NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS low_stock_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			product_id INTEGER NOT NULL,
+			stock_count INTEGER NOT NULL,
+			reorder_level INTEGER NOT NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE
 		);`,
 
 		`CREATE INDEX IF NOT EXISTS idx_orders_status_created ON orders(status, created_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_order_events_order_created ON order_events(order_id, created_at);`,
+
+		`CREATE TABLE IF NOT EXISTS stocktakes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			status TEXT NOT NULL CHECK(status IN ('DRAFT','COMMITTED')),
+			note TEXT NOT NULL DEFAULT '',
+			created_by_user_id INTEGER NULL,
+			committed_by_user_id INTEGER NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			updated_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			committed_at INTEGER NULL,
+			FOREIGN KEY(created_by_user_id) REFERENCES users(id) ON DELETE SET NULL,
+			FOREIGN KEY(committed_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+		);`,
+
+		// lines keep the product's name and category, so committed stocktakes still read
+		// right once a product is deleted
+		`CREATE TABLE IF NOT EXISTS stocktake_lines (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			stocktake_id INTEGER NOT NULL,
+			product_id INTEGER NULL,
+			product_name TEXT NOT NULL DEFAULT '',
+			product_category TEXT NOT NULL DEFAULT '',
+			counted INTEGER NOT NULL,
+			expected INTEGER NULL,
+			UNIQUE(stocktake_id, product_id),
+			FOREIGN KEY(stocktake_id) REFERENCES stocktakes(id) ON DELETE CASCADE,
+			FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE SET NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS media (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			path TEXT NOT NULL UNIQUE,
+			content_type TEXT NOT NULL DEFAULT '',
+			bytes INTEGER NOT NULL DEFAULT 0,
+			width INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			uploaded_by_user_id INTEGER NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+			unreferenced_at INTEGER NULL,
+			FOREIGN KEY(uploaded_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+		);`,
+		// the collector's grace period runs from when the last cocktail let go of an image
+		`CREATE TRIGGER IF NOT EXISTS media_unreferenced_on_update AFTER UPDATE OF image_path ON cocktails
+		WHEN COALESCE(old.image_path,'') <> COALESCE(new.image_path,'')
+		BEGIN
+			UPDATE media SET unreferenced_at=strftime('%s','now')
+			WHERE path=old.image_path AND NOT EXISTS (SELECT 1 FROM cocktails WHERE image_path=old.image_path);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS media_unreferenced_on_delete AFTER DELETE ON cocktails
+		BEGIN
+			UPDATE media SET unreferenced_at=strftime('%s','now')
+			WHERE path=old.image_path AND NOT EXISTS (SELECT 1 FROM cocktails WHERE image_path=old.image_path);
+		END;`,
+
+		// actor and target ids are kept without foreign keys: entries must outlive what they
+		// describe, and the names are copied in for the same reason
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor_user_id INTEGER NULL,
+			actor_name TEXT NOT NULL DEFAULT '',
+			action TEXT NOT NULL,
+			target_type TEXT NOT NULL DEFAULT '',
+			target_id INTEGER NULL,
+			target_label TEXT NOT NULL DEFAULT '',
+			before_json TEXT NULL,
+			after_json TEXT NULL,
+			created_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+		);`,
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;`,
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;`,
+
 		`CREATE INDEX IF NOT EXISTS idx_cocktail_ingredients_cocktail ON cocktail_ingredients(cocktail_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user_enabled ON push_subscriptions(bartender_user_id, enabled);`,
+		`CREATE INDEX IF NOT EXISTS idx_cocktail_modifiers_cocktail ON cocktail_modifiers(cocktail_id, sort_order);`,
+		`CREATE INDEX IF NOT EXISTS idx_order_modifiers_order ON order_modifiers(order_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_order_events_changed_by ON order_events(changed_by_user_id, created_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_push_subscriptions_enabled_updated ON push_subscriptions(enabled, updated_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_low_stock_events_created ON low_stock_events(created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_stocktakes_status ON stocktakes(status, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_cocktails_image_path ON cocktails(image_path);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_password_resets_user ON password_resets(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id, code_hash);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id);`,
+		// uploads from before the media table become library entries of unknown size
+		`INSERT OR IGNORE INTO media(path) SELECT DISTINCT image_path FROM cocktails WHERE image_path LIKE '/uploads/%';`,
+	}
+
+	// Columns added after a table first shipped. CREATE TABLE above already has them for
+	// new databases; older ones get them here, followed by the optional backfill.
+	columns := []struct {
+		table, column, ddl, backfill string
+	}{
+		{"products", "par_level", `ALTER TABLE products ADD COLUMN par_level INTEGER NULL`, ""},
+		// keep the old fixed "low at 2" behaviour for items that already track stock
+		{"products", "reorder_level", `ALTER TABLE products ADD COLUMN reorder_level INTEGER NULL`, `UPDATE products SET reorder_level = 2 WHERE stock_count IS NOT NULL`},
+		{"products", "barcode", `ALTER TABLE products ADD COLUMN barcode TEXT NULL`, ""},
+		{"users", "invite_id", `ALTER TABLE users ADD COLUMN invite_id INTEGER NULL REFERENCES invites(id) ON DELETE SET NULL`, ""},
+		// admins typed in the emails of accounts that didn't join by invite or walk-up, unless
+		// the audit log shows the user changed it since
+		{"users", "email_verified", `ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0`, `UPDATE users SET email_verified = 1
+			WHERE invite_id IS NULL AND email NOT LIKE '%@guests.invalid'
+			AND id NOT IN (SELECT target_id FROM audit_log WHERE action='user.update' AND target_type='user' AND actor_user_id=target_id)`},
+		// orders from before stations were all made at the main bar
+		{"orders", "station_id", `ALTER TABLE orders ADD COLUMN station_id INTEGER NULL REFERENCES stations(id) ON DELETE SET NULL`, `UPDATE orders SET station_id = (SELECT id FROM stations WHERE is_default=1)`},
+		// orders from before overdue alerts are not alerted about
+		{"orders", "overdue_notified_at", `ALTER TABLE orders ADD COLUMN overdue_notified_at INTEGER NULL`, `UPDATE orders SET overdue_notified_at = strftime('%s','now')`},
+		{"push_subscriptions", "notify_new_orders", `ALTER TABLE push_subscriptions ADD COLUMN notify_new_orders INTEGER NOT NULL DEFAULT 1`, ""},
+		{"push_subscriptions", "notify_overdue", `ALTER TABLE push_subscriptions ADD COLUMN notify_overdue INTEGER NOT NULL DEFAULT 1`, ""},
+		{"push_subscriptions", "notify_low_stock", `ALTER TABLE push_subscriptions ADD COLUMN notify_low_stock INTEGER NOT NULL DEFAULT 1`, ""},
+		{"push_subscriptions", "only_assigned", `ALTER TABLE push_subscriptions ADD COLUMN only_assigned INTEGER NOT NULL DEFAULT 0`, ""},
+		{"push_subscriptions", "quiet_start", `ALTER TABLE push_subscriptions ADD COLUMN quiet_start TEXT NOT NULL DEFAULT ''`, ""},
+		{"push_subscriptions", "quiet_end", `ALTER TABLE push_subscriptions ADD COLUMN quiet_end TEXT NOT NULL DEFAULT ''`, ""},
+		{"push_subscriptions", "quiet_zone", `ALTER TABLE push_subscriptions ADD COLUMN quiet_zone TEXT NOT NULL DEFAULT ''`, ""},
+	}
+
+	// Columns renamed after a table first shipped, as {table, old, new}.
+	renames := []struct {
+		table, from, to string
+	}{
+		// guests subscribe too now, so the owner is no longer necessarily a bartender
+		{"push_subscriptions", "bartender_user_id", "user_id"},
+	}
+
+	// Indexes on added or renamed columns can only be created once the columns exist.
+	late := []string{
+		`CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user_enabled ON push_subscriptions(user_id, enabled);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_products_barcode ON products(barcode) WHERE barcode IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_users_invite ON users(invite_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_station_status ON orders(station_id, status);`,
 	}
 
 	tx, err := db.Begin()
@@ -118,5 +521,116 @@ func Migrate(db *sql.DB) error {
 			return err
 		}
 	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, c.table, c.column, c.ddl, c.backfill); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	for _, c := range renames {
+		if err := renameColumnIfPresent(tx, c.table, c.from, c.to); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	for _, s := range late {
+		if _, err := tx.Exec(s); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return migrateUserRoles(db)
+}
+
+// migrateUserRoles rebuilds a users table that still has the CHECK constraint limiting
+// role to the three original roles; SQLite cannot drop a constraint in place.
+func migrateUserRoles(db *sql.DB) error {
+	var ddl string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='users'`).Scan(&ddl); err != nil {
+		return err
+	}
+	if !strings.Contains(ddl, "CHECK(role IN") {
+		return nil
+	}
+
+	// Foreign keys must be off while users is dropped, or the drop would cascade into every
+	// table that references it. The pragma is ignored inside a transaction, so it is set on
+	// a dedicated connection first.
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer func() { _, _ = conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`) }()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	cols := `id,email,password_hash,role,display_name,is_active,on_duty,created_at,updated_at,invite_id,email_verified`
+	for _, s := range []string{
+		fmt.Sprintf(usersTable, "users_new"),
+		`INSERT INTO users_new(` + cols + `) SELECT ` + cols + ` FROM users`,
+		`DROP TABLE users`,
+		`ALTER TABLE users_new RENAME TO users`,
+		// indexes go with the dropped table
+		`CREATE INDEX IF NOT EXISTS idx_users_invite ON users(invite_id)`,
+	} {
+		if _, err := tx.Exec(s); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migrate user roles: %w", err)
+		}
+	}
+	rows, err := tx.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	broken := rows.Next()
+	_ = rows.Close()
+	if broken {
+		_ = tx.Rollback()
+		return fmt.Errorf("migrate user roles: foreign key check failed")
+	}
 	return tx.Commit()
 }
+
+func addColumnIfMissing(tx *sql.Tx, table, column, ddl, backfill string) error {
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?`, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := tx.Exec(ddl); err != nil {
+		return err
+	}
+	if backfill != "" {
+		if _, err := tx.Exec(backfill); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameColumnIfPresent renames column from to to while the table still has the old
+// name. Indexes and foreign keys on the column follow it.
+func renameColumnIfPresent(tx *sql.Tx, table, from, to string) error {
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?`, table, from).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, table, from, to))
+	return err
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// newTestStore opens a fresh, migrated database.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s := openTestStore(t)
+	if err := Migrate(s.DB); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func count(t *testing.T, s *Store, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := s.DB.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestMigrateFromBaseline(t *testing.T) {
+	s := openTestStore(t)
+	baseline, err := os.ReadFile(filepath.Join("testdata", "baseline.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec(string(baseline)); err != nil {
+		t.Fatalf("baseline schema: %v", err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO users(id, email, password_hash, role, display_name, on_duty) VALUES
+			(1, 'admin@example.com', 'h', 'ADMIN', 'Admin', 0),
+			(2, 'bar@example.com', 'h', 'BARTENDER', 'Bar', 1),
+			(3, 'guest@example.com', 'h', 'USER', 'Guest', 0)`,
+		`INSERT INTO products(id, name, category, stock_count) VALUES (1, 'Gin', 'Spirit', 5)`,
+		`INSERT INTO cocktails(id, name) VALUES (1, 'Martini')`,
+		`INSERT INTO cocktail_ingredients(cocktail_id, product_id, quantity, unit) VALUES (1, 1, 60, 'ml')`,
+		`INSERT INTO orders(id, user_id, cocktail_id, status, assigned_bartender_id) VALUES
+			(1, 3, 1, 'ACCEPTED', 2),
+			(2, 3, 1, 'PLACED', NULL),
+			(3, 1, 1, 'DELIVERED', 2)`,
+		`INSERT INTO order_events(order_id, from_status, to_status, changed_by_user_id) VALUES (1, 'PLACED', 'ACCEPTED', 2)`,
+		`INSERT INTO push_subscriptions(bartender_user_id, endpoint, p256dh, auth) VALUES
+			(2, 'https://push.example/bar', 'p', 'a'),
+			(3, 'https://push.example/guest', 'p', 'a')`,
+	} {
+		if _, err := s.DB.Exec(stmt); err != nil {
+			t.Fatalf("seed baseline: %v", err)
+		}
+	}
+
+	if err := Migrate(s.DB); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var ddl string
+	if err := s.DB.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='users'`).Scan(&ddl); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(ddl, "CHECK(role IN") {
+		t.Fatal("users still limits role to the built-in roles")
+	}
+	for table, want := range map[string]int{"users": 3, "orders": 3, "order_events": 1, "push_subscriptions": 2} {
+		if got := count(t, s, `SELECT COUNT(*) FROM `+table); got != want {
+			t.Errorf("%s: %d rows after migrate, want %d", table, got, want)
+		}
+	}
+	if got := count(t, s, `SELECT COUNT(*) FROM users WHERE email_verified=1`); got != 3 {
+		t.Errorf("%d of the admin-created accounts have a confirmed email, want 3", got)
+	}
+	if got := count(t, s, `SELECT COUNT(*) FROM push_subscriptions WHERE user_id=2`); got != 1 {
+		t.Errorf("push subscription lost its owner: %d", got)
+	}
+	if got := count(t, s, `SELECT COUNT(*) FROM pragma_foreign_key_check`); got != 0 {
+		t.Fatalf("foreign_key_check reports %d broken rows", got)
+	}
+	if got := count(t, s, `PRAGMA foreign_keys`); got != 1 {
+		t.Fatal("foreign keys were left off")
+	}
+
+	// the rebuilt table takes custom roles, and its foreign keys still act
+	if err := s.Q.CreateRole("HOST", "", []string{"orders.place"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Q.CreateUser(CreateUserParams{Email: "host@example.com", PasswordHash: "h", Role: "HOST", DisplayName: "Host", IsActive: true}); err != nil {
+		t.Fatalf("custom role after migrate: %v", err)
+	}
+	if _, err := s.DB.Exec(`DELETE FROM users WHERE id=3`); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(t, s, `SELECT COUNT(*) FROM orders WHERE user_id=3`) + count(t, s, `SELECT COUNT(*) FROM push_subscriptions WHERE user_id=3`); got != 0 {
+		t.Errorf("deleting a user left %d orders and subscriptions behind", got)
+	}
+	if _, err := s.DB.Exec(`DELETE FROM users WHERE id=2`); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(t, s, `SELECT COUNT(*) FROM orders WHERE assigned_bartender_id IS NOT NULL`); got != 0 {
+		t.Errorf("deleting a bartender left %d orders assigned", got)
+	}
+
+	if err := Migrate(s.DB); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+}
@@ -10,8 +10,22 @@ type User struct {
 	DisplayName  string
 	IsActive     bool
 	OnDuty       bool
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	// EmailVerified is set when an admin or a verifying identity provider vouched for the
+	// email. Addresses users typed in themselves are not, so providers never link to them.
+	EmailVerified bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Role is a named permission set. Built-in roles are the original ADMIN, BARTENDER and
+// USER; they cannot be edited or deleted.
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string
+	Builtin     bool
+	Require2FA  bool // members must enroll an authenticator before using the app
+	UserCount   int
 }
 
 type Product struct {
@@ -23,11 +37,36 @@ type Product struct {
 	Notes         string
 	IsAvailable   bool
 	StockCount    *int64
+	ParLevel      *int64
+	ReorderLevel  *int64
+	Barcode       string
 	ComputedAvail bool
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 }
 
+// IsLow reports whether tracked stock is at or below the product's reorder level.
+func (p Product) IsLow() bool {
+	return IsLowStock(p.StockCount, p.ReorderLevel)
+}
+
+// BelowPar reports whether tracked stock is under the product's par level.
+func (p Product) BelowPar() bool {
+	return p.StockCount != nil && p.ParLevel != nil && *p.StockCount < *p.ParLevel
+}
+
+// Shortfall is how many units bring the product back up to par.
+func (p Product) Shortfall() int64 {
+	if !p.BelowPar() {
+		return 0
+	}
+	return *p.ParLevel - *p.StockCount
+}
+
+func IsLowStock(stock, reorderLevel *int64) bool {
+	return stock != nil && reorderLevel != nil && *stock <= *reorderLevel
+}
+
 type Cocktail struct {
 	ID              int64
 	Name            string
@@ -56,6 +95,133 @@ type CocktailIngredient struct {
 	ProductAvail    bool
 }
 
+type LowStockEvent struct {
+	ID              int64
+	ProductID       int64
+	ProductName     string
+	ProductCategory string
+	StockCount      int64
+	ReorderLevel    int64
+	CreatedAt       time.Time
+}
+
+const (
+	StocktakeDraft     = "DRAFT"
+	StocktakeCommitted = "COMMITTED"
+)
+
+type Stocktake struct {
+	ID              int64
+	Status          string
+	Note            string
+	CreatedByName   string
+	CommittedByName string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	CommittedAt     time.Time
+
+	// summary over lines with a tracked expected value
+	LineCount   int
+	NetVariance int64
+	AbsVariance int64
+}
+
+type StocktakeLine struct {
+	ID              int64
+	StocktakeID     int64
+	ProductID       int64
+	ProductName     string
+	ProductCategory string
+	Counted         int64
+	// Expected is live stock while the stocktake is a draft and the snapshot taken at
+	// commit afterwards. Nil means the product did not track stock.
+	Expected *int64
+}
+
+// Variance is counted minus expected; untracked products have none.
+func (l StocktakeLine) Variance() int64 {
+	if l.Expected == nil {
+		return 0
+	}
+	return l.Counted - *l.Expected
+}
+
+type StocktakeCount struct {
+	ProductID int64
+	Counted   int64
+}
+
+// Media is an uploaded image. Path is what cocktails store in image_path; for re-encoded
+// uploads that is the hero variant.
+type Media struct {
+	ID             int64
+	Path           string
+	ContentType    string
+	Bytes          int64
+	Width          int
+	Height         int
+	UploadedByName string
+	CreatedAt      time.Time
+
+	// RefCount is the number of cocktails using the image.
+	RefCount int
+	// UnreferencedAt is when the last cocktail using the image stopped, zero if none has.
+	UnreferencedAt time.Time
+}
+
+type CreateMediaParams struct {
+	Path        string
+	ContentType string
+	Bytes       int64
+	Width       int
+	Height      int
+	UploadedBy  int64
+}
+
+// AuditEntry is one row of the append-only audit log. Before and After hold JSON
+// snapshots of the target and are empty for creations and deletions respectively.
+type AuditEntry struct {
+	ID          int64
+	ActorID     int64
+	ActorName   string
+	Action      string
+	TargetType  string
+	TargetID    int64
+	TargetLabel string
+	Before      string
+	After       string
+	CreatedAt   time.Time
+}
+
+// AuditFilter narrows ListAudit. Zero values match everything; Limit 0 means no limit.
+type AuditFilter struct {
+	ActorID    int64
+	Action     string
+	TargetType string
+	Query      string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+type AuditActor struct {
+	ID   int64
+	Name string
+}
+
+type CocktailRequirement struct {
+	CocktailID      int64
+	CocktailName    string
+	CocktailEnabled bool
+
+	// ProductID is nil for cocktails without required ingredients.
+	ProductID       *int64
+	ProductName     string
+	ProductCategory string
+	ProductAvail    bool
+}
+
 type IngredientUpsertItem struct {
 	ProductID int64
 	Quantity  *float64
@@ -63,6 +229,65 @@ type IngredientUpsertItem struct {
 	Required  bool
 }
 
+// Modifier kinds a bartender can attach to a cocktail.
+const (
+	ModifierOptionalIngredient = "OPTIONAL_INGREDIENT"
+	ModifierGarnish            = "GARNISH"
+	ModifierSweetness          = "SWEETNESS"
+	ModifierStrength           = "STRENGTH"
+	ModifierGlass              = "GLASS"
+)
+
+// Fixed choices for OPTIONAL_INGREDIENT modifiers; the multiplier drives stock depletion.
+const (
+	IngredientChoiceRegular = "Regular"
+	IngredientChoiceNone    = "None"
+	IngredientChoiceExtra   = "Extra"
+)
+
+type CocktailModifier struct {
+	ID         int64
+	CocktailID int64
+	Kind       string
+	Label      string
+	Options    string // comma-separated; the first option is the default
+	ProductID  *int64
+	SortOrder  int64
+
+	ProductName  string
+	ProductAvail bool
+}
+
+type ModifierUpsertItem struct {
+	Kind      string
+	Label     string
+	Options   string
+	ProductID *int64
+}
+
+type OrderModifier struct {
+	ID        int64
+	OrderID   int64
+	Kind      string
+	Label     string
+	Choice    string
+	IsDefault bool
+	ProductID *int64
+}
+
+type OrderModifierInput struct {
+	Kind      string
+	Label     string
+	Choice    string
+	IsDefault bool
+	ProductID *int64
+}
+
+type StockDepletion struct {
+	ProductID int64
+	Units     int64
+}
+
 type Order struct {
 	ID                  int64
 	UserID              int64
@@ -72,6 +297,7 @@ type Order struct {
 	Location            string
 	Status              string
 	AssignedBartenderID *int64
+	StationID           int64
 	CreatedAt           time.Time
 	UpdatedAt           time.Time
 
@@ -79,6 +305,38 @@ type Order struct {
 	CocktailName          string
 	CocktailImagePath     string
 	AssignedBartenderName string
+	StationName           string
+
+	Modifiers []OrderModifier
+}
+
+// Why a shift ended.
+const (
+	ShiftEndSelf  = "self"  // the bartender went off duty
+	ShiftEndAdmin = "admin" // an admin took them off duty
+	ShiftEndRole  = "role"  // their role no longer manages orders
+	ShiftEndIdle  = "idle"  // their sessions went quiet; the shift ends when last seen
+)
+
+// Shift is one stretch on duty. EndedAt is zero while it runs.
+type Shift struct {
+	ID         int64
+	UserID     int64
+	UserName   string
+	StartedAt  time.Time
+	EndedAt    time.Time
+	LastSeenAt time.Time
+	EndReason  string
+}
+
+// ShiftOrderEvent is a status change a bartender made to an order during a shift.
+type ShiftOrderEvent struct {
+	ShiftID       int64
+	OrderID       int64
+	CocktailName  string
+	ToStatus      string
+	At            time.Time
+	OrderPlacedAt time.Time
 }
 
 type OrderEvent struct {
@@ -92,20 +350,96 @@ type OrderEvent struct {
 }
 
 type PushSubscription struct {
-	ID              int64
-	BartenderUserID int64
-	Endpoint        string
-	P256DH          string
-	Auth            string
-	UserAgent       string
-	DeviceLabel     string
-	Enabled         bool
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
-	LastSeenAt      time.Time
-	LastSuccessAt   time.Time
-	LastFailureAt   time.Time
-	FailureCount    int
+	ID            int64
+	UserID        int64
+	Endpoint      string
+	P256DH        string
+	Auth          string
+	UserAgent     string
+	DeviceLabel   string
+	Enabled       bool
+	Prefs         PushPreferences
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	LastSeenAt    time.Time
+	LastSuccessAt time.Time
+	LastFailureAt time.Time
+	FailureCount  int
+}
+
+// PushPreferences is what a bartender's device is sent. OnlyAssigned limits order alerts to
+// orders assigned to the device's owner. QuietStart and QuietEnd are times of day ("23:00")
+// in QuietZone, an IANA zone name, or the server's zone when that is empty; with either
+// time empty there are no quiet hours.
+type PushPreferences struct {
+	NewOrders    bool
+	Overdue      bool
+	LowStock     bool
+	OnlyAssigned bool
+	QuietStart   string
+	QuietEnd     string
+	QuietZone    string
+}
+
+// WebhookEndpoint is an admin-configured URL that is sent the events it subscribes to,
+// signed with its secret.
+type WebhookEndpoint struct {
+	ID        int64
+	Name      string
+	URL       string
+	Secret    string
+	Events    []string
+	IsEnabled bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Pending int // deliveries still to be sent
+	Failed  int // deliveries that gave up
+}
+
+// Subscribes reports whether the endpoint is sent event.
+func (e WebhookEndpoint) Subscribes(event string) bool {
+	for _, ev := range e.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook delivery statuses. A delivery is PENDING until an attempt succeeds or the
+// retries run out.
+const (
+	WebhookPending   = "PENDING"
+	WebhookDelivered = "DELIVERED"
+	WebhookFailed    = "FAILED"
+)
+
+// WebhookDelivery is one event queued for one endpoint. Payload is the exact JSON body
+// sent; a redelivery is a new delivery with the same event ID and payload.
+type WebhookDelivery struct {
+	ID             int64
+	EndpointID     int64
+	EventID        string
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	ResponseStatus int
+	ResponseBody   string
+	Error          string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// WebhookAttempt is the outcome of sending a delivery once.
+type WebhookAttempt struct {
+	Status         string
+	NextAttemptAt  time.Time
+	ResponseStatus int
+	ResponseBody   string
+	Error          string
 }
 
 /* ---------- parameter structs ---------- */
@@ -117,13 +451,133 @@ type CreateUserParams struct {
 	DisplayName  string
 	IsActive     bool
 	OnDuty       bool
+	// EmailVerified: an admin or identity provider supplied the email.
+	EmailVerified bool
 }
 
-type UpdateUserParams struct {
+// Invite is a self-registration link. Guests who join through it get its role.
+type Invite struct {
+	ID            int64
+	Token         string
+	Event         string
+	Role          string
+	MaxUses       int // 0 = unlimited
+	Uses          int
+	Passwordless  bool // guests may join without choosing a password
+	ExpiresAt     time.Time
+	RevokedAt     time.Time
+	CreatedByName string
+	CreatedAt     time.Time
+}
+
+type CreateInviteParams struct {
+	Token        string
+	Event        string
+	Role         string
+	MaxUses      int
+	Passwordless bool
+	ExpiresAt    time.Time
+	CreatedByID  int64
+}
+
+// PasswordReset is an unused, unexpired reset code, joined with the account it is for.
+type PasswordReset struct {
 	ID          int64
-	Email       string
-	Role        string
+	UserID      int64
 	DisplayName string
+	ExpiresAt   time.Time
+}
+
+// TOTP is a user's authenticator enrollment. Until Confirmed is set it is only a pending
+// secret from an enrollment that was not finished.
+type TOTP struct {
+	UserID        int64
+	Secret        string
+	Confirmed     bool
+	ConfirmedAt   time.Time
+	LastStep      int64
+	Failures      int
+	LastFailureAt time.Time
+	RecoveryLeft  int
+}
+
+// Kiosk is a shared tablet that guests switch between accounts on.
+type Kiosk struct {
+	ID            int64
+	Name          string
+	PairExpiresAt time.Time
+	PairedAt      time.Time
+	LastSeenAt    time.Time
+	RevokedAt     time.Time
+	CreatedByName string
+	CreatedAt     time.Time
+}
+
+// KioskGuest is an account offered on the kiosk picker.
+type KioskGuest struct {
+	ID          int64
+	DisplayName string
+	Role        string
+	HasPIN      bool
+}
+
+type KioskPIN struct {
+	UserID        int64
+	Hash          string
+	Failures      int
+	LastFailureAt time.Time
+}
+
+// Station is a bar counter with its own stock, bartenders and queue. The default station
+// is the main bar; its stock is the one kept on the products.
+type Station struct {
+	ID         int64
+	Name       string
+	IsDefault  bool
+	Cocktails  int // routed here; the default station counts the unrouted ones
+	Bartenders int
+	OpenOrders int
+	CreatedAt  time.Time
+}
+
+// StationStock is a product's stock at a station other than the main bar. A product the
+// station has no row for is not Stocked there and counts as unavailable.
+type StationStock struct {
+	StationID       int64
+	ProductID       int64
+	ProductName     string
+	ProductCategory string
+	Stocked         bool
+	IsAvailable     bool
+	StockCount      *int64
+	ComputedAvail   bool
+}
+
+// WalkupEvent is a window in which guests can order with just a name.
+type WalkupEvent struct {
+	ID            int64
+	Name          string
+	Role          string
+	EndsAt        time.Time
+	ClosedAt      time.Time
+	Guests        int
+	CreatedByName string
+	CreatedAt     time.Time
+}
+
+// WalkupGuest is a name-only account and the event it belongs to.
+type WalkupGuest struct {
+	UserID int64
+	Table  string
+	Event  WalkupEvent
+}
+
+type UpdateUserParams struct {
+	ID            int64
+	Email         string
+	Role          string
+	DisplayName   string
+	EmailVerified bool
 }
 
 type CreateProductParams struct {
@@ -134,6 +588,9 @@ type CreateProductParams struct {
 	Notes         string
 	IsAvailable   bool
 	StockCount    *int64
+	ParLevel      *int64
+	ReorderLevel  *int64
+	Barcode       string
 }
 
 type UpdateProductParams struct {
@@ -145,6 +602,9 @@ type UpdateProductParams struct {
 	Notes         string
 	IsAvailable   bool
 	StockCount    *int64
+	ParLevel      *int64
+	ReorderLevel  *int64
+	Barcode       string
 }
 
 type CreateCocktailParams struct {
@@ -176,13 +636,16 @@ type CreateOrderParams struct {
 	Quantity   int64
 	Notes      string
 	Location   string
+	StationID  int64
+	Modifiers  []OrderModifierInput
+	Depletions []StockDepletion // taken from the station's stock
 }
 
 type UpsertPushSubscriptionParams struct {
-	BartenderUserID int64
-	Endpoint        string
-	P256DH          string
-	Auth            string
-	UserAgent       string
-	DeviceLabel     string
+	UserID      int64
+	Endpoint    string
+	P256DH      string
+	Auth        string
+	UserAgent   string
+	DeviceLabel string
 }
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestCreateOrderRefusesMoreThanIsLeft(t *testing.T) {
+	f := newStationFixture(t)
+	q := f.s.Q
+	order := func(units int64) error {
+		_, err := q.CreateOrder(CreateOrderParams{
+			UserID: f.guestID, CocktailID: f.martini, Quantity: 1, StationID: f.mainBar,
+			Depletions: []StockDepletion{{ProductID: f.aperol, Units: 1}, {ProductID: f.gin, Units: units}},
+		})
+		return err
+	}
+
+	if err := order(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := order(3); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("second order for 3 of 2 left: %v", err)
+	}
+	if got := count(t, f.s, `SELECT COUNT(*) FROM orders`) + count(t, f.s, `SELECT COUNT(*) FROM order_events`); got != 2 {
+		t.Fatalf("a refused order left rows behind: %d orders and events", got)
+	}
+	if got, _ := q.StationStockCount(f.mainBar, f.gin); got == nil || *got != 2 {
+		t.Fatalf("gin = %v after a refused order, want 2", got)
+	}
+	if err := order(2); err != nil {
+		t.Fatalf("the last 2: %v", err)
+	}
+
+	// at another station, only what that station tracks is checked
+	n := int64(1)
+	_ = q.SetStationStock(f.patio, f.aperol, true, true, &n)
+	_, err := q.CreateOrder(CreateOrderParams{UserID: f.guestID, CocktailID: f.spritz, Quantity: 1, StationID: f.patio,
+		Depletions: []StockDepletion{{ProductID: f.aperol, Units: 2}}})
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("2 Aperol of 1 at the patio: %v", err)
+	}
+	_, err = q.CreateOrder(CreateOrderParams{UserID: f.guestID, CocktailID: f.spritz, Quantity: 1, StationID: f.patio,
+		Depletions: []StockDepletion{{ProductID: f.gin, Units: 9}}})
+	if err != nil {
+		t.Fatalf("gin the patio doesn't track: %v", err)
+	}
+}
+
+func TestCreateOrderStockUnderConcurrentOrders(t *testing.T) {
+	f := newStationFixture(t)
+	q := f.s.Q
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	placed, refused := 0, 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := q.CreateOrder(CreateOrderParams{UserID: f.guestID, CocktailID: f.martini, Quantity: 1, StationID: f.mainBar,
+				Depletions: []StockDepletion{{ProductID: f.gin, Units: 2}}})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				placed++
+			case errors.Is(err, ErrInsufficientStock):
+				refused++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if placed != 2 || refused != 6 {
+		t.Fatalf("%d placed and %d refused from 5 gin at 2 each", placed, refused)
+	}
+	if got, _ := q.StationStockCount(f.mainBar, f.gin); got == nil || *got != 1 {
+		t.Fatalf("gin = %v, want 1", got)
+	}
+}
@@ -2,16 +2,51 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
 
+var ErrStocktakeNotDraft = errors.New("stocktake is not an open draft")
+
+// ErrInviteUnavailable is returned when an invite is unknown, revoked, expired or used up.
+var ErrInviteUnavailable = errors.New("invite is no longer valid")
+
+// ErrKioskUnavailable is returned when a pairing link is unknown, used, expired or revoked.
+var ErrKioskUnavailable = errors.New("kiosk pairing link is no longer valid")
+
+// ErrWalkupClosed is returned when a guest joins walk-up ordering that has ended or closed.
+var ErrWalkupClosed = errors.New("walk-up ordering is closed")
+
+// ErrDefaultStation is returned when deleting the main bar.
+var ErrDefaultStation = errors.New("the main bar cannot be deleted")
+
+// ErrInsufficientStock is returned when an order needs more tracked stock than is left.
+var ErrInsufficientStock = errors.New("not enough stock left")
+
+// ErrResetUnavailable is returned when a reset code is unknown, used, expired or belongs
+// to a disabled account.
+var ErrResetUnavailable = errors.New("reset code is no longer valid")
+
+// Queries runs mutations on the writer connection and plain reads on the read pool.
 type Queries struct {
-	db *sql.DB
+	db  *sql.DB // single writer
+	rdb *sql.DB // read-only pool
+	fts bool    // FTS5 search index is available; see SetupSearch
 }
 
 func unixNow() int64 { return time.Now().Unix() }
+
+// nullString stores empty strings as NULL, for optional columns under a UNIQUE index.
+func nullString(v string) any {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
 func b2i(b bool) int {
 	if b {
 		return 1
@@ -36,25 +71,49 @@ func computedAvailExpr() string {
 	END)`
 }
 
+// stationAvailExpr is computedAvailExpr for a cocktail's ingredient p, read at the station
+// the cocktail in cocktailCol is routed to. Unrouted cocktails are made at the main bar and
+// see the product's own stock; at any other station a product without a station_stock row
+// is out.
+func stationAvailExpr(cocktailCol string) string {
+	return fmt.Sprintf(`COALESCE((
+		SELECT CASE
+			WHEN ss.product_id IS NULL THEN 0
+			WHEN ss.stock_count IS NOT NULL THEN (CASE WHEN ss.stock_count > 0 THEN 1 ELSE 0 END)
+			ELSE (CASE WHEN ss.is_available = 1 THEN 1 ELSE 0 END)
+		END
+		FROM cocktail_routes cr
+		LEFT JOIN station_stock ss ON ss.station_id = cr.station_id AND ss.product_id = p.id
+		WHERE cr.cocktail_id = %s
+	), %s)`, cocktailCol, computedAvailExpr())
+}
+
 type rowScanner interface {
 	Scan(dest ...any) error
 }
 
 func scanPushSubscription(scanner rowScanner) (*PushSubscription, error) {
 	var sub PushSubscription
-	var enabled int
+	var enabled, newOrders, overdue, lowStock, onlyAssigned int
 	var createdAt, updatedAt int64
 	var lastSeen, lastSuccess, lastFailure sql.NullInt64
 
 	if err := scanner.Scan(
 		&sub.ID,
-		&sub.BartenderUserID,
+		&sub.UserID,
 		&sub.Endpoint,
 		&sub.P256DH,
 		&sub.Auth,
 		&sub.UserAgent,
 		&sub.DeviceLabel,
 		&enabled,
+		&newOrders,
+		&overdue,
+		&lowStock,
+		&onlyAssigned,
+		&sub.Prefs.QuietStart,
+		&sub.Prefs.QuietEnd,
+		&sub.Prefs.QuietZone,
 		&createdAt,
 		&updatedAt,
 		&lastSeen,
@@ -66,6 +125,10 @@ func scanPushSubscription(scanner rowScanner) (*PushSubscription, error) {
 	}
 
 	sub.Enabled = i2b(enabled)
+	sub.Prefs.NewOrders = i2b(newOrders)
+	sub.Prefs.Overdue = i2b(overdue)
+	sub.Prefs.LowStock = i2b(lowStock)
+	sub.Prefs.OnlyAssigned = i2b(onlyAssigned)
 	sub.CreatedAt = tFromUnix(createdAt)
 	sub.UpdatedAt = tFromUnix(updatedAt)
 	if lastSeen.Valid {
@@ -81,10 +144,140 @@ func scanPushSubscription(scanner rowScanner) (*PushSubscription, error) {
 	return &sub, nil
 }
 
+/* ---------------- Roles ---------------- */
+
+const roleSelect = `
+	SELECT r.name,r.description,r.permissions,r.builtin,r.require_2fa,
+		(SELECT COUNT(*) FROM users u WHERE u.role=r.name)
+	FROM roles r`
+
+func scanRole(scanner rowScanner) (*Role, error) {
+	var r Role
+	var perms string
+	var builtin, require2FA int
+	if err := scanner.Scan(&r.Name, &r.Description, &perms, &builtin, &require2FA, &r.UserCount); err != nil {
+		return nil, err
+	}
+	r.Permissions = splitPermissions(perms)
+	r.Builtin = i2b(builtin)
+	r.Require2FA = i2b(require2FA)
+	return &r, nil
+}
+
+func splitPermissions(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ListRoles returns the built-in roles first, then custom roles by name.
+func (q *Queries) ListRoles() ([]Role, error) {
+	rows, err := q.rdb.Query(roleSelect + ` ORDER BY r.builtin DESC, CASE r.name WHEN 'ADMIN' THEN 0 WHEN 'BARTENDER' THEN 1 ELSE 2 END, r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Role
+	for rows.Next() {
+		r, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *r)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) GetRole(name string) (*Role, error) {
+	r, err := scanRole(q.rdb.QueryRow(roleSelect+` WHERE r.name=?`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+func (q *Queries) CreateRole(name, description string, permissions []string) error {
+	_, err := q.db.Exec(`
+		INSERT INTO roles(name,description,permissions,builtin,created_at,updated_at)
+		VALUES(?,?,?,0,?,?)`, name, description, strings.Join(permissions, ","), unixNow(), unixNow())
+	return err
+}
+
+// UpdateRole changes a custom role. When the role loses the duty-bearing permission
+// dutyPermission, its members are taken off duty and their shifts closed in the same
+// transaction.
+func (q *Queries) UpdateRole(name, description string, permissions []string, dutyPermission string) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`UPDATE roles SET description=?, permissions=?, updated_at=? WHERE name=? AND builtin=0`,
+		description, strings.Join(permissions, ","), unixNow(), name)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return sql.ErrNoRows
+	}
+	keepsDuty := false
+	for _, p := range permissions {
+		keepsDuty = keepsDuty || p == dutyPermission
+	}
+	if !keepsDuty {
+		if _, err := tx.Exec(`
+			UPDATE shifts SET ended_at=?, end_reason=? WHERE ended_at IS NULL
+			AND user_id IN (SELECT id FROM users WHERE role=?)`, unixNow(), ShiftEndRole, name); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE users SET on_duty=0, updated_at=? WHERE role=? AND on_duty=1`, unixNow(), name); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetRoleRequire2FA sets whether members of the role, built-in or not, must use two-factor
+// login.
+func (q *Queries) SetRoleRequire2FA(name string, require bool) error {
+	res, err := q.db.Exec(`UPDATE roles SET require_2fa=?, updated_at=? WHERE name=?`, b2i(require), unixNow(), name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteRole removes an unused custom role.
+func (q *Queries) DeleteRole(name string) error {
+	res, err := q.db.Exec(`DELETE FROM roles WHERE name=? AND builtin=0 AND NOT EXISTS (SELECT 1 FROM users WHERE role=?)`, name, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 /* ---------------- Users ---------------- */
 
+// HasAnyAdmin reports whether any account can manage users: ADMIN, or a custom role
+// with users.manage. Until one exists the instance is in onboarding.
 func (q *Queries) HasAnyAdmin() (bool, error) {
-	row := q.db.QueryRow(`SELECT COUNT(1) FROM users WHERE role='ADMIN'`)
+	row := q.rdb.QueryRow(`
+		SELECT COUNT(1) FROM users u JOIN roles r ON r.name=u.role
+		WHERE u.role='ADMIN' OR instr(','||r.permissions||',', ',users.manage,')>0`)
 	var n int
 	if err := row.Scan(&n); err != nil {
 		return false, err
@@ -93,13 +286,13 @@ func (q *Queries) HasAnyAdmin() (bool, error) {
 }
 
 func (q *Queries) GetUserByID(id int64) (*User, error) {
-	row := q.db.QueryRow(`
-		SELECT id,email,password_hash,role,display_name,is_active,on_duty,created_at,updated_at
+	row := q.rdb.QueryRow(`
+		SELECT id,email,password_hash,role,display_name,is_active,on_duty,email_verified,created_at,updated_at
 		FROM users WHERE id=?`, id)
 	var u User
-	var isActive, onDuty int
+	var isActive, onDuty, verified int
 	var ca, ua int64
-	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.DisplayName, &isActive, &onDuty, &ca, &ua); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.DisplayName, &isActive, &onDuty, &verified, &ca, &ua); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -107,19 +300,20 @@ func (q *Queries) GetUserByID(id int64) (*User, error) {
 	}
 	u.IsActive = i2b(isActive)
 	u.OnDuty = i2b(onDuty)
+	u.EmailVerified = i2b(verified)
 	u.CreatedAt = tFromUnix(ca)
 	u.UpdatedAt = tFromUnix(ua)
 	return &u, nil
 }
 
 func (q *Queries) GetUserByEmail(email string) (*User, error) {
-	row := q.db.QueryRow(`
-		SELECT id,email,password_hash,role,display_name,is_active,on_duty,created_at,updated_at
+	row := q.rdb.QueryRow(`
+		SELECT id,email,password_hash,role,display_name,is_active,on_duty,email_verified,created_at,updated_at
 		FROM users WHERE email=?`, email)
 	var u User
-	var isActive, onDuty int
+	var isActive, onDuty, verified int
 	var ca, ua int64
-	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.DisplayName, &isActive, &onDuty, &ca, &ua); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.DisplayName, &isActive, &onDuty, &verified, &ca, &ua); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -127,14 +321,15 @@ func (q *Queries) GetUserByEmail(email string) (*User, error) {
 	}
 	u.IsActive = i2b(isActive)
 	u.OnDuty = i2b(onDuty)
+	u.EmailVerified = i2b(verified)
 	u.CreatedAt = tFromUnix(ca)
 	u.UpdatedAt = tFromUnix(ua)
 	return &u, nil
 }
 
 func (q *Queries) ListUsers() ([]User, error) {
-	rows, err := q.db.Query(`
-		SELECT id,email,password_hash,role,display_name,is_active,on_duty,created_at,updated_at
+	rows, err := q.rdb.Query(`
+		SELECT id,email,password_hash,role,display_name,is_active,on_duty,email_verified,created_at,updated_at
 		FROM users ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, err
@@ -144,13 +339,14 @@ func (q *Queries) ListUsers() ([]User, error) {
 	var out []User
 	for rows.Next() {
 		var u User
-		var isActive, onDuty int
+		var isActive, onDuty, verified int
 		var ca, ua int64
-		if err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.DisplayName, &isActive, &onDuty, &ca, &ua); err != nil {
+		if err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.DisplayName, &isActive, &onDuty, &verified, &ca, &ua); err != nil {
 			return nil, err
 		}
 		u.IsActive = i2b(isActive)
 		u.OnDuty = i2b(onDuty)
+		u.EmailVerified = i2b(verified)
 		u.CreatedAt = tFromUnix(ca)
 		u.UpdatedAt = tFromUnix(ua)
 		out = append(out, u)
@@ -158,21 +354,38 @@ func (q *Queries) ListUsers() ([]User, error) {
 	return out, nil
 }
 
+// CreateUser adds an account; one created on duty starts its first shift.
 func (q *Queries) CreateUser(p CreateUserParams) (int64, error) {
-	res, err := q.db.Exec(`
-		INSERT INTO users(email,password_hash,role,display_name,is_active,on_duty,created_at,updated_at)
-		VALUES(?,?,?,?,?,?,?,?)`,
-		p.Email, p.PasswordHash, p.Role, p.DisplayName, b2i(p.IsActive), b2i(p.OnDuty), unixNow(), unixNow())
+	tx, err := q.db.Begin()
 	if err != nil {
 		return 0, err
 	}
-	return res.LastInsertId()
+	res, err := tx.Exec(`
+		INSERT INTO users(email,password_hash,role,display_name,is_active,on_duty,email_verified,created_at,updated_at)
+		VALUES(?,?,?,?,?,?,?,?,?)`,
+		p.Email, p.PasswordHash, p.Role, p.DisplayName, b2i(p.IsActive), b2i(p.OnDuty), b2i(p.EmailVerified), unixNow(), unixNow())
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if p.OnDuty {
+		if err := openShiftTx(tx, id); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
 }
 
 func (q *Queries) UpdateUser(p UpdateUserParams) error {
 	_, err := q.db.Exec(`
-		UPDATE users SET email=?, role=?, display_name=?, updated_at=? WHERE id=?`,
-		p.Email, p.Role, p.DisplayName, unixNow(), p.ID)
+		UPDATE users SET email=?, role=?, display_name=?, email_verified=?, updated_at=? WHERE id=?`,
+		p.Email, p.Role, p.DisplayName, b2i(p.EmailVerified), unixNow(), p.ID)
 	return err
 }
 
@@ -181,148 +394,1235 @@ func (q *Queries) SetUserPassword(id int64, hash string) error {
 	return err
 }
 
+// UpdateUserProfile changes the fields users may edit on their own account. An email they
+// change is no longer verified.
+func (q *Queries) UpdateUserProfile(id int64, email, displayName string) error {
+	_, err := q.db.Exec(`
+		UPDATE users SET email_verified = CASE WHEN email=? THEN email_verified ELSE 0 END,
+			email=?, display_name=?, updated_at=? WHERE id=?`, email, email, displayName, unixNow(), id)
+	return err
+}
+
 func (q *Queries) SetUserActive(id int64, active bool) error {
 	_, err := q.db.Exec(`UPDATE users SET is_active=?, updated_at=? WHERE id=?`, b2i(active), unixNow(), id)
 	return err
 }
 
-func (q *Queries) SetUserDuty(id int64, onDuty bool) error {
-	_, err := q.db.Exec(`UPDATE users SET on_duty=?, updated_at=? WHERE id=?`, b2i(onDuty), unixNow(), id)
+// SetUserDuty puts the user on or off duty, starting or closing their shift. reason is
+// recorded as why the shift ended and is ignored when going on duty.
+func (q *Queries) SetUserDuty(id int64, onDuty bool, reason string) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET on_duty=?, updated_at=? WHERE id=?`, b2i(onDuty), unixNow(), id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if onDuty {
+		err = openShiftTx(tx, id)
+	} else {
+		_, err = tx.Exec(`UPDATE shifts SET ended_at=?, end_reason=? WHERE user_id=? AND ended_at IS NULL`, unixNow(), reason, id)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// openShiftTx starts a shift for the user unless one is already running.
+func openShiftTx(tx *sql.Tx, userID int64) error {
+	now := unixNow()
+	_, err := tx.Exec(`
+		INSERT INTO shifts(user_id,started_at,last_seen_at) SELECT ?,?,?
+		WHERE NOT EXISTS (SELECT 1 FROM shifts WHERE user_id=? AND ended_at IS NULL)`, userID, now, now, userID)
 	return err
 }
 
-/* ---------------- Products ---------------- */
+/* ---------------- External identities ---------------- */
 
-func (q *Queries) ListProducts(search string) ([]Product, error) {
-	search = strings.TrimSpace(strings.ToLower(search))
-	var rows *sql.Rows
-	var err error
-	if search == "" {
-		rows, err = q.db.Query(fmt.Sprintf(`
-			SELECT
-				p.id,
-				COALESCE(p.name,'') AS name,
-				COALESCE(p.category,'') AS category,
-				COALESCE(p.abv_percent, 0) AS abv_percent,
-				COALESCE(p.allergen_flags,'') AS allergen_flags,
-				COALESCE(p.notes,'') AS notes,
-				COALESCE(p.is_available, 0) AS is_available,
-				p.stock_count,
-				%s AS computed_avail,
-				p.created_at,p.updated_at
-			FROM products p
-			ORDER BY p.category, p.name`, computedAvailExpr()))
-	} else {
-		like := "%" + search + "%"
-		rows, err = q.db.Query(fmt.Sprintf(`
-			SELECT
-				p.id,
-				COALESCE(p.name,'') AS name,
-				COALESCE(p.category,'') AS category,
-				COALESCE(p.abv_percent, 0) AS abv_percent,
-				COALESCE(p.allergen_flags,'') AS allergen_flags,
-				COALESCE(p.notes,'') AS notes,
-				COALESCE(p.is_available, 0) AS is_available,
-				p.stock_count,
-				%s AS computed_avail,
-				p.created_at,p.updated_at
-			FROM products p
-			WHERE lower(p.name) LIKE ? OR lower(p.category) LIKE ?
-			ORDER BY p.category, p.name`, computedAvailExpr()), like, like)
+// GetUserByIdentity returns the account linked to the provider identity, or nil.
+func (q *Queries) GetUserByIdentity(issuer, subject string) (*User, error) {
+	var id int64
+	err := q.rdb.QueryRow(`SELECT user_id FROM user_identities WHERE issuer=? AND subject=?`, issuer, subject).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return q.GetUserByID(id)
+}
 
-	var out []Product
-	for rows.Next() {
-		var p Product
-		var isAvail, comp int
-		var ca, ua int64
-		if err := rows.Scan(&p.ID, &p.Name, &p.Category, &p.ABVPercent, &p.AllergenFlags, &p.Notes, &isAvail, &p.StockCount, &comp, &ca, &ua); err != nil {
-			return nil, err
-		}
-		p.IsAvailable = i2b(isAvail)
-		p.ComputedAvail = i2b(comp)
-		p.CreatedAt = tFromUnix(ca)
-		p.UpdatedAt = tFromUnix(ua)
-		out = append(out, p)
-	}
-	return out, nil
+// LinkIdentity ties a provider identity to the account, or records another login through
+// an existing link.
+func (q *Queries) LinkIdentity(userID int64, issuer, subject, email string) error {
+	_, err := q.db.Exec(`
+		INSERT INTO user_identities(user_id,issuer,subject,email,created_at,last_login_at) VALUES(?,?,?,?,?,?)
+		ON CONFLICT(issuer,subject) DO UPDATE SET email=excluded.email, last_login_at=excluded.last_login_at`,
+		userID, issuer, subject, email, unixNow(), unixNow())
+	return err
 }
 
-func (q *Queries) CreateProduct(p CreateProductParams) (int64, error) {
-	res, err := q.db.Exec(`
-		INSERT INTO products(name,category,abv_percent,allergen_flags,notes,is_available,stock_count,created_at,updated_at)
+// CreateUserWithIdentity provisions an account for a first-time provider login and links
+// it in the same transaction.
+func (q *Queries) CreateUserWithIdentity(p CreateUserParams, issuer, subject string) (int64, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`
+		INSERT INTO users(email,password_hash,role,display_name,is_active,on_duty,email_verified,created_at,updated_at)
 		VALUES(?,?,?,?,?,?,?,?,?)`,
-		p.Name, p.Category, p.ABVPercent, p.AllergenFlags, p.Notes, b2i(p.IsAvailable), p.StockCount, unixNow(), unixNow())
+		p.Email, p.PasswordHash, p.Role, p.DisplayName, b2i(p.IsActive), b2i(p.OnDuty), b2i(p.EmailVerified), unixNow(), unixNow())
 	if err != nil {
+		_ = tx.Rollback()
 		return 0, err
 	}
-	return res.LastInsertId()
+	id, err := res.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO user_identities(user_id,issuer,subject,email,created_at,last_login_at) VALUES(?,?,?,?,?,?)`,
+		id, issuer, subject, p.Email, unixNow(), unixNow()); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if p.OnDuty {
+		if err := openShiftTx(tx, id); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
 }
 
-func (q *Queries) GetProductByID(id int64) (*Product, error) {
-	row := q.db.QueryRow(fmt.Sprintf(`
-		SELECT
-			p.id,
-			COALESCE(p.name,'') AS name,
-			COALESCE(p.category,'') AS category,
-			COALESCE(p.abv_percent, 0) AS abv_percent,
-			COALESCE(p.allergen_flags,'') AS allergen_flags,
-			COALESCE(p.notes,'') AS notes,
-			COALESCE(p.is_available, 0) AS is_available,
-			p.stock_count,
-			%s AS computed_avail,
-			p.created_at,p.updated_at
-		FROM products p
-		WHERE p.id=?`, computedAvailExpr()), id)
+/* ---------------- Password resets ---------------- */
 
-	var p Product
-	var isAvail, comp int
-	var ca, ua int64
-	if err := row.Scan(&p.ID, &p.Name, &p.Category, &p.ABVPercent, &p.AllergenFlags, &p.Notes, &isAvail, &p.StockCount, &comp, &ca, &ua); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+// CreatePasswordReset stores a new reset code for the user and drops any earlier unused
+// ones, so only the latest code handed out works.
+func (q *Queries) CreatePasswordReset(userID int64, tokenHash string, expiresAt time.Time, createdByID int64) error {
+	var createdBy any
+	if createdByID > 0 {
+		createdBy = createdByID
+	}
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM password_resets WHERE user_id=? AND used_at IS NULL`, userID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO password_resets(user_id,token_hash,expires_at,created_by_user_id,created_at)
+		VALUES(?,?,?,?,?)`, userID, tokenHash, expiresAt.Unix(), createdBy, unixNow()); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPasswordReset returns the reset for tokenHash if it can still be used, or nil.
+func (q *Queries) GetPasswordReset(tokenHash string) (*PasswordReset, error) {
+	var pr PasswordReset
+	var ea int64
+	err := q.rdb.QueryRow(`
+		SELECT r.id,r.user_id,u.display_name,r.expires_at
+		FROM password_resets r JOIN users u ON u.id=r.user_id
+		WHERE r.token_hash=? AND r.used_at IS NULL AND r.expires_at>? AND u.is_active=1`,
+		tokenHash, unixNow()).Scan(&pr.ID, &pr.UserID, &pr.DisplayName, &ea)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
 		return nil, err
 	}
-	p.IsAvailable = i2b(isAvail)
-	p.ComputedAvail = i2b(comp)
-	p.CreatedAt = tFromUnix(ca)
-	p.UpdatedAt = tFromUnix(ua)
-	return &p, nil
+	pr.ExpiresAt = tFromUnix(ea)
+	return &pr, nil
 }
 
-func (q *Queries) UpdateProduct(p UpdateProductParams) error {
-	_, err := q.db.Exec(`
-		UPDATE products
-		SET name=?, category=?, abv_percent=?, allergen_flags=?, notes=?, is_available=?, stock_count=?, updated_at=?
-		WHERE id=?`,
-		p.Name, p.Category, p.ABVPercent, p.AllergenFlags, p.Notes, b2i(p.IsAvailable), p.StockCount, unixNow(), p.ID)
-	return err
+// ConsumePasswordReset marks the code used and sets the new password in one transaction,
+// returning the account id. A code can only ever be consumed once.
+func (q *Queries) ConsumePasswordReset(tokenHash, passwordHash string) (int64, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	now := unixNow()
+	var id, userID int64
+	err = tx.QueryRow(`
+		SELECT r.id,r.user_id FROM password_resets r JOIN users u ON u.id=r.user_id
+		WHERE r.token_hash=? AND r.used_at IS NULL AND r.expires_at>? AND u.is_active=1`,
+		tokenHash, now).Scan(&id, &userID)
+	if err == sql.ErrNoRows {
+		_ = tx.Rollback()
+		return 0, ErrResetUnavailable
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE password_resets SET used_at=? WHERE id=?`, now, id); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE users SET password_hash=?, updated_at=? WHERE id=?`, passwordHash, now, userID); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	return userID, tx.Commit()
 }
 
-func (q *Queries) DeleteProduct(id int64) error {
-	_, err := q.db.Exec(`DELETE FROM products WHERE id=?`, id)
-	return err
+/* ---------------- Two-factor login ---------------- */
+
+// GetTOTP returns the user's enrollment, pending or confirmed, or nil.
+func (q *Queries) GetTOTP(userID int64) (*TOTP, error) {
+	var t TOTP
+	var confirmed, lastFailure sql.NullInt64
+	err := q.rdb.QueryRow(`
+		SELECT t.user_id,t.secret,t.confirmed_at,t.last_step,t.failures,t.last_failure_at,
+			(SELECT COUNT(*) FROM recovery_codes c WHERE c.user_id=t.user_id AND c.used_at IS NULL)
+		FROM user_totp t WHERE t.user_id=?`, userID).
+		Scan(&t.UserID, &t.Secret, &confirmed, &t.LastStep, &t.Failures, &lastFailure, &t.RecoveryLeft)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if confirmed.Valid {
+		t.Confirmed = true
+		t.ConfirmedAt = tFromUnix(confirmed.Int64)
+	}
+	if lastFailure.Valid {
+		t.LastFailureAt = tFromUnix(lastFailure.Int64)
+	}
+	return &t, nil
 }
 
-func (q *Queries) ToggleProductAvailability(id int64, avail bool) error {
-	_, err := q.db.Exec(`UPDATE products SET is_available=?, updated_at=? WHERE id=?`, b2i(avail), unixNow(), id)
-	return err
+// HasTOTP reports whether the user has a confirmed enrollment.
+func (q *Queries) HasTOTP(userID int64) (bool, error) {
+	var n int
+	err := q.rdb.QueryRow(`SELECT COUNT(*) FROM user_totp WHERE user_id=? AND confirmed_at IS NOT NULL`, userID).Scan(&n)
+	return n > 0, err
 }
 
-func (q *Queries) SetProductStock(id int64, stock *int64) error {
-	_, err := q.db.Exec(`UPDATE products SET stock_count=?, updated_at=? WHERE id=?`, stock, unixNow(), id)
-	return err
+// ListTOTPUserIDs returns the ids of users with a confirmed enrollment.
+func (q *Queries) ListTOTPUserIDs() (map[int64]bool, error) {
+	rows, err := q.rdb.Query(`SELECT user_id FROM user_totp WHERE confirmed_at IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out[id] = true
+	}
+	return out, rows.Err()
 }
 
-/* ---------------- Cocktails ---------------- */
+// StartTOTP stores a pending secret, replacing an earlier unfinished one. A confirmed
+// enrollment is left alone.
+func (q *Queries) StartTOTP(userID int64, secret string) error {
+	_, err := q.db.Exec(`
+		INSERT INTO user_totp(user_id,secret,created_at) VALUES(?,?,?)
+		ON CONFLICT(user_id) DO UPDATE SET secret=excluded.secret, created_at=excluded.created_at
+		WHERE user_totp.confirmed_at IS NULL`, userID, secret, unixNow())
+	return err
+}
 
-func (q *Queries) GetCocktailByID(id int64) (*Cocktail, error) {
-	row := q.db.QueryRow(`
+// ConfirmTOTP finishes enrollment with the step of the first code and stores a fresh set
+// of recovery codes.
+func (q *Queries) ConfirmTOTP(userID, step int64, recoveryHashes []string) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`
+		UPDATE user_totp SET confirmed_at=?, last_step=?, failures=0, last_failure_at=NULL
+		WHERE user_id=? AND confirmed_at IS NULL`, unixNow(), step, userID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return sql.ErrNoRows
+	}
+	if err := replaceRecoveryCodes(tx, userID, recoveryHashes); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes, used or not, for a new set.
+func (q *Queries) ReplaceRecoveryCodes(userID int64, hashes []string) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, hashes); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int64, hashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id=?`, userID); err != nil {
+		return err
+	}
+	now := unixNow()
+	for _, h := range hashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes(user_id,code_hash,created_at) VALUES(?,?,?)`, userID, h, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AcceptTOTPStep records a matched code's step. It reports false when that step or a later
+// one was already accepted, so a code cannot be replayed.
+func (q *Queries) AcceptTOTPStep(userID, step int64) (bool, error) {
+	res, err := q.db.Exec(`
+		UPDATE user_totp SET last_step=?, failures=0, last_failure_at=NULL
+		WHERE user_id=? AND confirmed_at IS NOT NULL AND last_step<?`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// UseRecoveryCode marks one of the user's unused recovery codes used. It reports false
+// when none matches.
+func (q *Queries) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`
+		UPDATE recovery_codes SET used_at=?
+		WHERE id=(SELECT id FROM recovery_codes WHERE user_id=? AND code_hash=? AND used_at IS NULL LIMIT 1)`,
+		unixNow(), userID, codeHash)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return false, nil
+	}
+	if _, err := tx.Exec(`UPDATE user_totp SET failures=0, last_failure_at=NULL WHERE user_id=?`, userID); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// RecordTOTPFailure counts a wrong code, for throttling guesses.
+func (q *Queries) RecordTOTPFailure(userID int64) error {
+	_, err := q.db.Exec(`UPDATE user_totp SET failures=failures+1, last_failure_at=? WHERE user_id=?`, unixNow(), userID)
+	return err
+}
+
+// DeleteTOTP turns two-factor login off for the user, dropping the recovery codes too.
+func (q *Queries) DeleteTOTP(userID int64) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id=?`, userID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id=?`, userID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+/* ---------------- Invites ---------------- */
+
+const inviteSelect = `
+	SELECT i.id,i.token,i.event,i.role,i.max_uses,i.uses,i.passwordless,i.expires_at,i.revoked_at,
+		COALESCE(u.display_name,''),i.created_at
+	FROM invites i
+	LEFT JOIN users u ON u.id=i.created_by_user_id`
+
+func scanInvite(scanner rowScanner) (*Invite, error) {
+	var inv Invite
+	var passwordless int
+	var ea, ca int64
+	var ra sql.NullInt64
+	if err := scanner.Scan(&inv.ID, &inv.Token, &inv.Event, &inv.Role, &inv.MaxUses, &inv.Uses, &passwordless,
+		&ea, &ra, &inv.CreatedByName, &ca); err != nil {
+		return nil, err
+	}
+	inv.Passwordless = i2b(passwordless)
+	inv.ExpiresAt = tFromUnix(ea)
+	inv.CreatedAt = tFromUnix(ca)
+	if ra.Valid {
+		inv.RevokedAt = tFromUnix(ra.Int64)
+	}
+	return &inv, nil
+}
+
+func (q *Queries) CreateInvite(p CreateInviteParams) (int64, error) {
+	var createdBy any
+	if p.CreatedByID > 0 {
+		createdBy = p.CreatedByID
+	}
+	res, err := q.db.Exec(`
+		INSERT INTO invites(token,event,role,max_uses,passwordless,expires_at,created_by_user_id,created_at)
+		VALUES(?,?,?,?,?,?,?,?)`,
+		p.Token, p.Event, p.Role, p.MaxUses, b2i(p.Passwordless), p.ExpiresAt.Unix(), createdBy, unixNow())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListInvites returns every invite, newest first.
+func (q *Queries) ListInvites() ([]Invite, error) {
+	rows, err := q.rdb.Query(inviteSelect + ` ORDER BY i.created_at DESC, i.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Invite
+	for rows.Next() {
+		inv, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *inv)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) GetInvite(id int64) (*Invite, error) {
+	inv, err := scanInvite(q.rdb.QueryRow(inviteSelect+` WHERE i.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return inv, err
+}
+
+func (q *Queries) GetInviteByToken(token string) (*Invite, error) {
+	inv, err := scanInvite(q.rdb.QueryRow(inviteSelect+` WHERE i.token=?`, token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return inv, err
+}
+
+func (q *Queries) RevokeInvite(id int64) error {
+	_, err := q.db.Exec(`UPDATE invites SET revoked_at=? WHERE id=? AND revoked_at IS NULL`, unixNow(), id)
+	return err
+}
+
+// RedeemInvite claims one use of the invite and creates the guest's account with the
+// invite's role, in one transaction. Two guests racing for the last use cannot both win.
+func (q *Queries) RedeemInvite(token, email, passwordHash, displayName string) (int64, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	now := unixNow()
+	res, err := tx.Exec(`
+		UPDATE invites SET uses=uses+1
+		WHERE token=? AND revoked_at IS NULL AND expires_at>? AND (max_uses=0 OR uses<max_uses)`, token, now)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return 0, ErrInviteUnavailable
+	}
+	res, err = tx.Exec(`
+		INSERT INTO users(email,password_hash,role,display_name,is_active,on_duty,created_at,updated_at,invite_id)
+		SELECT ?,?,role,?,1,0,?,?,id FROM invites WHERE token=?`,
+		email, passwordHash, displayName, now, now, token)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+/* ---------------- Kiosks ---------------- */
+
+const kioskSelect = `
+	SELECT k.id,k.name,k.pair_expires_at,k.paired_at,k.last_seen_at,k.revoked_at,
+		COALESCE(u.display_name,''),k.created_at
+	FROM kiosks k
+	LEFT JOIN users u ON u.id=k.created_by_user_id`
+
+func scanKiosk(scanner rowScanner) (*Kiosk, error) {
+	var k Kiosk
+	var pea, ca int64
+	var pa, lsa, ra sql.NullInt64
+	if err := scanner.Scan(&k.ID, &k.Name, &pea, &pa, &lsa, &ra, &k.CreatedByName, &ca); err != nil {
+		return nil, err
+	}
+	k.PairExpiresAt = tFromUnix(pea)
+	k.CreatedAt = tFromUnix(ca)
+	if pa.Valid {
+		k.PairedAt = tFromUnix(pa.Int64)
+	}
+	if lsa.Valid {
+		k.LastSeenAt = tFromUnix(lsa.Int64)
+	}
+	if ra.Valid {
+		k.RevokedAt = tFromUnix(ra.Int64)
+	}
+	return &k, nil
+}
+
+// CreateKiosk registers a kiosk waiting to be paired through the link behind pairHash.
+func (q *Queries) CreateKiosk(name, pairHash string, pairExpiresAt time.Time, createdByID int64) (int64, error) {
+	var createdBy any
+	if createdByID > 0 {
+		createdBy = createdByID
+	}
+	res, err := q.db.Exec(`
+		INSERT INTO kiosks(name,pair_hash,pair_expires_at,created_by_user_id,created_at)
+		VALUES(?,?,?,?,?)`, name, pairHash, pairExpiresAt.Unix(), createdBy, unixNow())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListKiosks returns every kiosk, newest first.
+func (q *Queries) ListKiosks() ([]Kiosk, error) {
+	rows, err := q.rdb.Query(kioskSelect + ` ORDER BY k.created_at DESC, k.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Kiosk
+	for rows.Next() {
+		k, err := scanKiosk(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *k)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) GetKiosk(id int64) (*Kiosk, error) {
+	k, err := scanKiosk(q.rdb.QueryRow(kioskSelect+` WHERE k.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// GetKioskByPairing returns the kiosk whose pairing link is still usable, or nil.
+func (q *Queries) GetKioskByPairing(pairHash string) (*Kiosk, error) {
+	k, err := scanKiosk(q.rdb.QueryRow(kioskSelect+`
+		WHERE k.pair_hash=? AND k.pair_expires_at>? AND k.paired_at IS NULL AND k.revoked_at IS NULL`, pairHash, unixNow()))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// GetKioskByToken returns the paired, unrevoked kiosk holding the device token, or nil.
+func (q *Queries) GetKioskByToken(tokenHash string) (*Kiosk, error) {
+	k, err := scanKiosk(q.rdb.QueryRow(kioskSelect+`
+		WHERE k.token_hash=? AND k.paired_at IS NOT NULL AND k.revoked_at IS NULL`, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// PairKiosk uses up the pairing link and binds the kiosk to the device token. A link pairs
+// one device only.
+func (q *Queries) PairKiosk(pairHash, tokenHash string) (int64, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	now := unixNow()
+	var id int64
+	err = tx.QueryRow(`
+		SELECT id FROM kiosks
+		WHERE pair_hash=? AND pair_expires_at>? AND paired_at IS NULL AND revoked_at IS NULL`, pairHash, now).Scan(&id)
+	if err == sql.ErrNoRows {
+		_ = tx.Rollback()
+		return 0, ErrKioskUnavailable
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE kiosks SET pair_hash=NULL, token_hash=?, paired_at=?, last_seen_at=? WHERE id=?`,
+		tokenHash, now, now, id); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (q *Queries) TouchKiosk(id int64) error {
+	_, err := q.db.Exec(`UPDATE kiosks SET last_seen_at=? WHERE id=?`, unixNow(), id)
+	return err
+}
+
+// RevokeKiosk retires the kiosk; its device token and any pairing link stop working.
+func (q *Queries) RevokeKiosk(id int64) error {
+	_, err := q.db.Exec(`UPDATE kiosks SET revoked_at=?, pair_hash=NULL WHERE id=? AND revoked_at IS NULL`, unixNow(), id)
+	return err
+}
+
+// ListKioskGuests returns the active accounts, by name, with whether each has a PIN.
+// Callers narrow it down to the roles a kiosk may switch to.
+func (q *Queries) ListKioskGuests() ([]KioskGuest, error) {
+	rows, err := q.rdb.Query(`
+		SELECT u.id,u.display_name,u.role,p.user_id IS NOT NULL
+		FROM users u LEFT JOIN kiosk_pins p ON p.user_id=u.id
+		WHERE u.is_active=1 AND u.id NOT IN (SELECT user_id FROM walkup_guests)
+		ORDER BY u.display_name COLLATE NOCASE, u.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []KioskGuest
+	for rows.Next() {
+		var g KioskGuest
+		var hasPIN int
+		if err := rows.Scan(&g.ID, &g.DisplayName, &g.Role, &hasPIN); err != nil {
+			return nil, err
+		}
+		g.HasPIN = i2b(hasPIN)
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// GetKioskPIN returns the user's PIN, or nil when they have none.
+func (q *Queries) GetKioskPIN(userID int64) (*KioskPIN, error) {
+	var p KioskPIN
+	var lastFailure sql.NullInt64
+	err := q.rdb.QueryRow(`SELECT user_id,pin_hash,failures,last_failure_at FROM kiosk_pins WHERE user_id=?`, userID).
+		Scan(&p.UserID, &p.Hash, &p.Failures, &lastFailure)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastFailure.Valid {
+		p.LastFailureAt = tFromUnix(lastFailure.Int64)
+	}
+	return &p, nil
+}
+
+func (q *Queries) SetKioskPIN(userID int64, pinHash string) error {
+	_, err := q.db.Exec(`
+		INSERT INTO kiosk_pins(user_id,pin_hash,updated_at) VALUES(?,?,?)
+		ON CONFLICT(user_id) DO UPDATE SET pin_hash=excluded.pin_hash, failures=0, last_failure_at=NULL, updated_at=excluded.updated_at`,
+		userID, pinHash, unixNow())
+	return err
+}
+
+func (q *Queries) DeleteKioskPIN(userID int64) error {
+	_, err := q.db.Exec(`DELETE FROM kiosk_pins WHERE user_id=?`, userID)
+	return err
+}
+
+// TakeKioskPINAttempt counts a try at the user's PIN as wrong before it is checked, unless
+// maxFailures wrong tries in a row, the last within lockout, have locked it; then it
+// returns false. The check and the count are one statement, so tries made at once can't
+// get past the limit. ClearKioskPINFailures undoes the count when the PIN was right.
+func (q *Queries) TakeKioskPINAttempt(userID int64, maxFailures int, lockout time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := q.db.Exec(`
+		UPDATE kiosk_pins SET failures=failures+1, last_failure_at=?
+		WHERE user_id=? AND NOT (failures >= ? AND COALESCE(last_failure_at, 0) > ?)`,
+		now.Unix(), userID, maxFailures, now.Add(-lockout).Unix())
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (q *Queries) ClearKioskPINFailures(userID int64) error {
+	_, err := q.db.Exec(`UPDATE kiosk_pins SET failures=0, last_failure_at=NULL WHERE user_id=?`, userID)
+	return err
+}
+
+/* ---------------- Walk-up ordering ---------------- */
+
+const walkupSelect = `
+	SELECT e.id,e.name,e.role,e.ends_at,e.closed_at,
+		(SELECT COUNT(*) FROM walkup_guests g WHERE g.event_id=e.id),
+		COALESCE(u.display_name,''),e.created_at
+	FROM walkup_events e
+	LEFT JOIN users u ON u.id=e.created_by_user_id`
+
+func scanWalkupEvent(scanner rowScanner) (*WalkupEvent, error) {
+	var e WalkupEvent
+	var ea, ca int64
+	var cla sql.NullInt64
+	if err := scanner.Scan(&e.ID, &e.Name, &e.Role, &ea, &cla, &e.Guests, &e.CreatedByName, &ca); err != nil {
+		return nil, err
+	}
+	e.EndsAt = tFromUnix(ea)
+	e.CreatedAt = tFromUnix(ca)
+	if cla.Valid {
+		e.ClosedAt = tFromUnix(cla.Int64)
+	}
+	return &e, nil
+}
+
+// OpenWalkupEvent opens walk-up ordering until endsAt. Only one event is open at a time,
+// so one still open is closed first.
+func (q *Queries) OpenWalkupEvent(name, role string, endsAt time.Time, createdByID int64) (int64, error) {
+	var createdBy any
+	if createdByID > 0 {
+		createdBy = createdByID
+	}
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	now := unixNow()
+	if _, err := tx.Exec(`UPDATE walkup_events SET closed_at=? WHERE closed_at IS NULL AND ends_at>?`, now, now); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	res, err := tx.Exec(`
+		INSERT INTO walkup_events(name,role,ends_at,created_by_user_id,created_at)
+		VALUES(?,?,?,?,?)`, name, role, endsAt.Unix(), createdBy, now)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// CurrentWalkupEvent returns the open event, or nil when walk-up ordering is off.
+func (q *Queries) CurrentWalkupEvent() (*WalkupEvent, error) {
+	e, err := scanWalkupEvent(q.rdb.QueryRow(walkupSelect+`
+		WHERE e.closed_at IS NULL AND e.ends_at>? ORDER BY e.id DESC LIMIT 1`, unixNow()))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// ListWalkupEvents returns the most recent events, newest first.
+func (q *Queries) ListWalkupEvents(limit int) ([]WalkupEvent, error) {
+	rows, err := q.rdb.Query(walkupSelect+` ORDER BY e.created_at DESC, e.id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WalkupEvent
+	for rows.Next() {
+		e, err := scanWalkupEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *e)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) GetWalkupEvent(id int64) (*WalkupEvent, error) {
+	e, err := scanWalkupEvent(q.rdb.QueryRow(walkupSelect+` WHERE e.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// SetWalkupEventEnd moves the end of an open event.
+func (q *Queries) SetWalkupEventEnd(id int64, endsAt time.Time) error {
+	res, err := q.db.Exec(`UPDATE walkup_events SET ends_at=? WHERE id=? AND closed_at IS NULL AND ends_at>?`,
+		endsAt.Unix(), id, unixNow())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWalkupClosed
+	}
+	return nil
+}
+
+// CloseWalkupEvent ends walk-up ordering early. Its guests are switched off by the next
+// ExpireWalkupGuests.
+func (q *Queries) CloseWalkupEvent(id int64) error {
+	_, err := q.db.Exec(`UPDATE walkup_events SET closed_at=? WHERE id=? AND closed_at IS NULL`, unixNow(), id)
+	return err
+}
+
+// JoinWalkup creates a name-only account in the event's role, as long as the event is
+// still open. The account has no password, so the signed walk-up cookie is its only way in.
+func (q *Queries) JoinWalkup(eventID int64, email, displayName, table string) (int64, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	now := unixNow()
+	res, err := tx.Exec(`
+		INSERT INTO users(email,password_hash,role,display_name,is_active,on_duty,created_at,updated_at)
+		SELECT ?,'',role,?,1,0,?,? FROM walkup_events WHERE id=? AND closed_at IS NULL AND ends_at>?`,
+		email, displayName, now, now, eventID, now)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return 0, ErrWalkupClosed
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.Exec(`INSERT INTO walkup_guests(user_id,event_id,table_label,created_at) VALUES(?,?,?,?)`,
+		id, eventID, table, now); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// GetWalkupGuest returns the walk-up record of a user, or nil for ordinary accounts.
+func (q *Queries) GetWalkupGuest(userID int64) (*WalkupGuest, error) {
+	var g WalkupGuest
+	var ea int64
+	var cla sql.NullInt64
+	err := q.rdb.QueryRow(`
+		SELECT g.user_id,g.table_label,e.id,e.name,e.role,e.ends_at,e.closed_at
+		FROM walkup_guests g JOIN walkup_events e ON e.id=g.event_id
+		WHERE g.user_id=?`, userID).Scan(&g.UserID, &g.Table, &g.Event.ID, &g.Event.Name, &g.Event.Role, &ea, &cla)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.Event.EndsAt = tFromUnix(ea)
+	if cla.Valid {
+		g.Event.ClosedAt = tFromUnix(cla.Int64)
+	}
+	return &g, nil
+}
+
+// ExpireWalkupGuests deactivates the guests of events that had ended or were closed by now.
+// The accounts and their orders are kept for history and reports.
+func (q *Queries) ExpireWalkupGuests(now time.Time) (int64, error) {
+	res, err := q.db.Exec(`
+		UPDATE users SET is_active=0, updated_at=?
+		WHERE is_active=1 AND id IN (
+			SELECT g.user_id FROM walkup_guests g JOIN walkup_events e ON e.id=g.event_id
+			WHERE e.closed_at IS NOT NULL OR e.ends_at<=?)`, now.Unix(), now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+/* ---------------- Products ---------------- */
+
+// productSelect is the shared column list for product reads; callers append joins,
+// filters and ordering after "FROM products p".
+func productSelect() string {
+	return fmt.Sprintf(`
+		SELECT
+			p.id,
+			COALESCE(p.name,'') AS name,
+			COALESCE(p.category,'') AS category,
+			COALESCE(p.abv_percent, 0) AS abv_percent,
+			COALESCE(p.allergen_flags,'') AS allergen_flags,
+			COALESCE(p.notes,'') AS notes,
+			COALESCE(p.is_available, 0) AS is_available,
+			p.stock_count,
+			p.par_level,
+			p.reorder_level,
+			COALESCE(p.barcode,'') AS barcode,
+			%s AS computed_avail,
+			p.created_at,p.updated_at
+		FROM products p`, computedAvailExpr())
+}
+
+// ListProducts returns every product, or with a search only the matching ones, best match first.
+func (q *Queries) ListProducts(search string) ([]Product, error) {
+	search = strings.TrimSpace(search)
+	var rows *sql.Rows
+	var err error
+	if search == "" {
+		rows, err = q.rdb.Query(productSelect() + ` ORDER BY p.category, p.name`)
+	} else {
+		rows, err = q.searchProducts(search)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Product
+	for rows.Next() {
+		var p Product
+		var isAvail, comp int
+		var ca, ua int64
+		if err := rows.Scan(&p.ID, &p.Name, &p.Category, &p.ABVPercent, &p.AllergenFlags, &p.Notes, &isAvail, &p.StockCount, &p.ParLevel, &p.ReorderLevel, &p.Barcode, &comp, &ca, &ua); err != nil {
+			return nil, err
+		}
+		p.IsAvailable = i2b(isAvail)
+		p.ComputedAvail = i2b(comp)
+		p.CreatedAt = tFromUnix(ca)
+		p.UpdatedAt = tFromUnix(ua)
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (q *Queries) CreateProduct(p CreateProductParams) (int64, error) {
+	res, err := q.db.Exec(`
+		INSERT INTO products(name,category,abv_percent,allergen_flags,notes,is_available,stock_count,par_level,reorder_level,barcode,created_at,updated_at)
+		VALUES(?,?,?,?,?,?,?,?,?,?,?,?)`,
+		p.Name, p.Category, p.ABVPercent, p.AllergenFlags, p.Notes, b2i(p.IsAvailable), p.StockCount, p.ParLevel, p.ReorderLevel, nullString(p.Barcode), unixNow(), unixNow())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (q *Queries) GetProductByID(id int64) (*Product, error) {
+	row := q.rdb.QueryRow(fmt.Sprintf(`
+		SELECT
+			p.id,
+			COALESCE(p.name,'') AS name,
+			COALESCE(p.category,'') AS category,
+			COALESCE(p.abv_percent, 0) AS abv_percent,
+			COALESCE(p.allergen_flags,'') AS allergen_flags,
+			COALESCE(p.notes,'') AS notes,
+			COALESCE(p.is_available, 0) AS is_available,
+			p.stock_count,
+			p.par_level,
+			p.reorder_level,
+			COALESCE(p.barcode,'') AS barcode,
+			%s AS computed_avail,
+			p.created_at,p.updated_at
+		FROM products p
+		WHERE p.id=?`, computedAvailExpr()), id)
+
+	var p Product
+	var isAvail, comp int
+	var ca, ua int64
+	if err := row.Scan(&p.ID, &p.Name, &p.Category, &p.ABVPercent, &p.AllergenFlags, &p.Notes, &isAvail, &p.StockCount, &p.ParLevel, &p.ReorderLevel, &p.Barcode, &comp, &ca, &ua); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	p.IsAvailable = i2b(isAvail)
+	p.ComputedAvail = i2b(comp)
+	p.CreatedAt = tFromUnix(ca)
+	p.UpdatedAt = tFromUnix(ua)
+	return &p, nil
+}
+
+// GetProductByBarcode returns (nil, nil) when no product carries the code.
+func (q *Queries) GetProductByBarcode(code string) (*Product, error) {
+	var id int64
+	err := q.rdb.QueryRow(`SELECT id FROM products WHERE barcode=?`, code).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q.GetProductByID(id)
+}
+
+// AdjustProductStock adds delta to a product's stock, starting untracked products at zero
+// and never going below zero.
+func (q *Queries) AdjustProductStock(id int64, delta int64) error {
+	_, err := q.db.Exec(`
+		UPDATE products SET stock_count=MAX(COALESCE(stock_count,0)+?, 0), updated_at=?
+		WHERE id=?`, delta, unixNow(), id)
+	return err
+}
+
+func (q *Queries) UpdateProduct(p UpdateProductParams) error {
+	_, err := q.db.Exec(`
+		UPDATE products
+		SET name=?, category=?, abv_percent=?, allergen_flags=?, notes=?, is_available=?, stock_count=?, par_level=?, reorder_level=?, barcode=?, updated_at=?
+		WHERE id=?`,
+		p.Name, p.Category, p.ABVPercent, p.AllergenFlags, p.Notes, b2i(p.IsAvailable), p.StockCount, p.ParLevel, p.ReorderLevel, nullString(p.Barcode), unixNow(), p.ID)
+	return err
+}
+
+func (q *Queries) DeleteProduct(id int64) error {
+	_, err := q.db.Exec(`DELETE FROM products WHERE id=?`, id)
+	return err
+}
+
+func (q *Queries) ToggleProductAvailability(id int64, avail bool) error {
+	_, err := q.db.Exec(`UPDATE products SET is_available=?, updated_at=? WHERE id=?`, b2i(avail), unixNow(), id)
+	return err
+}
+
+func (q *Queries) SetProductStock(id int64, stock *int64) error {
+	_, err := q.db.Exec(`UPDATE products SET stock_count=?, updated_at=? WHERE id=?`, stock, unixNow(), id)
+	return err
+}
+
+/* ---------------- Low stock ---------------- */
+
+func (q *Queries) RecordLowStockEvent(productID int64, stock int64, reorderLevel int64) error {
+	_, err := q.db.Exec(`
+		INSERT INTO low_stock_events(product_id,stock_count,reorder_level,created_at)
+		VALUES(?,?,?,?)`, productID, stock, reorderLevel, unixNow())
+	return err
+}
+
+// ListLowStockEvents returns reorder-level crossings in [from, to), newest first.
+func (q *Queries) ListLowStockEvents(from, to time.Time) ([]LowStockEvent, error) {
+	rows, err := q.rdb.Query(`
+		SELECT e.id,e.product_id,COALESCE(p.name,''),COALESCE(p.category,''),e.stock_count,e.reorder_level,e.created_at
+		FROM low_stock_events e
+		JOIN products p ON p.id = e.product_id
+		WHERE e.created_at >= ? AND e.created_at < ?
+		ORDER BY e.created_at DESC, e.id DESC`, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []LowStockEvent
+	for rows.Next() {
+		var e LowStockEvent
+		var ca int64
+		if err := rows.Scan(&e.ID, &e.ProductID, &e.ProductName, &e.ProductCategory, &e.StockCount, &e.ReorderLevel, &ca); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = tFromUnix(ca)
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+/* ---------------- Stocktakes ---------------- */
+
+const stocktakeSelect = `
+	SELECT
+		s.id,s.status,COALESCE(s.note,''),
+		COALESCE(uc.display_name,''),COALESCE(um.display_name,''),
+		s.created_at,s.updated_at,s.committed_at,
+		(SELECT COUNT(*) FROM stocktake_lines l WHERE l.stocktake_id=s.id),
+		(SELECT COALESCE(SUM(l.counted - l.expected),0) FROM stocktake_lines l WHERE l.stocktake_id=s.id AND l.expected IS NOT NULL),
+		(SELECT COALESCE(SUM(ABS(l.counted - l.expected)),0) FROM stocktake_lines l WHERE l.stocktake_id=s.id AND l.expected IS NOT NULL)
+	FROM stocktakes s
+	LEFT JOIN users uc ON uc.id=s.created_by_user_id
+	LEFT JOIN users um ON um.id=s.committed_by_user_id`
+
+func scanStocktake(scanner rowScanner) (*Stocktake, error) {
+	var st Stocktake
+	var ca, ua int64
+	var cm sql.NullInt64
+	if err := scanner.Scan(&st.ID, &st.Status, &st.Note, &st.CreatedByName, &st.CommittedByName, &ca, &ua, &cm,
+		&st.LineCount, &st.NetVariance, &st.AbsVariance); err != nil {
+		return nil, err
+	}
+	st.CreatedAt = tFromUnix(ca)
+	st.UpdatedAt = tFromUnix(ua)
+	if cm.Valid {
+		st.CommittedAt = tFromUnix(cm.Int64)
+	}
+	return &st, nil
+}
+
+func (q *Queries) CreateStocktake(userID int64, note string) (int64, error) {
+	res, err := q.db.Exec(`
+		INSERT INTO stocktakes(status,note,created_by_user_id,created_at,updated_at)
+		VALUES(?,?,?,?,?)`, StocktakeDraft, note, userID, unixNow(), unixNow())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (q *Queries) GetStocktake(id int64) (*Stocktake, error) {
+	st, err := scanStocktake(q.rdb.QueryRow(stocktakeSelect+` WHERE s.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return st, err
+}
+
+// ListStocktakes returns open drafts first, then committed stocktakes newest first.
+func (q *Queries) ListStocktakes() ([]Stocktake, error) {
+	rows, err := q.rdb.Query(stocktakeSelect + `
+		ORDER BY CASE WHEN s.status='DRAFT' THEN 0 ELSE 1 END, COALESCE(s.committed_at, s.created_at) DESC, s.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Stocktake
+	for rows.Next() {
+		st, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *st)
+	}
+	return out, nil
+}
+
+func (q *Queries) ListStocktakeLines(stocktakeID int64) ([]StocktakeLine, error) {
+	rows, err := q.rdb.Query(`
+		SELECT
+			l.id,l.stocktake_id,COALESCE(l.product_id,0),
+			CASE WHEN s.status='DRAFT' THEN COALESCE(p.name,l.product_name) ELSE l.product_name END AS name,
+			CASE WHEN s.status='DRAFT' THEN COALESCE(p.category,l.product_category) ELSE l.product_category END AS category,
+			l.counted,
+			CASE WHEN s.status='DRAFT' THEN p.stock_count ELSE l.expected END
+		FROM stocktake_lines l
+		JOIN stocktakes s ON s.id=l.stocktake_id
+		LEFT JOIN products p ON p.id=l.product_id
+		WHERE l.stocktake_id=?
+		ORDER BY category, name`, stocktakeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StocktakeLine
+	for rows.Next() {
+		var l StocktakeLine
+		var exp sql.NullInt64
+		if err := rows.Scan(&l.ID, &l.StocktakeID, &l.ProductID, &l.ProductName, &l.ProductCategory, &l.Counted, &exp); err != nil {
+			return nil, err
+		}
+		if exp.Valid {
+			l.Expected = &exp.Int64
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// SaveStocktakeCounts replaces the counted lines of a draft.
+func (q *Queries) SaveStocktakeCounts(stocktakeID int64, counts []StocktakeCount) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`UPDATE stocktakes SET updated_at=? WHERE id=? AND status=?`, unixNow(), stocktakeID, StocktakeDraft)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return ErrStocktakeNotDraft
+	}
+	if _, err := tx.Exec(`DELETE FROM stocktake_lines WHERE stocktake_id=?`, stocktakeID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	for _, c := range counts {
+		if _, err := tx.Exec(`
+			INSERT INTO stocktake_lines(stocktake_id,product_id,product_name,product_category,counted,expected)
+			SELECT ?,id,COALESCE(name,''),COALESCE(category,''),?,NULL FROM products WHERE id=?`, stocktakeID, c.Counted, c.ProductID); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CommitStocktake snapshots expected stock and product names for every line and applies
+// the counts in one transaction, so a failed commit leaves both the draft and inventory
+// untouched. Counts of products deleted since they were saved are dropped.
+func (q *Queries) CommitStocktake(stocktakeID, userID int64) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	now := unixNow()
+	res, err := tx.Exec(`
+		UPDATE stocktakes SET status=?, committed_by_user_id=?, committed_at=?, updated_at=?
+		WHERE id=? AND status=?`, StocktakeCommitted, userID, now, now, stocktakeID, StocktakeDraft)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return ErrStocktakeNotDraft
+	}
+	if _, err := tx.Exec(`DELETE FROM stocktake_lines WHERE stocktake_id=? AND product_id IS NULL`, stocktakeID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE stocktake_lines
+		SET expected = p.stock_count, product_name = COALESCE(p.name,''), product_category = COALESCE(p.category,'')
+		FROM products p
+		WHERE p.id = stocktake_lines.product_id AND stocktake_lines.stocktake_id=?`, stocktakeID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE products
+		SET stock_count = (SELECT l.counted FROM stocktake_lines l WHERE l.stocktake_id=? AND l.product_id=products.id),
+			updated_at = ?
+		WHERE id IN (SELECT product_id FROM stocktake_lines WHERE stocktake_id=?)`, stocktakeID, now, stocktakeID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (q *Queries) DeleteStocktakeDraft(stocktakeID int64) error {
+	_, err := q.db.Exec(`DELETE FROM stocktakes WHERE id=? AND status=?`, stocktakeID, StocktakeDraft)
+	return err
+}
+
+/* ---------------- Cocktails ---------------- */
+
+func (q *Queries) GetCocktailByID(id int64) (*Cocktail, error) {
+	row := q.rdb.QueryRow(`
 		SELECT
 			id,
 			COALESCE(name,''),
@@ -344,143 +1644,821 @@ func (q *Queries) GetCocktailByID(id int64) (*Cocktail, error) {
 		}
 		return nil, err
 	}
-	c.IsEnabled = i2b(enabled)
-	c.CreatedAt = tFromUnix(ca)
-	c.UpdatedAt = tFromUnix(ua)
-	return &c, nil
+	c.IsEnabled = i2b(enabled)
+	c.CreatedAt = tFromUnix(ca)
+	c.UpdatedAt = tFromUnix(ua)
+	return &c, nil
+}
+
+// cocktailComputedSelect selects every cocktail with computed availability: enabled AND
+// all required ingredients are available.
+func cocktailComputedSelect() string {
+	return fmt.Sprintf(`
+		SELECT
+			c.id,
+			COALESCE(c.name,'') AS name,
+			COALESCE(c.description,'') AS description,
+			COALESCE(c.image_path,'') AS image_path,
+			COALESCE(c.tags,'') AS tags,
+			COALESCE(c.difficulty,'easy') AS difficulty,
+			COALESCE(c.prep_time_minutes, 0) AS prep_time_minutes,
+			COALESCE(c.instructions,'') AS instructions,
+			COALESCE(c.is_enabled,0) AS is_enabled,
+			CASE
+				WHEN COALESCE(c.is_enabled,0) = 0 THEN 0
+				WHEN EXISTS (
+					SELECT 1
+					FROM cocktail_ingredients ci
+					JOIN products p ON p.id = ci.product_id
+					WHERE ci.cocktail_id = c.id
+					  AND ci.required = 1
+					  AND (%s) = 0
+				) THEN 0
+				ELSE 1
+			END AS computed_avail,
+			c.created_at,c.updated_at
+		FROM cocktails c`, stationAvailExpr("c.id"))
+}
+
+func (q *Queries) ListCocktailsComputed(onlyAvailable bool) ([]Cocktail, error) {
+	rows, err := q.rdb.Query(`SELECT * FROM (`+cocktailComputedSelect()+`) WHERE (? = 0 OR computed_avail = 1) ORDER BY name`, b2i(onlyAvailable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanComputedCocktails(rows)
+}
+
+func scanComputedCocktails(rows *sql.Rows) ([]Cocktail, error) {
+	var out []Cocktail
+	for rows.Next() {
+		var c Cocktail
+		var enabled, comp int
+		var ca, ua int64
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.ImagePath, &c.Tags, &c.Difficulty, &c.PrepTimeMinutes, &c.Instructions, &enabled, &comp, &ca, &ua); err != nil {
+			return nil, err
+		}
+		c.IsEnabled = i2b(enabled)
+		c.ComputedAvail = i2b(comp)
+		c.CreatedAt = tFromUnix(ca)
+		c.UpdatedAt = tFromUnix(ua)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) CreateCocktail(p CreateCocktailParams) (int64, error) {
+	res, err := q.db.Exec(`
+		INSERT INTO cocktails(name,description,image_path,tags,difficulty,prep_time_minutes,instructions,is_enabled,created_at,updated_at)
+		VALUES(?,?,?,?,?,?,?,?,?,?)`,
+		p.Name, p.Description, p.ImagePath, p.Tags, p.Difficulty, p.PrepTimeMinutes, p.Instructions, b2i(p.IsEnabled), unixNow(), unixNow())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (q *Queries) UpdateCocktail(p UpdateCocktailParams) error {
+	_, err := q.db.Exec(`
+		UPDATE cocktails
+		SET name=?, description=?, image_path=?, tags=?, difficulty=?, prep_time_minutes=?, instructions=?, is_enabled=?, updated_at=?
+		WHERE id=?`,
+		p.Name, p.Description, p.ImagePath, p.Tags, p.Difficulty, p.PrepTimeMinutes, p.Instructions, b2i(p.IsEnabled), unixNow(), p.ID)
+	return err
+}
+
+func (q *Queries) ToggleCocktailEnabled(id int64, enabled bool) error {
+	_, err := q.db.Exec(`UPDATE cocktails SET is_enabled=?, updated_at=? WHERE id=?`, b2i(enabled), unixNow(), id)
+	return err
+}
+
+func (q *Queries) DeleteCocktail(id int64) error {
+	_, err := q.db.Exec(`DELETE FROM cocktails WHERE id=?`, id)
+	return err
+}
+
+func cocktailIngredientSelect() string {
+	return fmt.Sprintf(`
+		SELECT
+			ci.id,ci.cocktail_id,ci.product_id,ci.quantity,COALESCE(ci.unit,''),ci.required,
+			COALESCE(p.name,''),COALESCE(p.category,''),
+			%s AS product_avail
+		FROM cocktail_ingredients ci
+		JOIN products p ON p.id = ci.product_id`, stationAvailExpr("ci.cocktail_id"))
+}
+
+func (q *Queries) GetCocktailIngredients(cocktailID int64) ([]CocktailIngredient, error) {
+	rows, err := q.rdb.Query(cocktailIngredientSelect()+`
+		WHERE ci.cocktail_id=?
+		ORDER BY ci.required DESC, p.category, p.name`, cocktailID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanCocktailIngredients(rows)
+}
+
+// ListAllCocktailIngredients loads every cocktail's ingredients in one query, grouped by
+// cocktail and ordered as GetCocktailIngredients orders them.
+func (q *Queries) ListAllCocktailIngredients() ([]CocktailIngredient, error) {
+	rows, err := q.rdb.Query(cocktailIngredientSelect() + `
+		ORDER BY ci.cocktail_id, ci.required DESC, p.category, p.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanCocktailIngredients(rows)
+}
+
+func scanCocktailIngredients(rows *sql.Rows) ([]CocktailIngredient, error) {
+	var out []CocktailIngredient
+	for rows.Next() {
+		var ci CocktailIngredient
+		var req, pav int
+		if err := rows.Scan(&ci.ID, &ci.CocktailID, &ci.ProductID, &ci.Quantity, &ci.Unit, &req, &ci.ProductName, &ci.ProductCategory, &pav); err != nil {
+			return nil, err
+		}
+		ci.Required = i2b(req)
+		ci.ProductAvail = i2b(pav)
+		out = append(out, ci)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) ReplaceCocktailIngredients(cocktailID int64, items []IngredientUpsertItem) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM cocktail_ingredients WHERE cocktail_id=?`, cocktailID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	for _, it := range items {
+		if it.ProductID <= 0 {
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO cocktail_ingredients(cocktail_id,product_id,quantity,unit,required)
+			VALUES(?,?,?,?,?)`, cocktailID, it.ProductID, it.Quantity, it.Unit, b2i(it.Required)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/* ---------------- Media ---------------- */
+
+const mediaSelect = `
+	SELECT
+		m.id,m.path,m.content_type,m.bytes,m.width,m.height,
+		COALESCE(u.display_name,''),m.created_at,m.unreferenced_at,
+		(SELECT COUNT(*) FROM cocktails c WHERE c.image_path=m.path)
+	FROM media m
+	LEFT JOIN users u ON u.id=m.uploaded_by_user_id`
+
+func scanMedia(scanner rowScanner) (*Media, error) {
+	var m Media
+	var ca int64
+	var unreferenced sql.NullInt64
+	if err := scanner.Scan(&m.ID, &m.Path, &m.ContentType, &m.Bytes, &m.Width, &m.Height, &m.UploadedByName, &ca, &unreferenced, &m.RefCount); err != nil {
+		return nil, err
+	}
+	m.CreatedAt = tFromUnix(ca)
+	if unreferenced.Valid {
+		m.UnreferencedAt = tFromUnix(unreferenced.Int64)
+	}
+	return &m, nil
+}
+
+func (q *Queries) CreateMedia(p CreateMediaParams) (int64, error) {
+	var uploadedBy any
+	if p.UploadedBy > 0 {
+		uploadedBy = p.UploadedBy
+	}
+	res, err := q.db.Exec(`
+		INSERT INTO media(path,content_type,bytes,width,height,uploaded_by_user_id,created_at)
+		VALUES(?,?,?,?,?,?,?)`, p.Path, p.ContentType, p.Bytes, p.Width, p.Height, uploadedBy, unixNow())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (q *Queries) GetMediaByPath(path string) (*Media, error) {
+	m, err := scanMedia(q.rdb.QueryRow(mediaSelect+` WHERE m.path=?`, path))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+// ListMedia returns the media library newest first.
+func (q *Queries) ListMedia() ([]Media, error) {
+	rows, err := q.rdb.Query(mediaSelect + ` ORDER BY m.created_at DESC, m.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Media
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *m)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) DeleteMediaByPath(path string) error {
+	_, err := q.db.Exec(`DELETE FROM media WHERE path=?`, path)
+	return err
+}
+
+// ListCocktailImagePaths returns every distinct image path cocktails refer to.
+func (q *Queries) ListCocktailImagePaths() ([]string, error) {
+	rows, err := q.rdb.Query(`SELECT DISTINCT image_path FROM cocktails WHERE COALESCE(image_path,'')<>''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+/* ---------------- Audit log ---------------- */
+
+// InsertAudit appends an entry. The table has no update or delete path.
+func (q *Queries) InsertAudit(e AuditEntry) error {
+	var actor, target any
+	if e.ActorID > 0 {
+		actor = e.ActorID
+	}
+	if e.TargetID > 0 {
+		target = e.TargetID
+	}
+	_, err := q.db.Exec(`
+		INSERT INTO audit_log(actor_user_id,actor_name,action,target_type,target_id,target_label,before_json,after_json,created_at)
+		VALUES(?,?,?,?,?,?,?,?,?)`,
+		actor, e.ActorName, e.Action, e.TargetType, target, e.TargetLabel, nullString(e.Before), nullString(e.After), unixNow())
+	return err
+}
+
+// ListAudit returns the matching entries newest first, plus the total match count for paging.
+func (q *Queries) ListAudit(f AuditFilter) ([]AuditEntry, int, error) {
+	where := []string{"1=1"}
+	var args []any
+	if f.ActorID > 0 {
+		where = append(where, "actor_user_id=?")
+		args = append(args, f.ActorID)
+	}
+	if f.Action != "" {
+		where = append(where, "action=?")
+		args = append(args, f.Action)
+	}
+	if f.TargetType != "" {
+		where = append(where, "target_type=?")
+		args = append(args, f.TargetType)
+	}
+	if f.Query != "" {
+		where = append(where, "(instr(lower(target_label),lower(?))>0 OR instr(lower(actor_name),lower(?))>0)")
+		args = append(args, f.Query, f.Query)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at>=?")
+		args = append(args, f.Since.Unix())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created_at<?")
+		args = append(args, f.Until.Unix())
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := q.rdb.QueryRow(`SELECT COUNT(*) FROM audit_log WHERE `+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id,COALESCE(actor_user_id,0),actor_name,action,target_type,COALESCE(target_id,0),target_label,
+			COALESCE(before_json,''),COALESCE(after_json,''),created_at
+		FROM audit_log WHERE ` + cond + ` ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := q.rdb.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var ca int64
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.ActorName, &e.Action, &e.TargetType, &e.TargetID, &e.TargetLabel, &e.Before, &e.After, &ca); err != nil {
+			return nil, 0, err
+		}
+		e.CreatedAt = tFromUnix(ca)
+		out = append(out, e)
+	}
+	return out, total, rows.Err()
+}
+
+// ListAuditActions returns the distinct actions and target types present in the log, for
+// the viewer's filter menus.
+func (q *Queries) ListAuditActions() (actions, targetTypes []string, err error) {
+	rows, err := q.rdb.Query(`SELECT DISTINCT action, target_type FROM audit_log ORDER BY action`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	seenAction := map[string]bool{}
+	seenType := map[string]bool{}
+	for rows.Next() {
+		var a, t string
+		if err := rows.Scan(&a, &t); err != nil {
+			return nil, nil, err
+		}
+		if !seenAction[a] {
+			seenAction[a] = true
+			actions = append(actions, a)
+		}
+		if t != "" && !seenType[t] {
+			seenType[t] = true
+			targetTypes = append(targetTypes, t)
+		}
+	}
+	sort.Strings(targetTypes)
+	return actions, targetTypes, rows.Err()
+}
+
+// ListAuditActors returns everyone who appears as an actor, with their latest recorded name.
+func (q *Queries) ListAuditActors() ([]AuditActor, error) {
+	rows, err := q.rdb.Query(`
+		SELECT actor_user_id, actor_name FROM audit_log
+		WHERE id IN (SELECT MAX(id) FROM audit_log WHERE actor_user_id IS NOT NULL GROUP BY actor_user_id)
+		ORDER BY actor_name COLLATE NOCASE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AuditActor
+	for rows.Next() {
+		var a AuditActor
+		if err := rows.Scan(&a.ID, &a.Name); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+/* ---------------- Cocktail modifiers ---------------- */
+
+func (q *Queries) GetCocktailModifiers(cocktailID int64) ([]CocktailModifier, error) {
+	sqlq := fmt.Sprintf(`
+		SELECT
+			m.id,m.cocktail_id,m.kind,COALESCE(m.label,''),COALESCE(m.options,''),m.product_id,m.sort_order,
+			COALESCE(p.name,''),
+			CASE WHEN p.id IS NULL THEN 1 ELSE %s END AS product_avail
+		FROM cocktail_modifiers m
+		LEFT JOIN products p ON p.id = m.product_id
+		WHERE m.cocktail_id=?
+		ORDER BY m.sort_order, m.id`, stationAvailExpr("m.cocktail_id"))
+
+	rows, err := q.rdb.Query(sqlq, cocktailID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []CocktailModifier
+	for rows.Next() {
+		var m CocktailModifier
+		var pid sql.NullInt64
+		var pav int
+		if err := rows.Scan(&m.ID, &m.CocktailID, &m.Kind, &m.Label, &m.Options, &pid, &m.SortOrder, &m.ProductName, &pav); err != nil {
+			return nil, err
+		}
+		if pid.Valid {
+			m.ProductID = &pid.Int64
+		}
+		m.ProductAvail = i2b(pav)
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func (q *Queries) ReplaceCocktailModifiers(cocktailID int64, items []ModifierUpsertItem) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM cocktail_modifiers WHERE cocktail_id=?`, cocktailID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	for i, it := range items {
+		if _, err := tx.Exec(`
+			INSERT INTO cocktail_modifiers(cocktail_id,kind,label,options,product_id,sort_order)
+			VALUES(?,?,?,?,?,?)`, cocktailID, it.Kind, it.Label, it.Options, it.ProductID, i); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (q *Queries) ListOrderModifiers(orderIDs []int64) (map[int64][]OrderModifier, error) {
+	out := map[int64][]OrderModifier{}
+	if len(orderIDs) == 0 {
+		return out, nil
+	}
+
+	placeholders := make([]string, len(orderIDs))
+	args := make([]any, len(orderIDs))
+	for i, id := range orderIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := q.rdb.Query(`
+		SELECT id,order_id,kind,label,choice,is_default,product_id
+		FROM order_modifiers
+		WHERE order_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY order_id, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m OrderModifier
+		var def int
+		var pid sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.OrderID, &m.Kind, &m.Label, &m.Choice, &def, &pid); err != nil {
+			return nil, err
+		}
+		m.IsDefault = i2b(def)
+		if pid.Valid {
+			m.ProductID = &pid.Int64
+		}
+		out[m.OrderID] = append(out[m.OrderID], m)
+	}
+	return out, nil
 }
 
-func (q *Queries) ListCocktailsComputed(onlyAvailable bool) ([]Cocktail, error) {
-	// computed availability: enabled AND all required ingredients are available
+/* ---------------- Makeable analysis ---------------- */
+
+// ListCocktailRequirements returns one row per required ingredient of every cocktail,
+// plus a single row with a nil ProductID for cocktails that have none.
+func (q *Queries) ListCocktailRequirements() ([]CocktailRequirement, error) {
 	sqlq := fmt.Sprintf(`
-		SELECT * FROM (
-			SELECT
-				c.id,
-				COALESCE(c.name,'') AS name,
-				COALESCE(c.description,'') AS description,
-				COALESCE(c.image_path,'') AS image_path,
-				COALESCE(c.tags,'') AS tags,
-				COALESCE(c.difficulty,'easy') AS difficulty,
-				COALESCE(c.prep_time_minutes, 0) AS prep_time_minutes,
-				COALESCE(c.instructions,'') AS instructions,
-				COALESCE(c.is_enabled,0) AS is_enabled,
-				CASE
-					WHEN COALESCE(c.is_enabled,0) = 0 THEN 0
-					WHEN EXISTS (
-						SELECT 1
-						FROM cocktail_ingredients ci
-						JOIN products p ON p.id = ci.product_id
-						WHERE ci.cocktail_id = c.id
-						  AND ci.required = 1
-						  AND (%s) = 0
-					) THEN 0
-					ELSE 1
-				END AS computed_avail,
-				c.created_at,c.updated_at
-			FROM cocktails c
-		) WHERE (? = 0 OR computed_avail = 1)
-		ORDER BY name`, computedAvailExpr())
-
-	rows, err := q.db.Query(sqlq, b2i(onlyAvailable))
+		SELECT
+			c.id, COALESCE(c.name,''), COALESCE(c.is_enabled,0),
+			p.id, COALESCE(p.name,''), COALESCE(p.category,''),
+			CASE WHEN p.id IS NULL THEN 1 ELSE %s END AS product_avail
+		FROM cocktails c
+		LEFT JOIN cocktail_ingredients ci ON ci.cocktail_id = c.id AND ci.required = 1
+		LEFT JOIN products p ON p.id = ci.product_id
+		ORDER BY c.name, c.id, p.name`, stationAvailExpr("c.id"))
+
+	rows, err := q.rdb.Query(sqlq)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var out []Cocktail
+	var out []CocktailRequirement
 	for rows.Next() {
-		var c Cocktail
-		var enabled, comp int
-		var ca, ua int64
-		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.ImagePath, &c.Tags, &c.Difficulty, &c.PrepTimeMinutes, &c.Instructions, &enabled, &comp, &ca, &ua); err != nil {
+		var it CocktailRequirement
+		var enabled, pav int
+		var pid sql.NullInt64
+		if err := rows.Scan(&it.CocktailID, &it.CocktailName, &enabled, &pid, &it.ProductName, &it.ProductCategory, &pav); err != nil {
 			return nil, err
 		}
-		c.IsEnabled = i2b(enabled)
-		c.ComputedAvail = i2b(comp)
-		c.CreatedAt = tFromUnix(ca)
-		c.UpdatedAt = tFromUnix(ua)
-		out = append(out, c)
+		it.CocktailEnabled = i2b(enabled)
+		if pid.Valid {
+			it.ProductID = &pid.Int64
+		}
+		it.ProductAvail = i2b(pav)
+		out = append(out, it)
 	}
 	return out, nil
 }
 
-func (q *Queries) CreateCocktail(p CreateCocktailParams) (int64, error) {
-	res, err := q.db.Exec(`
-		INSERT INTO cocktails(name,description,image_path,tags,difficulty,prep_time_minutes,instructions,is_enabled,created_at,updated_at)
-		VALUES(?,?,?,?,?,?,?,?,?,?)`,
-		p.Name, p.Description, p.ImagePath, p.Tags, p.Difficulty, p.PrepTimeMinutes, p.Instructions, b2i(p.IsEnabled), unixNow(), unixNow())
+// CocktailOrderCounts sums ordered drinks per cocktail, ignoring cancelled orders.
+func (q *Queries) CocktailOrderCounts() (map[int64]int64, error) {
+	rows, err := q.rdb.Query(`
+		SELECT cocktail_id, COALESCE(SUM(quantity),0)
+		FROM orders
+		WHERE status <> 'CANCELLED'
+		GROUP BY cocktail_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64]int64{}
+	for rows.Next() {
+		var id, n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		out[id] = n
+	}
+	return out, nil
+}
+
+/* ---------------- Stations ---------------- */
+
+const stationSelect = `
+	SELECT
+		s.id,s.name,s.is_default,s.created_at,
+		CASE WHEN s.is_default=1
+			THEN (SELECT COUNT(*) FROM cocktails c WHERE NOT EXISTS (SELECT 1 FROM cocktail_routes cr WHERE cr.cocktail_id=c.id))
+			ELSE (SELECT COUNT(*) FROM cocktail_routes cr WHERE cr.station_id=s.id)
+		END,
+		(SELECT COUNT(*) FROM station_bartenders sb WHERE sb.station_id=s.id),
+		(SELECT COUNT(*) FROM orders o WHERE o.station_id=s.id AND o.status NOT IN ('DELIVERED','CANCELLED'))
+	FROM stations s`
+
+func scanStation(scanner rowScanner) (*Station, error) {
+	var st Station
+	var def int
+	var ca int64
+	if err := scanner.Scan(&st.ID, &st.Name, &def, &ca, &st.Cocktails, &st.Bartenders, &st.OpenOrders); err != nil {
+		return nil, err
+	}
+	st.IsDefault = i2b(def)
+	st.CreatedAt = tFromUnix(ca)
+	return &st, nil
+}
+
+// ListStations returns the main bar first, then the other stations by name.
+func (q *Queries) ListStations() ([]Station, error) {
+	rows, err := q.rdb.Query(stationSelect + ` ORDER BY s.is_default DESC, s.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Station
+	for rows.Next() {
+		st, err := scanStation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *st)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) GetStation(id int64) (*Station, error) {
+	st, err := scanStation(q.rdb.QueryRow(stationSelect+` WHERE s.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return st, err
+}
+
+func (q *Queries) DefaultStation() (*Station, error) {
+	st, err := scanStation(q.rdb.QueryRow(stationSelect + ` WHERE s.is_default=1`))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return st, err
+}
+
+// CocktailStation is the station a cocktail is made at: its route, or the main bar.
+func (q *Queries) CocktailStation(cocktailID int64) (*Station, error) {
+	st, err := scanStation(q.rdb.QueryRow(stationSelect+`
+		WHERE s.id = COALESCE((SELECT station_id FROM cocktail_routes WHERE cocktail_id=?), (SELECT id FROM stations WHERE is_default=1))`, cocktailID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return st, err
+}
+
+func (q *Queries) CreateStation(name string) (int64, error) {
+	res, err := q.db.Exec(`INSERT INTO stations(name,is_default,created_at) VALUES(?,0,?)`, name, unixNow())
 	if err != nil {
 		return 0, err
 	}
 	return res.LastInsertId()
 }
 
-func (q *Queries) UpdateCocktail(p UpdateCocktailParams) error {
-	_, err := q.db.Exec(`
-		UPDATE cocktails
-		SET name=?, description=?, image_path=?, tags=?, difficulty=?, prep_time_minutes=?, instructions=?, is_enabled=?, updated_at=?
-		WHERE id=?`,
-		p.Name, p.Description, p.ImagePath, p.Tags, p.Difficulty, p.PrepTimeMinutes, p.Instructions, b2i(p.IsEnabled), unixNow(), p.ID)
+func (q *Queries) RenameStation(id int64, name string) error {
+	_, err := q.db.Exec(`UPDATE stations SET name=? WHERE id=?`, name, id)
 	return err
 }
 
-func (q *Queries) ToggleCocktailEnabled(id int64, enabled bool) error {
-	_, err := q.db.Exec(`UPDATE cocktails SET is_enabled=?, updated_at=? WHERE id=?`, b2i(enabled), unixNow(), id)
-	return err
+// DeleteStation removes a station. Its cocktails fall back to the main bar, and so do its
+// orders, so nothing in the queue is lost.
+func (q *Queries) DeleteStation(id int64) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	var def int
+	if err := tx.QueryRow(`SELECT is_default FROM stations WHERE id=?`, id).Scan(&def); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if def == 1 {
+		_ = tx.Rollback()
+		return ErrDefaultStation
+	}
+	if _, err := tx.Exec(`UPDATE orders SET station_id=(SELECT id FROM stations WHERE is_default=1) WHERE station_id=?`, id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM stations WHERE id=?`, id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
-func (q *Queries) DeleteCocktail(id int64) error {
-	_, err := q.db.Exec(`DELETE FROM cocktails WHERE id=?`, id)
+// ListStationStock returns every product with its stock at a station other than the main
+// bar, stocked or not.
+func (q *Queries) ListStationStock(stationID int64) ([]StationStock, error) {
+	rows, err := q.rdb.Query(`
+		SELECT
+			p.id,COALESCE(p.name,''),COALESCE(p.category,''),
+			ss.product_id IS NOT NULL,
+			COALESCE(ss.is_available,0),
+			ss.stock_count
+		FROM products p
+		LEFT JOIN station_stock ss ON ss.product_id=p.id AND ss.station_id=?
+		ORDER BY p.category, p.name`, stationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StationStock
+	for rows.Next() {
+		it := StationStock{StationID: stationID}
+		var stocked, avail int
+		if err := rows.Scan(&it.ProductID, &it.ProductName, &it.ProductCategory, &stocked, &avail, &it.StockCount); err != nil {
+			return nil, err
+		}
+		it.Stocked = i2b(stocked)
+		it.IsAvailable = i2b(avail)
+		switch {
+		case !it.Stocked:
+		case it.StockCount != nil:
+			it.ComputedAvail = *it.StockCount > 0
+		default:
+			it.ComputedAvail = it.IsAvailable
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// SetStationStock stocks a product at a station, tracked when stock is set, or removes it
+// from the station when stocked is false.
+func (q *Queries) SetStationStock(stationID, productID int64, stocked, available bool, stock *int64) error {
+	if !stocked {
+		_, err := q.db.Exec(`DELETE FROM station_stock WHERE station_id=? AND product_id=?`, stationID, productID)
+		return err
+	}
+	_, err := q.db.Exec(`
+		INSERT INTO station_stock(station_id,product_id,is_available,stock_count,updated_at)
+		VALUES(?,?,?,?,?)
+		ON CONFLICT(station_id,product_id) DO UPDATE SET
+			is_available=excluded.is_available,
+			stock_count=excluded.stock_count,
+			updated_at=excluded.updated_at`, stationID, productID, b2i(available), stock, unixNow())
 	return err
 }
 
-func (q *Queries) GetCocktailIngredients(cocktailID int64) ([]CocktailIngredient, error) {
-	sqlq := fmt.Sprintf(`
-		SELECT
-			ci.id,ci.cocktail_id,ci.product_id,ci.quantity,COALESCE(ci.unit,''),ci.required,
-			COALESCE(p.name,''),COALESCE(p.category,''),
-			%s AS product_avail
-		FROM cocktail_ingredients ci
-		JOIN products p ON p.id = ci.product_id
-		WHERE ci.cocktail_id=?
-		ORDER BY ci.required DESC, p.category, p.name`, computedAvailExpr())
+// StationStockCount is a product's tracked stock where station keeps it, nil when it is not
+// tracked there.
+func (q *Queries) StationStockCount(stationID, productID int64) (*int64, error) {
+	var n sql.NullInt64
+	err := q.rdb.QueryRow(`
+		SELECT CASE WHEN s.is_default=1 THEN p.stock_count ELSE ss.stock_count END
+		FROM stations s
+		JOIN products p ON p.id=?
+		LEFT JOIN station_stock ss ON ss.station_id=s.id AND ss.product_id=p.id
+		WHERE s.id=?`, productID, stationID).Scan(&n)
+	if err == sql.ErrNoRows || (err == nil && !n.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n.Int64, nil
+}
 
-	rows, err := q.db.Query(sqlq, cocktailID)
+// ListStationBartenders maps each station to the users assigned to it.
+func (q *Queries) ListStationBartenders() (map[int64][]int64, error) {
+	rows, err := q.rdb.Query(`SELECT station_id,user_id FROM station_bartenders ORDER BY station_id,user_id`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var out []CocktailIngredient
+	out := map[int64][]int64{}
 	for rows.Next() {
-		var ci CocktailIngredient
-		var req, pav int
-		if err := rows.Scan(&ci.ID, &ci.CocktailID, &ci.ProductID, &ci.Quantity, &ci.Unit, &req, &ci.ProductName, &ci.ProductCategory, &pav); err != nil {
+		var sid, uid int64
+		if err := rows.Scan(&sid, &uid); err != nil {
 			return nil, err
 		}
-		ci.Required = i2b(req)
-		ci.ProductAvail = i2b(pav)
-		out = append(out, ci)
+		out[sid] = append(out[sid], uid)
 	}
-	return out, nil
+	return out, rows.Err()
 }
 
-func (q *Queries) ReplaceCocktailIngredients(cocktailID int64, items []IngredientUpsertItem) error {
+// StationIDsForUser lists the stations a bartender works, none meaning every station.
+func (q *Queries) StationIDsForUser(userID int64) ([]int64, error) {
+	rows, err := q.rdb.Query(`SELECT station_id FROM station_bartenders WHERE user_id=? ORDER BY station_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) SetStationBartenders(stationID int64, userIDs []int64) error {
 	tx, err := q.db.Begin()
 	if err != nil {
 		return err
 	}
-	if _, err := tx.Exec(`DELETE FROM cocktail_ingredients WHERE cocktail_id=?`, cocktailID); err != nil {
+	if _, err := tx.Exec(`DELETE FROM station_bartenders WHERE station_id=?`, stationID); err != nil {
 		_ = tx.Rollback()
 		return err
 	}
-	for _, it := range items {
-		if it.ProductID <= 0 {
-			continue
+	for _, uid := range userIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO station_bartenders(station_id,user_id) VALUES(?,?)`, stationID, uid); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListCocktailRoutes maps routed cocktails to their station; the rest go to the main bar.
+func (q *Queries) ListCocktailRoutes() (map[int64]int64, error) {
+	rows, err := q.rdb.Query(`SELECT cocktail_id,station_id FROM cocktail_routes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64]int64{}
+	for rows.Next() {
+		var cid, sid int64
+		if err := rows.Scan(&cid, &sid); err != nil {
+			return nil, err
 		}
+		out[cid] = sid
+	}
+	return out, rows.Err()
+}
+
+// ReplaceCocktailRoutes sets every cocktail's station at once. Routes to the main bar are
+// not stored, as an unrouted cocktail is made there anyway.
+func (q *Queries) ReplaceCocktailRoutes(routes map[int64]int64) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM cocktail_routes`); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	for cid, sid := range routes {
 		if _, err := tx.Exec(`
-			INSERT INTO cocktail_ingredients(cocktail_id,product_id,quantity,unit,required)
-			VALUES(?,?,?,?,?)`, cocktailID, it.ProductID, it.Quantity, it.Unit, b2i(it.Required)); err != nil {
+			INSERT INTO cocktail_routes(cocktail_id,station_id)
+			SELECT ?, id FROM stations WHERE id=? AND is_default=0`, cid, sid); err != nil {
 			_ = tx.Rollback()
 			return err
 		}
@@ -495,10 +2473,15 @@ func (q *Queries) CreateOrder(p CreateOrderParams) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	var mainBar int
+	if err := tx.QueryRow(`SELECT is_default FROM stations WHERE id=?`, p.StationID).Scan(&mainBar); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
 	res, err := tx.Exec(`
-		INSERT INTO orders(user_id,cocktail_id,quantity,notes,location,status,assigned_bartender_id,created_at,updated_at)
-		VALUES(?,?,?,?,?,'PLACED',NULL,?,?)`,
-		p.UserID, p.CocktailID, p.Quantity, p.Notes, p.Location, unixNow(), unixNow())
+		INSERT INTO orders(user_id,cocktail_id,quantity,notes,location,status,assigned_bartender_id,station_id,created_at,updated_at)
+		VALUES(?,?,?,?,?,'PLACED',NULL,?,?,?)`,
+		p.UserID, p.CocktailID, p.Quantity, p.Notes, p.Location, p.StationID, unixNow(), unixNow())
 	if err != nil {
 		_ = tx.Rollback()
 		return 0, err
@@ -512,112 +2495,141 @@ func (q *Queries) CreateOrder(p CreateOrderParams) (int64, error) {
 		return 0, err
 	}
 
+	for _, m := range p.Modifiers {
+		if _, err := tx.Exec(`
+			INSERT INTO order_modifiers(order_id,kind,label,choice,is_default,product_id)
+			VALUES(?,?,?,?,?,?)`, id, m.Kind, m.Label, m.Choice, b2i(m.IsDefault), m.ProductID); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+
+	// Only tracked stock is depleted; untracked products keep manual availability. The
+	// count is checked here, in the write transaction, so two guests can't both order the
+	// last of it.
+	deplete := `
+		UPDATE products
+		SET stock_count=stock_count-?, updated_at=?
+		WHERE id=? AND stock_count IS NOT NULL AND stock_count >= ?`
+	tracked := `SELECT COUNT(*) FROM products WHERE id=? AND stock_count IS NOT NULL`
+	if mainBar == 0 {
+		deplete = `
+		UPDATE station_stock
+		SET stock_count=stock_count-?, updated_at=?
+		WHERE product_id=? AND station_id=? AND stock_count IS NOT NULL AND stock_count >= ?`
+		tracked = `SELECT COUNT(*) FROM station_stock WHERE product_id=? AND station_id=? AND stock_count IS NOT NULL`
+	}
+	for _, d := range p.Depletions {
+		if d.ProductID <= 0 || d.Units <= 0 {
+			continue
+		}
+		where := []any{d.ProductID}
+		if mainBar == 0 {
+			where = append(where, p.StationID)
+		}
+		res, err := tx.Exec(deplete, append(append([]any{d.Units, unixNow()}, where...), d.Units)...)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			continue
+		}
+		var isTracked int
+		if err := tx.QueryRow(tracked, where...).Scan(&isTracked); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		if isTracked > 0 {
+			_ = tx.Rollback()
+			return 0, ErrInsufficientStock
+		}
+	}
+
 	return id, tx.Commit()
 }
 
-func (q *Queries) GetOrderByID(id int64) (*Order, error) {
-	row := q.db.QueryRow(`
-		SELECT
-			o.id,o.user_id,o.cocktail_id,o.quantity,COALESCE(o.notes,''),COALESCE(o.location,''),COALESCE(o.status,''),o.assigned_bartender_id,o.created_at,o.updated_at,
-			COALESCE(u.display_name,''),
-			COALESCE(c.name,''),COALESCE(c.image_path,''),
-			COALESCE(ub.display_name,'')
-		FROM orders o
-		JOIN users u ON u.id=o.user_id
-		JOIN cocktails c ON c.id=o.cocktail_id
-		LEFT JOIN users ub ON ub.id=o.assigned_bartender_id
-		WHERE o.id=?`, id)
-
+const orderSelect = `
+	SELECT
+		o.id,o.user_id,o.cocktail_id,o.quantity,COALESCE(o.notes,''),COALESCE(o.location,''),COALESCE(o.status,''),o.assigned_bartender_id,COALESCE(o.station_id,0),o.created_at,o.updated_at,
+		COALESCE(u.display_name,''),
+		COALESCE(c.name,''),COALESCE(c.image_path,''),
+		COALESCE(ub.display_name,''),
+		COALESCE(st.name,'')
+	FROM orders o
+	JOIN users u ON u.id=o.user_id
+	JOIN cocktails c ON c.id=o.cocktail_id
+	LEFT JOIN users ub ON ub.id=o.assigned_bartender_id
+	LEFT JOIN stations st ON st.id=o.station_id`
+
+func scanOrder(scanner rowScanner) (*Order, error) {
 	var o Order
 	var bid sql.NullInt64
 	var ca, ua int64
-	if err := row.Scan(&o.ID, &o.UserID, &o.CocktailID, &o.Quantity, &o.Notes, &o.Location, &o.Status, &bid, &ca, &ua,
-		&o.UserDisplayName, &o.CocktailName, &o.CocktailImagePath, &o.AssignedBartenderName); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+	if err := scanner.Scan(&o.ID, &o.UserID, &o.CocktailID, &o.Quantity, &o.Notes, &o.Location, &o.Status, &bid, &o.StationID, &ca, &ua,
+		&o.UserDisplayName, &o.CocktailName, &o.CocktailImagePath, &o.AssignedBartenderName, &o.StationName); err != nil {
 		return nil, err
 	}
-	if bid.Valid {
-		o.AssignedBartenderID = &bid.Int64
+	if bid.Valid {
+		o.AssignedBartenderID = &bid.Int64
+	}
+	o.CreatedAt = tFromUnix(ca)
+	o.UpdatedAt = tFromUnix(ua)
+	return &o, nil
+}
+
+func scanOrders(rows *sql.Rows) ([]Order, error) {
+	var out []Order
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *o)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) GetOrderByID(id int64) (*Order, error) {
+	o, err := scanOrder(q.rdb.QueryRow(orderSelect+` WHERE o.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	o.CreatedAt = tFromUnix(ca)
-	o.UpdatedAt = tFromUnix(ua)
-	return &o, nil
+	return o, err
 }
 
 func (q *Queries) ListOrdersForUser(userID int64) ([]Order, error) {
-	rows, err := q.db.Query(`
-		SELECT
-			o.id,o.user_id,o.cocktail_id,o.quantity,COALESCE(o.notes,''),COALESCE(o.location,''),COALESCE(o.status,''),o.assigned_bartender_id,o.created_at,o.updated_at,
-			COALESCE(u.display_name,''),
-			COALESCE(c.name,''),COALESCE(c.image_path,''),
-			COALESCE(ub.display_name,'')
-		FROM orders o
-		JOIN users u ON u.id=o.user_id
-		JOIN cocktails c ON c.id=o.cocktail_id
-		LEFT JOIN users ub ON ub.id=o.assigned_bartender_id
+	rows, err := q.rdb.Query(orderSelect+`
 		WHERE o.user_id=?
 		ORDER BY o.created_at DESC`, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	return scanOrders(rows)
+}
 
-	var out []Order
-	for rows.Next() {
-		var o Order
-		var bid sql.NullInt64
-		var ca, ua int64
-		if err := rows.Scan(&o.ID, &o.UserID, &o.CocktailID, &o.Quantity, &o.Notes, &o.Location, &o.Status, &bid, &ca, &ua,
-			&o.UserDisplayName, &o.CocktailName, &o.CocktailImagePath, &o.AssignedBartenderName); err != nil {
-			return nil, err
+// ListOrderQueue returns the open orders of the given stations, or of every station when
+// stationIDs is empty.
+func (q *Queries) ListOrderQueue(stationIDs []int64) ([]Order, error) {
+	where := `o.status NOT IN ('DELIVERED','CANCELLED')`
+	args := make([]any, 0, len(stationIDs))
+	if len(stationIDs) > 0 {
+		placeholders := make([]string, len(stationIDs))
+		for i, id := range stationIDs {
+			placeholders[i] = "?"
+			args = append(args, id)
 		}
-		if bid.Valid {
-			o.AssignedBartenderID = &bid.Int64
-		}
-		o.CreatedAt = tFromUnix(ca)
-		o.UpdatedAt = tFromUnix(ua)
-		out = append(out, o)
+		where += ` AND o.station_id IN (` + strings.Join(placeholders, ",") + `)`
 	}
-	return out, nil
-}
-
-func (q *Queries) ListOrderQueue() ([]Order, error) {
-	rows, err := q.db.Query(`
-		SELECT
-			o.id,o.user_id,o.cocktail_id,o.quantity,COALESCE(o.notes,''),COALESCE(o.location,''),COALESCE(o.status,''),o.assigned_bartender_id,o.created_at,o.updated_at,
-			COALESCE(u.display_name,''),
-			COALESCE(c.name,''),COALESCE(c.image_path,''),
-			COALESCE(ub.display_name,'')
-		FROM orders o
-		JOIN users u ON u.id=o.user_id
-		JOIN cocktails c ON c.id=o.cocktail_id
-		LEFT JOIN users ub ON ub.id=o.assigned_bartender_id
-		WHERE o.status NOT IN ('DELIVERED','CANCELLED')
-		ORDER BY o.created_at DESC`)
+	rows, err := q.rdb.Query(orderSelect+`
+		WHERE `+where+`
+		ORDER BY o.created_at DESC`, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	var out []Order
-	for rows.Next() {
-		var o Order
-		var bid sql.NullInt64
-		var ca, ua int64
-		if err := rows.Scan(&o.ID, &o.UserID, &o.CocktailID, &o.Quantity, &o.Notes, &o.Location, &o.Status, &bid, &ca, &ua,
-			&o.UserDisplayName, &o.CocktailName, &o.CocktailImagePath, &o.AssignedBartenderName); err != nil {
-			return nil, err
-		}
-		if bid.Valid {
-			o.AssignedBartenderID = &bid.Int64
-		}
-		o.CreatedAt = tFromUnix(ca)
-		o.UpdatedAt = tFromUnix(ua)
-		out = append(out, o)
-	}
-	return out, nil
+	return scanOrders(rows)
 }
 
 func (q *Queries) AssignOrder(orderID int64, bartenderID *int64) error {
@@ -645,7 +2657,7 @@ func (q *Queries) UpdateOrderStatus(orderID int64, from, to string, changedBy *i
 }
 
 func (q *Queries) ListOrderEvents(orderID int64) ([]OrderEvent, error) {
-	rows, err := q.db.Query(`
+	rows, err := q.rdb.Query(`
 		SELECT
 			e.id,e.order_id,COALESCE(e.from_status,''),COALESCE(e.to_status,''),e.changed_by_user_id,e.created_at,
 			COALESCE(u.display_name,'')
@@ -675,18 +2687,177 @@ func (q *Queries) ListOrderEvents(orderID int64) ([]OrderEvent, error) {
 	return out, nil
 }
 
+/* ---------------- Shifts ---------------- */
+
+const shiftSelect = `
+	SELECT s.id,s.user_id,COALESCE(u.display_name,''),s.started_at,s.ended_at,s.last_seen_at,s.end_reason
+	FROM shifts s
+	JOIN users u ON u.id=s.user_id`
+
+func scanShift(scanner rowScanner) (*Shift, error) {
+	var sh Shift
+	var sa, la int64
+	var ea sql.NullInt64
+	if err := scanner.Scan(&sh.ID, &sh.UserID, &sh.UserName, &sa, &ea, &la, &sh.EndReason); err != nil {
+		return nil, err
+	}
+	sh.StartedAt = tFromUnix(sa)
+	sh.LastSeenAt = tFromUnix(la)
+	if ea.Valid {
+		sh.EndedAt = tFromUnix(ea.Int64)
+	}
+	return &sh, nil
+}
+
+// GetShift returns the shift, or nil.
+func (q *Queries) GetShift(id int64) (*Shift, error) {
+	sh, err := scanShift(q.rdb.QueryRow(shiftSelect+` WHERE s.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sh, err
+}
+
+// OpenShift returns the user's running shift, or nil when they are off duty.
+func (q *Queries) OpenShift(userID int64) (*Shift, error) {
+	sh, err := scanShift(q.rdb.QueryRow(shiftSelect+` WHERE s.user_id=? AND s.ended_at IS NULL`, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sh, err
+}
+
+// ListShifts returns shifts that overlap [from, to), newest first. A zero bound is open;
+// userID 0 means everybody.
+func (q *Queries) ListShifts(from, to time.Time, userID int64, limit int) ([]Shift, error) {
+	where := []string{"1=1"}
+	var args []any
+	if !to.IsZero() {
+		where = append(where, "s.started_at<?")
+		args = append(args, to.Unix())
+	}
+	if !from.IsZero() {
+		where = append(where, "COALESCE(s.ended_at,?)>=?")
+		args = append(args, unixNow(), from.Unix())
+	}
+	if userID > 0 {
+		where = append(where, "s.user_id=?")
+		args = append(args, userID)
+	}
+	args = append(args, limit)
+	rows, err := q.rdb.Query(shiftSelect+`
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY s.started_at DESC, s.id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Shift
+	for rows.Next() {
+		sh, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *sh)
+	}
+	return out, rows.Err()
+}
+
+// ListShiftOrderEvents returns the order status changes each shift's bartender made while
+// the shift ran, oldest first.
+func (q *Queries) ListShiftOrderEvents(shiftIDs []int64) ([]ShiftOrderEvent, error) {
+	if len(shiftIDs) == 0 {
+		return nil, nil
+	}
+	args := []any{unixNow()}
+	placeholders := make([]string, len(shiftIDs))
+	for i, id := range shiftIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	rows, err := q.rdb.Query(`
+		SELECT s.id,e.order_id,COALESCE(c.name,''),COALESCE(e.to_status,''),e.created_at,o.created_at
+		FROM shifts s
+		JOIN order_events e ON e.changed_by_user_id=s.user_id
+			AND e.created_at>=s.started_at AND e.created_at<=COALESCE(s.ended_at,?)
+		JOIN orders o ON o.id=e.order_id
+		LEFT JOIN cocktails c ON c.id=o.cocktail_id
+		WHERE s.id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY e.created_at ASC, e.id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ShiftOrderEvent
+	for rows.Next() {
+		var e ShiftOrderEvent
+		var at, pa int64
+		if err := rows.Scan(&e.ShiftID, &e.OrderID, &e.CocktailName, &e.ToStatus, &at, &pa); err != nil {
+			return nil, err
+		}
+		e.At = tFromUnix(at)
+		e.OrderPlacedAt = tFromUnix(pa)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// TouchShift records that the user was active at now. Shifts last seen after staleBefore
+// are left alone, so a busy bartender doesn't write on every request.
+func (q *Queries) TouchShift(userID int64, now, staleBefore time.Time) error {
+	_, err := q.db.Exec(`UPDATE shifts SET last_seen_at=? WHERE user_id=? AND ended_at IS NULL AND last_seen_at<?`,
+		now.Unix(), userID, staleBefore.Unix())
+	return err
+}
+
+// CloseIdleShifts ends the shifts last seen before before and takes their bartenders off
+// duty. A shift ends when it was last seen, or at the bartender's last order change if
+// that came later.
+func (q *Queries) CloseIdleShifts(before time.Time) (int64, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE users SET on_duty=0, updated_at=?
+		WHERE id IN (SELECT user_id FROM shifts WHERE ended_at IS NULL AND last_seen_at<?)`, unixNow(), before.Unix()); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	res, err := tx.Exec(`
+		UPDATE shifts SET end_reason=?, ended_at=MAX(last_seen_at, COALESCE((
+			SELECT MAX(e.created_at) FROM order_events e
+			WHERE e.changed_by_user_id=shifts.user_id AND e.created_at>=shifts.started_at), 0))
+		WHERE ended_at IS NULL AND last_seen_at<?`, ShiftEndIdle, before.Unix())
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
 /* ---------------- Push subscriptions ---------------- */
 
+const pushSubscriptionSelect = `
+	SELECT
+		ps.id,ps.user_id,ps.endpoint,ps.p256dh,ps.auth,COALESCE(ps.user_agent,''),COALESCE(ps.device_label,''),ps.enabled,
+		ps.notify_new_orders,ps.notify_overdue,ps.notify_low_stock,ps.only_assigned,ps.quiet_start,ps.quiet_end,ps.quiet_zone,
+		ps.created_at,ps.updated_at,ps.last_seen_at,ps.last_success_at,ps.last_failure_at,ps.failure_count
+	FROM push_subscriptions ps`
+
 func (q *Queries) UpsertPushSubscription(p UpsertPushSubscriptionParams) error {
 	now := unixNow()
 	_, err := q.db.Exec(`
 		INSERT INTO push_subscriptions(
-			bartender_user_id, endpoint, p256dh, auth, user_agent, device_label, enabled,
+			user_id, endpoint, p256dh, auth, user_agent, device_label, enabled,
 			created_at, updated_at, last_seen_at, last_success_at, last_failure_at, failure_count
 		)
 		VALUES(?,?,?,?,?,?,1,?,?,?,?,NULL,0)
 		ON CONFLICT(endpoint) DO UPDATE SET
-			bartender_user_id=excluded.bartender_user_id,
+			user_id=excluded.user_id,
 			p256dh=excluded.p256dh,
 			auth=excluded.auth,
 			user_agent=COALESCE(NULLIF(excluded.user_agent,''), push_subscriptions.user_agent),
@@ -696,19 +2867,40 @@ func (q *Queries) UpsertPushSubscription(p UpsertPushSubscriptionParams) error {
 			last_seen_at=excluded.last_seen_at,
 			last_failure_at=NULL,
 			failure_count=0`,
-		p.BartenderUserID, p.Endpoint, p.P256DH, p.Auth, p.UserAgent, p.DeviceLabel,
+		p.UserID, p.Endpoint, p.P256DH, p.Auth, p.UserAgent, p.DeviceLabel,
 		now, now, now, nil)
 	return err
 }
 
 func (q *Queries) ListPushSubscriptionsForUser(userID int64) ([]PushSubscription, error) {
-	rows, err := q.db.Query(`
-		SELECT
-			id,bartender_user_id,endpoint,p256dh,auth,COALESCE(user_agent,''),COALESCE(device_label,''),enabled,
-			created_at,updated_at,last_seen_at,last_success_at,last_failure_at,failure_count
-		FROM push_subscriptions
-		WHERE bartender_user_id=?
-		ORDER BY enabled DESC, updated_at DESC, id DESC`, userID)
+	rows, err := q.rdb.Query(pushSubscriptionSelect+`
+		WHERE ps.user_id=?
+		ORDER BY ps.enabled DESC, ps.updated_at DESC, ps.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PushSubscription
+	for rows.Next() {
+		sub, err := scanPushSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *sub)
+	}
+	return out, nil
+}
+
+// ListEnabledPushSubscriptionsForUser returns the devices the user gets their own order
+// updates on; none once the account is switched off.
+func (q *Queries) ListEnabledPushSubscriptionsForUser(userID int64) ([]PushSubscription, error) {
+	rows, err := q.rdb.Query(pushSubscriptionSelect+`
+		JOIN users u ON u.id = ps.user_id
+		WHERE ps.user_id = ?
+		  AND ps.enabled = 1
+		  AND u.is_active = 1
+		ORDER BY ps.updated_at DESC, ps.id DESC`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -726,14 +2918,9 @@ func (q *Queries) ListPushSubscriptionsForUser(userID int64) ([]PushSubscription
 }
 
 func (q *Queries) ListPushSubscriptionsForOnDutyBartenders() ([]PushSubscription, error) {
-	rows, err := q.db.Query(`
-		SELECT
-			ps.id,ps.bartender_user_id,ps.endpoint,ps.p256dh,ps.auth,COALESCE(ps.user_agent,''),COALESCE(ps.device_label,''),ps.enabled,
-			ps.created_at,ps.updated_at,ps.last_seen_at,ps.last_success_at,ps.last_failure_at,ps.failure_count
-		FROM push_subscriptions ps
-		JOIN users u ON u.id = ps.bartender_user_id
+	rows, err := q.rdb.Query(pushSubscriptionSelect + `
+		JOIN users u ON u.id = ps.user_id
 		WHERE ps.enabled = 1
-		  AND u.role = 'BARTENDER'
 		  AND u.is_active = 1
 		  AND u.on_duty = 1
 		ORDER BY ps.updated_at DESC, ps.id DESC`)
@@ -753,11 +2940,36 @@ func (q *Queries) ListPushSubscriptionsForOnDutyBartenders() ([]PushSubscription
 	return out, nil
 }
 
+func (q *Queries) GetPushSubscription(id int64) (*PushSubscription, error) {
+	sub, err := scanPushSubscription(q.rdb.QueryRow(pushSubscriptionSelect+` WHERE ps.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// UpdatePushSubscriptionForUser renames one of the user's devices and sets what it is sent.
+func (q *Queries) UpdatePushSubscriptionForUser(userID, id int64, label string, prefs PushPreferences) error {
+	_, err := q.db.Exec(`
+		UPDATE push_subscriptions
+		SET device_label=?, notify_new_orders=?, notify_overdue=?, notify_low_stock=?, only_assigned=?,
+			quiet_start=?, quiet_end=?, quiet_zone=?, updated_at=?
+		WHERE id=? AND user_id=?`,
+		label, b2i(prefs.NewOrders), b2i(prefs.Overdue), b2i(prefs.LowStock), b2i(prefs.OnlyAssigned),
+		prefs.QuietStart, prefs.QuietEnd, prefs.QuietZone, unixNow(), id, userID)
+	return err
+}
+
+func (q *Queries) DeletePushSubscriptionForUser(userID, id int64) error {
+	_, err := q.db.Exec(`DELETE FROM push_subscriptions WHERE id=? AND user_id=?`, id, userID)
+	return err
+}
+
 func (q *Queries) DisablePushSubscriptionForUser(userID int64, endpoint string) error {
 	_, err := q.db.Exec(`
 		UPDATE push_subscriptions
 		SET enabled=0, updated_at=?
-		WHERE bartender_user_id=? AND endpoint=?`, unixNow(), userID, endpoint)
+		WHERE user_id=? AND endpoint=?`, unixNow(), userID, endpoint)
 	return err
 }
 
@@ -788,6 +3000,279 @@ func (q *Queries) DisablePushSubscriptionByEndpoint(endpoint string) error {
 	return err
 }
 
+// ClaimOverdueOrders marks the open orders placed before before as alerted about and returns
+// them, oldest first. Each order is claimed once.
+func (q *Queries) ClaimOverdueOrders(before time.Time) ([]int64, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(`
+		SELECT id FROM orders
+		WHERE status IN ('PLACED','ACCEPTED','IN_PROGRESS')
+		  AND created_at < ?
+		  AND overdue_notified_at IS NULL
+		ORDER BY created_at ASC, id ASC`, before.Unix())
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			_ = tx.Rollback()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	now := unixNow()
+	for _, id := range ids {
+		if _, err := tx.Exec(`UPDATE orders SET overdue_notified_at=? WHERE id=?`, now, id); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+/* ---------------- Webhooks ---------------- */
+
+const webhookEndpointSelect = `
+	SELECT
+		e.id,e.name,e.url,e.secret,e.events,e.is_enabled,e.created_at,e.updated_at,
+		(SELECT COUNT(*) FROM webhook_deliveries d WHERE d.endpoint_id=e.id AND d.status='PENDING'),
+		(SELECT COUNT(*) FROM webhook_deliveries d WHERE d.endpoint_id=e.id AND d.status='FAILED')
+	FROM webhook_endpoints e`
+
+func scanWebhookEndpoint(scanner rowScanner) (*WebhookEndpoint, error) {
+	var e WebhookEndpoint
+	var events string
+	var enabled int
+	var ca, ua int64
+	if err := scanner.Scan(&e.ID, &e.Name, &e.URL, &e.Secret, &events, &enabled, &ca, &ua, &e.Pending, &e.Failed); err != nil {
+		return nil, err
+	}
+	e.Events = splitPermissions(events)
+	e.IsEnabled = i2b(enabled)
+	e.CreatedAt = tFromUnix(ca)
+	e.UpdatedAt = tFromUnix(ua)
+	return &e, nil
+}
+
+func (q *Queries) listWebhookEndpoints(where string, args ...any) ([]WebhookEndpoint, error) {
+	rows, err := q.rdb.Query(webhookEndpointSelect+where+` ORDER BY e.name, e.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WebhookEndpoint
+	for rows.Next() {
+		e, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *e)
+	}
+	return out, rows.Err()
+}
+
+func (q *Queries) ListWebhookEndpoints() ([]WebhookEndpoint, error) {
+	return q.listWebhookEndpoints("")
+}
+
+// ListWebhookEndpointsForEvent returns the enabled endpoints subscribed to event.
+func (q *Queries) ListWebhookEndpointsForEvent(event string) ([]WebhookEndpoint, error) {
+	list, err := q.listWebhookEndpoints(` WHERE e.is_enabled=1`)
+	if err != nil {
+		return nil, err
+	}
+	var out []WebhookEndpoint
+	for _, e := range list {
+		if e.Subscribes(event) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (q *Queries) GetWebhookEndpoint(id int64) (*WebhookEndpoint, error) {
+	e, err := scanWebhookEndpoint(q.rdb.QueryRow(webhookEndpointSelect+` WHERE e.id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+func (q *Queries) CreateWebhookEndpoint(name, url, secret string, events []string, enabled bool) (int64, error) {
+	now := unixNow()
+	res, err := q.db.Exec(`
+		INSERT INTO webhook_endpoints(name,url,secret,events,is_enabled,created_at,updated_at)
+		VALUES(?,?,?,?,?,?,?)`, name, url, secret, strings.Join(events, ","), b2i(enabled), now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (q *Queries) UpdateWebhookEndpoint(id int64, name, url string, events []string, enabled bool) error {
+	_, err := q.db.Exec(`
+		UPDATE webhook_endpoints SET name=?, url=?, events=?, is_enabled=?, updated_at=?
+		WHERE id=?`, name, url, strings.Join(events, ","), b2i(enabled), unixNow(), id)
+	return err
+}
+
+func (q *Queries) SetWebhookEndpointSecret(id int64, secret string) error {
+	_, err := q.db.Exec(`UPDATE webhook_endpoints SET secret=?, updated_at=? WHERE id=?`, secret, unixNow(), id)
+	return err
+}
+
+// DeleteWebhookEndpoint removes the endpoint with its queued and logged deliveries.
+func (q *Queries) DeleteWebhookEndpoint(id int64) error {
+	_, err := q.db.Exec(`DELETE FROM webhook_endpoints WHERE id=?`, id)
+	return err
+}
+
+const webhookDeliverySelect = `
+	SELECT id,endpoint_id,event_id,event,payload,status,attempts,next_attempt_at,
+		response_status,response_body,error,created_at,updated_at
+	FROM webhook_deliveries`
+
+func scanWebhookDelivery(scanner rowScanner) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	var next, ca, ua int64
+	if err := scanner.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &next,
+		&d.ResponseStatus, &d.ResponseBody, &d.Error, &ca, &ua); err != nil {
+		return nil, err
+	}
+	d.NextAttemptAt = tFromUnix(next)
+	d.CreatedAt = tFromUnix(ca)
+	d.UpdatedAt = tFromUnix(ua)
+	return &d, nil
+}
+
+func (q *Queries) listWebhookDeliveries(query string, args ...any) ([]WebhookDelivery, error) {
+	rows, err := q.rdb.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *d)
+	}
+	return out, rows.Err()
+}
+
+// CreateWebhookDeliveries queues the event for each endpoint, due now, and returns the
+// deliveries' IDs in the same order.
+func (q *Queries) CreateWebhookDeliveries(endpointIDs []int64, eventID, event, payload string) ([]int64, error) {
+	if len(endpointIDs) == 0 {
+		return nil, nil
+	}
+	tx, err := q.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	now := unixNow()
+	ids := make([]int64, 0, len(endpointIDs))
+	for _, id := range endpointIDs {
+		res, err := tx.Exec(`
+			INSERT INTO webhook_deliveries(endpoint_id,event_id,event,payload,status,next_attempt_at,created_at,updated_at)
+			VALUES(?,?,?,?,'PENDING',?,?,?)`, id, eventID, event, payload, now, now, now)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+		did, err := res.LastInsertId()
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+		ids = append(ids, did)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// RedeliverWebhookDelivery queues a copy of a delivery, due now, and returns its ID, or
+// zero when there is no such delivery.
+func (q *Queries) RedeliverWebhookDelivery(id int64) (int64, error) {
+	now := unixNow()
+	res, err := q.db.Exec(`
+		INSERT INTO webhook_deliveries(endpoint_id,event_id,event,payload,status,next_attempt_at,created_at,updated_at)
+		SELECT endpoint_id,event_id,event,payload,'PENDING',?,?,? FROM webhook_deliveries WHERE id=?`, now, now, now, id)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, nil
+	}
+	return res.LastInsertId()
+}
+
+func (q *Queries) GetWebhookDelivery(id int64) (*WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(q.rdb.QueryRow(webhookDeliverySelect+` WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// ListDueWebhookDeliveries returns the pending deliveries to enabled endpoints whose next
+// attempt is due at now, oldest first.
+func (q *Queries) ListDueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	return q.listWebhookDeliveries(webhookDeliverySelect+`
+		WHERE status='PENDING' AND next_attempt_at <= ?
+		  AND endpoint_id IN (SELECT id FROM webhook_endpoints WHERE is_enabled=1)
+		ORDER BY next_attempt_at, id
+		LIMIT ?`, now.Unix(), limit)
+}
+
+// ListWebhookDeliveries returns an endpoint's latest deliveries, newest first.
+func (q *Queries) ListWebhookDeliveries(endpointID int64, limit int) ([]WebhookDelivery, error) {
+	return q.listWebhookDeliveries(webhookDeliverySelect+`
+		WHERE endpoint_id=?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`, endpointID, limit)
+}
+
+// RecordWebhookAttempt stores the outcome of one attempt at a pending delivery.
+func (q *Queries) RecordWebhookAttempt(id int64, a WebhookAttempt) error {
+	_, err := q.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status=?, attempts=attempts+1, next_attempt_at=?, response_status=?, response_body=?, error=?, updated_at=?
+		WHERE id=? AND status='PENDING'`,
+		a.Status, a.NextAttemptAt.Unix(), a.ResponseStatus, a.ResponseBody, a.Error, unixNow(), id)
+	return err
+}
+
+// PruneWebhookDeliveries drops finished deliveries created before before.
+func (q *Queries) PruneWebhookDeliveries(before time.Time) (int64, error) {
+	res, err := q.db.Exec(`DELETE FROM webhook_deliveries WHERE status!='PENDING' AND created_at < ?`, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 /* ---------------- Debug ---------------- */
 
 func (q *Queries) DebugCounts() (string, error) {
@@ -804,7 +3289,7 @@ func (q *Queries) DebugCounts() (string, error) {
 	}
 	var parts []string
 	for _, it := range checks {
-		row := q.db.QueryRow(it.qry)
+		row := q.rdb.QueryRow(it.qry)
 		var n int
 		if err := row.Scan(&n); err != nil {
 			return "", err
@@ -0,0 +1,336 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Full-text search lives in two FTS5 tables kept in sync by triggers. FTS5 is only compiled
+// into go-sqlite3 with the sqlite_fts5 build tag; without it every search falls back to LIKE.
+// What people type becomes an FTS5 match expression: every word matches as a prefix, and
+// words that look like typos also match the indexed terms they are a small edit away from.
+
+const cocktailsFTSRefresh = `
+	DELETE FROM cocktails_fts WHERE rowid IN (%[1]s);
+	INSERT INTO cocktails_fts(rowid,name,tags,ingredients,description,instructions)
+	SELECT c.id, COALESCE(c.name,''), COALESCE(c.tags,''),
+		COALESCE((SELECT group_concat(p.name,' ') FROM cocktail_ingredients ci JOIN products p ON p.id = ci.product_id WHERE ci.cocktail_id = c.id),''),
+		COALESCE(c.description,''), COALESCE(c.instructions,'')
+	FROM cocktails c WHERE c.id IN (%[1]s);`
+
+const productsFTSRefresh = `
+	DELETE FROM products_fts WHERE rowid = %[1]s;
+	INSERT INTO products_fts(rowid,name,category)
+	SELECT p.id, COALESCE(p.name,''), COALESCE(p.category,'') FROM products p WHERE p.id = %[1]s;`
+
+var searchTriggers = []struct {
+	name, on, body string
+}{
+	{"search_cocktails_ai", "AFTER INSERT ON cocktails", fmt.Sprintf(cocktailsFTSRefresh, "new.id")},
+	{"search_cocktails_au", "AFTER UPDATE ON cocktails", fmt.Sprintf(cocktailsFTSRefresh, "new.id")},
+	{"search_cocktails_ad", "AFTER DELETE ON cocktails", `DELETE FROM cocktails_fts WHERE rowid = old.id;`},
+	{"search_ingredients_ai", "AFTER INSERT ON cocktail_ingredients", fmt.Sprintf(cocktailsFTSRefresh, "new.cocktail_id")},
+	{"search_ingredients_au", "AFTER UPDATE ON cocktail_ingredients", fmt.Sprintf(cocktailsFTSRefresh, "old.cocktail_id, new.cocktail_id")},
+	{"search_ingredients_ad", "AFTER DELETE ON cocktail_ingredients", fmt.Sprintf(cocktailsFTSRefresh, "old.cocktail_id")},
+	{"search_products_ai", "AFTER INSERT ON products", fmt.Sprintf(productsFTSRefresh, "new.id")},
+	{"search_products_au", "AFTER UPDATE OF name, category ON products",
+		fmt.Sprintf(productsFTSRefresh, "new.id") +
+			fmt.Sprintf(cocktailsFTSRefresh, "SELECT cocktail_id FROM cocktail_ingredients WHERE product_id = new.id")},
+	{"search_products_ad", "AFTER DELETE ON products", `DELETE FROM products_fts WHERE rowid = old.id;`},
+}
+
+// SetupSearch creates the FTS5 index and its triggers and rebuilds it from scratch, so writes
+// made by a build without FTS5 are picked up. It reports false, after removing the triggers,
+// when this build has no FTS5; Queries then searches with LIKE instead.
+func (s *Store) SetupSearch() (bool, error) {
+	var enabled int
+	if err := s.DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		return false, err
+	}
+	if enabled == 0 {
+		// Triggers left by an FTS5 build would make every write fail here.
+		for _, t := range searchTriggers {
+			if _, err := s.DB.Exec(`DROP TRIGGER IF EXISTS ` + t.name); err != nil {
+				return false, err
+			}
+		}
+		s.Q.fts = false
+		return false, nil
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	stmts := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS cocktails_fts USING fts5(
+			name, tags, ingredients, description, instructions,
+			tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3')`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5(
+			name, category,
+			tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3')`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS cocktails_fts_vocab USING fts5vocab(cocktails_fts, 'row')`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS products_fts_vocab USING fts5vocab(products_fts, 'row')`,
+	}
+	for _, t := range searchTriggers {
+		stmts = append(stmts, fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s %s BEGIN %s END", t.name, t.on, t.body))
+	}
+	stmts = append(stmts,
+		`DELETE FROM cocktails_fts`,
+		fmt.Sprintf(cocktailsFTSRefresh, "SELECT id FROM cocktails"),
+		`DELETE FROM products_fts`,
+		`INSERT INTO products_fts(rowid,name,category) SELECT id, COALESCE(name,''), COALESCE(category,'') FROM products`,
+	)
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	s.Q.fts = true
+	return true, nil
+}
+
+// matchExpression expands a query against an FTS vocabulary table for typo tolerance.
+func (q *Queries) matchExpression(vocabTable, query string) (string, error) {
+	words := searchWords(query)
+	if len(words) == 0 {
+		return "", nil
+	}
+
+	var vocab []string
+	rows, err := q.rdb.Query(`SELECT term FROM ` + vocabTable)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return "", err
+		}
+		vocab = append(vocab, term)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return ftsMatch(expandTerms(words, vocab)), nil
+}
+
+/* ---------------- Cocktail search ---------------- */
+
+// SearchCocktailIDs returns one page of the cocktails matching the query, best match first,
+// and how many match in all. within, when not nil, limits the search to those cocktails, so
+// the caller's availability filters apply before paging. With FTS5 matches are ranked by
+// relevance; otherwise they match by substring and come in name order. A limit of 0 means
+// no limit.
+func (q *Queries) SearchCocktailIDs(query string, within []int64, limit, offset int) ([]int64, int, error) {
+	query = strings.TrimSpace(query)
+	if within != nil && len(within) == 0 {
+		return nil, 0, nil
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	var args []any
+	scope := func(string) string { return "" }
+	if within != nil {
+		ids, err := json.Marshal(within)
+		if err != nil {
+			return nil, 0, err
+		}
+		scope = func(col string) string { return ` AND ` + col + ` IN (SELECT value FROM json_each(?))` }
+		args = append(args, string(ids))
+	}
+
+	var rows *sql.Rows
+	var err error
+	if q.fts {
+		match, err := q.matchExpression("cocktails_fts_vocab", query)
+		if err != nil || match == "" {
+			return nil, 0, err
+		}
+		// Column weights follow cocktails_fts: name, tags, ingredients, description, instructions.
+		rows, err = q.rdb.Query(`
+			SELECT id, COUNT(*) OVER () FROM (
+				SELECT rowid AS id, bm25(cocktails_fts, 10.0, 5.0, 4.0, 2.0, 1.0) AS score
+				FROM cocktails_fts WHERE cocktails_fts MATCH ?`+scope("rowid")+`
+			) ORDER BY score LIMIT ? OFFSET ?`,
+			append(append([]any{match}, args...), limit, offset)...)
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		like := "%" + strings.ToLower(query) + "%"
+		rows, err = q.rdb.Query(`
+			SELECT c.id, COUNT(*) OVER () FROM cocktails c
+			WHERE (lower(c.name) LIKE ? OR lower(COALESCE(c.description,'')) LIKE ? OR lower(COALESCE(c.tags,'')) LIKE ?
+				OR lower(COALESCE(c.difficulty,'')) LIKE ? OR EXISTS (
+					SELECT 1 FROM cocktail_ingredients ci JOIN products p ON p.id = ci.product_id
+					WHERE ci.cocktail_id = c.id AND lower(p.name) LIKE ?))`+scope("c.id")+`
+			ORDER BY c.name LIMIT ? OFFSET ?`,
+			append(append([]any{like, like, like, like, like}, args...), limit, offset)...)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	defer rows.Close()
+
+	var out []int64
+	total := 0
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(out) == 0 && offset > 0 {
+		// past the last page; the window count came back with no rows
+		_, total, err := q.SearchCocktailIDs(query, within, 1, 0)
+		return nil, total, err
+	}
+	return out, total, nil
+}
+
+/* ---------------- Product search ---------------- */
+
+func (q *Queries) searchProducts(query string) (*sql.Rows, error) {
+	if !q.fts {
+		like := "%" + strings.ToLower(query) + "%"
+		return q.rdb.Query(productSelect()+`
+			WHERE lower(p.name) LIKE ? OR lower(p.category) LIKE ? OR p.barcode = ?
+			ORDER BY p.category, p.name`, like, like, query)
+	}
+
+	match, err := q.matchExpression("products_fts_vocab", query)
+	if err != nil {
+		return nil, err
+	}
+	if match == "" {
+		return q.rdb.Query(productSelect()+` WHERE p.barcode = ?`, query)
+	}
+	return q.rdb.Query(productSelect()+`
+		LEFT JOIN (
+			SELECT rowid AS id, bm25(products_fts, 5.0, 1.0) AS score
+			FROM products_fts WHERE products_fts MATCH ?
+		) hits ON hits.id = p.id
+		WHERE hits.id IS NOT NULL OR p.barcode = ?
+		ORDER BY COALESCE(hits.score, -1e9), p.category, p.name`, match, query)
+}
+
+/* ---------------- Typo tolerance ---------------- */
+
+// searchTerm is one query word plus the indexed vocabulary it should also match.
+type searchTerm struct {
+	Text     string
+	Variants []string
+}
+
+// searchWords splits a query into lowercase words, dropping punctuation and FTS syntax.
+func searchWords(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// typoDistance is how many edits a word may be from an indexed term and still match.
+// Short words get no tolerance; they already match broadly as prefixes.
+func typoDistance(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4:
+		return 0
+	case n < 7:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// similarTerm reports whether term is within typoDistance edits of word, either as a whole
+// or through its leading runes, so "margr" finds "margarita".
+func similarTerm(word, term string) bool {
+	max := typoDistance(word)
+	if max == 0 || word == term {
+		return false
+	}
+	w, t := []rune(word), []rune(term)
+	if editDistance(w, t) <= max {
+		return true
+	}
+	return len(t) > len(w) && editDistance(w, t[:len(w)]) <= max
+}
+
+// expandTerms pairs every word with the vocabulary terms it is similar to.
+func expandTerms(words []string, vocab []string) []searchTerm {
+	out := make([]searchTerm, 0, len(words))
+	for _, w := range words {
+		t := searchTerm{Text: w}
+		for _, v := range vocab {
+			if strings.HasPrefix(v, w) {
+				continue // already covered by the prefix query
+			}
+			if similarTerm(w, v) {
+				t.Variants = append(t.Variants, v)
+			}
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// ftsMatch builds an FTS5 query requiring every term, each as a prefix or one of its
+// variants. It returns "" when there is nothing to match.
+func ftsMatch(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if t.Text == "" {
+			continue
+		}
+		alts := []string{ftsQuote(t.Text) + "*"}
+		for _, v := range t.Variants {
+			alts = append(alts, ftsQuote(v))
+		}
+		if len(alts) == 1 {
+			parts = append(parts, alts[0])
+			continue
+		}
+		parts = append(parts, "("+strings.Join(alts, " OR ")+")")
+	}
+	return strings.Join(parts, " AND ")
+}
+
+func ftsQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
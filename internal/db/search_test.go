@@ -0,0 +1,242 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newSearchStore is a migrated store with search set up, holding three gin cocktails and
+// one with rum. It reports whether this build has FTS5.
+func newSearchStore(t *testing.T) (*Store, bool, map[string]int64) {
+	t.Helper()
+	s := newTestStore(t)
+	fts, err := s.SetupSearch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO products(id, name, category) VALUES (1, 'London Dry Gin', 'Spirit'), (2, 'White Rum', 'Spirit'), (3, 'Lime', 'Fresh')`,
+		`INSERT INTO cocktails(id, name, tags) VALUES (1, 'Gin Fizz', 'fizzy'), (2, 'Southside', 'mint'), (3, 'Gimlet', 'sour'), (4, 'Daiquiri', 'sour')`,
+		`INSERT INTO cocktail_ingredients(cocktail_id, product_id) VALUES (1, 1), (2, 1), (3, 1), (3, 3), (4, 2), (4, 3)`,
+	} {
+		if _, err := s.DB.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, fts, map[string]int64{"Gin Fizz": 1, "Southside": 2, "Gimlet": 3, "Daiquiri": 4}
+}
+
+func TestSearchCocktailIDsPagesInSQL(t *testing.T) {
+	s, _, ids := newSearchStore(t)
+	q := s.Q
+
+	page, total, err := q.SearchCocktailIDs("gin", nil, 2, 0)
+	if err != nil || total != 3 || len(page) != 2 {
+		t.Fatalf("first page = %v of %d, %v", page, total, err)
+	}
+	rest, total, _ := q.SearchCocktailIDs("gin", nil, 2, 2)
+	if total != 3 || len(rest) != 1 || rest[0] == page[0] || rest[0] == page[1] {
+		t.Fatalf("second page = %v of %d after %v", rest, total, page)
+	}
+	if past, total, _ := q.SearchCocktailIDs("gin", nil, 2, 10); len(past) != 0 || total != 3 {
+		t.Fatalf("past the end = %v of %d", past, total)
+	}
+	if all, _, _ := q.SearchCocktailIDs("gin", nil, 0, 0); len(all) != 3 {
+		t.Fatalf("no limit = %v", all)
+	}
+
+	within := []int64{ids["Gimlet"], ids["Daiquiri"]}
+	if got, total, _ := q.SearchCocktailIDs("gin", within, 10, 0); total != 1 || len(got) != 1 || got[0] != ids["Gimlet"] {
+		t.Fatalf("within %v = %v of %d", within, got, total)
+	}
+	if got, total, _ := q.SearchCocktailIDs("gin", []int64{}, 10, 0); total != 0 || len(got) != 0 {
+		t.Fatalf("within nothing = %v of %d", got, total)
+	}
+}
+
+// searchNames runs SearchCocktailIDs and returns the names found, in order.
+func searchNames(t *testing.T, s *Store, query string) []string {
+	t.Helper()
+	ids, _, err := s.Q.SearchCocktailIDs(query, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("search %q: %v", query, err)
+	}
+	var out []string
+	for _, id := range ids {
+		c, _ := s.Q.GetCocktailByID(id)
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+func TestSearchTriggersFollowWrites(t *testing.T) {
+	s, fts, ids := newSearchStore(t)
+	if !fts {
+		t.Skip("built without sqlite_fts5")
+	}
+	exec := func(stmt string, args ...any) {
+		t.Helper()
+		if _, err := s.DB.Exec(stmt, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exec(`UPDATE cocktails SET name='Ramos Fizz' WHERE id=?`, ids["Gin Fizz"])
+	if got := searchNames(t, s, "ramos"); len(got) != 1 {
+		t.Fatalf("renamed cocktail: %v", got)
+	}
+	exec(`INSERT INTO products(id, name, category) VALUES (4, 'Mint', 'Fresh')`)
+	exec(`INSERT INTO cocktail_ingredients(cocktail_id, product_id) VALUES (?, 4)`, ids["Daiquiri"])
+	if got := searchNames(t, s, "mint"); len(got) != 2 {
+		t.Fatalf("added ingredient: %v", got)
+	}
+	exec(`DELETE FROM cocktail_ingredients WHERE cocktail_id=? AND product_id=4`, ids["Daiquiri"])
+	if got := searchNames(t, s, "mint"); !reflect.DeepEqual(got, []string{"Southside"}) {
+		t.Fatalf("removed ingredient: %v", got)
+	}
+	exec(`UPDATE products SET name='Cane Rum' WHERE id=2`)
+	if got := searchNames(t, s, "cane"); !reflect.DeepEqual(got, []string{"Daiquiri"}) {
+		t.Fatalf("renamed product: %v", got)
+	}
+	if list, _ := s.Q.ListProducts("cane"); len(list) != 1 || list[0].Name != "Cane Rum" {
+		t.Fatalf("product search after rename: %+v", list)
+	}
+	exec(`DELETE FROM cocktails WHERE id=?`, ids["Gimlet"])
+	if got := searchNames(t, s, "gimlet"); len(got) != 0 {
+		t.Fatalf("deleted cocktail still found: %v", got)
+	}
+	exec(`DELETE FROM products WHERE id=4`)
+	if list, _ := s.Q.ListProducts("mint"); len(list) != 0 {
+		t.Fatalf("deleted product still found: %+v", list)
+	}
+
+	// typos match indexed terms
+	if got := searchNames(t, s, "daiqiri"); !reflect.DeepEqual(got, []string{"Daiquiri"}) {
+		t.Fatalf("typo: %v", got)
+	}
+	if got := searchNames(t, s, "rammos"); !reflect.DeepEqual(got, []string{"Ramos Fizz"}) {
+		t.Fatalf("typo in a renamed cocktail: %v", got)
+	}
+}
+
+func TestSetupSearchIndexesEarlierWrites(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.DB.Exec(`INSERT INTO cocktails(id, name, tags) VALUES (1, 'Paloma', 'grapefruit')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetupSearch(); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchNames(t, s, "grapefruit"); !reflect.DeepEqual(got, []string{"Paloma"}) {
+		t.Fatalf("written before setup: %v", got)
+	}
+}
+
+func TestSearchFallsBackToLike(t *testing.T) {
+	s, fts, _ := newSearchStore(t)
+	if fts {
+		// the same store, as a build without FTS5 would search it
+		s.Q.fts = false
+	}
+	if got := searchNames(t, s, "IZZ"); !reflect.DeepEqual(got, []string{"Gin Fizz"}) {
+		t.Fatalf("substring: %v", got)
+	}
+	if got := searchNames(t, s, "gin"); !reflect.DeepEqual(got, []string{"Gimlet", "Gin Fizz", "Southside"}) {
+		t.Fatalf("by ingredient, in name order: %v", got)
+	}
+	if got := searchNames(t, s, "sour"); !reflect.DeepEqual(got, []string{"Daiquiri", "Gimlet"}) {
+		t.Fatalf("by tag: %v", got)
+	}
+	if _, err := s.DB.Exec(`UPDATE products SET barcode='4006' WHERE id=3`); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.Q.ListProducts("4006"); len(list) != 1 || list[0].Name != "Lime" {
+		t.Fatalf("product by barcode: %+v", list)
+	}
+	if list, _ := s.Q.ListProducts("spir"); len(list) != 2 {
+		t.Fatalf("product by category: %+v", list)
+	}
+}
+
+func TestSetupSearchWithoutFTSDropsStaleTriggers(t *testing.T) {
+	s := newTestStore(t)
+	var enabled int
+	_ = s.DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	if enabled == 1 {
+		t.Skip("built with sqlite_fts5")
+	}
+	// a trigger left by an FTS5 build; its table doesn't exist here
+	if _, err := s.DB.Exec(`CREATE TRIGGER search_products_ai AFTER INSERT ON products BEGIN DELETE FROM products_fts WHERE rowid = new.id; END`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec(`INSERT INTO products(name, category) VALUES ('Gin', 'Spirit')`); err == nil {
+		t.Fatal("the stale trigger should break writes until it is dropped")
+	}
+	if fts, err := s.SetupSearch(); err != nil || fts {
+		t.Fatalf("SetupSearch = %v, %v", fts, err)
+	}
+	if _, err := s.DB.Exec(`INSERT INTO products(name, category) VALUES ('Gin', 'Spirit')`); err != nil {
+		t.Fatalf("write after SetupSearch: %v", err)
+	}
+}
+
+func TestSearchWordsDropPunctuationAndSyntax(t *testing.T) {
+	got := searchWords(`  Gin-Tonic "OR" lime*  `)
+	want := []string{"gin", "tonic", "or", "lime"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("searchWords = %v, want %v", got, want)
+	}
+}
+
+func TestSimilarTermToleratesTyposByLength(t *testing.T) {
+	cases := []struct {
+		word, term string
+		want       bool
+	}{
+		{"rum", "gum", false},           // too short for tolerance
+		{"mojto", "mojito", true},       // one missing letter
+		{"margr", "margarita", true},    // typo in a partial word
+		{"negorni", "negroni", true},    // transposition costs two edits
+		{"mint", "lime", false},         // too far apart
+		{"daiquiri", "daiquiri", false}, // exact terms come from the prefix query
+		{"whsikey", "whiskey", true},
+	}
+	for _, c := range cases {
+		if got := similarTerm(c.word, c.term); got != c.want {
+			t.Errorf("similarTerm(%q, %q) = %v, want %v", c.word, c.term, got, c.want)
+		}
+	}
+}
+
+func TestExpandTermsSkipsTermsCoveredByPrefix(t *testing.T) {
+	terms := expandTerms([]string{"mojto"}, []string{"mojito", "mojtos", "mint"})
+	if len(terms) != 1 {
+		t.Fatalf("got %d terms", len(terms))
+	}
+	if want := []string{"mojito"}; !reflect.DeepEqual(terms[0].Variants, want) {
+		t.Fatalf("variants = %v, want %v", terms[0].Variants, want)
+	}
+}
+
+func TestFTSMatch(t *testing.T) {
+	got := ftsMatch([]searchTerm{
+		{Text: "gin"},
+		{Text: "tonc", Variants: []string{"tonic"}},
+	})
+	want := `"gin"* AND ("tonc"* OR "tonic")`
+	if got != want {
+		t.Fatalf("ftsMatch = %s, want %s", got, want)
+	}
+	if got := ftsMatch(nil); got != "" {
+		t.Fatalf("empty ftsMatch = %q", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	if d := editDistance([]rune("kitten"), []rune("sitting")); d != 3 {
+		t.Fatalf("editDistance = %d, want 3", d)
+	}
+	if d := editDistance([]rune(""), []rune("abc")); d != 3 {
+		t.Fatalf("editDistance = %d, want 3", d)
+	}
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"testing"
+)
+
+// stationFixture is a main bar and a patio, a Martini made at the main bar and a Spritz
+// routed to the patio. Gin is tracked at the main bar; the patio tracks Aperol and keeps
+// Prosecco untracked.
+type stationFixture struct {
+	s                        *Store
+	mainBar, patio           int64
+	gin, aperol, prosecco    int64
+	martini, spritz, guestID int64
+}
+
+func newStationFixture(t *testing.T) *stationFixture {
+	t.Helper()
+	s := newTestStore(t)
+	q := s.Q
+	f := &stationFixture{s: s}
+
+	main, err := q.DefaultStation()
+	if err != nil || main == nil {
+		t.Fatalf("DefaultStation = %v, %v", main, err)
+	}
+	f.mainBar = main.ID
+	if f.patio, err = q.CreateStation("Patio"); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO products(id, name, category, stock_count, is_available) VALUES
+			(1, 'Gin', 'Spirit', 5, 1), (2, 'Aperol', 'Liqueur', NULL, 1), (3, 'Prosecco', 'Wine', NULL, 0)`,
+		`INSERT INTO cocktails(id, name, is_enabled) VALUES (1, 'Martini', 1), (2, 'Spritz', 1)`,
+		`INSERT INTO cocktail_ingredients(cocktail_id, product_id, quantity, unit, required) VALUES
+			(1, 1, 60, 'ml', 1), (2, 2, 60, 'ml', 1), (2, 3, 90, 'ml', 1)`,
+		`INSERT INTO users(id, email, password_hash, role, display_name) VALUES (1, 'guest@example.com', 'h', 'USER', 'Guest')`,
+	} {
+		if _, err := s.DB.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.gin, f.aperol, f.prosecco = 1, 2, 3
+	f.martini, f.spritz, f.guestID = 1, 2, 1
+	if err := q.ReplaceCocktailRoutes(map[int64]int64{f.spritz: f.patio}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *stationFixture) available(t *testing.T) map[int64]bool {
+	t.Helper()
+	list, err := f.s.Q.ListCocktailsComputed(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[int64]bool{}
+	for _, c := range list {
+		out[c.ID] = c.ComputedAvail
+	}
+	return out
+}
+
+func TestStationAvailability(t *testing.T) {
+	f := newStationFixture(t)
+	q := f.s.Q
+
+	// nothing is stocked at the patio yet, though the main bar has it all but the Prosecco
+	if got := f.available(t); !got[f.martini] || got[f.spritz] {
+		t.Fatalf("before stocking the patio: %v", got)
+	}
+	n := int64(2)
+	_ = q.SetStationStock(f.patio, f.aperol, true, true, &n)
+	_ = q.SetStationStock(f.patio, f.prosecco, true, true, nil)
+	if got := f.available(t); !got[f.spritz] {
+		t.Fatal("the Spritz should follow the patio's stock, not the main bar's")
+	}
+	_ = q.SetStationStock(f.patio, f.prosecco, true, false, nil)
+	if got := f.available(t); got[f.spritz] {
+		t.Fatal("Prosecco marked out at the patio should take the Spritz off")
+	}
+	_ = q.SetStationStock(f.patio, f.prosecco, false, false, nil)
+	if got := f.available(t); got[f.spritz] {
+		t.Fatal("Prosecco unstocked at the patio should take the Spritz off")
+	}
+
+	// the main bar reads the products themselves
+	if _, err := f.s.DB.Exec(`UPDATE products SET stock_count=0 WHERE id=?`, f.gin); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.available(t); got[f.martini] {
+		t.Fatal("the Martini should be out with no gin at the main bar")
+	}
+	ings, err := q.GetCocktailIngredients(f.spritz)
+	if err != nil || len(ings) != 2 {
+		t.Fatalf("GetCocktailIngredients = %v, %v", ings, err)
+	}
+	for _, ing := range ings {
+		if want := ing.ProductID == f.aperol; ing.ProductAvail != want {
+			t.Errorf("%s at the patio: avail %v, want %v", ing.ProductName, ing.ProductAvail, want)
+		}
+	}
+}
+
+func TestCocktailStation(t *testing.T) {
+	f := newStationFixture(t)
+	q := f.s.Q
+
+	for cid, want := range map[int64]int64{f.martini: f.mainBar, f.spritz: f.patio} {
+		st, err := q.CocktailStation(cid)
+		if err != nil || st == nil || st.ID != want {
+			t.Fatalf("CocktailStation(%d) = %+v, %v, want station %d", cid, st, err, want)
+		}
+	}
+	if main, _ := q.GetStation(f.mainBar); main.Cocktails != 1 {
+		t.Errorf("main bar makes %d cocktails, want 1", main.Cocktails)
+	}
+}
+
+func TestReplaceCocktailRoutes(t *testing.T) {
+	f := newStationFixture(t)
+	q := f.s.Q
+
+	// routes to the main bar or to stations that don't exist are not stored
+	if err := q.ReplaceCocktailRoutes(map[int64]int64{f.martini: f.mainBar, f.spritz: 999}); err != nil {
+		t.Fatal(err)
+	}
+	if routes, _ := q.ListCocktailRoutes(); len(routes) != 0 {
+		t.Fatalf("routes = %v, want none", routes)
+	}
+	if err := q.ReplaceCocktailRoutes(map[int64]int64{f.martini: f.patio}); err != nil {
+		t.Fatal(err)
+	}
+	routes, _ := q.ListCocktailRoutes()
+	if len(routes) != 1 || routes[f.martini] != f.patio {
+		t.Fatalf("routes = %v, want only the Martini at the patio", routes)
+	}
+}
+
+func TestCreateOrderDepletesTheOrderStation(t *testing.T) {
+	f := newStationFixture(t)
+	q := f.s.Q
+	n := int64(3)
+	_ = q.SetStationStock(f.patio, f.aperol, true, true, &n)
+	_ = q.SetStationStock(f.patio, f.gin, true, true, &n)
+
+	order := func(station int64, d ...StockDepletion) {
+		t.Helper()
+		if _, err := q.CreateOrder(CreateOrderParams{UserID: f.guestID, CocktailID: f.spritz, Quantity: 1, StationID: station, Depletions: d}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	order(f.patio, StockDepletion{ProductID: f.aperol, Units: 1}, StockDepletion{ProductID: f.gin, Units: 3}, StockDepletion{ProductID: f.prosecco, Units: 1})
+	if got, _ := q.StationStockCount(f.patio, f.aperol); got == nil || *got != 2 {
+		t.Errorf("patio Aperol = %v, want 2", got)
+	}
+	if got, _ := q.StationStockCount(f.patio, f.gin); got == nil || *got != 0 {
+		t.Errorf("patio gin = %v, want 0", got)
+	}
+	if got, _ := q.StationStockCount(f.mainBar, f.gin); got == nil || *got != 5 {
+		t.Errorf("a patio order took main bar gin: %v left", got)
+	}
+	if got := count(t, f.s, `SELECT COUNT(*) FROM station_stock WHERE product_id=?`, f.prosecco); got != 0 {
+		t.Error("depleting an unstocked product stocked it")
+	}
+
+	order(f.mainBar, StockDepletion{ProductID: f.gin, Units: 2}, StockDepletion{ProductID: f.aperol, Units: 1})
+	if got, _ := q.StationStockCount(f.mainBar, f.gin); got == nil || *got != 3 {
+		t.Errorf("main bar gin = %v, want 3", got)
+	}
+	if got, _ := q.StationStockCount(f.patio, f.aperol); got == nil || *got != 2 {
+		t.Errorf("a main bar order took patio Aperol: %v left", got)
+	}
+	if got := count(t, f.s, `SELECT COUNT(*) FROM products WHERE id=? AND stock_count IS NULL`, f.aperol); got != 1 {
+		t.Error("untracked Aperol at the main bar gained a count")
+	}
+}
+
+func TestDeleteStationMovesItsOrdersToTheMainBar(t *testing.T) {
+	f := newStationFixture(t)
+	q := f.s.Q
+	id, err := q.CreateOrder(CreateOrderParams{UserID: f.guestID, CocktailID: f.spritz, Quantity: 1, StationID: f.patio})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.DeleteStation(f.mainBar); err != ErrDefaultStation {
+		t.Fatalf("deleting the main bar: %v", err)
+	}
+	if err := q.DeleteStation(f.patio); err != nil {
+		t.Fatal(err)
+	}
+	o, err := q.GetOrderByID(id)
+	if err != nil || o == nil || o.StationID != f.mainBar {
+		t.Fatalf("order after deleting its station = %+v, %v", o, err)
+	}
+	if st, _ := q.CocktailStation(f.spritz); st == nil || st.ID != f.mainBar {
+		t.Fatalf("the Spritz is made at %+v, want the main bar", st)
+	}
+	if got := count(t, f.s, `SELECT COUNT(*) FROM station_stock`); got != 0 {
+		t.Errorf("%d station_stock rows outlived their station", got)
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+// newStocktakeStore has Gin tracked at 5, untracked Tonic, and a bartender.
+func newStocktakeStore(t *testing.T) (*Store, int64) {
+	t.Helper()
+	s := newTestStore(t)
+	for _, stmt := range []string{
+		`INSERT INTO products(id, name, category, stock_count) VALUES (1, 'Gin', 'Spirit', 5), (2, 'Tonic', 'Mixer', NULL)`,
+		`INSERT INTO users(id, email, password_hash, role, display_name) VALUES (1, 'bar@example.com', 'h', 'BARTENDER', 'Bar')`,
+	} {
+		if _, err := s.DB.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, err := s.Q.CreateStocktake(1, "Friday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, id
+}
+
+func stockOf(t *testing.T, s *Store, productID int64) *int64 {
+	t.Helper()
+	p, err := s.Q.GetProductByID(productID)
+	if err != nil || p == nil {
+		t.Fatalf("GetProductByID(%d) = %v, %v", productID, p, err)
+	}
+	return p.StockCount
+}
+
+func TestCommitStocktake(t *testing.T) {
+	s, id := newStocktakeStore(t)
+	q := s.Q
+
+	if err := q.SaveStocktakeCounts(id, []StocktakeCount{{ProductID: 1, Counted: 3}, {ProductID: 2, Counted: 12}}); err != nil {
+		t.Fatal(err)
+	}
+	// a draft reads live stock
+	_, _ = s.DB.Exec(`UPDATE products SET stock_count=4 WHERE id=1`)
+	lines, _ := q.ListStocktakeLines(id)
+	if len(lines) != 2 || lines[0].ProductName != "Tonic" || lines[1].Expected == nil || *lines[1].Expected != 4 {
+		t.Fatalf("draft lines = %+v", lines)
+	}
+
+	if err := q.CommitStocktake(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := stockOf(t, s, 1); got == nil || *got != 3 {
+		t.Fatalf("gin = %v after commit, want 3", got)
+	}
+	if got := stockOf(t, s, 2); got == nil || *got != 12 {
+		t.Fatalf("tonic = %v after commit, want 12 and tracked", got)
+	}
+	st, _ := q.GetStocktake(id)
+	if st.Status != StocktakeCommitted || st.CommittedByName != "Bar" || st.LineCount != 2 || st.NetVariance != -1 || st.AbsVariance != 1 {
+		t.Fatalf("committed stocktake = %+v", st)
+	}
+	lines, _ = q.ListStocktakeLines(id)
+	if lines[0].Expected != nil || lines[1].Expected == nil || *lines[1].Expected != 4 || lines[1].Variance() != -1 {
+		t.Fatalf("committed lines = %+v", lines)
+	}
+
+	// a committed stocktake is history
+	if err := q.CommitStocktake(id, 1); !errors.Is(err, ErrStocktakeNotDraft) {
+		t.Fatalf("second commit: %v", err)
+	}
+	if err := q.SaveStocktakeCounts(id, nil); !errors.Is(err, ErrStocktakeNotDraft) {
+		t.Fatalf("saving a committed stocktake: %v", err)
+	}
+	if err := q.DeleteStocktakeDraft(id); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := q.GetStocktake(id); st == nil {
+		t.Fatal("DeleteStocktakeDraft deleted a committed stocktake")
+	}
+}
+
+func TestStocktakeHistoryOutlivesProducts(t *testing.T) {
+	s, id := newStocktakeStore(t)
+	q := s.Q
+	_ = q.SaveStocktakeCounts(id, []StocktakeCount{{ProductID: 1, Counted: 3}})
+	if err := q.CommitStocktake(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec(`DELETE FROM products WHERE id=1`); err != nil {
+		t.Fatal(err)
+	}
+	lines, _ := q.ListStocktakeLines(id)
+	if len(lines) != 1 || lines[0].ProductName != "Gin" || lines[0].ProductCategory != "Spirit" || lines[0].Variance() != -2 {
+		t.Fatalf("lines after deleting the product = %+v", lines)
+	}
+	if st, _ := q.GetStocktake(id); st.NetVariance != -2 {
+		t.Fatalf("variance after deleting the product = %d", st.NetVariance)
+	}
+
+	// a product deleted while counted in a draft is dropped when it is committed
+	draft, _ := q.CreateStocktake(1, "")
+	_ = q.SaveStocktakeCounts(draft, []StocktakeCount{{ProductID: 2, Counted: 1}})
+	_, _ = s.DB.Exec(`DELETE FROM products WHERE id=2`)
+	if err := q.CommitStocktake(draft, 1); err != nil {
+		t.Fatal(err)
+	}
+	if lines, _ := q.ListStocktakeLines(draft); len(lines) != 0 {
+		t.Fatalf("committed a count for a deleted product: %+v", lines)
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/invites"
+	"house-bartender-go/internal/services/resets"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/* ---------------- Account ---------------- */
+
+type AccountPage struct {
+	Account     *db.User
+	HasPassword bool
+	// GuestEmail is set for guests who joined by invite without an email; they need to add
+	// one before a password is any use.
+	GuestEmail bool
+	Email      string
+	TwoFactor  *db.TOTP // nil until enrollment is confirmed
+	Require2FA bool
+	// KioskGuest is set for accounts that kiosks offer on their guest picker.
+	KioskGuest bool
+	HasPIN     bool
+}
+
+func isGuestEmail(email string) bool {
+	return strings.HasSuffix(email, "@"+invites.PlaceholderDomain)
+}
+
+func (s *Server) AccountGet(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	page := AccountPage{Account: u, HasPassword: u.PasswordHash != "", GuestEmail: isGuestEmail(u.Email), Email: u.Email}
+	if page.GuestEmail {
+		page.Email = ""
+	}
+	if t, _ := s.App.Store().Q.GetTOTP(u.ID); t != nil && t.Confirmed {
+		page.TwoFactor = t
+	}
+	page.Require2FA = s.App.RoleRequires2FA(u.Role)
+	if page.KioskGuest = s.App.KioskGuestAllowed(u); page.KioskGuest {
+		pin, _ := s.App.Store().Q.GetKioskPIN(u.ID)
+		page.HasPIN = pin != nil
+	}
+	s.renderLayout(w, r, "Account", "account.html", page)
+}
+
+func (s *Server) AccountProfilePost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	_ = r.ParseForm()
+	name := strings.TrimSpace(r.FormValue("display_name"))
+	email := app.NormalizeEmail(r.FormValue("email"))
+	if email == "" && isGuestEmail(u.Email) {
+		email = u.Email
+	}
+	if name == "" || email == "" {
+		s.App.AddFlash(w, r, app.FlashError, "Name and email are required.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	if isGuestEmail(email) && email != u.Email {
+		s.App.AddFlash(w, r, app.FlashError, "Use your own email address.")
+		s.redirect(w, r, "/account")
+		return
+	}
+
+	before := s.userAudit(u.ID)
+	if err := s.App.Store().Q.UpdateUserProfile(u.ID, email, name); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not save (email might already be in use).")
+		s.redirect(w, r, "/account")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.UserUpdate, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: name, Before: before, After: s.userAudit(u.ID)})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Profile saved.")
+	s.redirect(w, r, "/account")
+}
+
+func (s *Server) AccountPasswordPost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	_ = r.ParseForm()
+	next := r.FormValue("new_password")
+
+	// Guests who joined without a password have none to confirm.
+	if u.PasswordHash != "" && !app.CheckPassword(u.PasswordHash, r.FormValue("current_password")) {
+		s.App.AddFlash(w, r, app.FlashError, "Current password is incorrect.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	if isGuestEmail(u.Email) {
+		s.App.AddFlash(w, r, app.FlashError, "Add your email first; you log in with it and the password.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	if next != r.FormValue("confirm_password") {
+		s.App.AddFlash(w, r, app.FlashError, "The new passwords don't match.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	hash, err := app.HashPassword(next)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Password must be at least 8 characters.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	if err := s.App.Store().Q.SetUserPassword(u.ID, hash); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Password change failed.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.UserPassword, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: u.DisplayName})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Password changed.")
+	s.redirect(w, r, "/account")
+}
+
+/* ---------------- Password reset ---------------- */
+
+type AdminUserResetPage struct {
+	Account   *db.User
+	Code      string
+	URL       string
+	ExpiresAt time.Time
+}
+
+type ResetPage struct {
+	Code  string
+	Reset *db.PasswordReset // nil when Code is missing or no longer valid
+}
+
+// AdminUserResetPost issues a one-time reset code for the user. The code is shown on this
+// response only; just its hash is kept.
+func (s *Server) AdminUserResetPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	target, _ := s.App.Store().Q.GetUserByID(id)
+	if target == nil {
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	if s.outranks(r, target.Role) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksUser)
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	if !target.IsActive {
+		s.App.AddFlash(w, r, app.FlashError, "Enable the account before issuing a reset link.")
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	code, err := resets.NewCode()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create a reset link.")
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	expires := time.Now().Add(resets.Lifetime)
+	var by int64
+	if u := s.App.CurrentUser(r); u != nil {
+		by = u.ID
+	}
+	if err := s.App.Store().Q.CreatePasswordReset(id, resets.Hash(code), expires, by); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create a reset link.")
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.UserResetIssue, TargetType: audit.TargetUser, TargetID: id, TargetLabel: target.DisplayName})
+
+	w.Header().Set("Cache-Control", "no-store")
+	s.renderLayout(w, r, "Reset Link", "admin_user_reset.html", AdminUserResetPage{
+		Account:   target,
+		Code:      code,
+		URL:       resets.URL(s.App.Config().BaseURL, code),
+		ExpiresAt: expires,
+	})
+}
+
+// ResetGet asks for a reset code typed in by hand.
+func (s *Server) ResetGet(w http.ResponseWriter, r *http.Request) {
+	if code := strings.TrimSpace(r.URL.Query().Get("code")); code != "" {
+		s.redirect(w, r, "/reset/"+resets.Normalize(code))
+		return
+	}
+	s.renderLayout(w, r, "Reset Password", "reset.html", ResetPage{})
+}
+
+func (s *Server) ResetCodeGet(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	reset, _ := s.App.Store().Q.GetPasswordReset(resets.Hash(code))
+	s.renderLayout(w, r, "Reset Password", "reset.html", ResetPage{Code: code, Reset: reset})
+}
+
+// ResetCodePost sets the new password, uses up the code and signs the user in, through the
+// two-factor challenge if they have one.
+func (s *Server) ResetCodePost(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	back := "/reset/" + code
+	_ = r.ParseForm()
+	next := r.FormValue("new_password")
+	if next != r.FormValue("confirm_password") {
+		s.App.AddFlash(w, r, app.FlashError, "The passwords don't match.")
+		s.redirect(w, r, back)
+		return
+	}
+	hash, err := app.HashPassword(next)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Password must be at least 8 characters.")
+		s.redirect(w, r, back)
+		return
+	}
+	id, err := s.App.Store().Q.ConsumePasswordReset(resets.Hash(code), hash)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "This reset link is no longer valid. Ask an admin for a new one.")
+		s.redirect(w, r, back)
+		return
+	}
+
+	u, _ := s.App.Store().Q.GetUserByID(id)
+	if u == nil {
+		s.redirect(w, r, "/login")
+		return
+	}
+	s.App.Audit().Record(audit.Entry{Actor: u, Action: audit.UserReset, TargetType: audit.TargetUser, TargetID: id, TargetLabel: u.DisplayName})
+	// a reset code stands in for the password only; an enrolled authenticator is still asked for
+	s.signIn(w, r, u, "Password changed. You're signed in.", s.App.HomePath(u))
+}
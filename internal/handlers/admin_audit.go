@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+)
+
+const auditPageSize = 50
+
+type AdminAuditPage struct {
+	Entries     []AuditRow
+	Total       int
+	Page        int
+	Pages       int
+	Filter      AuditFilterForm
+	Actions     []string
+	TargetTypes []string
+	Actors      []db.AuditActor
+	PrevURL     string
+	NextURL     string
+	ExportCSV   string
+	ExportJSON  string
+}
+
+type AuditRow struct {
+	db.AuditEntry
+	Changes []audit.Change
+}
+
+// AuditFilterForm holds the viewer's filters as submitted, so the form can be redrawn.
+type AuditFilterForm struct {
+	Actor  string
+	Action string
+	Target string
+	Query  string
+	Since  string
+	Until  string
+}
+
+func (f AuditFilterForm) query() url.Values {
+	v := url.Values{}
+	for k, s := range map[string]string{"actor": f.Actor, "action": f.Action, "target": f.Target, "q": f.Query, "since": f.Since, "until": f.Until} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	return v
+}
+
+// recordAudit appends an entry attributed to the signed-in user.
+func (s *Server) recordAudit(r *http.Request, e audit.Entry) {
+	e.Actor = s.App.CurrentUser(r)
+	s.App.Audit().Record(e)
+}
+
+func auditFilterFromRequest(r *http.Request) (AuditFilterForm, db.AuditFilter) {
+	q := r.URL.Query()
+	form := AuditFilterForm{
+		Actor:  strings.TrimSpace(q.Get("actor")),
+		Action: strings.TrimSpace(q.Get("action")),
+		Target: strings.TrimSpace(q.Get("target")),
+		Query:  strings.TrimSpace(q.Get("q")),
+		Since:  strings.TrimSpace(q.Get("since")),
+		Until:  strings.TrimSpace(q.Get("until")),
+	}
+	f := db.AuditFilter{Action: form.Action, TargetType: form.Target, Query: form.Query}
+	if id, ok := parseInt64(form.Actor); ok {
+		f.ActorID = id
+	}
+	if t, err := time.ParseInLocation(digestDayLayout, form.Since, time.Local); err == nil {
+		f.Since = t
+	} else {
+		form.Since = ""
+	}
+	// Until is inclusive of the whole day.
+	if t, err := time.ParseInLocation(digestDayLayout, form.Until, time.Local); err == nil {
+		f.Until = t.AddDate(0, 0, 1)
+	} else {
+		form.Until = ""
+	}
+	return form, f
+}
+
+func (s *Server) AdminAuditGet(w http.ResponseWriter, r *http.Request) {
+	form, filter := auditFilterFromRequest(r)
+	page := 1
+	if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && n > 1 {
+		page = n
+	}
+	filter.Limit = auditPageSize
+	filter.Offset = (page - 1) * auditPageSize
+
+	entries, total, _ := s.App.Store().Q.ListAudit(filter)
+	out := AdminAuditPage{
+		Total:  total,
+		Page:   page,
+		Pages:  (total + auditPageSize - 1) / auditPageSize,
+		Filter: form,
+	}
+	for _, e := range entries {
+		out.Entries = append(out.Entries, AuditRow{AuditEntry: e, Changes: audit.Changes(e.Before, e.After)})
+	}
+	out.Actions, out.TargetTypes, _ = s.App.Store().Q.ListAuditActions()
+	out.Actors, _ = s.App.Store().Q.ListAuditActors()
+
+	base := form.query()
+	pageURL := func(n int) string {
+		v := form.query()
+		v.Set("page", strconv.Itoa(n))
+		return "/admin/audit?" + v.Encode()
+	}
+	if page > 1 {
+		out.PrevURL = pageURL(page - 1)
+	}
+	if page < out.Pages {
+		out.NextURL = pageURL(page + 1)
+	}
+	out.ExportCSV = "/admin/audit/export?" + base.Encode()
+	base.Set("format", "json")
+	out.ExportJSON = "/admin/audit/export?" + base.Encode()
+
+	s.renderLayout(w, r, "Audit Log", "admin_audit.html", out)
+}
+
+// auditExportEntry is the JSON export shape; snapshots stay structured.
+type auditExportEntry struct {
+	ID          int64           `json:"id"`
+	At          time.Time       `json:"at"`
+	ActorID     int64           `json:"actor_id,omitempty"`
+	ActorName   string          `json:"actor_name"`
+	Action      string          `json:"action"`
+	TargetType  string          `json:"target_type"`
+	TargetID    int64           `json:"target_id,omitempty"`
+	TargetLabel string          `json:"target_label"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+}
+
+// AdminAuditExportGet downloads every entry matching the viewer's filters as CSV, or as
+// JSON with format=json.
+func (s *Server) AdminAuditExportGet(w http.ResponseWriter, r *http.Request) {
+	_, filter := auditFilterFromRequest(r)
+	entries, _, err := s.App.Store().Q.ListAudit(filter)
+	if err != nil {
+		http.Error(w, "export failed", http.StatusInternalServerError)
+		return
+	}
+	stamp := time.Now().Format("20060102-150405")
+
+	if r.URL.Query().Get("format") == "json" {
+		out := make([]auditExportEntry, 0, len(entries))
+		for _, e := range entries {
+			x := auditExportEntry{
+				ID: e.ID, At: e.CreatedAt, ActorID: e.ActorID, ActorName: e.ActorName,
+				Action: e.Action, TargetType: e.TargetType, TargetID: e.TargetID, TargetLabel: e.TargetLabel,
+			}
+			if e.Before != "" {
+				x.Before = json.RawMessage(e.Before)
+			}
+			if e.After != "" {
+				x.After = json.RawMessage(e.After)
+			}
+			out = append(out, x)
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.json"`, stamp))
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.csv"`, stamp))
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "at", "actor_id", "actor_name", "action", "target_type", "target_id", "target_label", "before", "after"})
+	for _, e := range entries {
+		_ = cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.Format(time.RFC3339),
+			optionalID(e.ActorID),
+			e.ActorName,
+			e.Action,
+			e.TargetType,
+			optionalID(e.TargetID),
+			e.TargetLabel,
+			e.Before,
+			e.After,
+		})
+	}
+	cw.Flush()
+}
+
+func optionalID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// cocktailAuditState is a cocktail with its recipe, as stored in audit snapshots.
+type cocktailAuditState struct {
+	db.Cocktail
+	Ingredients []string
+	Modifiers   []string
+}
+
+// cocktailAudit loads the cocktail for an audit snapshot; it returns nil when it is gone.
+func (s *Server) cocktailAudit(id int64) *cocktailAuditState {
+	c, _ := s.App.Store().Q.GetCocktailByID(id)
+	if c == nil {
+		return nil
+	}
+	state := &cocktailAuditState{Cocktail: *c}
+	items, _ := s.App.Store().Q.GetCocktailIngredients(id)
+	for _, it := range items {
+		line := it.ProductName
+		if it.Quantity != nil {
+			line = strings.TrimSpace(strconv.FormatFloat(*it.Quantity, 'f', -1, 64)+" "+it.Unit) + " " + line
+		}
+		if !it.Required {
+			line += " (optional)"
+		}
+		state.Ingredients = append(state.Ingredients, line)
+	}
+	mods, _ := s.App.Store().Q.GetCocktailModifiers(id)
+	for _, m := range mods {
+		state.Modifiers = append(state.Modifiers, m.Label+": "+m.Options)
+	}
+	return state
+}
+
+// productAudit loads the product for an audit snapshot; it returns nil when it is gone.
+func (s *Server) productAudit(id int64) *db.Product {
+	p, _ := s.App.Store().Q.GetProductByID(id)
+	return p
+}
+
+// userAudit loads the user for an audit snapshot; it returns nil when it is gone. The
+// password hash is dropped by audit.Snapshot.
+func (s *Server) userAudit(id int64) *db.User {
+	u, _ := s.App.Store().Q.GetUserByID(id)
+	return u
+}
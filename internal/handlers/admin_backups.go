@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/backup"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// restoreTimeout replaces the server's read and write timeouts for a restore: an archive of
+// up to backup.MaxArchiveBytes over a slow link takes far longer than a form post.
+const restoreTimeout = 2 * time.Hour
+
+// BackupOverview is the backup section of the settings page.
+type BackupOverview struct {
+	Dir            string
+	Interval       time.Duration
+	Keep           int
+	IncludeUploads bool
+	Last           backup.Result
+	Archives       []backup.Info
+	Err            string
+}
+
+func (s *Server) backupOverview() BackupOverview {
+	svc := s.App.Backups()
+	cfg := s.App.Config()
+	out := BackupOverview{
+		Dir:            cfg.BackupDir,
+		Interval:       cfg.BackupInterval,
+		Keep:           cfg.BackupKeep,
+		IncludeUploads: cfg.BackupIncludeUploads,
+		Last:           svc.Last(),
+	}
+	list, err := svc.List()
+	if err != nil {
+		out.Err = err.Error()
+	}
+	out.Archives = list
+	return out
+}
+
+func (s *Server) AdminBackupCreatePost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	info, err := s.App.Backups().Create(time.Now(), formBool(r, "include_uploads"), "")
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Backup failed: "+err.Error())
+		s.redirect(w, r, "/admin/settings")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.BackupCreate, TargetType: audit.TargetSystem, TargetLabel: info.Name, After: info})
+	s.App.AddFlash(w, r, app.FlashSuccess, fmt.Sprintf("Backup %s written (%d KB).", info.Name, info.Size>>10))
+	s.redirect(w, r, "/admin/settings")
+}
+
+func (s *Server) AdminBackupDownloadGet(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	path, err := s.App.Backups().Path(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, st.ModTime(), f)
+}
+
+// AdminBackupRestorePost streams an uploaded archive to disk and restores it. The form is
+// read part by part so a large archive is never held in memory.
+func (s *Server) AdminBackupRestorePost(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		s.App.AddFlash(w, r, app.FlashError, "Restore failed: "+msg)
+		s.redirect(w, r, "/admin/settings")
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Now().Add(restoreTimeout))
+	_ = rc.SetWriteDeadline(time.Now().Add(restoreTimeout))
+	r.Body = http.MaxBytesReader(w, r.Body, backup.MaxArchiveBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		fail("choose a backup archive to upload.")
+		return
+	}
+	dir := s.App.Config().BackupDir
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		fail(err.Error())
+		return
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*.tar.gz")
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var got bool
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail("the upload was interrupted.")
+			return
+		}
+		if part.FormName() != "archive" || part.FileName() == "" {
+			continue
+		}
+		if _, err := io.Copy(tmp, part); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				fail("the archive is too large.")
+				return
+			}
+			fail("the upload was interrupted.")
+			return
+		}
+		got = true
+		break
+	}
+	if !got {
+		fail("choose a backup archive to upload.")
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		fail(err.Error())
+		return
+	}
+
+	manifest, err := s.App.RestoreBackup(tmp.Name())
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	// Written after the restore, so the entry survives in the restored log.
+	s.recordAudit(r, audit.Entry{Action: audit.BackupRestore, TargetType: audit.TargetSystem, TargetLabel: manifest.CreatedAt.Format(time.RFC3339), After: manifest})
+	msg := "Backup from " + manifest.CreatedAt.Local().Format("2006-01-02 15:04") + " restored."
+	if manifest.IncludeUploads {
+		msg = fmt.Sprintf("Backup from %s restored with %d uploaded files.", manifest.CreatedAt.Local().Format("2006-01-02 15:04"), manifest.Uploads)
+	}
+	s.App.AddFlash(w, r, app.FlashSuccess, msg)
+	s.redirect(w, r, "/admin/settings")
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestoreOutlastsTheServerReadTimeout(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(s.AdminBackupRestorePost))
+	srv.Config.ReadTimeout = 100 * time.Millisecond
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	// the archive trickles in over several read timeouts
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, _ := mw.CreateFormFile("archive", "bar.tar.gz")
+		for i := 0; i < 5; i++ {
+			_, _ = part.Write([]byte(strings.Repeat("x", 512)))
+			time.Sleep(80 * time.Millisecond)
+		}
+		_ = mw.Close()
+		_ = pw.Close()
+	}()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, pr)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("restore upload cut off: %v", err)
+	}
+	resp.Body.Close()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range resp.Cookies() {
+		r.AddCookie(c)
+	}
+	flashes := s.App.PopFlashes(httptest.NewRecorder(), r)
+	if len(flashes) != 1 || strings.Contains(flashes[0].Message, "interrupted") || !strings.HasPrefix(flashes[0].Message, "Restore failed") {
+		t.Fatalf("flashes = %+v, want the junk archive refused after it arrived", flashes)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/invites"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminInvitesPage struct {
+	Invites  []InviteRow
+	Roles    []db.Role
+	Lifetime []InviteLifetime
+	Active   int
+}
+
+type InviteRow struct {
+	db.Invite
+	Status string
+	URL    string
+}
+
+type InviteLifetime struct {
+	Hours int
+	Label string
+}
+
+// inviteLifetimes are the expiry choices on the create form; the first is the default.
+var inviteLifetimes = []InviteLifetime{
+	{12, "12 hours"},
+	{24, "1 day"},
+	{72, "3 days"},
+	{7 * 24, "1 week"},
+	{30 * 24, "30 days"},
+}
+
+func (s *Server) AdminInvitesGet(w http.ResponseWriter, r *http.Request) {
+	list, _ := s.App.Store().Q.ListInvites()
+	roles, _ := s.App.Store().Q.ListRoles()
+	now := time.Now()
+	base := s.App.Config().BaseURL
+
+	out := AdminInvitesPage{Lifetime: inviteLifetimes}
+	for _, role := range roles {
+		if s.App.GuestRoleAllowed(role.Name) {
+			out.Roles = append(out.Roles, role)
+		}
+	}
+	for _, inv := range list {
+		row := InviteRow{Invite: inv, Status: invites.Status(inv, now), URL: invites.URL(base, inv.Token)}
+		if row.Status == invites.StatusActive {
+			out.Active++
+		}
+		out.Invites = append(out.Invites, row)
+	}
+	s.renderLayout(w, r, "Invites", "admin_invites.html", out)
+}
+
+func (s *Server) AdminInviteCreatePost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	event := strings.TrimSpace(r.FormValue("event"))
+	role := strings.TrimSpace(r.FormValue("role"))
+	if role == "" {
+		role = app.RoleUser
+	}
+	if !s.App.RoleExists(role) || !s.App.GuestRoleAllowed(role) {
+		s.App.AddFlash(w, r, app.FlashError, "Invited guests need a role that can order and has no staff access or two-factor requirement.")
+		s.redirect(w, r, "/admin/invites")
+		return
+	}
+	if s.outranks(r, role) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksRole)
+		s.redirect(w, r, "/admin/invites")
+		return
+	}
+	hours, err := strconv.Atoi(r.FormValue("expires_in"))
+	if err != nil || hours <= 0 || hours > 90*24 {
+		s.App.AddFlash(w, r, app.FlashError, "Choose when the invite expires.")
+		s.redirect(w, r, "/admin/invites")
+		return
+	}
+	maxUses := 0
+	if v := strings.TrimSpace(r.FormValue("max_uses")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			s.App.AddFlash(w, r, app.FlashError, "Use limit must be a whole number; leave it empty for no limit.")
+			s.redirect(w, r, "/admin/invites")
+			return
+		}
+		maxUses = n
+	}
+	token, err := invites.NewToken()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create invite.")
+		s.redirect(w, r, "/admin/invites")
+		return
+	}
+
+	p := db.CreateInviteParams{
+		Token:        token,
+		Event:        event,
+		Role:         role,
+		MaxUses:      maxUses,
+		Passwordless: formBool(r, "passwordless"),
+		ExpiresAt:    time.Now().Add(time.Duration(hours) * time.Hour),
+	}
+	if u := s.App.CurrentUser(r); u != nil {
+		p.CreatedByID = u.ID
+	}
+	id, err := s.App.Store().Q.CreateInvite(p)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create invite.")
+		s.redirect(w, r, "/admin/invites")
+		return
+	}
+	created, _ := s.App.Store().Q.GetInvite(id)
+	s.recordAudit(r, audit.Entry{Action: audit.InviteCreate, TargetType: audit.TargetInvite, TargetID: id, TargetLabel: inviteLabel(created), After: created})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Invite created. Share the link or show the QR code.")
+	s.redirect(w, r, "/admin/invites")
+}
+
+func (s *Server) AdminInviteRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/invites")
+		return
+	}
+	before, _ := s.App.Store().Q.GetInvite(id)
+	if before == nil || !before.RevokedAt.IsZero() {
+		s.redirect(w, r, "/admin/invites")
+		return
+	}
+	if err := s.App.Store().Q.RevokeInvite(id); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Revoke failed.")
+		s.redirect(w, r, "/admin/invites")
+		return
+	}
+	after, _ := s.App.Store().Q.GetInvite(id)
+	s.recordAudit(r, audit.Entry{Action: audit.InviteRevoke, TargetType: audit.TargetInvite, TargetID: id, TargetLabel: inviteLabel(before), Before: before, After: after})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Invite revoked. Guests who already joined keep their accounts.")
+	s.redirect(w, r, "/admin/invites")
+}
+
+// AdminInviteQRGet serves the invite link as an SVG QR code, for the admin screen and for
+// printing.
+func (s *Server) AdminInviteQRGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	inv, _ := s.App.Store().Q.GetInvite(id)
+	if inv == nil {
+		http.NotFound(w, r)
+		return
+	}
+	svg, err := invites.QRSVG(invites.URL(s.App.Config().BaseURL, inv.Token))
+	if err != nil {
+		http.Error(w, "qr failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	_, _ = w.Write(svg)
+}
+
+func inviteLabel(inv *db.Invite) string {
+	if inv == nil {
+		return ""
+	}
+	if inv.Event != "" {
+		return inv.Event
+	}
+	return "Invite #" + strconv.FormatInt(inv.ID, 10)
+}
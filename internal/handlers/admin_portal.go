@@ -1,17 +1,23 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"house-bartender-go/internal/app"
 	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/media"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type AdminUsersPage struct {
-	Users []db.User
+	Users     []db.User
+	Roles     []db.Role
+	TwoFactor map[int64]bool // users with an authenticator enrolled
 }
 
 type CountStat struct {
@@ -24,11 +30,26 @@ type AdminSettingsPage struct {
 	DataDir   string
 	Counts    string
 	CountList []CountStat
+	Pool      db.PoolStats
+	Media     MediaUsage
+	Backups   BackupOverview
+}
+
+// MediaUsage summarises the upload library and the last garbage collection.
+type MediaUsage struct {
+	Files      int
+	Bytes      int64
+	Unused     int
+	UnusedSize int64
+	Grace      time.Duration
+	LastGC     media.Result
 }
 
 func (s *Server) AdminUsersGet(w http.ResponseWriter, r *http.Request) {
 	users, _ := s.App.Store().Q.ListUsers()
-	s.renderLayout(w, r, "Users", "admin_users.html", AdminUsersPage{Users: users})
+	roles, _ := s.App.Store().Q.ListRoles()
+	twoFactor, _ := s.App.Store().Q.ListTOTPUserIDs()
+	s.renderLayout(w, r, "Users", "admin_users.html", AdminUsersPage{Users: users, Roles: roles, TwoFactor: twoFactor})
 }
 
 func (s *Server) AdminUserCreatePost(w http.ResponseWriter, r *http.Request) {
@@ -43,11 +64,16 @@ func (s *Server) AdminUserCreatePost(w http.ResponseWriter, r *http.Request) {
 		s.redirect(w, r, "/admin/users")
 		return
 	}
-	if role != app.RoleUser && role != app.RoleBartender && role != app.RoleAdmin {
+	if !s.App.RoleExists(role) {
 		s.App.AddFlash(w, r, app.FlashError, "Invalid role.")
 		s.redirect(w, r, "/admin/users")
 		return
 	}
+	if s.outranks(r, role) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksRole)
+		s.redirect(w, r, "/admin/users")
+		return
+	}
 	hash, err := app.HashPassword(pw)
 	if err != nil {
 		s.App.AddFlash(w, r, app.FlashError, "Password must be at least 8 characters.")
@@ -55,19 +81,23 @@ func (s *Server) AdminUserCreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = s.App.Store().Q.CreateUser(db.CreateUserParams{
-		Email:        email,
-		PasswordHash: hash,
-		Role:         role,
-		DisplayName:  name,
-		IsActive:     true,
-		OnDuty:       role == app.RoleBartender,
+	id, err := s.App.Store().Q.CreateUser(db.CreateUserParams{
+		Email:         email,
+		PasswordHash:  hash,
+		Role:          role,
+		DisplayName:   name,
+		IsActive:      true,
+		OnDuty:        role == app.RoleBartender,
+		EmailVerified: true,
 	})
 	if err != nil {
 		s.App.AddFlash(w, r, app.FlashError, "Could not create user (email might already exist).")
 		s.redirect(w, r, "/admin/users")
 		return
 	}
+	created := s.userAudit(id)
+	s.recordAudit(r, audit.Entry{Action: audit.UserCreate, TargetType: audit.TargetUser, TargetID: id, TargetLabel: name, After: created})
+	s.emitUserCreated(created)
 
 	s.App.AddFlash(w, r, app.FlashSuccess, "User created.")
 	s.redirect(w, r, "/admin/users")
@@ -93,35 +123,55 @@ func (s *Server) AdminUserUpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.App.RoleExists(role) {
+		s.App.AddFlash(w, r, app.FlashError, "Invalid role.")
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+
 	target, _ := s.App.Store().Q.GetUserByID(id)
 	if target == nil {
 		s.redirect(w, r, "/admin/users")
 		return
 	}
+	if s.outranks(r, target.Role) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksUser)
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	if s.outranks(r, role) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksRole)
+		s.redirect(w, r, "/admin/users")
+		return
+	}
 
-	// Prevent removing the last active admin
-	if target.Role == app.RoleAdmin && role != app.RoleAdmin {
-		if !s.hasAnotherActiveAdmin(id) {
-			s.App.AddFlash(w, r, app.FlashError, "Cannot remove the last active admin.")
+	// Prevent removing the last active account that can manage users
+	if s.App.Can(target, app.PermUsersManage) && !s.App.RoleCan(role, app.PermUsersManage) {
+		if !s.hasAnotherUserManager(id, "") {
+			s.App.AddFlash(w, r, app.FlashError, "Cannot remove the last active account that can manage users.")
 			s.redirect(w, r, "/admin/users")
 			return
 		}
 	}
 
+	// An email the admin typed in is verified; one the user set stays unverified until the
+	// admin confirms it.
 	if err := s.App.Store().Q.UpdateUser(db.UpdateUserParams{
-		ID:          id,
-		Email:       email,
-		Role:        role,
-		DisplayName: name,
+		ID:            id,
+		Email:         email,
+		Role:          role,
+		DisplayName:   name,
+		EmailVerified: email != target.Email || formBool(r, "email_verified"),
 	}); err != nil {
 		s.App.AddFlash(w, r, app.FlashError, "Update failed (email might already exist).")
 		s.redirect(w, r, "/admin/users")
 		return
 	}
 
-	if role != app.RoleBartender {
-		_ = s.App.Store().Q.SetUserDuty(id, false)
+	if !s.App.RoleCan(role, app.PermOrdersManage) {
+		_ = s.App.Store().Q.SetUserDuty(id, false, db.ShiftEndRole)
 	}
+	s.recordAudit(r, audit.Entry{Action: audit.UserUpdate, TargetType: audit.TargetUser, TargetID: id, TargetLabel: name, Before: target, After: s.userAudit(id)})
 
 	if strings.TrimSpace(pw) != "" {
 		hash, err := app.HashPassword(pw)
@@ -131,6 +181,7 @@ func (s *Server) AdminUserUpdatePost(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		_ = s.App.Store().Q.SetUserPassword(id, hash)
+		s.recordAudit(r, audit.Entry{Action: audit.UserPassword, TargetType: audit.TargetUser, TargetID: id, TargetLabel: name})
 	}
 
 	s.App.AddFlash(w, r, app.FlashSuccess, "User updated.")
@@ -153,16 +204,22 @@ func (s *Server) AdminUserTogglePost(w http.ResponseWriter, r *http.Request) {
 		s.redirect(w, r, "/admin/users")
 		return
 	}
+	if s.outranks(r, target.Role) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksUser)
+		s.redirect(w, r, "/admin/users")
+		return
+	}
 
-	if target.Role == app.RoleAdmin && !active {
-		if !s.hasAnotherActiveAdmin(id) {
-			s.App.AddFlash(w, r, app.FlashError, "Cannot disable the last active admin.")
+	if s.App.Can(target, app.PermUsersManage) && !active {
+		if !s.hasAnotherUserManager(id, "") {
+			s.App.AddFlash(w, r, app.FlashError, "Cannot disable the last active account that can manage users.")
 			s.redirect(w, r, "/admin/users")
 			return
 		}
 	}
 
 	_ = s.App.Store().Q.SetUserActive(id, active)
+	s.recordAudit(r, audit.Entry{Action: audit.UserToggle, TargetType: audit.TargetUser, TargetID: id, TargetLabel: target.DisplayName, Before: target, After: s.userAudit(id)})
 	s.App.AddFlash(w, r, app.FlashSuccess, "User status updated.")
 	s.redirect(w, r, "/admin/users")
 }
@@ -180,15 +237,16 @@ func (s *Server) AdminUserDutyPost(w http.ResponseWriter, r *http.Request) {
 		s.redirect(w, r, "/admin/users")
 		return
 	}
-	if target.Role != app.RoleBartender {
-		s.App.AddFlash(w, r, app.FlashInfo, "Duty applies only to bartender accounts.")
+	if !s.App.Can(target, app.PermOrdersManage) {
+		s.App.AddFlash(w, r, app.FlashInfo, "Duty applies only to accounts that manage orders.")
 		s.redirect(w, r, "/admin/users")
 		return
 	}
 
 	_ = r.ParseForm()
 	onDuty := strings.TrimSpace(r.FormValue("on_duty")) == "1"
-	_ = s.App.Store().Q.SetUserDuty(id, onDuty)
+	_ = s.App.Store().Q.SetUserDuty(id, onDuty, db.ShiftEndAdmin)
+	s.recordAudit(r, audit.Entry{Action: audit.UserDuty, TargetType: audit.TargetUser, TargetID: id, TargetLabel: target.DisplayName, Before: target, After: s.userAudit(id)})
 	s.App.AddFlash(w, r, app.FlashSuccess, "Duty updated.")
 	s.redirect(w, r, "/admin/users")
 }
@@ -201,6 +259,9 @@ func (s *Server) AdminSettingsGet(w http.ResponseWriter, r *http.Request) {
 		DataDir:   cfg.DataDir,
 		Counts:    counts,
 		CountList: parseCountStats(counts),
+		Pool:      s.App.Store().Stats(),
+		Media:     s.mediaUsage(),
+		Backups:   s.backupOverview(),
 	}
 	s.renderLayout(w, r, "Settings", "admin_settings.html", page)
 }
@@ -211,22 +272,53 @@ func (s *Server) AdminSettingsSeedPost(w http.ResponseWriter, r *http.Request) {
 		s.redirect(w, r, "/admin/settings")
 		return
 	}
+	s.recordAudit(r, audit.Entry{Action: audit.CatalogSeed, TargetType: audit.TargetSystem, TargetLabel: "catalog"})
+	s.broadcastInventory()
 	s.App.AddFlash(w, r, app.FlashSuccess, "Catalog seed ran (idempotent).")
 	s.redirect(w, r, "/admin/settings")
 }
 
-func (s *Server) hasAnotherActiveAdmin(excludeID int64) bool {
+func (s *Server) mediaUsage() MediaUsage {
+	gc := s.App.Media()
+	usage := MediaUsage{Grace: gc.Grace(), LastGC: gc.Last()}
+	library, _ := s.App.Store().Q.ListMedia()
+	for _, m := range library {
+		usage.Files++
+		usage.Bytes += m.Bytes
+		if m.RefCount == 0 {
+			usage.Unused++
+			usage.UnusedSize += m.Bytes
+		}
+	}
+	return usage
+}
+
+// AdminMediaGCPost runs the upload garbage collector now instead of waiting for the timer.
+func (s *Server) AdminMediaGCPost(w http.ResponseWriter, r *http.Request) {
+	res, err := s.App.Media().Run(time.Now())
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Media cleanup failed: "+err.Error())
+		s.redirect(w, r, "/admin/settings")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.MediaGC, TargetType: audit.TargetSystem, TargetLabel: "uploads", After: res})
+	s.App.AddFlash(w, r, app.FlashSuccess, fmt.Sprintf("Media cleanup removed %d files (%d KB).", res.Removed, res.Reclaimed>>10))
+	s.redirect(w, r, "/admin/settings")
+}
+
+// hasAnotherUserManager reports whether an active account other than excludeID, and
+// outside excludeRole, can manage users.
+func (s *Server) hasAnotherUserManager(excludeID int64, excludeRole string) bool {
 	users, _ := s.App.Store().Q.ListUsers()
-	n := 0
 	for _, u := range users {
-		if u.ID == excludeID {
+		if u.ID == excludeID || (excludeRole != "" && u.Role == excludeRole) {
 			continue
 		}
-		if u.Role == app.RoleAdmin && u.IsActive {
-			n++
+		if u.IsActive && s.App.Can(&u, app.PermUsersManage) {
+			return true
 		}
 	}
-	return n > 0
+	return false
 }
 
 func parseCountStats(raw string) []CountStat {
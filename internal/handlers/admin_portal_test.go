@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"house-bartender-go/internal/app"
+)
+
+func TestSeedRefreshesLibrary(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser(t, "admin@example.com", app.RoleAdmin)
+
+	before, err := s.App.Library().Snapshot()
+	if err != nil || before.Len() == 0 {
+		t.Fatalf("seeded library: %v, %d items", err, before.Len())
+	}
+	gone := before.Items()[0].Cocktail
+	if _, err := s.App.Store().DB.Exec(`DELETE FROM cocktails WHERE id=?`, gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	s.serve(t, s.AdminSettingsSeedPost, admin, http.MethodPost, "/admin/settings/seed", nil, nil)
+
+	after, err := s.App.Library().Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := after.Get(gone.ID); ok {
+		t.Fatal("the library still serves the snapshot from before the seed")
+	}
+	if after.Len() != before.Len() {
+		t.Fatalf("library has %d cocktails after the seed, want %d", after.Len(), before.Len())
+	}
+}
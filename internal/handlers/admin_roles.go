@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminRolesPage struct {
+	Roles       []db.Role
+	Permissions []app.Permission
+}
+
+var roleNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,31}$`)
+
+// normalizeRoleName turns "Head bartender" into HEAD_BARTENDER, matching the built-in names.
+func normalizeRoleName(raw string) string {
+	name := strings.ToUpper(strings.TrimSpace(raw))
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool { return r == ' ' || r == '-' || r == '_' }), "_")
+}
+
+// rolePermissionsFromForm keeps the known permissions from the checked boxes, in
+// app.Permissions order.
+func rolePermissionsFromForm(r *http.Request) []string {
+	checked := map[string]bool{}
+	for _, p := range r.Form["permissions"] {
+		checked[p] = true
+	}
+	var out []string
+	for _, p := range app.Permissions {
+		if checked[p.Key] {
+			out = append(out, p.Key)
+		}
+	}
+	return out
+}
+
+func hasPermission(perms []string, perm string) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// outranks reports whether role grants something the current user doesn't hold. They may
+// then neither assign it, edit it, nor act on its members.
+func (s *Server) outranks(r *http.Request, role string) bool {
+	return !s.App.CanAssignRole(s.App.CurrentUser(r), role)
+}
+
+const flashOutranksRole = "You can only hand out permissions you hold yourself."
+const flashOutranksUser = "That account's role has permissions you don't hold."
+
+func (s *Server) AdminRolesGet(w http.ResponseWriter, r *http.Request) {
+	roles, _ := s.App.Store().Q.ListRoles()
+	s.renderLayout(w, r, "Roles", "admin_roles.html", AdminRolesPage{Roles: roles, Permissions: app.Permissions})
+}
+
+func (s *Server) AdminRoleCreatePost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	name := normalizeRoleName(r.FormValue("name"))
+	desc := strings.TrimSpace(r.FormValue("description"))
+	perms := rolePermissionsFromForm(r)
+
+	if !roleNamePattern.MatchString(name) {
+		s.App.AddFlash(w, r, app.FlashError, "Role names are 2 to 32 letters, digits or underscores, starting with a letter.")
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	if !s.App.CanGrant(s.App.CurrentUser(r), perms) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksRole)
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	if err := s.App.Store().Q.CreateRole(name, desc, perms); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create role (name might already exist).")
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	_ = s.App.ReloadRoles()
+	created, _ := s.App.Store().Q.GetRole(name)
+	s.recordAudit(r, audit.Entry{Action: audit.RoleCreate, TargetType: audit.TargetRole, TargetLabel: name, After: created})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Role created.")
+	s.redirect(w, r, "/admin/roles")
+}
+
+func (s *Server) AdminRoleUpdatePost(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	existing, _ := s.App.Store().Q.GetRole(name)
+	if existing == nil {
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	if existing.Builtin {
+		s.App.AddFlash(w, r, app.FlashError, "Built-in roles cannot be changed.")
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+
+	_ = r.ParseForm()
+	desc := strings.TrimSpace(r.FormValue("description"))
+	perms := rolePermissionsFromForm(r)
+
+	if s.outranks(r, name) || !s.App.CanGrant(s.App.CurrentUser(r), perms) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksRole)
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+
+	// Don't let an edit leave nobody able to manage users.
+	if existing.UserCount > 0 && hasPermission(existing.Permissions, app.PermUsersManage) && !hasPermission(perms, app.PermUsersManage) {
+		if !s.hasAnotherUserManager(0, name) {
+			s.App.AddFlash(w, r, app.FlashError, "Cannot remove user management from the only role that has active members with it.")
+			s.redirect(w, r, "/admin/roles")
+			return
+		}
+	}
+
+	if err := s.App.Store().Q.UpdateRole(name, desc, perms, app.PermOrdersManage); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Update failed.")
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	_ = s.App.ReloadRoles()
+	updated, _ := s.App.Store().Q.GetRole(name)
+	s.recordAudit(r, audit.Entry{Action: audit.RoleUpdate, TargetType: audit.TargetRole, TargetLabel: name, Before: existing, After: updated})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Role updated.")
+	s.redirect(w, r, "/admin/roles")
+}
+
+func (s *Server) AdminRoleDeletePost(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	existing, _ := s.App.Store().Q.GetRole(name)
+	if existing == nil {
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	if existing.Builtin || existing.UserCount > 0 {
+		s.App.AddFlash(w, r, app.FlashError, "Only unused custom roles can be deleted; move their members to another role first.")
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	if err := s.App.Store().Q.DeleteRole(name); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Delete failed (the role might have gained members).")
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	_ = s.App.ReloadRoles()
+	s.recordAudit(r, audit.Entry{Action: audit.RoleDelete, TargetType: audit.TargetRole, TargetLabel: name, Before: existing})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Role deleted.")
+	s.redirect(w, r, "/admin/roles")
+}
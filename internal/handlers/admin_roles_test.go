@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+)
+
+// A user manager without settings.manage, as a kiosk or walk-up operator might be.
+func newManagerServer(t *testing.T) (*Server, *userPair) {
+	t.Helper()
+	s := newTestServer(t)
+	s.addRole(t, "MANAGER", app.PermOrdersPlace, app.PermOrdersManage, app.PermUsersManage)
+	return s, &userPair{
+		admin:   s.addUser(t, "admin@example.com", app.RoleAdmin),
+		manager: s.addUser(t, "manager@example.com", "MANAGER"),
+	}
+}
+
+type userPair struct {
+	admin, manager *db.User
+}
+
+func TestRoleCreateRefusesPermissionsTheActorLacks(t *testing.T) {
+	s, u := newManagerServer(t)
+
+	form := url.Values{"name": {"ESCALATED"}, "permissions": {app.PermUsersManage, app.PermSettingsManage}}
+	s.serve(t, s.AdminRoleCreatePost, u.manager, http.MethodPost, "/admin/roles", nil, form)
+	if r, _ := s.App.Store().Q.GetRole("ESCALATED"); r != nil {
+		t.Fatalf("manager created a role with settings.manage: %+v", r.Permissions)
+	}
+
+	form = url.Values{"name": {"DOOR"}, "permissions": {app.PermOrdersPlace, app.PermUsersManage}}
+	s.serve(t, s.AdminRoleCreatePost, u.manager, http.MethodPost, "/admin/roles", nil, form)
+	if r, _ := s.App.Store().Q.GetRole("DOOR"); r == nil {
+		t.Fatal("manager could not create a role within their own permissions")
+	}
+
+	form = url.Values{"name": {"OWNER"}, "permissions": {app.PermSettingsManage}}
+	s.serve(t, s.AdminRoleCreatePost, u.admin, http.MethodPost, "/admin/roles", nil, form)
+	if r, _ := s.App.Store().Q.GetRole("OWNER"); r == nil {
+		t.Fatal("admin could not create a role with settings.manage")
+	}
+}
+
+func TestRoleUpdateRefusesEscalation(t *testing.T) {
+	s, u := newManagerServer(t)
+	s.addRole(t, "AUDITOR", app.PermReportsView)
+
+	// their own role
+	form := url.Values{"permissions": {app.PermOrdersPlace, app.PermOrdersManage, app.PermUsersManage, app.PermSettingsManage}}
+	s.serve(t, s.AdminRoleUpdatePost, u.manager, http.MethodPost, "/admin/roles/MANAGER", map[string]string{"name": "MANAGER"}, form)
+	if s.App.RoleCan("MANAGER", app.PermSettingsManage) {
+		t.Fatal("manager granted their own role settings.manage")
+	}
+
+	// a role that already holds more than they do
+	form = url.Values{"permissions": {}}
+	s.serve(t, s.AdminRoleUpdatePost, u.manager, http.MethodPost, "/admin/roles/AUDITOR", map[string]string{"name": "AUDITOR"}, form)
+	if !s.App.RoleCan("AUDITOR", app.PermReportsView) {
+		t.Fatal("manager edited a role holding a permission they lack")
+	}
+
+	form = url.Values{"permissions": {app.PermOrdersPlace, app.PermUsersManage}}
+	s.serve(t, s.AdminRoleUpdatePost, u.manager, http.MethodPost, "/admin/roles/MANAGER", map[string]string{"name": "MANAGER"}, form)
+	if s.App.RoleCan("MANAGER", app.PermOrdersManage) {
+		t.Fatal("manager could not narrow their own role")
+	}
+}
+
+func TestUserRoleAssignmentRefusesEscalation(t *testing.T) {
+	s, u := newManagerServer(t)
+	q := s.App.Store().Q
+	id := func(x *db.User) map[string]string { return map[string]string{"id": strconv.FormatInt(x.ID, 10)} }
+
+	form := url.Values{"email": {u.manager.Email}, "display_name": {"Manager"}, "role": {app.RoleAdmin}}
+	s.serve(t, s.AdminUserUpdatePost, u.manager, http.MethodPost, "/admin/users/x", id(u.manager), form)
+	if got, _ := q.GetUserByID(u.manager.ID); got.Role != "MANAGER" {
+		t.Fatalf("manager promoted themselves to %s", got.Role)
+	}
+
+	form = url.Values{"email": {"new@example.com"}, "display_name": {"New"}, "role": {app.RoleAdmin}, "password": {"long enough pw"}}
+	s.serve(t, s.AdminUserCreatePost, u.manager, http.MethodPost, "/admin/users", nil, form)
+	if got, _ := q.GetUserByEmail("new@example.com"); got != nil {
+		t.Fatalf("manager created an %s account", got.Role)
+	}
+
+	form = url.Values{"email": {"taken@example.com"}, "display_name": {"Admin"}, "role": {app.RoleAdmin}, "password": {"long enough pw"}}
+	s.serve(t, s.AdminUserUpdatePost, u.manager, http.MethodPost, "/admin/users/x", id(u.admin), form)
+	if got, _ := q.GetUserByID(u.admin.ID); got.Email != u.admin.Email || got.PasswordHash != u.admin.PasswordHash {
+		t.Fatal("manager changed an admin's email or password")
+	}
+
+	s.serve(t, s.AdminUserTogglePost, u.manager, http.MethodPost, "/admin/users/x/toggle", id(u.admin), url.Values{"active": {"0"}})
+	if got, _ := q.GetUserByID(u.admin.ID); !got.IsActive {
+		t.Fatal("manager disabled an admin")
+	}
+
+	w := s.serve(t, s.AdminUserResetPost, u.manager, http.MethodPost, "/admin/users/x/reset", id(u.admin), url.Values{})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("manager got a reset link for an admin (status %d)", w.Code)
+	}
+
+	form = url.Values{"email": {"guest@example.com"}, "display_name": {"Guest"}, "role": {app.RoleUser}, "password": {"long enough pw"}}
+	s.serve(t, s.AdminUserCreatePost, u.manager, http.MethodPost, "/admin/users", nil, form)
+	if got, _ := q.GetUserByEmail("guest@example.com"); got == nil {
+		t.Fatal("manager could not create a guest")
+	}
+
+	form = url.Values{"email": {u.manager.Email}, "display_name": {"Manager"}, "role": {app.RoleAdmin}}
+	s.serve(t, s.AdminUserUpdatePost, u.admin, http.MethodPost, "/admin/users/x", id(u.manager), form)
+	if got, _ := q.GetUserByID(u.manager.ID); got.Role != app.RoleAdmin {
+		t.Fatal("admin could not promote the manager")
+	}
+}
+
+func TestInviteRefusesRolesBeyondTheActor(t *testing.T) {
+	s, u := newManagerServer(t)
+
+	form := url.Values{"role": {app.RoleAdmin}, "expires_in": {"24"}}
+	s.serve(t, s.AdminInviteCreatePost, u.manager, http.MethodPost, "/admin/invites", nil, form)
+	if list, _ := s.App.Store().Q.ListInvites(); len(list) != 0 {
+		t.Fatalf("manager created an invite for %s", list[0].Role)
+	}
+}
+
+func TestInvitesAreForGuestRolesOnly(t *testing.T) {
+	s, u := newManagerServer(t)
+	q := s.App.Store().Q
+	s.addRole(t, "VIP", app.PermOrdersPlace)
+
+	for _, role := range []string{app.RoleBartender, "MANAGER", "VIP"} {
+		form := url.Values{"role": {role}, "expires_in": {"24"}}
+		s.serve(t, s.AdminInviteCreatePost, u.admin, http.MethodPost, "/admin/invites", nil, form)
+	}
+	list, _ := q.ListInvites()
+	if len(list) != 1 || list[0].Role != "VIP" {
+		t.Fatalf("invites = %+v, want only the VIP one", list)
+	}
+
+	// a role that requires two-factor after the invite was made can't be joined
+	if err := q.SetRoleRequire2FA("VIP", true); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.App.ReloadRoles()
+	form := url.Values{"display_name": {"Sam"}, "email": {"sam@example.com"}, "password": {"long enough pw"}}
+	s.serve(t, s.JoinPost, nil, http.MethodPost, "/join/x", map[string]string{"token": list[0].Token}, form)
+	if got, _ := q.GetUserByEmail("sam@example.com"); got != nil {
+		t.Fatalf("joined as %s through an invite to a two-factor role", got.Role)
+	}
+}
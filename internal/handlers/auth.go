@@ -23,6 +23,8 @@ type ViewData struct {
 	PageTemplate string
 	Page         any
 	Now          time.Time
+	Kiosk        *app.KioskSession // set while a guest orders on a kiosk
+	Walkup       *db.WalkupGuest   // set for a name-only walk-up guest
 }
 
 func (s *Server) renderLayout(w http.ResponseWriter, r *http.Request, title, pageTemplate string, page any) {
@@ -35,6 +37,8 @@ func (s *Server) renderLayout(w http.ResponseWriter, r *http.Request, title, pag
 		PageTemplate: pageTemplate,
 		Page:         page,
 		Now:          time.Now(),
+		Kiosk:        s.App.KioskSession(r),
+		Walkup:       s.App.WalkupGuest(r),
 	}
 	_ = s.App.Templates().ExecuteTemplate(w, "layout.html", data)
 }
@@ -82,7 +86,11 @@ func (s *Server) LoginGet(w http.ResponseWriter, r *http.Request) {
 		s.redirect(w, r, "/")
 		return
 	}
-	s.renderLayout(w, r, "Login", "login.html", map[string]any{})
+	page := LoginPage{}
+	if s.App.OIDC() != nil {
+		page.OIDCName = s.App.Config().OIDCProviderName
+	}
+	s.renderLayout(w, r, "Login", "login.html", page)
 }
 
 func (s *Server) LoginPost(w http.ResponseWriter, r *http.Request) {
@@ -97,9 +105,7 @@ func (s *Server) LoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = s.App.SetSessionUser(w, r, u.ID)
-	s.App.AddFlash(w, r, app.FlashSuccess, "Welcome back, "+u.DisplayName+"!")
-	s.redirect(w, r, "/")
+	s.signIn(w, r, u, "Welcome back, "+u.DisplayName+"!", "/")
 }
 
 func (s *Server) LogoutPost(w http.ResponseWriter, r *http.Request) {
@@ -148,12 +154,13 @@ func (s *Server) OnboardingPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	id, err := s.App.Store().Q.CreateUser(db.CreateUserParams{
-		Email:        email,
-		PasswordHash: hash,
-		Role:         app.RoleAdmin,
-		DisplayName:  name,
-		IsActive:     true,
-		OnDuty:       false,
+		Email:         email,
+		PasswordHash:  hash,
+		Role:          app.RoleAdmin,
+		DisplayName:   name,
+		IsActive:      true,
+		OnDuty:        false,
+		EmailVerified: true,
 	})
 	if err != nil {
 		// If another request created the admin first, guide user to login.
@@ -163,6 +170,7 @@ func (s *Server) OnboardingPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.emitUserCreated(s.userAudit(id))
 	_ = s.App.SetSessionUser(w, r, id)
 	s.App.AddFlash(w, r, app.FlashSuccess, "Admin created. You're in.")
 	s.redirect(w, r, "/admin/users")
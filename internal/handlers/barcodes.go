@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"house-bartender-go/internal/services/audit"
+)
+
+type barcodeScanRequest struct {
+	Code  string `json:"code"`
+	Delta int64  `json:"delta"`
+}
+
+type barcodeScanProduct struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Stock *int64 `json:"stock"`
+}
+
+type barcodeScanResponse struct {
+	OK        bool                `json:"ok"`
+	Found     bool                `json:"found"`
+	Code      string              `json:"code,omitempty"`
+	Product   *barcodeScanProduct `json:"product,omitempty"`
+	CreateURL string              `json:"create_url,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// normalizeBarcode strips the whitespace scanners and keyboards like to add around codes.
+func normalizeBarcode(raw string) string {
+	return strings.Join(strings.Fields(raw), "")
+}
+
+// BarcodeScanPost looks a product up by barcode and, with delta +1 or -1, adjusts its stock
+// by one unit. Unknown codes answer with a link to create a prefilled ingredient.
+func (s *Server) BarcodeScanPost(w http.ResponseWriter, r *http.Request) {
+	if !s.requireTrustedOrigin(w, r) {
+		return
+	}
+
+	var req barcodeScanRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, barcodeScanResponse{Error: "Invalid scan payload."})
+		return
+	}
+	code := normalizeBarcode(req.Code)
+	if code == "" || len(code) > 64 {
+		writeJSON(w, http.StatusBadRequest, barcodeScanResponse{Error: "Scan a barcode first."})
+		return
+	}
+	if req.Delta < -1 || req.Delta > 1 {
+		writeJSON(w, http.StatusBadRequest, barcodeScanResponse{Error: "A scan adjusts stock by one unit at a time."})
+		return
+	}
+
+	p, err := s.App.Store().Q.GetProductByBarcode(code)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, barcodeScanResponse{Error: "Lookup failed."})
+		return
+	}
+	if p == nil {
+		writeJSON(w, http.StatusOK, barcodeScanResponse{
+			OK:        true,
+			Code:      code,
+			CreateURL: "/bartender/products?barcode=" + url.QueryEscape(code) + "#inventory-editor",
+		})
+		return
+	}
+
+	if req.Delta != 0 {
+		before := s.stockSnapshot(p.ID)
+		if err := s.App.Store().Q.AdjustProductStock(p.ID, req.Delta); err != nil {
+			writeJSON(w, http.StatusInternalServerError, barcodeScanResponse{Error: "Could not adjust stock."})
+			return
+		}
+		s.broadcastInventory()
+		s.notifyLowStock(before)
+		if updated, _ := s.App.Store().Q.GetProductByID(p.ID); updated != nil {
+			s.recordAudit(r, audit.Entry{Action: audit.ProductScan, TargetType: audit.TargetProduct, TargetID: p.ID, TargetLabel: p.Name, Before: p, After: updated})
+			p = updated
+		}
+	}
+
+	writeJSON(w, http.StatusOK, barcodeScanResponse{
+		OK:      true,
+		Found:   true,
+		Code:    code,
+		Product: &barcodeScanProduct{ID: p.ID, Name: p.Name, Stock: p.StockCount},
+	})
+}
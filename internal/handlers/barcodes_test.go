@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+)
+
+// scan posts a barcode scan as the scanner page does, from the app's own origin.
+func (s *Server) scan(t *testing.T, u *db.User, body string) (int, barcodeScanResponse) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/bartender/products/scan", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Origin", "http://example.com")
+	rec := httptest.NewRecorder()
+	if err := s.App.SetSessionUser(rec, r, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	s.App.MiddlewareLoadCurrentUser(http.HandlerFunc(s.BarcodeScanPost)).ServeHTTP(w, r)
+	var resp barcodeScanResponse
+	if w.Code != http.StatusForbidden {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("scan answer %q: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestBarcodeScan(t *testing.T) {
+	s := newTestServer(t)
+	q := s.App.Store().Q
+	bartender := s.addUser(t, "bar@example.com", app.RoleBartender)
+	one := int64(1)
+	id, err := q.CreateProduct(db.CreateProductParams{Name: "Test Tonic", Category: "Mixer", IsAvailable: true, StockCount: &one, Barcode: "5000112637922"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp := s.scan(t, bartender, `{"code":" 50001 12637922\n","delta":0}`)
+	if code != http.StatusOK || !resp.Found || resp.Product.ID != id || *resp.Product.Stock != 1 {
+		t.Fatalf("lookup = %d %+v", code, resp)
+	}
+
+	_, resp = s.scan(t, bartender, `{"code":"5000112637922","delta":1}`)
+	if *resp.Product.Stock != 2 {
+		t.Fatalf("after +1 = %+v", resp.Product)
+	}
+	for i := 0; i < 3; i++ {
+		_, resp = s.scan(t, bartender, `{"code":"5000112637922","delta":-1}`)
+	}
+	if *resp.Product.Stock != 0 {
+		t.Fatalf("stock went below zero: %d", *resp.Product.Stock)
+	}
+	if entries, _, _ := q.ListAudit(db.AuditFilter{Action: audit.ProductScan}); len(entries) != 4 {
+		t.Fatalf("%d scan audit entries, want 4", len(entries))
+	}
+
+	code, resp = s.scan(t, bartender, `{"code":"0000 0000","delta":1}`)
+	if code != http.StatusOK || resp.Found || resp.Code != "00000000" || resp.CreateURL != "/bartender/products?barcode=00000000#inventory-editor" {
+		t.Fatalf("unknown code = %d %+v", code, resp)
+	}
+
+	for _, bad := range []string{`{"code":"","delta":0}`, `{"code":"1","delta":2}`, `{"code":"1","extra":true}`, `not json`} {
+		if code, _ := s.scan(t, bartender, bad); code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", bad, code)
+		}
+	}
+}
+
+func TestBarcodeIsUniqueWhenSet(t *testing.T) {
+	s := newTestServer(t)
+	q := s.App.Store().Q
+	bartender := s.addUser(t, "bar@example.com", app.RoleBartender)
+
+	// any number of products go without a barcode
+	for _, name := range []string{"Test Lime", "Test Mint"} {
+		if _, err := q.CreateProduct(db.CreateProductParams{Name: name, Category: "Fresh"}); err != nil {
+			t.Fatalf("%s without a barcode: %v", name, err)
+		}
+	}
+	if _, err := q.CreateProduct(db.CreateProductParams{Name: "Test Cola", Category: "Mixer", Barcode: "123"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.CreateProduct(db.CreateProductParams{Name: "Test Cola Zero", Category: "Mixer", Barcode: "123"}); err == nil {
+		t.Fatal("two products share a barcode")
+	}
+
+	form := url.Values{"name": {"Test Soda"}, "category": {"Mixer"}, "barcode": {" 1 2 3 "}}
+	s.serve(t, s.ProductCreatePost, bartender, http.MethodPost, "/bartender/products", nil, form)
+	if p, _ := q.GetProductByBarcode("123"); p == nil || p.Name != "Test Cola" {
+		t.Fatalf("barcode 123 belongs to %+v", p)
+	}
+	list, _ := q.ListProducts("Test Soda")
+	if len(list) != 0 {
+		t.Fatal("the form created a product with a taken barcode")
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"house-bartender-go/internal/app"
 	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
 )
 
 type BartenderDashboardPage struct {
@@ -23,23 +24,30 @@ type BartenderDashboardPage struct {
 	FeaturedName    string
 	FeaturedContext string
 	FeaturedImage   string
-	Push            BartenderPushPage
+	Push            PushCard
+	PushDevices     []PushDevice
 }
 
-type BartenderPushPage struct {
-	Show               bool
-	Configured         bool
-	PublicKey          string
-	EnabledDeviceCount int
+type BartenderProductsPage struct {
+	Search        string
+	Category      string
+	Status        string
+	Categories    []string
+	StatusOptions []InventoryStatusOption
+	Products      []db.Product
+	Form          ProductFormState
 }
 
-type BartenderProductsPage struct {
-	Search     string
-	Category   string
-	Status     string
-	Categories []string
-	Products   []db.Product
-	Form       ProductFormState
+type InventoryStatusOption struct {
+	Value string
+	Label string
+}
+
+var inventoryStatusOptions = []InventoryStatusOption{
+	{Value: "", Label: "All"},
+	{Value: "below_par", Label: "Below Par"},
+	{Value: "low", Label: "Low Stock"},
+	{Value: "out", Label: "Out of Stock"},
 }
 
 type ProductFormState struct {
@@ -51,6 +59,9 @@ type ProductFormState struct {
 	AllergenFlags string
 	Notes         string
 	StockCount    string
+	ParLevel      string
+	ReorderLevel  string
+	Barcode       string
 	IsAvailable   bool
 }
 
@@ -59,6 +70,7 @@ type BartenderOrdersPage struct {
 	Orders     []db.Order
 	Bartenders []db.User
 	Events     map[int64][]db.OrderEvent // optional
+	QueueFilter
 }
 
 type DashboardOrdersPreviewPage struct {
@@ -72,7 +84,8 @@ func (s *Server) BartenderDashboardGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	queue := s.listBartenderQueue()
+	ids, _ := s.queueStations(r)
+	queue := s.listBartenderQueue(ids)
 	var cPlaced, cAcc, cProg, cReady int
 	var claimed int
 	var totalAge time.Duration
@@ -119,7 +132,10 @@ func (s *Server) BartenderDashboardGet(w http.ResponseWriter, r *http.Request) {
 		FeaturedName:    featuredName,
 		FeaturedContext: featuredContext,
 		FeaturedImage:   featuredImage,
+		Push:            s.pushCard(r, u, "Get new orders, overdue orders and low stock on this device, even with the app closed. Choose what each device gets below."),
+		PushDevices:     s.pushDevices(u.ID),
 	}
+	page.Push.Reload = true
 	s.renderLayout(w, r, "Bartender", "bartender_dashboard.html", page)
 }
 
@@ -130,7 +146,8 @@ func (s *Server) BartenderDutyPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	newDuty := !u.OnDuty
-	_ = s.App.Store().Q.SetUserDuty(u.ID, newDuty)
+	_ = s.App.Store().Q.SetUserDuty(u.ID, newDuty, db.ShiftEndSelf)
+	s.recordAudit(r, audit.Entry{Action: audit.UserDuty, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: u.DisplayName, Before: u, After: s.userAudit(u.ID)})
 	if newDuty {
 		s.App.AddFlash(w, r, app.FlashSuccess, "You are now On Duty.")
 	} else {
@@ -141,7 +158,10 @@ func (s *Server) BartenderDutyPost(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) BartenderProductsGet(w http.ResponseWriter, r *http.Request) {
 	search, category, status := inventoryFiltersFromRequest(r)
-	page := s.buildBartenderProductsPage(search, category, status, defaultProductFormState(inventoryURL("/bartender/products", search, category, status)))
+	form := defaultProductFormState(inventoryURL("/bartender/products", search, category, status))
+	// An unknown scan links here so the new ingredient starts with its barcode filled in.
+	form.Barcode = normalizeBarcode(r.URL.Query().Get("barcode"))
+	page := s.buildBartenderProductsPage(search, category, status, form)
 	s.renderLayout(w, r, "Ingredients", "bartender_products.html", page)
 }
 
@@ -170,43 +190,37 @@ func (s *Server) BartenderCocktailsPartialGet(w http.ResponseWriter, r *http.Req
 }
 
 func (s *Server) BartenderOrdersGet(w http.ResponseWriter, r *http.Request) {
-	page := s.buildBartenderOrdersPage("bartender")
+	page := s.buildBartenderOrdersPage(r, "bartender")
 	s.renderLayout(w, r, "Orders", "bartender_orders.html", page)
 }
 
 func (s *Server) BartenderOrdersPartialGet(w http.ResponseWriter, r *http.Request) {
 	if strings.TrimSpace(r.URL.Query().Get("view")) == "dashboard" {
-		page := DashboardOrdersPreviewPage{Orders: limitOrders(s.listBartenderQueue(), 5)}
+		ids, _ := s.queueStations(r)
+		page := DashboardOrdersPreviewPage{Orders: limitOrders(s.listBartenderQueue(ids), 5)}
 		s.renderPartial(w, r, "dashboard_orders_preview.html", page, "/bartender")
 		return
 	}
 
-	page := s.buildBartenderOrdersPage("bartender")
+	page := s.buildBartenderOrdersPage(r, "bartender")
 	s.renderPartial(w, r, "orders_list.html", page, "/bartender/orders")
 }
 
-func (s *Server) buildBartenderOrdersPage(mode string) BartenderOrdersPage {
-	orders := s.listBartenderQueue()
-	users, _ := s.App.Store().Q.ListUsers()
-
-	var bartenders []db.User
-	for _, u := range users {
-		if u.Role == app.RoleBartender && u.IsActive {
-			bartenders = append(bartenders, u)
-		}
-	}
-
+func (s *Server) buildBartenderOrdersPage(r *http.Request, mode string) BartenderOrdersPage {
+	ids, filter := s.queueStations(r)
 	return BartenderOrdersPage{
-		Mode:       mode,
-		Orders:     orders,
-		Bartenders: bartenders,
-		Events:     map[int64][]db.OrderEvent{},
+		Mode:        mode,
+		Orders:      s.listBartenderQueue(ids),
+		Bartenders:  s.queueBartenders(),
+		Events:      map[int64][]db.OrderEvent{},
+		QueueFilter: filter,
 	}
 }
 
-func (s *Server) listBartenderQueue() []db.Order {
-	orders, _ := s.App.Store().Q.ListOrderQueue()
-	return orders
+// listBartenderQueue lists the open orders of the given stations, nil meaning all.
+func (s *Server) listBartenderQueue(stationIDs []int64) []db.Order {
+	orders, _ := s.App.Store().Q.ListOrderQueue(stationIDs)
+	return s.attachOrderModifiers(orders)
 }
 
 func limitOrders[T any](items []T, limit int) []T {
@@ -251,11 +265,12 @@ func (s *Server) buildBartenderProductsPage(search, category, status string, for
 	allProducts, _ := s.App.Store().Q.ListProducts("")
 
 	return BartenderProductsPage{
-		Search:     search,
-		Category:   category,
-		Status:     status,
-		Categories: productCategories(allProducts),
-		Products:   filterProducts(products, category, status),
-		Form:       form,
+		Search:        search,
+		Category:      category,
+		Status:        status,
+		Categories:    productCategories(allProducts),
+		StatusOptions: inventoryStatusOptions,
+		Products:      filterProducts(products, category, status),
+		Form:          form,
 	}
 }
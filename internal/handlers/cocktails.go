@@ -1,17 +1,19 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"house-bartender-go/internal/app"
 	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/images"
+	"house-bartender-go/internal/services/media"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -23,12 +25,35 @@ type CocktailFormRow struct {
 	Required    bool
 }
 
+type CocktailModifierFormRow struct {
+	Kind      string
+	Label     string
+	Options   string
+	ProductID int64
+}
+
 type CocktailFormPage struct {
-	Mode     string // "new" or "edit"
-	Cocktail db.Cocktail
-	Tags     string
-	Products []db.Product
-	IngRows  []CocktailFormRow
+	Mode         string // "new" or "edit"
+	Cocktail     db.Cocktail
+	Tags         string
+	Products     []db.Product
+	IngRows      []CocktailFormRow
+	ModRows      []CocktailModifierFormRow
+	ModifierKind []ModifierKindOption
+	Media        []db.Media // library images the editor can reuse
+}
+
+type ModifierKindOption struct {
+	Value string
+	Label string
+}
+
+var modifierKindOptions = []ModifierKindOption{
+	{Value: db.ModifierOptionalIngredient, Label: "Optional ingredient"},
+	{Value: db.ModifierGarnish, Label: "Garnish"},
+	{Value: db.ModifierSweetness, Label: "Sweetness"},
+	{Value: db.ModifierStrength, Label: "Strength"},
+	{Value: db.ModifierGlass, Label: "Glass"},
 }
 
 func defaultCocktailFormRows(count int) []CocktailFormRow {
@@ -62,21 +87,45 @@ func cocktailFormRowsFromIngredients(ings []db.CocktailIngredient) []CocktailFor
 	return rows
 }
 
+func cocktailModifierRowsFromModifiers(mods []db.CocktailModifier) []CocktailModifierFormRow {
+	rows := make([]CocktailModifierFormRow, 0, len(mods)+1)
+	for _, m := range mods {
+		row := CocktailModifierFormRow{
+			Kind:    m.Kind,
+			Label:   m.Label,
+			Options: m.Options,
+		}
+		if m.ProductID != nil {
+			row.ProductID = *m.ProductID
+		}
+		rows = append(rows, row)
+	}
+
+	rows = append(rows, CocktailModifierFormRow{Kind: db.ModifierOptionalIngredient})
+	return rows
+}
+
 func (s *Server) CocktailNewGet(w http.ResponseWriter, r *http.Request) {
 	products, _ := s.App.Store().Q.ListProducts("")
 	page := CocktailFormPage{
-		Mode:     "new",
-		Cocktail: db.Cocktail{Difficulty: "easy", PrepTimeMinutes: 5, IsEnabled: true},
-		Products: products,
-		IngRows:  defaultCocktailFormRows(3),
+		Mode:         "new",
+		Cocktail:     db.Cocktail{Difficulty: "easy", PrepTimeMinutes: 5, IsEnabled: true},
+		Products:     products,
+		IngRows:      defaultCocktailFormRows(3),
+		ModRows:      []CocktailModifierFormRow{{Kind: db.ModifierOptionalIngredient}},
+		ModifierKind: modifierKindOptions,
 	}
+	page.Media, _ = s.App.Store().Q.ListMedia()
 	s.renderLayout(w, r, "New Cocktail", "cocktail_form.html", page)
 }
 
 func (s *Server) CocktailNewPost(w http.ResponseWriter, r *http.Request) {
-	_ = r.ParseMultipartForm(10 << 20)
+	if !s.parseCocktailMultipart(w, r) {
+		s.redirect(w, r, "/bartender/cocktails/new")
+		return
+	}
 
-	c, items, imagePath, ok := s.parseCocktailForm(w, r, 0, "")
+	c, items, mods, imagePath, ok := s.parseCocktailForm(w, r, 0, "")
 	if !ok {
 		s.redirect(w, r, "/bartender/cocktails/new")
 		return
@@ -100,6 +149,8 @@ func (s *Server) CocktailNewPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_ = s.App.Store().Q.ReplaceCocktailIngredients(id, items)
+	_ = s.App.Store().Q.ReplaceCocktailModifiers(id, mods)
+	s.recordAudit(r, audit.Entry{Action: audit.CocktailCreate, TargetType: audit.TargetCocktail, TargetID: id, TargetLabel: c.Name, After: s.cocktailAudit(id)})
 	s.broadcastInventory()
 	s.App.AddFlash(w, r, app.FlashSuccess, "Cocktail created.")
 	s.redirect(w, r, "/bartender/cocktails")
@@ -121,14 +172,18 @@ func (s *Server) CocktailEditGet(w http.ResponseWriter, r *http.Request) {
 
 	products, _ := s.App.Store().Q.ListProducts("")
 	ings, _ := s.App.Store().Q.GetCocktailIngredients(id)
+	mods, _ := s.App.Store().Q.GetCocktailModifiers(id)
 
 	page := CocktailFormPage{
-		Mode:     "edit",
-		Cocktail: *c,
-		Tags:     c.Tags,
-		Products: products,
-		IngRows:  cocktailFormRowsFromIngredients(ings),
+		Mode:         "edit",
+		Cocktail:     *c,
+		Tags:         c.Tags,
+		Products:     products,
+		IngRows:      cocktailFormRowsFromIngredients(ings),
+		ModRows:      cocktailModifierRowsFromModifiers(mods),
+		ModifierKind: modifierKindOptions,
 	}
+	page.Media, _ = s.App.Store().Q.ListMedia()
 	s.renderLayout(w, r, "Edit Cocktail", "cocktail_form.html", page)
 }
 
@@ -146,14 +201,18 @@ func (s *Server) CocktailEditPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = r.ParseMultipartForm(10 << 20)
+	if !s.parseCocktailMultipart(w, r) {
+		s.redirect(w, r, "/bartender/cocktails/"+idStr+"/edit")
+		return
+	}
 
-	c, items, imagePath, ok := s.parseCocktailForm(w, r, id, existing.ImagePath)
+	c, items, mods, imagePath, ok := s.parseCocktailForm(w, r, id, existing.ImagePath)
 	if !ok {
 		s.redirect(w, r, "/bartender/cocktails/"+idStr+"/edit")
 		return
 	}
 
+	before := s.cocktailAudit(id)
 	err := s.App.Store().Q.UpdateCocktail(db.UpdateCocktailParams{
 		ID:              id,
 		Name:            c.Name,
@@ -172,6 +231,8 @@ func (s *Server) CocktailEditPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_ = s.App.Store().Q.ReplaceCocktailIngredients(id, items)
+	_ = s.App.Store().Q.ReplaceCocktailModifiers(id, mods)
+	s.recordAudit(r, audit.Entry{Action: audit.CocktailUpdate, TargetType: audit.TargetCocktail, TargetID: id, TargetLabel: c.Name, Before: before, After: s.cocktailAudit(id)})
 	s.broadcastInventory()
 	s.App.AddFlash(w, r, app.FlashSuccess, "Cocktail updated.")
 	s.redirect(w, r, "/bartender/cocktails")
@@ -187,7 +248,10 @@ func (s *Server) CocktailTogglePost(w http.ResponseWriter, r *http.Request) {
 
 	_ = r.ParseForm()
 	enabled := formBool(r, "is_enabled")
-	_ = s.App.Store().Q.ToggleCocktailEnabled(id, enabled)
+	if before := s.cocktailAudit(id); before != nil {
+		_ = s.App.Store().Q.ToggleCocktailEnabled(id, enabled)
+		s.recordAudit(r, audit.Entry{Action: audit.CocktailToggle, TargetType: audit.TargetCocktail, TargetID: id, TargetLabel: before.Name, Before: before, After: s.cocktailAudit(id)})
+	}
 	s.broadcastInventory()
 	s.redirect(w, r, "/bartender/cocktails")
 }
@@ -199,13 +263,16 @@ func (s *Server) CocktailDeletePost(w http.ResponseWriter, r *http.Request) {
 		s.redirect(w, r, "/bartender/cocktails")
 		return
 	}
-	_ = s.App.Store().Q.DeleteCocktail(id)
+	before := s.cocktailAudit(id)
+	if err := s.App.Store().Q.DeleteCocktail(id); err == nil && before != nil {
+		s.recordAudit(r, audit.Entry{Action: audit.CocktailDelete, TargetType: audit.TargetCocktail, TargetID: id, TargetLabel: before.Name, Before: before})
+	}
 	s.broadcastInventory()
 	s.App.AddFlash(w, r, app.FlashSuccess, "Cocktail deleted.")
 	s.redirect(w, r, "/bartender/cocktails")
 }
 
-func (s *Server) parseCocktailForm(w http.ResponseWriter, r *http.Request, id int64, existingImage string) (db.Cocktail, []db.IngredientUpsertItem, string, bool) {
+func (s *Server) parseCocktailForm(w http.ResponseWriter, r *http.Request, id int64, existingImage string) (db.Cocktail, []db.IngredientUpsertItem, []db.ModifierUpsertItem, string, bool) {
 	name := strings.TrimSpace(r.FormValue("name"))
 	desc := strings.TrimSpace(r.FormValue("description"))
 	tags := strings.TrimSpace(r.FormValue("tags"))
@@ -224,7 +291,7 @@ func (s *Server) parseCocktailForm(w http.ResponseWriter, r *http.Request, id in
 
 	if name == "" {
 		s.App.AddFlash(w, r, app.FlashError, "Name is required.")
-		return db.Cocktail{}, nil, existingImage, false
+		return db.Cocktail{}, nil, nil, existingImage, false
 	}
 
 	// Ingredients arrays
@@ -265,13 +332,26 @@ func (s *Server) parseCocktailForm(w http.ResponseWriter, r *http.Request, id in
 		})
 	}
 
+	mods := s.parseModifierRows(r)
+
 	// Image upload (optional)
 	imagePath := existingImage
-	if file, hdr, err := r.FormFile("image"); err == nil && file != nil {
+	if file, _, err := r.FormFile("image"); err == nil && file != nil {
 		defer file.Close()
-		if p, err := s.saveUpload(hdr.Filename, file); err == nil {
-			imagePath = p
+		p, err := s.saveUpload(r, file)
+		if err != nil {
+			s.App.AddFlash(w, r, app.FlashError, "Image upload failed: "+err.Error())
+			return db.Cocktail{}, nil, nil, existingImage, false
+		}
+		imagePath = p
+	} else if pick := strings.TrimSpace(r.FormValue("library_image")); pick != "" && pick != imagePath {
+		// Reuse an image from the media library; only known library paths are accepted.
+		m, _ := s.App.Store().Q.GetMediaByPath(pick)
+		if m == nil {
+			s.App.AddFlash(w, r, app.FlashError, "That library image no longer exists.")
+			return db.Cocktail{}, nil, nil, existingImage, false
 		}
+		imagePath = m.Path
 	}
 
 	c := db.Cocktail{
@@ -284,37 +364,146 @@ func (s *Server) parseCocktailForm(w http.ResponseWriter, r *http.Request, id in
 		Instructions:    instr,
 		IsEnabled:       enabled,
 	}
-	return c, items, imagePath, true
+	return c, items, mods, imagePath, true
 }
 
-func (s *Server) saveUpload(original string, src io.Reader) (string, error) {
-	ext := strings.ToLower(filepath.Ext(original))
-	if ext == "" {
-		ext = ".img"
+// parseModifierRows reads the guest modifier arrays from the cocktail editor.
+// Rows without a label (or product, for optional ingredients) are ignored.
+func (s *Server) parseModifierRows(r *http.Request) []db.ModifierUpsertItem {
+	kinds := r.Form["modifier_kind"]
+	labels := r.Form["modifier_label"]
+	options := r.Form["modifier_options"]
+	pids := r.Form["modifier_product_id"]
+
+	// Unlabelled ingredient rows take the product's name, looked up once for the lot.
+	var productNames map[int64]string
+	productName := func(id int64) string {
+		if productNames == nil {
+			productNames = map[int64]string{}
+			products, _ := s.App.Store().Q.ListProducts("")
+			for _, p := range products {
+				productNames[p.ID] = p.Name
+			}
+		}
+		return productNames[id]
 	}
-	name := fmt.Sprintf("%d_%s%s", time.Now().UnixNano(), "cocktail", ext)
-	dstPath := filepath.Join(s.App.Config().UploadDir, name)
 
-	if err := os.MkdirAll(s.App.Config().UploadDir, 0o755); err != nil {
-		return "", err
+	var out []db.ModifierUpsertItem
+	for i := range kinds {
+		kind := normalizeModifierKind(kinds[i])
+		if kind == "" {
+			continue
+		}
+		label := ""
+		if i < len(labels) {
+			label = strings.TrimSpace(labels[i])
+		}
+		opts := ""
+		if i < len(options) {
+			opts = strings.Join(splitCSV(options[i]), ", ")
+		}
+
+		item := db.ModifierUpsertItem{Kind: kind, Label: label, Options: opts}
+		if kind == db.ModifierOptionalIngredient {
+			if i >= len(pids) {
+				continue
+			}
+			pid, ok := parseInt64(pids[i])
+			if !ok {
+				continue
+			}
+			item.ProductID = &pid
+			// Choices are fixed for ingredients so stock depletion stays predictable.
+			item.Options = strings.Join([]string{db.IngredientChoiceRegular, db.IngredientChoiceNone, db.IngredientChoiceExtra}, ", ")
+			if item.Label == "" {
+				item.Label = productName(pid)
+			}
+		} else if item.Options == "" {
+			continue
+		}
+		if item.Label == "" {
+			item.Label = humanModifierKind(kind)
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func normalizeModifierKind(raw string) string {
+	raw = strings.TrimSpace(strings.ToUpper(raw))
+	for _, k := range modifierKindOptions {
+		if k.Value == raw {
+			return raw
+		}
+	}
+	return ""
+}
+
+func humanModifierKind(kind string) string {
+	for _, k := range modifierKindOptions {
+		if k.Value == kind {
+			return k.Label
+		}
 	}
+	return kind
+}
+
+// parseCocktailMultipart caps the request body before parsing the cocktail form, so an
+// oversized upload is refused instead of spooled to disk.
+func (s *Server) parseCocktailMultipart(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, images.MaxUploadBytes+1<<20)
+	err := r.ParseMultipartForm(10 << 20)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.App.AddFlash(w, r, app.FlashError, "Image upload failed: "+images.ErrTooLarge.Error())
+		return false
+	}
+	return true
+}
 
-	f, err := os.Create(dstPath)
+// saveUpload accepts only real JPEG, PNG or WebP data and stores re-encoded variants; the
+// returned path is the hero image.
+func (s *Server) saveUpload(r *http.Request, src io.Reader) (string, error) {
+	data, contentType, err := images.Sniff(src)
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
-
-	_, err = io.Copy(f, src)
+	img, err := images.Decode(data)
+	if err != nil {
+		return "", err
+	}
+	dir := s.App.Config().UploadDir
+	hero, err := images.Save(dir, fmt.Sprintf("%d_cocktail", time.Now().UnixNano()), img)
 	if err != nil {
 		return "", err
 	}
-	return "/uploads/" + name, nil
+	path := media.URLPrefix + hero
+
+	// The files are already on disk; without a library row the image still works and is
+	// collected once nothing uses it.
+	params := db.CreateMediaParams{
+		Path:        path,
+		ContentType: contentType,
+		Bytes:       media.Size(dir, path),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+	if u := s.App.CurrentUser(r); u != nil {
+		params.UploadedBy = u.ID
+	}
+	if _, err := s.App.Store().Q.CreateMedia(params); err != nil {
+		s.App.Logger().Warn("upload not added to the media library", "path", path, "err", err)
+	}
+	return path, nil
 }
 
+// broadcastInventory follows every write that can change what is makeable: it drops the
+// cached library before telling clients to refresh.
 func (s *Server) broadcastInventory() {
+	s.App.Library().Invalidate()
 	s.App.SSE().BroadcastInventory(app.SSEEvent{
 		Type: "inventory:updated",
 		Data: map[string]any{"ts": time.Now().Unix()},
 	})
+	s.App.EmitInventoryUpdated()
 }
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/invites"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type JoinPage struct {
+	Token  string
+	Invite *db.Invite
+	// Status is empty for a usable invite, otherwise why it can't be used.
+	Status string
+}
+
+// JoinGet shows the self-registration form for an invite link.
+func (s *Server) JoinGet(w http.ResponseWriter, r *http.Request) {
+	if s.App.CurrentUser(r) != nil {
+		s.App.AddFlash(w, r, app.FlashInfo, "You're already signed in.")
+		s.redirect(w, r, "/")
+		return
+	}
+	token := chi.URLParam(r, "token")
+	inv, _ := s.App.Store().Q.GetInviteByToken(token)
+	page := JoinPage{Token: token, Invite: inv}
+	if inv == nil {
+		page.Status = "unknown"
+	} else if st := invites.Status(*inv, time.Now()); st != invites.StatusActive {
+		page.Status = st
+	} else if !s.App.GuestRoleAllowed(inv.Role) {
+		// the role gained staff access or two-factor since the invite was made
+		page.Status = invites.StatusRevoked
+	}
+	s.renderLayout(w, r, "Join", "join.html", page)
+}
+
+// JoinPost registers a guest through an invite. With mode=passwordless (when the invite
+// allows it) the guest only gives a name and is kept signed in by the session cookie.
+func (s *Server) JoinPost(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	back := "/join/" + token
+	inv, _ := s.App.Store().Q.GetInviteByToken(token)
+	if inv == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.App.GuestRoleAllowed(inv.Role) {
+		s.App.AddFlash(w, r, app.FlashError, "This invite is no longer valid. Ask your host for a new one.")
+		s.redirect(w, r, back)
+		return
+	}
+
+	_ = r.ParseForm()
+	name := strings.TrimSpace(r.FormValue("display_name"))
+	if name == "" {
+		s.App.AddFlash(w, r, app.FlashError, "Tell us your name.")
+		s.redirect(w, r, back)
+		return
+	}
+
+	var email, hash string
+	if r.FormValue("mode") == "passwordless" {
+		if !inv.Passwordless {
+			s.App.AddFlash(w, r, app.FlashError, "This invite needs an email and password.")
+			s.redirect(w, r, back)
+			return
+		}
+		var err error
+		if email, err = invites.PlaceholderEmail(); err != nil {
+			s.App.AddFlash(w, r, app.FlashError, "Could not create your account.")
+			s.redirect(w, r, back)
+			return
+		}
+	} else {
+		email = app.NormalizeEmail(r.FormValue("email"))
+		if email == "" {
+			s.App.AddFlash(w, r, app.FlashError, "Email is required.")
+			s.redirect(w, r, back)
+			return
+		}
+		var err error
+		if hash, err = app.HashPassword(r.FormValue("password")); err != nil {
+			s.App.AddFlash(w, r, app.FlashError, "Password must be at least 8 characters.")
+			s.redirect(w, r, back)
+			return
+		}
+	}
+
+	id, err := s.App.Store().Q.RedeemInvite(token, email, hash, name)
+	if errors.Is(err, db.ErrInviteUnavailable) {
+		s.App.AddFlash(w, r, app.FlashError, "This invite is no longer valid. Ask your host for a new one.")
+		s.redirect(w, r, back)
+		return
+	}
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create your account (email might already exist). Try logging in.")
+		s.redirect(w, r, back)
+		return
+	}
+
+	u, _ := s.App.Store().Q.GetUserByID(id)
+	s.App.Audit().Record(audit.Entry{Actor: u, Action: audit.UserJoin, TargetType: audit.TargetUser, TargetID: id, TargetLabel: name, After: u})
+	s.emitUserCreated(u)
+
+	_ = s.App.SetSessionUser(w, r, id)
+	s.App.AddFlash(w, r, app.FlashSuccess, "Welcome, "+name+"! Pick something to drink.")
+	s.redirect(w, r, "/")
+}
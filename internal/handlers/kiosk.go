@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/invites"
+	"house-bartender-go/internal/services/kiosk"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// After maxPINFailures wrong PINs in a row the guest can't be picked until pinLockout has
+// passed since the last one.
+const (
+	maxPINFailures = 5
+	pinLockout     = 15 * time.Minute
+)
+
+type AdminKiosksPage struct {
+	Kiosks []KioskRow
+	Paired int
+}
+
+type KioskRow struct {
+	db.Kiosk
+	Status string
+}
+
+type AdminKioskPairPage struct {
+	Kiosk     *db.Kiosk
+	URL       string
+	QR        template.URL // data: URI; the pairing code is never stored, so no QR endpoint
+	ExpiresAt time.Time
+}
+
+type KioskPairPage struct {
+	Code  string
+	Kiosk *db.Kiosk // nil when the link is no longer valid
+}
+
+type KioskPage struct {
+	Kiosk  *db.Kiosk
+	Guests []db.KioskGuest
+}
+
+/* ---------------- Admin ---------------- */
+
+func (s *Server) AdminKiosksGet(w http.ResponseWriter, r *http.Request) {
+	list, _ := s.App.Store().Q.ListKiosks()
+	now := time.Now()
+	out := AdminKiosksPage{}
+	for _, k := range list {
+		row := KioskRow{Kiosk: k, Status: kiosk.Status(k, now)}
+		if row.Status == kiosk.StatusPaired {
+			out.Paired++
+		}
+		out.Kiosks = append(out.Kiosks, row)
+	}
+	s.renderLayout(w, r, "Kiosks", "admin_kiosks.html", out)
+}
+
+// AdminKioskCreatePost registers a kiosk and shows its one-time pairing link.
+func (s *Server) AdminKioskCreatePost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		s.App.AddFlash(w, r, app.FlashError, "Name the kiosk, e.g. where it stands.")
+		s.redirect(w, r, "/admin/kiosks")
+		return
+	}
+	code, err := kiosk.NewToken()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create the kiosk.")
+		s.redirect(w, r, "/admin/kiosks")
+		return
+	}
+	expires := time.Now().Add(kiosk.PairLifetime)
+	var by int64
+	if u := s.App.CurrentUser(r); u != nil {
+		by = u.ID
+	}
+	id, err := s.App.Store().Q.CreateKiosk(name, kiosk.Hash(code), expires, by)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create the kiosk.")
+		s.redirect(w, r, "/admin/kiosks")
+		return
+	}
+	k, _ := s.App.Store().Q.GetKiosk(id)
+	s.recordAudit(r, audit.Entry{Action: audit.KioskCreate, TargetType: audit.TargetKiosk, TargetID: id, TargetLabel: name, After: k})
+
+	url := kiosk.PairURL(s.App.Config().BaseURL, code)
+	page := AdminKioskPairPage{Kiosk: k, URL: url, ExpiresAt: expires}
+	if svg, err := invites.QRSVG(url); err == nil {
+		page.QR = template.URL("data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(svg))
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	s.renderLayout(w, r, "Pair Kiosk", "admin_kiosk_pair.html", page)
+}
+
+func (s *Server) AdminKioskRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/kiosks")
+		return
+	}
+	before, _ := s.App.Store().Q.GetKiosk(id)
+	if before == nil {
+		s.redirect(w, r, "/admin/kiosks")
+		return
+	}
+	if err := s.App.Store().Q.RevokeKiosk(id); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Revoke failed.")
+		s.redirect(w, r, "/admin/kiosks")
+		return
+	}
+	after, _ := s.App.Store().Q.GetKiosk(id)
+	s.recordAudit(r, audit.Entry{Action: audit.KioskRevoke, TargetType: audit.TargetKiosk, TargetID: id, TargetLabel: before.Name, Before: before, After: after})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Kiosk revoked; the tablet is signed out.")
+	s.redirect(w, r, "/admin/kiosks")
+}
+
+/* ---------------- Pairing ---------------- */
+
+func (s *Server) KioskPairGet(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	k, _ := s.App.Store().Q.GetKioskByPairing(kiosk.Hash(code))
+	s.renderLayout(w, r, "Pair Kiosk", "kiosk_pair.html", KioskPairPage{Code: code, Kiosk: k})
+}
+
+// KioskPairPost turns this browser into the kiosk. Whoever was signed in on it is signed
+// out, so a tablet is never left logged in as the admin who set it up.
+func (s *Server) KioskPairPost(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	token, err := kiosk.NewToken()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not pair this device.")
+		s.redirect(w, r, "/kiosk/pair/"+code)
+		return
+	}
+	id, err := s.App.Store().Q.PairKiosk(kiosk.Hash(code), kiosk.Hash(token))
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "This pairing link is no longer valid. Create a new kiosk.")
+		s.redirect(w, r, "/kiosk/pair/"+code)
+		return
+	}
+	k, _ := s.App.Store().Q.GetKiosk(id)
+	if k != nil {
+		s.recordAudit(r, audit.Entry{Action: audit.KioskPair, TargetType: audit.TargetKiosk, TargetID: id, TargetLabel: k.Name})
+	}
+
+	_ = s.App.ClearSession(w, r)
+	s.App.ClearKioskGuest(w)
+	s.App.SetKioskDevice(w, token)
+	s.redirect(w, r, "/kiosk")
+}
+
+/* ---------------- Guest picker ---------------- */
+
+// KioskGet shows the guest picker. Getting here ends whoever's turn it was.
+func (s *Server) KioskGet(w http.ResponseWriter, r *http.Request) {
+	k := s.App.Kiosk(r)
+	if k == nil {
+		s.App.AddFlash(w, r, app.FlashInfo, "This device is not a kiosk. An admin can pair it under Kiosks.")
+		s.redirect(w, r, "/login")
+		return
+	}
+	s.App.ClearKioskGuest(w)
+	_ = s.App.ClearSession(w, r)
+	_ = s.App.Store().Q.TouchKiosk(k.ID)
+
+	all, _ := s.App.Store().Q.ListKioskGuests()
+	page := KioskPage{Kiosk: k}
+	for _, g := range all {
+		if s.App.KioskGuestAllowed(&db.User{ID: g.ID, Role: g.Role, IsActive: true}) {
+			page.Guests = append(page.Guests, g)
+		}
+	}
+	s.renderLayout(w, r, k.Name, "kiosk.html", page)
+}
+
+// KioskGuestPost switches the kiosk to a guest, checking their PIN if they set one.
+func (s *Server) KioskGuestPost(w http.ResponseWriter, r *http.Request) {
+	k := s.App.Kiosk(r)
+	if k == nil {
+		s.redirect(w, r, "/login")
+		return
+	}
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/kiosk")
+		return
+	}
+	q := s.App.Store().Q
+	u, _ := q.GetUserByID(id)
+	if !s.App.KioskGuestAllowed(u) {
+		s.redirect(w, r, "/kiosk")
+		return
+	}
+	pin, err := q.GetKioskPIN(u.ID)
+	if err != nil {
+		s.redirect(w, r, "/kiosk")
+		return
+	}
+	if pin != nil {
+		allowed, err := q.TakeKioskPINAttempt(u.ID, maxPINFailures, pinLockout)
+		if err != nil {
+			s.redirect(w, r, "/kiosk")
+			return
+		}
+		if !allowed {
+			s.App.AddFlash(w, r, app.FlashError, "Too many wrong PINs for "+u.DisplayName+". Try again in 15 minutes, or ask the bartender.")
+			s.redirect(w, r, "/kiosk")
+			return
+		}
+		_ = r.ParseForm()
+		if !app.CheckPassword(pin.Hash, r.FormValue("pin")) {
+			s.App.AddFlash(w, r, app.FlashError, "Wrong PIN for "+u.DisplayName+".")
+			s.redirect(w, r, "/kiosk")
+			return
+		}
+		_ = q.ClearKioskPINFailures(u.ID)
+	}
+
+	if err := s.App.SetKioskGuest(w, u.ID, k.ID, kiosk.GuestTTL); err != nil {
+		s.redirect(w, r, "/kiosk")
+		return
+	}
+	_ = q.TouchKiosk(k.ID)
+	s.App.AddFlash(w, r, app.FlashSuccess, "Hi "+u.DisplayName+"! Pick a drink.")
+	s.redirect(w, r, "/")
+}
+
+/* ---------------- Guest PIN ---------------- */
+
+// AccountPINPost sets the PIN that guards the user on kiosks.
+func (s *Server) AccountPINPost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	_ = r.ParseForm()
+	pin := strings.TrimSpace(r.FormValue("pin"))
+	if !kiosk.ValidPIN(pin) {
+		s.App.AddFlash(w, r, app.FlashError, "A PIN is exactly 4 digits.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	if pin != strings.TrimSpace(r.FormValue("confirm_pin")) {
+		s.App.AddFlash(w, r, app.FlashError, "The PINs don't match.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	hash, err := app.HashPIN(pin)
+	if err == nil {
+		err = s.App.Store().Q.SetKioskPIN(u.ID, hash)
+	}
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not save the PIN.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	s.App.AddFlash(w, r, app.FlashSuccess, "Kiosk PIN saved.")
+	s.redirect(w, r, "/account")
+}
+
+func (s *Server) AccountPINDeletePost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	if err := s.App.Store().Q.DeleteKioskPIN(u.ID); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not remove the PIN.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	s.App.AddFlash(w, r, app.FlashSuccess, "Kiosk PIN removed; anyone at a kiosk can pick you.")
+	s.redirect(w, r, "/account")
+}
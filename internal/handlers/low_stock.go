@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+)
+
+type AdminLowStockPage struct {
+	Day       time.Time
+	PrevDay   string
+	NextDay   string
+	IsToday   bool
+	Low       []db.Product
+	BelowPar  []db.Product
+	Crossings []db.LowStockEvent
+}
+
+const digestDayLayout = "2006-01-02"
+
+// stockSnapshot remembers tracked stock for the given products so notifyLowStock can tell
+// which of them crossed their reorder level afterwards.
+func (s *Server) stockSnapshot(ids ...int64) map[int64]*int64 {
+	out := make(map[int64]*int64, len(ids))
+	for _, id := range ids {
+		p, _ := s.App.Store().Q.GetProductByID(id)
+		if p == nil {
+			continue
+		}
+		out[id] = p.StockCount
+	}
+	return out
+}
+
+// notifyLowStock records and announces every product from the snapshot that is now at or
+// below its reorder level but was not before: inventory:low over SSE plus a push to on-duty
+// bartenders.
+func (s *Server) notifyLowStock(before map[int64]*int64) {
+	var crossed []db.Product
+	for id, prev := range before {
+		p, _ := s.App.Store().Q.GetProductByID(id)
+		if p == nil || !p.IsLow() || db.IsLowStock(prev, p.ReorderLevel) {
+			continue
+		}
+		_ = s.App.Store().Q.RecordLowStockEvent(p.ID, *p.StockCount, *p.ReorderLevel)
+		crossed = append(crossed, *p)
+	}
+	if len(crossed) == 0 {
+		return
+	}
+
+	for _, p := range crossed {
+		ev := app.SSEEvent{Type: "inventory:low", Data: map[string]any{
+			"product_id":    p.ID,
+			"name":          p.Name,
+			"stock":         *p.StockCount,
+			"reorder_level": *p.ReorderLevel,
+		}}
+		s.App.SSE().BroadcastPermission(app.PermInventoryEdit, ev)
+	}
+
+	go func() {
+		_ = s.App.Push().NotifyLowStock(crossed)
+	}()
+}
+
+func (s *Server) AdminLowStockGet(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := today
+	if raw := strings.TrimSpace(r.URL.Query().Get("date")); raw != "" {
+		if t, err := time.ParseInLocation(digestDayLayout, raw, now.Location()); err == nil && !t.After(today) {
+			day = t
+		}
+	}
+
+	page := AdminLowStockPage{
+		Day:     day,
+		PrevDay: day.AddDate(0, 0, -1).Format(digestDayLayout),
+		IsToday: day.Equal(today),
+	}
+	if !page.IsToday {
+		page.NextDay = day.AddDate(0, 0, 1).Format(digestDayLayout)
+	}
+
+	products, _ := s.App.Store().Q.ListProducts("")
+	for _, p := range products {
+		if p.IsLow() {
+			page.Low = append(page.Low, p)
+		}
+		if p.BelowPar() {
+			page.BelowPar = append(page.BelowPar, p)
+		}
+	}
+	page.Crossings, _ = s.App.Store().Q.ListLowStockEvents(day, day.AddDate(0, 0, 1))
+
+	s.renderLayout(w, r, "Low Stock Digest", "admin_low_stock.html", page)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/services/makeable"
+)
+
+type BartenderMakeablePage struct {
+	Report     makeable.Report
+	BestUnlock *makeable.Unlock
+}
+
+func (s *Server) BartenderMakeableGet(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.buildMakeableReport()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not analyse the bar right now.")
+	}
+	page := BartenderMakeablePage{Report: rep, BestUnlock: rep.BestUnlock()}
+	s.renderLayout(w, r, "What Can We Make", "bartender_makeable.html", page)
+}
+
+func (s *Server) BartenderShoppingListGet(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.buildMakeableReport()
+	if err != nil {
+		http.Error(w, "could not build shopping list", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("format")), "csv") {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="shopping-list.csv"`)
+		_ = makeable.WriteCSV(w, rep.ShoppingList)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="shopping-list.txt"`)
+	_ = makeable.WriteText(w, rep.ShoppingList)
+}
+
+func (s *Server) buildMakeableReport() (makeable.Report, error) {
+	reqs, err := s.App.Store().Q.ListCocktailRequirements()
+	if err != nil {
+		return makeable.Report{}, err
+	}
+	popularity, err := s.App.Store().Q.CocktailOrderCounts()
+	if err != nil {
+		return makeable.Report{}, err
+	}
+	return makeable.Analyze(reqs, popularity), nil
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"house-bartender-go/internal/catalog"
+	"house-bartender-go/internal/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GeneratedCocktailImageGet draws the placeholder for a cocktail that has neither an upload
+// nor bundled art. The slug is matched against the library so the glass shows the
+// cocktail's dominant ingredients; unknown slugs still get a name-derived image.
+func (s *Server) GeneratedCocktailImageGet(w http.ResponseWriter, r *http.Request) {
+	slug := strings.TrimSuffix(chi.URLParam(r, "file"), ".svg")
+	name := strings.ReplaceAll(slug, "-", " ")
+
+	var layers []catalog.PlaceholderIngredient
+	if snap, err := s.App.Library().Snapshot(); err == nil && snap != nil {
+		for _, it := range snap.Items() {
+			if catalog.Slug(it.Cocktail.Name) == slug {
+				name = it.Cocktail.Name
+				layers = dominantIngredients(it.Ingredients)
+				break
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	_, _ = w.Write(catalog.PlaceholderSVG(name, layers))
+}
+
+// dominantIngredients orders the required ingredients by volume, largest first. Quantities
+// in other units are converted roughly to millilitres; unmeasured ones count as a splash.
+func dominantIngredients(ings []db.CocktailIngredient) []catalog.PlaceholderIngredient {
+	var out []catalog.PlaceholderIngredient
+	for _, ing := range ings {
+		if !ing.Required {
+			continue
+		}
+		out = append(out, catalog.PlaceholderIngredient{Name: ing.ProductName, Amount: approxMillilitres(ing.Quantity, ing.Unit)})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Amount > out[j].Amount })
+	return out
+}
+
+func approxMillilitres(q *float64, unit string) float64 {
+	if q == nil || *q <= 0 {
+		return 10
+	}
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "oz", "fl oz":
+		return *q * 30
+	case "cl":
+		return *q * 10
+	case "l":
+		return *q * 1000
+	case "dash", "dashes", "drop", "drops":
+		return *q
+	case "tsp":
+		return *q * 5
+	case "tbsp":
+		return *q * 15
+	case "cup", "cups":
+		return *q * 240
+	}
+	return *q
+}
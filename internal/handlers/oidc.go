@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/invites"
+	"house-bartender-go/internal/services/oidc"
+)
+
+type LoginPage struct {
+	OIDCName string // provider button label; empty when OIDC is off
+}
+
+// OIDCLoginGet starts a provider login.
+func (s *Server) OIDCLoginGet(w http.ResponseWriter, r *http.Request) {
+	p := s.App.OIDC()
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := oidc.NewFlow()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not start sign-in.")
+		s.redirect(w, r, "/login")
+		return
+	}
+	to, err := p.AuthURL(r.Context(), f)
+	if err != nil {
+		s.App.Logger().Error("oidc login", "err", err)
+		s.App.AddFlash(w, r, app.FlashError, s.App.Config().OIDCProviderName+" is unavailable right now. Use your password instead.")
+		s.redirect(w, r, "/login")
+		return
+	}
+	if err := s.App.SetOIDCFlow(w, f); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not start sign-in.")
+		s.redirect(w, r, "/login")
+		return
+	}
+	http.Redirect(w, r, to, http.StatusFound)
+}
+
+// OIDCCallbackGet finishes a provider login. The account is found by its linked identity,
+// then by email (linking it) when both the provider and this app have verified it, and
+// otherwise provisioned if that is enabled. When the role mapping matches, the account's
+// role follows the provider.
+func (s *Server) OIDCCallbackGet(w http.ResponseWriter, r *http.Request) {
+	p := s.App.OIDC()
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+	cfg := s.App.Config()
+	fail := func(msg string) {
+		s.App.AddFlash(w, r, app.FlashError, msg)
+		s.redirect(w, r, "/login")
+	}
+
+	f, ok := s.App.TakeOIDCFlow(w, r)
+	params := r.URL.Query()
+	if !ok || params.Get("state") == "" || params.Get("state") != f.State {
+		fail("Sign-in expired or was started elsewhere. Try again.")
+		return
+	}
+	if e := params.Get("error"); e != "" {
+		fail(cfg.OIDCProviderName + " did not sign you in (" + e + ").")
+		return
+	}
+	id, err := p.Exchange(r.Context(), params.Get("code"), f)
+	if err != nil {
+		s.App.Logger().Warn("oidc callback", "err", err)
+		fail("Sign-in with " + cfg.OIDCProviderName + " failed.")
+		return
+	}
+	mapped := p.MapRole(*id)
+	if mapped != "" && !s.App.RoleExists(mapped) {
+		s.App.Logger().Warn("oidc role map names an unknown role", "role", mapped)
+		mapped = ""
+	}
+
+	q := s.App.Store().Q
+	u, err := q.GetUserByIdentity(id.Issuer, id.Subject)
+	if err != nil {
+		fail("Sign-in failed.")
+		return
+	}
+	if u == nil && id.Email != "" && id.EmailVerified {
+		// An address users typed in themselves proves nothing; linking to it would hand
+		// the provider's user an account someone else holds the password to.
+		if u, _ = q.GetUserByEmail(id.Email); u != nil && !u.EmailVerified {
+			s.App.Logger().Warn("oidc login matches an unverified email; not linking", "user", u.ID)
+			fail("An account uses your email, but it hasn't been confirmed. Ask an admin to confirm it, then sign in again.")
+			return
+		}
+		if u != nil {
+			if err := q.LinkIdentity(u.ID, id.Issuer, id.Subject, id.Email); err != nil {
+				fail("Sign-in failed.")
+				return
+			}
+			s.App.Audit().Record(audit.Entry{Actor: u, Action: audit.UserLink, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: u.DisplayName, After: map[string]string{"Issuer": id.Issuer, "Subject": id.Subject}})
+		}
+	}
+	welcome := "Welcome back, "
+	if u == nil {
+		if !cfg.OIDCAutoProvision {
+			fail("No account matches your " + cfg.OIDCProviderName + " login. Ask an admin to add you.")
+			return
+		}
+		if id.Email != "" && !id.EmailVerified {
+			fail(cfg.OIDCProviderName + " has not verified your email, so no account was created.")
+			return
+		}
+		email := id.Email
+		if email == "" {
+			if email, err = invites.PlaceholderEmail(); err != nil {
+				fail("Sign-in failed.")
+				return
+			}
+		}
+		role := mapped
+		if role == "" {
+			role = cfg.OIDCDefaultRole
+		}
+		uid, err := q.CreateUserWithIdentity(db.CreateUserParams{
+			Email:         email,
+			Role:          role,
+			DisplayName:   id.DisplayName(),
+			IsActive:      true,
+			EmailVerified: id.Email != "",
+		}, id.Issuer, id.Subject)
+		if err != nil {
+			fail("Could not create your account (the email may belong to an existing one).")
+			return
+		}
+		u, _ = q.GetUserByID(uid)
+		if u == nil {
+			fail("Sign-in failed.")
+			return
+		}
+		s.App.Audit().Record(audit.Entry{Actor: u, Action: audit.UserCreate, TargetType: audit.TargetUser, TargetID: uid, TargetLabel: u.DisplayName, After: u})
+		s.emitUserCreated(u)
+		mapped = ""
+		welcome = "Welcome, "
+	}
+	if !u.IsActive {
+		fail("Your account is disabled.")
+		return
+	}
+
+	if mapped != "" && mapped != u.Role {
+		// The provider may demote, but not leave the instance without a user manager.
+		if s.App.Can(u, app.PermUsersManage) && !s.App.RoleCan(mapped, app.PermUsersManage) && !s.hasAnotherUserManager(u.ID, "") {
+			s.App.Logger().Warn("oidc role sync skipped: last user manager", "user", u.ID, "role", mapped)
+		} else if err := q.UpdateUser(db.UpdateUserParams{ID: u.ID, Email: u.Email, Role: mapped, DisplayName: u.DisplayName, EmailVerified: u.EmailVerified}); err == nil {
+			if !s.App.RoleCan(mapped, app.PermOrdersManage) {
+				_ = q.SetUserDuty(u.ID, false, db.ShiftEndRole)
+			}
+			before := u
+			u, _ = q.GetUserByID(before.ID)
+			s.App.Audit().Record(audit.Entry{Actor: u, Action: audit.UserUpdate, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: u.DisplayName, Before: before, After: u})
+		}
+	}
+	_ = q.LinkIdentity(u.ID, id.Issuer, id.Subject, id.Email)
+
+	s.signIn(w, r, u, welcome+u.DisplayName+"!", "/")
+}
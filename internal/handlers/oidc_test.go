@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/oidc/oidctest"
+)
+
+func newOIDCServer(t *testing.T) (*Server, *oidctest.Provider) {
+	t.Helper()
+	stub := oidctest.New()
+	t.Cleanup(stub.Close)
+	s := newTestServerWith(t, app.Config{
+		BaseURL:          "http://bar.test",
+		OIDCIssuer:       stub.URL + "/",
+		OIDCClientID:     oidctest.ClientID,
+		OIDCClientSecret: oidctest.ClientSecret,
+		OIDCScopes:       "openid email profile",
+		OIDCRoleClaim:    "groups",
+		OIDCRoleMap:      "bar-admins=ADMIN",
+	})
+	return s, stub
+}
+
+// oidcLogin runs a provider login through the stub as whoever it was last set to, and
+// returns the callback's response.
+func oidcLogin(t *testing.T, s *Server, stub *oidctest.Provider) *httptest.ResponseRecorder {
+	t.Helper()
+	start := s.serve(t, s.OIDCLoginGet, nil, http.MethodGet, "/auth/oidc/login", nil, nil)
+	if start.Code != http.StatusFound {
+		t.Fatalf("login start: %d", start.Code)
+	}
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noFollow.Get(start.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: %s %v", resp.Status, err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, back.RequestURI(), nil)
+	for _, c := range start.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	s.OIDCCallbackGet(w, r)
+	return w
+}
+
+func signedIn(w *httptest.ResponseRecorder) bool {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "hb_session" && c.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOIDCDoesNotLinkSelfChosenEmail(t *testing.T) {
+	s, stub := newOIDCServer(t)
+	q := s.App.Store().Q
+	s.addUser(t, "admin@example.com", app.RoleAdmin)
+	guest := s.addUser(t, "guest@example.com", app.RoleUser)
+
+	// the guest claims the admin's address at the provider
+	form := url.Values{"display_name": {"Guest"}, "email": {"boss@corp.example"}}
+	s.serve(t, s.AccountProfilePost, guest, http.MethodPost, "/account/profile", nil, form)
+	if got, _ := q.GetUserByID(guest.ID); got.Email != "boss@corp.example" || got.EmailVerified {
+		t.Fatalf("profile change: email %q verified %v", got.Email, got.EmailVerified)
+	}
+
+	stub.SetUser(map[string]any{"sub": "boss", "email": "boss@corp.example", "email_verified": true, "groups": []string{"bar-admins"}})
+	w := oidcLogin(t, s, stub)
+	if signedIn(w) {
+		t.Fatal("the provider login signed in to the guest's account")
+	}
+	if u, _ := q.GetUserByIdentity(stub.URL, "boss"); u != nil {
+		t.Fatalf("identity linked to account %d", u.ID)
+	}
+	if got, _ := q.GetUserByID(guest.ID); got.Role != app.RoleUser {
+		t.Fatalf("guest promoted to %s", got.Role)
+	}
+}
+
+func TestOIDCLinksConfirmedEmail(t *testing.T) {
+	s, stub := newOIDCServer(t)
+	q := s.App.Store().Q
+	admin := s.addUser(t, "admin@example.com", app.RoleAdmin)
+	id, err := q.CreateUser(db.CreateUserParams{Email: "bar@corp.example", PasswordHash: "x", Role: app.RoleUser, DisplayName: "Bar", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.SetUser(map[string]any{"sub": "bar", "email": "bar@corp.example", "email_verified": true})
+
+	if w := oidcLogin(t, s, stub); signedIn(w) {
+		t.Fatal("linked before the email was confirmed")
+	}
+
+	// an admin confirms it on the Users screen
+	form := url.Values{"email": {"bar@corp.example"}, "display_name": {"Bar"}, "role": {app.RoleUser}, "email_verified": {"1"}}
+	s.serve(t, s.AdminUserUpdatePost, admin, http.MethodPost, "/admin/users/x", map[string]string{"id": strconv.FormatInt(id, 10)}, form)
+
+	if w := oidcLogin(t, s, stub); !signedIn(w) {
+		t.Fatal("confirmed email was not linked")
+	}
+	if u, _ := q.GetUserByIdentity(stub.URL, "bar"); u == nil || u.ID != id {
+		t.Fatalf("identity linked to %+v, want account %d", u, id)
+	}
+}
+
+func TestAdminEnteredEmailIsConfirmed(t *testing.T) {
+	s := newTestServer(t)
+	q := s.App.Store().Q
+	admin := s.addUser(t, "admin@example.com", app.RoleAdmin)
+
+	form := url.Values{"email": {"new@example.com"}, "display_name": {"New"}, "role": {app.RoleUser}, "password": {"long enough pw"}}
+	s.serve(t, s.AdminUserCreatePost, admin, http.MethodPost, "/admin/users", nil, form)
+	u, _ := q.GetUserByEmail("new@example.com")
+	if u == nil || !u.EmailVerified {
+		t.Fatalf("admin-created account: %+v", u)
+	}
+
+	// saving the form without the box ticked and the same email keeps it as it was
+	s.serve(t, s.AccountProfilePost, u, http.MethodPost, "/account/profile", nil, url.Values{"display_name": {"New"}, "email": {"mine@example.com"}})
+	form = url.Values{"email": {"mine@example.com"}, "display_name": {"New"}, "role": {app.RoleUser}}
+	s.serve(t, s.AdminUserUpdatePost, admin, http.MethodPost, "/admin/users/x", map[string]string{"id": strconv.FormatInt(u.ID, 10)}, form)
+	if got, _ := q.GetUserByID(u.ID); got.EmailVerified {
+		t.Fatal("an unchanged, user-set email became confirmed")
+	}
+
+	form.Set("email", "typed@example.com")
+	s.serve(t, s.AdminUserUpdatePost, admin, http.MethodPost, "/admin/users/x", map[string]string{"id": strconv.FormatInt(u.ID, 10)}, form)
+	if got, _ := q.GetUserByID(u.ID); !got.EmailVerified {
+		t.Fatal("an email the admin typed in is not confirmed")
+	}
+}
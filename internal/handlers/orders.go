@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"house-bartender-go/internal/app"
 	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/kiosk"
+	"house-bartender-go/internal/services/webhooks"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -59,23 +62,70 @@ func (s *Server) OrderCreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	station, err := s.App.Store().Q.CocktailStation(cid)
+	if err != nil || station == nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create order.")
+		s.redirect(w, r, "/cocktails/"+cidStr)
+		return
+	}
+
+	mods, depletions, msg := s.resolveOrderModifiers(r, cid, station.ID, qty)
+	if msg != "" {
+		s.App.AddFlash(w, r, app.FlashError, msg)
+		s.redirect(w, r, "/cocktails/"+cidStr)
+		return
+	}
+
+	// Low-stock alerts watch the main bar only.
+	depleted := make([]int64, 0, len(depletions))
+	if station.IsDefault {
+		for _, d := range depletions {
+			depleted = append(depleted, d.ProductID)
+		}
+	}
+	before := s.stockSnapshot(depleted...)
+
 	oid, err := s.App.Store().Q.CreateOrder(db.CreateOrderParams{
 		UserID:     u.ID,
 		CocktailID: cid,
 		Quantity:   qty,
 		Notes:      notes,
 		Location:   location,
+		StationID:  station.ID,
+		Modifiers:  mods,
+		Depletions: depletions,
 	})
+	if errors.Is(err, db.ErrInsufficientStock) {
+		s.App.AddFlash(w, r, app.FlashError, "Not enough left for that order any more.")
+		s.redirect(w, r, "/cocktails/"+cidStr)
+		return
+	}
 	if err != nil {
 		s.App.AddFlash(w, r, app.FlashError, "Could not create order.")
 		s.redirect(w, r, "/cocktails/"+cidStr)
 		return
 	}
 
-	// SSE: order:created -> bartenders/admin
-	s.App.SSE().BroadcastRole(app.RoleBartender, app.SSEEvent{Type: "order:created", Data: map[string]any{"order_id": oid}})
-	s.App.SSE().BroadcastRole(app.RoleAdmin, app.SSEEvent{Type: "order:created", Data: map[string]any{"order_id": oid}})
-	s.App.SSE().BroadcastOrders(app.SSEEvent{Type: "order:created", Data: map[string]any{"order_id": oid}})
+	// SSE: order:created -> the whole bar and the station making it
+	ev := app.SSEEvent{Type: "order:created", Data: map[string]any{"order_id": oid, "station_id": station.ID}}
+	s.App.SSE().BroadcastOrders(ev)
+	s.App.SSE().BroadcastStation(station.ID, ev)
+	go func() {
+		_ = s.App.Push().NotifyNewOrder(oid)
+	}()
+	s.emitOrderWebhook(webhooks.OrderCreated, oid)
+	if len(depletions) > 0 {
+		s.broadcastInventory()
+		s.notifyLowStock(before)
+	}
+
+	// On a kiosk, the guest's turn ends shortly after ordering.
+	if ks := s.App.KioskSession(r); ks != nil {
+		_ = s.App.SetKioskGuest(w, u.ID, ks.Kiosk.ID, kiosk.AfterOrderTTL)
+		s.App.AddFlash(w, r, app.FlashSuccess, "Order placed. This kiosk goes back to the guest list in a moment.")
+		s.redirect(w, r, "/orders")
+		return
+	}
 
 	s.App.AddFlash(w, r, app.FlashSuccess, "Order placed.")
 	s.redirect(w, r, "/orders")
@@ -91,17 +141,17 @@ func (s *Server) OrderAcceptPost(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	oid, ok := parseInt64(idStr)
 	if !ok {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
 	o, _ := s.App.Store().Q.GetOrderByID(oid)
 	if o == nil {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 	if o.Status != "PLACED" {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
@@ -110,9 +160,11 @@ func (s *Server) OrderAcceptPost(w http.ResponseWriter, r *http.Request) {
 		_ = s.App.Store().Q.AssignOrder(oid, &u.ID)
 	}
 
-	_ = s.App.Store().Q.UpdateOrderStatus(oid, "PLACED", "ACCEPTED", &u.ID)
+	if err := s.App.Store().Q.UpdateOrderStatus(oid, "PLACED", "ACCEPTED", &u.ID); err == nil {
+		s.notifyOrderOwner(oid)
+	}
 	s.broadcastOrderUpdated(oid)
-	s.redirect(w, r, "/bartender/orders")
+	s.redirect(w, r, queuePath(r))
 }
 
 func (s *Server) OrderAssignPost(w http.ResponseWriter, r *http.Request) {
@@ -125,7 +177,7 @@ func (s *Server) OrderAssignPost(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	oid, ok := parseInt64(idStr)
 	if !ok {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
@@ -141,9 +193,13 @@ func (s *Server) OrderAssignPost(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	_ = s.App.Store().Q.AssignOrder(oid, bid)
+	if err := s.App.Store().Q.AssignOrder(oid, bid); err == nil && bid != nil {
+		go func() {
+			_ = s.App.Push().NotifyAssigned(oid, u.ID)
+		}()
+	}
 	s.broadcastOrderUpdated(oid)
-	s.redirect(w, r, "/bartender/orders")
+	s.redirect(w, r, queuePath(r))
 }
 
 func (s *Server) OrderCompletePost(w http.ResponseWriter, r *http.Request) {
@@ -156,17 +212,17 @@ func (s *Server) OrderCompletePost(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	oid, ok := parseInt64(idStr)
 	if !ok {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
 	o, _ := s.App.Store().Q.GetOrderByID(oid)
 	if o == nil {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 	if o.Status == "DELIVERED" || o.Status == "CANCELLED" {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
@@ -178,7 +234,7 @@ func (s *Server) OrderCompletePost(w http.ResponseWriter, r *http.Request) {
 	for next := nextOrderTransition(current); next != ""; next = nextOrderTransition(current) {
 		if err := s.App.Store().Q.UpdateOrderStatus(oid, current, next, &u.ID); err != nil {
 			s.App.AddFlash(w, r, app.FlashError, "Could not complete the order.")
-			s.redirect(w, r, "/bartender/orders")
+			s.redirect(w, r, queuePath(r))
 			return
 		}
 		current = next
@@ -188,7 +244,7 @@ func (s *Server) OrderCompletePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s.broadcastOrderUpdated(oid)
-	s.redirect(w, r, "/bartender/orders")
+	s.redirect(w, r, queuePath(r))
 }
 
 func (s *Server) OrderStatusPost(w http.ResponseWriter, r *http.Request) {
@@ -200,27 +256,27 @@ func (s *Server) OrderStatusPost(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	oid, ok := parseInt64(idStr)
 	if !ok {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
 	_ = r.ParseForm()
 	to := strings.TrimSpace(r.FormValue("to_status"))
 	if to == "" {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
 	o, _ := s.App.Store().Q.GetOrderByID(oid)
 	if o == nil {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
 	from := o.Status
 	if !allowedTransition(from, to) {
 		s.App.AddFlash(w, r, app.FlashError, "Invalid status transition.")
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
@@ -229,9 +285,11 @@ func (s *Server) OrderStatusPost(w http.ResponseWriter, r *http.Request) {
 		_ = s.App.Store().Q.AssignOrder(oid, &u.ID)
 	}
 
-	_ = s.App.Store().Q.UpdateOrderStatus(oid, from, to, &u.ID)
+	if err := s.App.Store().Q.UpdateOrderStatus(oid, from, to, &u.ID); err == nil {
+		s.notifyOrderOwner(oid)
+	}
 	s.broadcastOrderUpdated(oid)
-	s.redirect(w, r, "/bartender/orders")
+	s.redirect(w, r, queuePath(r))
 }
 
 func (s *Server) OrderCancelPost(w http.ResponseWriter, r *http.Request) {
@@ -243,23 +301,112 @@ func (s *Server) OrderCancelPost(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	oid, ok := parseInt64(idStr)
 	if !ok {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
 	o, _ := s.App.Store().Q.GetOrderByID(oid)
 	if o == nil {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 	if o.Status == "DELIVERED" || o.Status == "CANCELLED" {
-		s.redirect(w, r, "/bartender/orders")
+		s.redirect(w, r, queuePath(r))
 		return
 	}
 
-	_ = s.App.Store().Q.UpdateOrderStatus(oid, o.Status, "CANCELLED", &u.ID)
+	if err := s.App.Store().Q.UpdateOrderStatus(oid, o.Status, "CANCELLED", &u.ID); err == nil {
+		s.notifyOrderOwner(oid)
+	}
 	s.broadcastOrderUpdated(oid)
-	s.redirect(w, r, "/bartender/orders")
+	s.redirect(w, r, queuePath(r))
+}
+
+// resolveOrderModifiers validates the guest's modifier choices against the cocktail's
+// modifiers and returns what to store on the order plus the tracked stock to deplete.
+// A non-empty message means the order must be rejected.
+func (s *Server) resolveOrderModifiers(r *http.Request, cocktailID, stationID, qty int64) ([]db.OrderModifierInput, []db.StockDepletion, string) {
+	mods, err := s.App.Store().Q.GetCocktailModifiers(cocktailID)
+	if err != nil {
+		return nil, nil, "Could not load drink options."
+	}
+
+	var out []db.OrderModifierInput
+	var depletions []db.StockDepletion
+	for _, m := range mods {
+		values := modifierChoiceValues(m)
+		if len(values) == 0 {
+			continue
+		}
+		choice := strings.TrimSpace(r.FormValue(modifierFieldName(m.ID)))
+		if choice == "" {
+			choice = defaultModifierChoice(m)
+		}
+		valid := false
+		for _, v := range values {
+			if v == choice {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, nil, "Invalid choice for " + m.Label + "."
+		}
+
+		if m.Kind == db.ModifierOptionalIngredient && m.ProductID != nil {
+			if !m.ProductAvail && choice != db.IngredientChoiceNone {
+				return nil, nil, m.Label + " is out right now."
+			}
+			if units := ingredientChoiceUnits(choice) * qty; units > 0 {
+				stock, _ := s.App.Store().Q.StationStockCount(stationID, *m.ProductID)
+				if stock != nil {
+					if *stock < units {
+						return nil, nil, "Not enough " + m.Label + " for that choice."
+					}
+					depletions = append(depletions, db.StockDepletion{ProductID: *m.ProductID, Units: units})
+				}
+			}
+		}
+
+		out = append(out, db.OrderModifierInput{
+			Kind:      m.Kind,
+			Label:     m.Label,
+			Choice:    choice,
+			IsDefault: choice == values[0],
+			ProductID: m.ProductID,
+		})
+	}
+	return out, depletions, ""
+}
+
+// ingredientChoiceUnits is how many tracked units one drink uses for an optional ingredient choice.
+func ingredientChoiceUnits(choice string) int64 {
+	switch choice {
+	case db.IngredientChoiceNone:
+		return 0
+	case db.IngredientChoiceExtra:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func (s *Server) attachOrderModifiers(orders []db.Order) []db.Order {
+	if len(orders) == 0 {
+		return orders
+	}
+	ids := make([]int64, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+	byOrder, err := s.App.Store().Q.ListOrderModifiers(ids)
+	if err != nil {
+		return orders
+	}
+	for i := range orders {
+		orders[i].Modifiers = byOrder[orders[i].ID]
+	}
+	return orders
 }
 
 func (s *Server) broadcastOrderUpdated(orderID int64) {
@@ -267,13 +414,13 @@ func (s *Server) broadcastOrderUpdated(orderID int64) {
 	if o == nil {
 		return
 	}
-	ev := app.SSEEvent{Type: "order:updated", Data: map[string]any{"order_id": orderID, "status": o.Status}}
+	ev := app.SSEEvent{Type: "order:updated", Data: map[string]any{"order_id": orderID, "status": o.Status, "station_id": o.StationID}}
 
-	// to owner + bartenders/admin
+	// to owner + the whole bar + the station making it
 	s.App.SSE().BroadcastUser(o.UserID, ev)
-	s.App.SSE().BroadcastRole(app.RoleBartender, ev)
-	s.App.SSE().BroadcastRole(app.RoleAdmin, ev)
 	s.App.SSE().BroadcastOrders(ev)
+	s.App.SSE().BroadcastStation(o.StationID, ev)
+	s.emitOrderWebhook(webhooks.OrderUpdated, orderID)
 }
 
 func allowedTransition(from, to string) bool {
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+)
+
+// newModifierServer has a Gin Tonic with an optional lime wedge, 3 of them tracked at the
+// main bar, and a guest to order it.
+func newModifierServer(t *testing.T) (*Server, *db.User, int64, string) {
+	t.Helper()
+	s := newTestServer(t)
+	q := s.App.Store().Q
+	three := int64(3)
+	gin, err := q.CreateProduct(db.CreateProductParams{Name: "Test Gin", Category: "Spirit", IsAvailable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lime, err := q.CreateProduct(db.CreateProductParams{Name: "Test Lime", Category: "Fresh", IsAvailable: true, StockCount: &three})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid, err := q.CreateCocktail(db.CreateCocktailParams{Name: "Test Gin Tonic", IsEnabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.ReplaceCocktailIngredients(cid, []db.IngredientUpsertItem{{ProductID: gin, Unit: "ml", Required: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.ReplaceCocktailModifiers(cid, []db.ModifierUpsertItem{{
+		Kind:      db.ModifierOptionalIngredient,
+		Label:     "Lime",
+		Options:   db.IngredientChoiceRegular + ", " + db.IngredientChoiceNone + ", " + db.IngredientChoiceExtra,
+		ProductID: &lime,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	mods, err := q.GetCocktailModifiers(cid)
+	if err != nil || len(mods) != 1 {
+		t.Fatalf("GetCocktailModifiers = %v, %v", mods, err)
+	}
+	return s, s.addUser(t, "guest@example.com", app.RoleUser), cid, modifierFieldName(mods[0].ID)
+}
+
+func TestOrderModifiersDepleteTrackedStock(t *testing.T) {
+	s, guest, cid, field := newModifierServer(t)
+	q := s.App.Store().Q
+	order := func(qty int, choice string) {
+		form := url.Values{"cocktail_id": {strconv.FormatInt(cid, 10)}, "quantity": {strconv.Itoa(qty)}, "location": {"Sofa"}}
+		if choice != "" {
+			form.Set(field, choice)
+		}
+		s.serve(t, s.OrderCreatePost, guest, http.MethodPost, "/orders", nil, form)
+	}
+	limes := func() int64 {
+		mods, _ := q.GetCocktailModifiers(cid)
+		p, _ := q.GetProductByID(*mods[0].ProductID)
+		return *p.StockCount
+	}
+	orders := func() int {
+		list, _ := q.ListOrdersForUser(guest.ID)
+		return len(list)
+	}
+
+	order(2, db.IngredientChoiceExtra) // 4 of 3
+	if orders() != 0 || limes() != 3 {
+		t.Fatalf("extra lime for two: %d orders, %d limes", orders(), limes())
+	}
+	order(1, "Squeezed")
+	if orders() != 0 {
+		t.Fatal("an unknown choice was ordered")
+	}
+	order(1, db.IngredientChoiceNone)
+	if orders() != 1 || limes() != 3 {
+		t.Fatalf("no lime: %d orders, %d limes", orders(), limes())
+	}
+	order(1, "") // the default, Regular
+	if orders() != 2 || limes() != 2 {
+		t.Fatalf("default lime: %d orders, %d limes", orders(), limes())
+	}
+	order(1, db.IngredientChoiceExtra)
+	if orders() != 3 || limes() != 0 {
+		t.Fatalf("extra lime: %d orders, %d limes", orders(), limes())
+	}
+	order(1, db.IngredientChoiceRegular)
+	if orders() != 3 {
+		t.Fatal("ordered lime with none left")
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"house-bartender-go/internal/app"
 	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -21,18 +22,21 @@ type productFormInput struct {
 	Notes         string
 	IsAvailable   bool
 	StockCount    *int64
+	ParLevel      *int64
+	ReorderLevel  *int64
+	Barcode       string
 }
 
 func (s *Server) ProductCreatePost(w http.ResponseWriter, r *http.Request) {
 	search, category, status := inventoryFiltersFromRequest(r)
-	in, ok := parseProductFormInput(r)
-	if !ok {
-		s.App.AddFlash(w, r, app.FlashError, "Name and category are required.")
+	in, problem := parseProductFormInput(r)
+	if problem != "" {
+		s.App.AddFlash(w, r, app.FlashError, problem)
 		s.redirect(w, r, inventoryURL("/bartender/products", search, category, status))
 		return
 	}
 
-	_, err := s.App.Store().Q.CreateProduct(db.CreateProductParams{
+	id, err := s.App.Store().Q.CreateProduct(db.CreateProductParams{
 		Name:          in.Name,
 		Category:      in.Category,
 		ABVPercent:    in.ABVPercent,
@@ -40,12 +44,16 @@ func (s *Server) ProductCreatePost(w http.ResponseWriter, r *http.Request) {
 		Notes:         in.Notes,
 		IsAvailable:   in.IsAvailable,
 		StockCount:    in.StockCount,
+		ParLevel:      in.ParLevel,
+		ReorderLevel:  in.ReorderLevel,
+		Barcode:       in.Barcode,
 	})
 	if err != nil {
-		s.App.AddFlash(w, r, app.FlashError, "Could not create ingredient (name might already exist).")
+		s.App.AddFlash(w, r, app.FlashError, "Could not create ingredient (name or barcode might already exist).")
 		s.redirect(w, r, inventoryURL("/bartender/products", search, category, status))
 		return
 	}
+	s.recordAudit(r, audit.Entry{Action: audit.ProductCreate, TargetType: audit.TargetProduct, TargetID: id, TargetLabel: in.Name, After: s.productAudit(id)})
 
 	s.broadcastInventory()
 	s.App.AddFlash(w, r, app.FlashSuccess, "Ingredient created.")
@@ -62,7 +70,10 @@ func (s *Server) ProductTogglePost(w http.ResponseWriter, r *http.Request) {
 	}
 	_ = r.ParseForm()
 	avail := formBool(r, "is_available")
-	_ = s.App.Store().Q.ToggleProductAvailability(id, avail)
+	if existing, _ := s.App.Store().Q.GetProductByID(id); existing != nil {
+		_ = s.App.Store().Q.ToggleProductAvailability(id, avail)
+		s.recordAudit(r, audit.Entry{Action: audit.ProductToggle, TargetType: audit.TargetProduct, TargetID: id, TargetLabel: existing.Name, Before: existing, After: s.productAudit(id)})
+	}
 
 	s.broadcastInventory()
 	if r.Header.Get("HX-Request") != "" {
@@ -106,13 +117,14 @@ func (s *Server) ProductEditPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	in, ok := parseProductFormInput(r)
-	if !ok {
-		s.App.AddFlash(w, r, app.FlashError, "Name and category are required.")
+	in, problem := parseProductFormInput(r)
+	if problem != "" {
+		s.App.AddFlash(w, r, app.FlashError, problem)
 		s.redirect(w, r, inventoryURL("/bartender/products/"+idStr+"/edit", search, category, status))
 		return
 	}
 
+	before := s.stockSnapshot(id)
 	if err := s.App.Store().Q.UpdateProduct(db.UpdateProductParams{
 		ID:            id,
 		Name:          in.Name,
@@ -122,13 +134,18 @@ func (s *Server) ProductEditPost(w http.ResponseWriter, r *http.Request) {
 		Notes:         in.Notes,
 		IsAvailable:   in.IsAvailable,
 		StockCount:    in.StockCount,
+		ParLevel:      in.ParLevel,
+		ReorderLevel:  in.ReorderLevel,
+		Barcode:       in.Barcode,
 	}); err != nil {
-		s.App.AddFlash(w, r, app.FlashError, "Update failed (name might already exist).")
+		s.App.AddFlash(w, r, app.FlashError, "Update failed (name or barcode might already exist).")
 		s.redirect(w, r, inventoryURL("/bartender/products/"+idStr+"/edit", search, category, status))
 		return
 	}
+	s.recordAudit(r, audit.Entry{Action: audit.ProductUpdate, TargetType: audit.TargetProduct, TargetID: id, TargetLabel: in.Name, Before: existing, After: s.productAudit(id)})
 
 	s.broadcastInventory()
+	s.notifyLowStock(before)
 	s.App.AddFlash(w, r, app.FlashSuccess, "Ingredient updated.")
 	s.redirect(w, r, inventoryURL("/bartender/products", search, category, status))
 }
@@ -150,9 +167,17 @@ func (s *Server) ProductStockPost(w http.ResponseWriter, r *http.Request) {
 			stock = &n
 		}
 	}
+	existing, _ := s.App.Store().Q.GetProductByID(id)
+	if existing == nil {
+		s.redirect(w, r, inventoryURL("/bartender/products", search, category, status))
+		return
+	}
+	before := s.stockSnapshot(id)
 	_ = s.App.Store().Q.SetProductStock(id, stock)
+	s.recordAudit(r, audit.Entry{Action: audit.ProductStock, TargetType: audit.TargetProduct, TargetID: id, TargetLabel: existing.Name, Before: existing, After: s.productAudit(id)})
 
 	s.broadcastInventory()
+	s.notifyLowStock(before)
 	if r.Header.Get("HX-Request") != "" {
 		s.renderProductsTablePartial(w, r)
 		return
@@ -168,11 +193,15 @@ func (s *Server) ProductDeletePost(w http.ResponseWriter, r *http.Request) {
 		s.redirect(w, r, inventoryURL("/bartender/products", search, category, status))
 		return
 	}
+	existing, _ := s.App.Store().Q.GetProductByID(id)
 	if err := s.App.Store().Q.DeleteProduct(id); err != nil {
 		s.App.AddFlash(w, r, app.FlashError, "Delete failed (ingredient might be used by a cocktail).")
 		s.redirect(w, r, inventoryURL("/bartender/products", search, category, status))
 		return
 	}
+	if existing != nil {
+		s.recordAudit(r, audit.Entry{Action: audit.ProductDelete, TargetType: audit.TargetProduct, TargetID: id, TargetLabel: existing.Name, Before: existing})
+	}
 
 	s.broadcastInventory()
 	if r.Header.Get("HX-Request") != "" {
@@ -188,18 +217,21 @@ func (s *Server) renderProductsTablePartial(w http.ResponseWriter, r *http.Reque
 	s.renderPartial(w, r, "products_table.html", s.buildBartenderProductsPage(search, category, status, ProductFormState{}), "/bartender/products")
 }
 
-func parseProductFormInput(r *http.Request) (productFormInput, bool) {
+// parseProductFormInput reads the ingredient form; problem says what is wrong with it, empty
+// when it can be saved.
+func parseProductFormInput(r *http.Request) (in productFormInput, problem string) {
 	_ = r.ParseForm()
-	in := productFormInput{
+	in = productFormInput{
 		Name:          strings.TrimSpace(r.FormValue("name")),
 		Category:      strings.TrimSpace(r.FormValue("category")),
 		AllergenFlags: strings.TrimSpace(r.FormValue("allergen_flags")),
 		Notes:         strings.TrimSpace(r.FormValue("notes")),
 		IsAvailable:   formBool(r, "is_available"),
+		Barcode:       normalizeBarcode(r.FormValue("barcode")),
 	}
 
 	if in.Name == "" || in.Category == "" {
-		return in, false
+		return in, "Name and category are required."
 	}
 
 	if abvStr := strings.TrimSpace(r.FormValue("abv_percent")); abvStr != "" {
@@ -213,8 +245,29 @@ func parseProductFormInput(r *http.Request) (productFormInput, bool) {
 			in.StockCount = &n
 		}
 	}
+	var ok bool
+	if in.ParLevel, ok = parseLevel(r.FormValue("par_level")); !ok {
+		return in, "Par level must be a whole number of 0 or more."
+	}
+	if in.ReorderLevel, ok = parseLevel(r.FormValue("reorder_level")); !ok {
+		return in, "Reorder level must be a whole number of 0 or more."
+	}
 
-	return in, true
+	return in, ""
+}
+
+// parseLevel reads an optional non-negative stock level; blank means "not set". ok is false
+// for anything else, so a typo doesn't clear a level that was set.
+func parseLevel(raw string) (level *int64, ok bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, true
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n < 0 {
+		return nil, false
+	}
+	return &n, true
 }
 
 func productFormStateFromProduct(p db.Product, action string) ProductFormState {
@@ -227,6 +280,9 @@ func productFormStateFromProduct(p db.Product, action string) ProductFormState {
 		AllergenFlags: p.AllergenFlags,
 		Notes:         p.Notes,
 		StockCount:    int64PtrToString(p.StockCount),
+		ParLevel:      int64PtrToString(p.ParLevel),
+		ReorderLevel:  int64PtrToString(p.ReorderLevel),
+		Barcode:       p.Barcode,
 		IsAvailable:   p.IsAvailable,
 	}
 }
@@ -247,14 +303,14 @@ func int64PtrToString(v *int64) string {
 
 func inventoryFiltersFromRequest(r *http.Request) (string, string, string) {
 	q := strings.TrimSpace(r.URL.Query().Get("q"))
-	return q, "", ""
+	return q, "", normalizeInventoryStatus(r.URL.Query().Get("status"))
 }
 
 func normalizeInventoryStatus(raw string) string {
 	switch strings.TrimSpace(strings.ToLower(raw)) {
 	case "", "all":
 		return ""
-	case "available", "low", "out", "unavailable":
+	case "available", "low", "out", "unavailable", "below_par":
 		return strings.TrimSpace(strings.ToLower(raw))
 	default:
 		return ""
@@ -292,7 +348,7 @@ func filterProducts(products []db.Product, category, status string) []db.Product
 			continue
 		}
 
-		label := inventoryStatusLabel(product.StockCount, product.ComputedAvail)
+		label := inventoryStatusLabel(product)
 		switch status {
 		case "available":
 			if label != "Available" {
@@ -310,6 +366,10 @@ func filterProducts(products []db.Product, category, status string) []db.Product
 			if label != "Unavailable" {
 				continue
 			}
+		case "below_par":
+			if !product.BelowPar() {
+				continue
+			}
 		}
 
 		filtered = append(filtered, product)
@@ -335,17 +395,17 @@ func inventoryURL(path, search, category, status string) string {
 	return path
 }
 
-func inventoryStatusLabel(stock *int64, available bool) string {
-	if stock != nil {
-		if *stock <= 0 {
+func inventoryStatusLabel(product db.Product) string {
+	if product.StockCount != nil {
+		if *product.StockCount <= 0 {
 			return "Out of Stock"
 		}
-		if *stock <= 2 {
+		if product.IsLow() {
 			return "Low Stock"
 		}
 		return "Available"
 	}
-	if available {
+	if product.ComputedAvail {
 		return "Available"
 	}
 	return "Unavailable"
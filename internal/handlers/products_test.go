@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+)
+
+func TestProductFormRefusesBadLevels(t *testing.T) {
+	s := newTestServer(t)
+	q := s.App.Store().Q
+	bartender := s.addUser(t, "bar@example.com", app.RoleBartender)
+	par, reorder := int64(6), int64(2)
+	id, err := q.CreateProduct(db.CreateProductParams{Name: "Test Gin", Category: "Spirit", ParLevel: &par, ReorderLevel: &reorder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/bartender/products/" + strconv.FormatInt(id, 10) + "/edit"
+	params := map[string]string{"id": strconv.FormatInt(id, 10)}
+
+	for _, bad := range []url.Values{
+		{"par_level": {"six"}, "reorder_level": {"2"}},
+		{"par_level": {"6"}, "reorder_level": {"-1"}},
+	} {
+		form := url.Values{"name": {"Test Gin"}, "category": {"Spirit"}, "stock_count": {"1"}}
+		for k, v := range bad {
+			form[k] = v
+		}
+		if w := s.serve(t, s.ProductEditPost, bartender, http.MethodPost, path, params, form); w.Code != http.StatusSeeOther || w.Header().Get("Location") != path {
+			t.Fatalf("%v: got %d to %q", bad, w.Code, w.Header().Get("Location"))
+		}
+		p, _ := q.GetProductByID(id)
+		if p.ParLevel == nil || *p.ParLevel != 6 || p.ReorderLevel == nil || *p.ReorderLevel != 2 || p.StockCount != nil {
+			t.Fatalf("%v saved the product: %+v", bad, p)
+		}
+	}
+
+	// blank still clears a level
+	form := url.Values{"name": {"Test Gin"}, "category": {"Spirit"}, "par_level": {"8"}, "reorder_level": {""}}
+	s.serve(t, s.ProductEditPost, bartender, http.MethodPost, path, params, form)
+	if p, _ := q.GetProductByID(id); p.ParLevel == nil || *p.ParLevel != 8 || p.ReorderLevel != nil {
+		t.Fatalf("after clearing the reorder level: %+v", p)
+	}
+
+	form = url.Values{"name": {"Test Rum"}, "category": {"Spirit"}, "reorder_level": {"1.5"}}
+	s.serve(t, s.ProductCreatePost, bartender, http.MethodPost, "/bartender/products", nil, form)
+	if list, _ := q.ListProducts("Test Rum"); len(list) != 0 {
+		t.Fatal("the form created a product with a bad reorder level")
+	}
+}
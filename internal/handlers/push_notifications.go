@@ -3,13 +3,17 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
 	pushsvc "house-bartender-go/internal/services/push"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type pushSubscriptionRequest struct {
@@ -31,14 +35,163 @@ type pushAPIResponse struct {
 	Error string `json:"error,omitempty"`
 }
 
+// kioskPushError refuses notifications on a shared kiosk, where the next guest would get
+// them.
+const kioskPushError = "Notifications can't be turned on for a shared kiosk."
+
+// PushCard is the notifications card: what the browser needs to subscribe, and how many
+// devices the signed-in user already has.
+type PushCard struct {
+	Show               bool
+	Configured         bool
+	PublicKey          string
+	EnabledDeviceCount int
+	Purpose            string // what turning notifications on gets you
+	Reload             bool   // reload the page once this device is turned on or off
+}
+
+// pushCard builds the card for u. It stays hidden on a kiosk, which is shared.
+func (s *Server) pushCard(r *http.Request, u *db.User, purpose string) PushCard {
+	if u == nil || s.App.Kiosk(r) != nil {
+		return PushCard{}
+	}
+	card := PushCard{Show: true, Configured: s.App.Push().Enabled(), PublicKey: s.App.Push().PublicKey(), Purpose: purpose}
+	if card.Configured {
+		subs, _ := s.App.Store().Q.ListEnabledPushSubscriptionsForUser(u.ID)
+		card.EnabledDeviceCount = len(subs)
+	}
+	return card
+}
+
+// PushDevice is one of the user's devices in the dashboard's device list.
+type PushDevice struct {
+	db.PushSubscription
+	Name string
+}
+
+func (s *Server) pushDevices(userID int64) []PushDevice {
+	subs, _ := s.App.Store().Q.ListPushSubscriptionsForUser(userID)
+	out := make([]PushDevice, 0, len(subs))
+	for _, sub := range subs {
+		out = append(out, PushDevice{PushSubscription: sub, Name: pushsvc.DeviceName(sub)})
+	}
+	return out
+}
+
+// pushDevicesPath is where the device list lives.
+const pushDevicesPath = "/bartender#notifications"
+
+// PushDevicePost renames one of the user's devices and saves what it is sent.
+func (s *Server) PushDevicePost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	if u == nil {
+		s.redirect(w, r, "/login")
+		return
+	}
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, pushDevicesPath)
+		return
+	}
+	_ = r.ParseForm()
+	prefs := db.PushPreferences{
+		NewOrders:    r.FormValue("new_orders") == "1",
+		Overdue:      r.FormValue("overdue") == "1",
+		LowStock:     r.FormValue("low_stock") == "1",
+		OnlyAssigned: r.FormValue("only_assigned") == "1",
+		QuietStart:   r.FormValue("quiet_start"),
+		QuietEnd:     r.FormValue("quiet_end"),
+		QuietZone:    r.FormValue("quiet_zone"),
+	}
+	err := s.App.Push().UpdateDevice(u.ID, id, r.FormValue("label"), prefs)
+	switch {
+	case errors.Is(err, pushsvc.ErrSubscriptionNotFound):
+		s.App.AddFlash(w, r, app.FlashError, "Device not found.")
+	case errors.Is(err, pushsvc.ErrInvalidPreferences):
+		s.App.AddFlash(w, r, app.FlashError, "Quiet hours need both a start and an end in a known time zone, and a name at most 255 characters.")
+	case err != nil:
+		s.App.AddFlash(w, r, app.FlashError, "Could not save the device.")
+	default:
+		s.App.AddFlash(w, r, app.FlashSuccess, "Device saved.")
+	}
+	s.redirect(w, r, pushDevicesPath)
+}
+
+// PushDeviceDeletePost forgets one of the user's devices.
+func (s *Server) PushDeviceDeletePost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	if u == nil {
+		s.redirect(w, r, "/login")
+		return
+	}
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, pushDevicesPath)
+		return
+	}
+	err := s.App.Push().RemoveDevice(u.ID, id)
+	switch {
+	case errors.Is(err, pushsvc.ErrSubscriptionNotFound):
+		s.App.AddFlash(w, r, app.FlashError, "Device not found.")
+	case err != nil:
+		s.App.AddFlash(w, r, app.FlashError, "Could not remove the device.")
+	default:
+		s.App.AddFlash(w, r, app.FlashSuccess, "Device removed.")
+	}
+	s.redirect(w, r, pushDevicesPath)
+}
+
+// PushDeviceTestPost sends a test notification to one of the user's devices and reports
+// what the push service answered.
+func (s *Server) PushDeviceTestPost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	if u == nil {
+		s.redirect(w, r, "/login")
+		return
+	}
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, pushDevicesPath)
+		return
+	}
+	result, err := s.App.Push().SendTest(u.ID, id)
+	switch {
+	case err == nil:
+		s.App.AddFlash(w, r, app.FlashSuccess, fmt.Sprintf("Test sent: the push service answered %d %s.", result.StatusCode, http.StatusText(result.StatusCode)))
+	case errors.Is(err, pushsvc.ErrNotConfigured):
+		s.App.AddFlash(w, r, app.FlashError, "Push notifications are not configured on this server.")
+	case errors.Is(err, pushsvc.ErrSubscriptionNotFound):
+		s.App.AddFlash(w, r, app.FlashError, "Device not found.")
+	case result.StatusCode == http.StatusNotFound || result.StatusCode == http.StatusGone:
+		s.App.AddFlash(w, r, app.FlashError, fmt.Sprintf("Test failed: the push service answered %d %s, so the device was switched off. Turn notifications on again on it.", result.StatusCode, http.StatusText(result.StatusCode)))
+	case result.StatusCode != 0:
+		msg := fmt.Sprintf("Test failed: the push service answered %d %s", result.StatusCode, http.StatusText(result.StatusCode))
+		if details := truncateString(result.Details, 200); details != "" {
+			msg += " (" + details + ")"
+		}
+		s.App.AddFlash(w, r, app.FlashError, msg+".")
+	default:
+		s.App.AddFlash(w, r, app.FlashError, "Test failed: could not reach the push service ("+err.Error()+").")
+	}
+	s.redirect(w, r, pushDevicesPath)
+}
+
+// notifyOrderOwner pushes the order's new status to the guest who placed it. It runs in
+// the background so a slow push gateway never holds up the queue.
+func (s *Server) notifyOrderOwner(orderID int64) {
+	go func() {
+		_ = s.App.Push().NotifyOrderStatus(orderID)
+	}()
+}
+
 func (s *Server) PushSubscribePost(w http.ResponseWriter, r *http.Request) {
 	u := s.App.CurrentUser(r)
 	if u == nil {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if u.Role != app.RoleBartender {
-		http.Error(w, "forbidden", http.StatusForbidden)
+	if s.App.Kiosk(r) != nil {
+		writeJSON(w, http.StatusForbidden, pushAPIResponse{OK: false, Error: kioskPushError})
 		return
 	}
 	if !s.requireTrustedOrigin(w, r) {
@@ -78,8 +231,8 @@ func (s *Server) PushUnsubscribePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if u.Role != app.RoleBartender {
-		http.Error(w, "forbidden", http.StatusForbidden)
+	if s.App.Kiosk(r) != nil {
+		writeJSON(w, http.StatusForbidden, pushAPIResponse{OK: false, Error: kioskPushError})
 		return
 	}
 	if !s.requireTrustedOrigin(w, r) {
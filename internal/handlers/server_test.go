@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// The app parses views/ relative to the working directory, as it does when run from the
+// repository root.
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	return newTestServerWith(t, app.Config{})
+}
+
+// newTestServerWith starts the app with cfg, in a fresh data directory.
+func newTestServerWith(t *testing.T, cfg app.Config) *Server {
+	t.Helper()
+	cfg.DataDir = t.TempDir()
+	cfg.SessionHashKey = []byte(strings.Repeat("k", 32))
+	a, err := app.New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("app.New: %v", err)
+	}
+	t.Cleanup(func() { _ = a.Close() })
+	return &Server{App: a}
+}
+
+// addUser creates an active account in role.
+func (s *Server) addUser(t *testing.T, email, role string) *db.User {
+	t.Helper()
+	hash, err := app.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.App.Store().Q.CreateUser(db.CreateUserParams{Email: email, PasswordHash: hash, Role: role, DisplayName: email, IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser %s: %v", email, err)
+	}
+	u, err := s.App.Store().Q.GetUserByID(id)
+	if err != nil || u == nil {
+		t.Fatalf("GetUserByID %d: %v", id, err)
+	}
+	return u
+}
+
+// addRole creates a custom role and reloads the permission cache.
+func (s *Server) addRole(t *testing.T, name string, perms ...string) {
+	t.Helper()
+	if err := s.App.Store().Q.CreateRole(name, "", perms); err != nil {
+		t.Fatalf("CreateRole %s: %v", name, err)
+	}
+	if err := s.App.ReloadRoles(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve runs h for a request signed in as u (nil for nobody), with params as chi URL
+// parameters and form as the POST body.
+func (s *Server) serve(t *testing.T, h http.HandlerFunc, u *db.User, method, path string, params map[string]string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	r := httptest.NewRequest(method, path, body)
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if u != nil {
+		rec := httptest.NewRecorder()
+		if err := s.App.SetSessionUser(rec, r, u.ID); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range rec.Result().Cookies() {
+			r.AddCookie(c)
+		}
+	}
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+	w := httptest.NewRecorder()
+	s.App.MiddlewareLoadCurrentUser(h).ServeHTTP(w, r)
+	return w
+}
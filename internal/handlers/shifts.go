@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/shifts"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// shiftListLimit caps the shifts on one report; narrow the window to see older ones.
+const shiftListLimit = 200
+
+type AdminShiftsPage struct {
+	Filter    ShiftFilterForm
+	People    []db.User
+	Shifts    []ShiftRow
+	Totals    []shifts.Total
+	Window    string
+	Truncated bool
+	Now       time.Time
+}
+
+type ShiftRow struct {
+	db.Shift
+	shifts.Summary
+	Length time.Duration
+}
+
+// ShiftFilterForm holds the report's filters as submitted, so the form can be redrawn.
+// From and Until are times of day on Day; an Until at or before From runs past midnight.
+type ShiftFilterForm struct {
+	Day   string
+	From  string
+	Until string
+	User  string
+}
+
+func (f ShiftFilterForm) query() url.Values {
+	v := url.Values{}
+	for k, s := range map[string]string{"day": f.Day, "from": f.From, "until": f.Until, "user": f.User} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	return v
+}
+
+type AdminShiftPage struct {
+	Shift  ShiftRow
+	Orders []ShiftOrderRow
+	Back   string
+	Now    time.Time
+}
+
+// ShiftOrderRow is one order the bartender touched during the shift, with the status
+// changes they made.
+type ShiftOrderRow struct {
+	OrderID    int64
+	Cocktail   string
+	PlacedAt   time.Time
+	Statuses   []string
+	LastAt     time.Time
+	Completion time.Duration // placed to delivered, when they delivered it
+}
+
+const shiftTimeLayout = "15:04"
+
+// shiftWindow reads the report filters. Without a day the report covers the last week.
+func shiftWindow(r *http.Request, now time.Time) (ShiftFilterForm, time.Time, time.Time, int64, string) {
+	q := r.URL.Query()
+	form := ShiftFilterForm{
+		Day:   strings.TrimSpace(q.Get("day")),
+		From:  strings.TrimSpace(q.Get("from")),
+		Until: strings.TrimSpace(q.Get("until")),
+		User:  strings.TrimSpace(q.Get("user")),
+	}
+	userID, ok := parseInt64(form.User)
+	if !ok {
+		form.User = ""
+	}
+
+	day, err := time.ParseInLocation(digestDayLayout, form.Day, time.Local)
+	if err != nil {
+		form = ShiftFilterForm{User: form.User}
+		return form, now.AddDate(0, 0, -7), time.Time{}, userID, "Last 7 days"
+	}
+	from, to := day, day.AddDate(0, 0, 1)
+	label := day.Format("Mon 2 Jan 2006")
+	if t, err := time.ParseInLocation(shiftTimeLayout, form.From, time.Local); err == nil {
+		from = day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	} else {
+		form.From = ""
+	}
+	if t, err := time.ParseInLocation(shiftTimeLayout, form.Until, time.Local); err == nil {
+		to = day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+		if !to.After(from) {
+			to = to.AddDate(0, 0, 1)
+		}
+	} else {
+		form.Until = ""
+	}
+	if form.From != "" || form.Until != "" {
+		label += ", " + from.Format(shiftTimeLayout) + "–" + to.Format(shiftTimeLayout)
+	}
+	return form, from, to, userID, label
+}
+
+// shiftRows pairs each shift with what its bartender did with the orders.
+func (s *Server) shiftRows(list []db.Shift, now time.Time) ([]ShiftRow, map[int64]shifts.Summary, []db.ShiftOrderEvent) {
+	ids := make([]int64, len(list))
+	for i, sh := range list {
+		ids[i] = sh.ID
+	}
+	events, _ := s.App.Store().Q.ListShiftOrderEvents(ids)
+	sums := shifts.Summarize(events)
+	rows := make([]ShiftRow, 0, len(list))
+	for _, sh := range list {
+		rows = append(rows, ShiftRow{Shift: sh, Summary: sums[sh.ID], Length: shifts.Length(sh, now)})
+	}
+	return rows, sums, events
+}
+
+// AdminShiftsGet reports who was on shift in a window and what they handled. Stats cover
+// the whole of each shift that overlaps the window.
+func (s *Server) AdminShiftsGet(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	form, from, to, userID, label := shiftWindow(r, now)
+	q := s.App.Store().Q
+	list, _ := q.ListShifts(from, to, userID, shiftListLimit+1)
+	out := AdminShiftsPage{Filter: form, Window: label, Now: now}
+	if len(list) > shiftListLimit {
+		list = list[:shiftListLimit]
+		out.Truncated = true
+	}
+	var sums map[int64]shifts.Summary
+	out.Shifts, sums, _ = s.shiftRows(list, now)
+	out.Totals = shifts.Totals(list, sums, now)
+
+	users, _ := q.ListUsers()
+	for _, u := range users {
+		if s.App.RoleCan(u.Role, app.PermOrdersManage) || u.ID == userID {
+			out.People = append(out.People, u)
+		}
+	}
+	s.renderLayout(w, r, "Shifts", "admin_shifts.html", out)
+}
+
+// AdminShiftGet lists the orders a bartender handled during one shift.
+func (s *Server) AdminShiftGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/shifts")
+		return
+	}
+	sh, _ := s.App.Store().Q.GetShift(id)
+	if sh == nil {
+		s.App.AddFlash(w, r, app.FlashError, "Shift not found.")
+		s.redirect(w, r, "/admin/shifts")
+		return
+	}
+	now := time.Now()
+	rows, _, events := s.shiftRows([]db.Shift{*sh}, now)
+	day := ShiftFilterForm{Day: sh.StartedAt.Local().Format(digestDayLayout)}
+	out := AdminShiftPage{Shift: rows[0], Now: now, Back: "/admin/shifts?" + day.query().Encode()}
+
+	byOrder := map[int64]int{}
+	for _, e := range events {
+		i, seen := byOrder[e.OrderID]
+		if !seen {
+			i = len(out.Orders)
+			byOrder[e.OrderID] = i
+			out.Orders = append(out.Orders, ShiftOrderRow{OrderID: e.OrderID, Cocktail: e.CocktailName, PlacedAt: e.OrderPlacedAt})
+		}
+		row := &out.Orders[i]
+		row.Statuses = append(row.Statuses, e.ToStatus)
+		row.LastAt = e.At
+		if e.ToStatus == "DELIVERED" {
+			row.Completion = e.At.Sub(e.OrderPlacedAt)
+		}
+	}
+	s.renderLayout(w, r, "Shift", "admin_shift.html", out)
+}
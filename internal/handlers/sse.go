@@ -22,9 +22,20 @@ func (s *Server) SSEGet(w http.ResponseWriter, r *http.Request) {
 		app.TopicInventory(),
 	}
 
-	// Bartenders/Admin get global order events + role events
-	if u.Role == app.RoleBartender || u.Role == app.RoleAdmin {
-		topics = append(topics, app.TopicOrdersGlobal(), app.TopicRole(u.Role))
+	// Staff get the order events of the stations on their queue tab (?station=, as on the
+	// queue) + the events of each permission they hold
+	staff := s.App.Can(u, app.PermOrdersManage)
+	if staff {
+		ids, _ := s.queueStations(r)
+		if ids == nil {
+			topics = append(topics, app.TopicOrdersGlobal())
+		}
+		for _, id := range ids {
+			topics = append(topics, app.TopicStation(id))
+		}
+	}
+	for _, p := range s.App.UserPermissions(u) {
+		topics = append(topics, app.TopicPermission(p))
 	}
 
 	ch, cancel := s.App.SSE().Subscribe(topics, 32)
@@ -53,9 +64,12 @@ func (s *Server) SSEGet(w http.ResponseWriter, r *http.Request) {
 		case <-r.Context().Done():
 			return
 		case <-keep.C:
-			// comment ping
+			// comment ping; an open queue also keeps the bartender's shift going
 			fmt.Fprint(w, ": ping\n\n")
 			flusher.Flush()
+			if staff {
+				s.App.TouchShift(u.ID)
+			}
 		case ev, ok := <-ch:
 			if !ok {
 				return
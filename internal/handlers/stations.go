@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/stations"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminStationsPage struct {
+	Stations   []StationRow
+	Bartenders []db.User // everyone who can work the queue
+	Cocktails  []db.Cocktail
+	Routes     map[int64]int64 // cocktail -> station; missing means the main bar
+	MainBar    int64
+}
+
+type StationRow struct {
+	db.Station
+	Staff map[int64]bool
+}
+
+type StationStockPage struct {
+	Stations []db.Station
+	Station  *db.Station // nil when there is no station besides the main bar
+	Stock    []db.StationStock
+	Stocked  int
+}
+
+/* ---------------- Admin ---------------- */
+
+func (s *Server) AdminStationsGet(w http.ResponseWriter, r *http.Request) {
+	q := s.App.Store().Q
+	list, _ := q.ListStations()
+	staff, _ := q.ListStationBartenders()
+	out := AdminStationsPage{Bartenders: s.queueBartenders()}
+	for _, st := range list {
+		row := StationRow{Station: st, Staff: map[int64]bool{}}
+		for _, uid := range staff[st.ID] {
+			row.Staff[uid] = true
+		}
+		if st.IsDefault {
+			out.MainBar = st.ID
+		}
+		out.Stations = append(out.Stations, row)
+	}
+	out.Cocktails, _ = q.ListCocktailsComputed(false)
+	out.Routes, _ = q.ListCocktailRoutes()
+	s.renderLayout(w, r, "Stations", "admin_stations.html", out)
+}
+
+func (s *Server) AdminStationCreatePost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	name := stations.Clean(r.FormValue("name"))
+	if name == "" {
+		s.App.AddFlash(w, r, app.FlashError, "Name the station, e.g. Beer & Wine.")
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	q := s.App.Store().Q
+	id, err := q.CreateStation(name)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create the station; the name may be taken.")
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	st, _ := q.GetStation(id)
+	s.recordAudit(r, audit.Entry{Action: audit.StationCreate, TargetType: audit.TargetStation, TargetID: id, TargetLabel: name, After: st})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Station created. Stock it, then route cocktails to it.")
+	s.redirect(w, r, "/admin/stations")
+}
+
+func (s *Server) AdminStationRenamePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	_ = r.ParseForm()
+	name := stations.Clean(r.FormValue("name"))
+	q := s.App.Store().Q
+	before, _ := q.GetStation(id)
+	if before == nil || name == "" {
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	if err := q.RenameStation(id, name); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not rename the station; the name may be taken.")
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	after, _ := q.GetStation(id)
+	s.recordAudit(r, audit.Entry{Action: audit.StationRename, TargetType: audit.TargetStation, TargetID: id, TargetLabel: name, Before: before, After: after})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Station renamed.")
+	s.redirect(w, r, "/admin/stations")
+}
+
+// AdminStationDeletePost removes a station; its cocktails and open orders move to the
+// main bar.
+func (s *Server) AdminStationDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	q := s.App.Store().Q
+	before, _ := q.GetStation(id)
+	if before == nil {
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	err := q.DeleteStation(id)
+	if errors.Is(err, db.ErrDefaultStation) {
+		s.App.AddFlash(w, r, app.FlashError, "The main bar cannot be deleted.")
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Delete failed.")
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.StationDelete, TargetType: audit.TargetStation, TargetID: id, TargetLabel: before.Name, Before: before})
+
+	s.broadcastInventory()
+	s.App.AddFlash(w, r, app.FlashSuccess, "Station deleted; its cocktails and open orders moved to the main bar.")
+	s.redirect(w, r, "/admin/stations")
+}
+
+// AdminStationBartendersPost sets who works a station. Their queue and live updates then
+// default to their stations.
+func (s *Server) AdminStationBartendersPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	q := s.App.Store().Q
+	st, _ := q.GetStation(id)
+	if st == nil {
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	_ = r.ParseForm()
+	allowed := map[int64]string{}
+	for _, u := range s.queueBartenders() {
+		allowed[u.ID] = u.DisplayName
+	}
+	before, _ := q.ListStationBartenders()
+	var ids []int64
+	var names []string
+	for _, v := range r.Form["user_id"] {
+		uid, ok := parseInt64(v)
+		if name, known := allowed[uid]; ok && known {
+			ids = append(ids, uid)
+			names = append(names, name)
+		}
+	}
+	if err := q.SetStationBartenders(id, ids); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not save the bartenders.")
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	var prev []string
+	for _, uid := range before[id] {
+		if u, _ := q.GetUserByID(uid); u != nil {
+			prev = append(prev, u.DisplayName)
+		}
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.StationStaff, TargetType: audit.TargetStation, TargetID: id, TargetLabel: st.Name,
+		Before: map[string]string{"Bartenders": strings.Join(prev, ", ")},
+		After:  map[string]string{"Bartenders": strings.Join(names, ", ")}})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Bartenders for "+st.Name+" saved.")
+	s.redirect(w, r, "/admin/stations")
+}
+
+// AdminStationRoutesPost saves which station makes each cocktail.
+func (s *Server) AdminStationRoutesPost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	q := s.App.Store().Q
+	list, _ := q.ListStations()
+	names := map[int64]string{}
+	for _, st := range list {
+		names[st.ID] = st.Name
+	}
+	cocktails, _ := q.ListCocktailsComputed(false)
+	before, _ := q.ListCocktailRoutes()
+
+	// audit snapshots map each routed cocktail to its station's name
+	routes := map[int64]int64{}
+	prev, next := map[string]string{}, map[string]string{}
+	for _, c := range cocktails {
+		if sid, ok := before[c.ID]; ok {
+			prev[c.Name] = names[sid]
+		}
+		sid, ok := parseInt64(r.FormValue("station_" + strconv.FormatInt(c.ID, 10)))
+		if !ok {
+			if sid, ok = before[c.ID]; !ok {
+				continue
+			}
+		}
+		if _, known := names[sid]; known {
+			routes[c.ID] = sid
+		}
+	}
+	if err := q.ReplaceCocktailRoutes(routes); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not save the routing.")
+		s.redirect(w, r, "/admin/stations")
+		return
+	}
+	after, _ := q.ListCocktailRoutes()
+	for _, c := range cocktails {
+		if sid, ok := after[c.ID]; ok {
+			next[c.Name] = names[sid]
+		}
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.StationRoutes, TargetType: audit.TargetStation, TargetLabel: "Routing", Before: prev, After: next})
+
+	s.broadcastInventory()
+	s.App.AddFlash(w, r, app.FlashSuccess, "Routing saved. Availability now follows each station's stock.")
+	s.redirect(w, r, "/admin/stations")
+}
+
+// queueBartenders lists the active users who can work the order queue.
+func (s *Server) queueBartenders() []db.User {
+	users, _ := s.App.Store().Q.ListUsers()
+	var out []db.User
+	for _, u := range users {
+		if u.IsActive && s.App.Can(&u, app.PermOrdersManage) {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+/* ---------------- Station stock ---------------- */
+
+// BartenderStationStockGet shows what a station other than the main bar stocks. Without an
+// id it opens the first such station.
+func (s *Server) BartenderStationStockGet(w http.ResponseWriter, r *http.Request) {
+	q := s.App.Store().Q
+	list, _ := q.ListStations()
+	page := StationStockPage{Stations: list}
+	id, hasID := parseInt64(chi.URLParam(r, "id"))
+	for i := range list {
+		if list[i].IsDefault {
+			continue
+		}
+		if !hasID || list[i].ID == id {
+			page.Station = &list[i]
+			break
+		}
+	}
+	if hasID && page.Station == nil {
+		// the main bar keeps its stock on the ingredients themselves
+		s.redirect(w, r, "/bartender/products")
+		return
+	}
+	if page.Station != nil {
+		page.Stock, _ = q.ListStationStock(page.Station.ID)
+		for _, it := range page.Stock {
+			if it.Stocked {
+				page.Stocked++
+			}
+		}
+	}
+	s.renderLayout(w, r, "Station Stock", "bartender_station_stock.html", page)
+}
+
+// BartenderStationStockPost stocks one product at a station, or takes it off the station.
+func (s *Server) BartenderStationStockPost(w http.ResponseWriter, r *http.Request) {
+	sid, ok := parseInt64(chi.URLParam(r, "id"))
+	pid, ok2 := parseInt64(chi.URLParam(r, "product"))
+	if !ok || !ok2 {
+		s.redirect(w, r, "/bartender/stations")
+		return
+	}
+	back := "/bartender/stations/" + strconv.FormatInt(sid, 10)
+	q := s.App.Store().Q
+	st, _ := q.GetStation(sid)
+	p, _ := q.GetProductByID(pid)
+	if st == nil || st.IsDefault || p == nil {
+		s.redirect(w, r, "/bartender/stations")
+		return
+	}
+	_ = r.ParseForm()
+	stocked := r.FormValue("stocked") == "1"
+	available := r.FormValue("is_available") == "1"
+	var stock *int64
+	if v := strings.TrimSpace(r.FormValue("stock_count")); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			s.App.AddFlash(w, r, app.FlashError, "Stock must be a whole number, or empty when not counted.")
+			s.redirect(w, r, back)
+			return
+		}
+		stock = &n
+	}
+
+	before := stationStockOf(q, sid, pid)
+	if err := q.SetStationStock(sid, pid, stocked, available, stock); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not save the stock.")
+		s.redirect(w, r, back)
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.StationStock, TargetType: audit.TargetStation, TargetID: sid, TargetLabel: st.Name + ": " + p.Name, Before: before, After: stationStockOf(q, sid, pid)})
+
+	s.broadcastInventory()
+	s.redirect(w, r, back)
+}
+
+// stationStockOf is one product's row of ListStationStock, for audit snapshots.
+func stationStockOf(q *db.Queries, stationID, productID int64) *db.StationStock {
+	list, _ := q.ListStationStock(stationID)
+	for i := range list {
+		if list[i].ProductID == productID {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+/* ---------------- Queue ---------------- */
+
+// QueueFilter is the station tab of the order queue.
+type QueueFilter struct {
+	Stations []db.Station
+	Station  string // the applied ?station= filter; see stations.Queue
+	Mine     bool   // the bartender works particular stations
+}
+
+// queueStations resolves the queue's ?station= filter for the signed-in bartender to the
+// stations whose orders they see, nil meaning all.
+func (s *Server) queueStations(r *http.Request) ([]int64, QueueFilter) {
+	q := s.App.Store().Q
+	list, _ := q.ListStations()
+	known := map[int64]bool{}
+	for _, st := range list {
+		known[st.ID] = true
+	}
+	var assigned []int64
+	if u := s.App.CurrentUser(r); u != nil {
+		assigned, _ = q.StationIDsForUser(u.ID)
+	}
+	ids, applied := stations.Queue(r.URL.Query().Get("station"), assigned, func(id int64) bool { return known[id] })
+	return ids, QueueFilter{Stations: list, Station: applied, Mine: len(assigned) > 0}
+}
+
+// queuePath is the order queue on the tab an order action was posted from.
+func queuePath(r *http.Request) string {
+	if v := strings.TrimSpace(r.FormValue("station")); v != "" {
+		return "/bartender/orders?station=" + url.QueryEscape(v)
+	}
+	return "/bartender/orders"
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type BartenderStocktakesPage struct {
+	Stocktakes []db.Stocktake
+}
+
+type BartenderStocktakePage struct {
+	Stocktake db.Stocktake
+	Rows      []StocktakeRow     // draft: every product with its entered count
+	Lines     []db.StocktakeLine // committed: recorded variance
+}
+
+type StocktakeRow struct {
+	Product db.Product
+	Counted string
+}
+
+func (s *Server) StocktakesGet(w http.ResponseWriter, r *http.Request) {
+	list, _ := s.App.Store().Q.ListStocktakes()
+	s.renderLayout(w, r, "Stocktakes", "bartender_stocktakes.html", BartenderStocktakesPage{Stocktakes: list})
+}
+
+func (s *Server) StocktakeCreatePost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	if u == nil {
+		s.redirect(w, r, "/login")
+		return
+	}
+	_ = r.ParseForm()
+
+	id, err := s.App.Store().Q.CreateStocktake(u.ID, strings.TrimSpace(r.FormValue("note")))
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not start a stocktake.")
+		s.redirect(w, r, "/bartender/stocktakes")
+		return
+	}
+	s.redirect(w, r, "/bartender/stocktakes/"+strconv.FormatInt(id, 10))
+}
+
+func (s *Server) StocktakeGet(w http.ResponseWriter, r *http.Request) {
+	st := s.loadStocktake(r)
+	if st == nil {
+		s.redirect(w, r, "/bartender/stocktakes")
+		return
+	}
+
+	lines, _ := s.App.Store().Q.ListStocktakeLines(st.ID)
+	page := BartenderStocktakePage{Stocktake: *st}
+	if st.Status == db.StocktakeDraft {
+		counted := make(map[int64]int64, len(lines))
+		for _, l := range lines {
+			counted[l.ProductID] = l.Counted
+		}
+		products, _ := s.App.Store().Q.ListProducts("")
+		for _, p := range products {
+			row := StocktakeRow{Product: p}
+			if n, ok := counted[p.ID]; ok {
+				row.Counted = strconv.FormatInt(n, 10)
+			}
+			page.Rows = append(page.Rows, row)
+		}
+	} else {
+		page.Lines = lines
+	}
+
+	s.renderLayout(w, r, "Stocktake", "bartender_stocktake.html", page)
+}
+
+// StocktakePost saves the entered counts; with action=commit it then applies them.
+func (s *Server) StocktakePost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	st := s.loadStocktake(r)
+	if u == nil || st == nil {
+		s.redirect(w, r, "/bartender/stocktakes")
+		return
+	}
+	self := "/bartender/stocktakes/" + strconv.FormatInt(st.ID, 10)
+	_ = r.ParseForm()
+
+	products, _ := s.App.Store().Q.ListProducts("")
+	var counts []db.StocktakeCount
+	for _, p := range products {
+		raw := strings.TrimSpace(r.FormValue("count_" + strconv.FormatInt(p.ID, 10)))
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			s.App.AddFlash(w, r, app.FlashError, "Count for "+p.Name+" must be a whole number of zero or more.")
+			s.redirect(w, r, self)
+			return
+		}
+		counts = append(counts, db.StocktakeCount{ProductID: p.ID, Counted: n})
+	}
+
+	if err := s.App.Store().Q.SaveStocktakeCounts(st.ID, counts); err != nil {
+		if errors.Is(err, db.ErrStocktakeNotDraft) {
+			s.App.AddFlash(w, r, app.FlashError, "This stocktake was already committed.")
+		} else {
+			s.App.AddFlash(w, r, app.FlashError, "Could not save counts.")
+		}
+		s.redirect(w, r, self)
+		return
+	}
+
+	if strings.TrimSpace(r.FormValue("action")) != "commit" {
+		s.App.AddFlash(w, r, app.FlashSuccess, "Draft saved.")
+		s.redirect(w, r, self)
+		return
+	}
+
+	if len(counts) == 0 {
+		s.App.AddFlash(w, r, app.FlashError, "Enter at least one count before committing.")
+		s.redirect(w, r, self)
+		return
+	}
+
+	ids := make([]int64, 0, len(counts))
+	for _, c := range counts {
+		ids = append(ids, c.ProductID)
+	}
+	before := s.stockSnapshot(ids...)
+	if err := s.App.Store().Q.CommitStocktake(st.ID, u.ID); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Commit failed; inventory was not changed.")
+		s.redirect(w, r, self)
+		return
+	}
+	s.recordAudit(r, stocktakeAudit(st, products, before, counts))
+
+	s.broadcastInventory()
+	s.notifyLowStock(before)
+	s.App.AddFlash(w, r, app.FlashSuccess, "Stocktake committed.")
+	s.redirect(w, r, self)
+}
+
+func (s *Server) StocktakeDiscardPost(w http.ResponseWriter, r *http.Request) {
+	st := s.loadStocktake(r)
+	if st != nil && st.Status == db.StocktakeDraft {
+		_ = s.App.Store().Q.DeleteStocktakeDraft(st.ID)
+		s.App.AddFlash(w, r, app.FlashInfo, "Draft discarded.")
+	}
+	s.redirect(w, r, "/bartender/stocktakes")
+}
+
+func (s *Server) loadStocktake(r *http.Request) *db.Stocktake {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		return nil
+	}
+	st, _ := s.App.Store().Q.GetStocktake(id)
+	return st
+}
+
+// stocktakeAudit records a commit as the stock of each counted product before and after,
+// keyed by product name.
+func stocktakeAudit(st *db.Stocktake, products []db.Product, before map[int64]*int64, counts []db.StocktakeCount) audit.Entry {
+	names := make(map[int64]string, len(products))
+	for _, p := range products {
+		names[p.ID] = p.Name
+	}
+	was := make(map[string]*int64, len(counts))
+	now := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		was[names[c.ProductID]] = before[c.ProductID]
+		now[names[c.ProductID]] = c.Counted
+	}
+	label := "Stocktake #" + strconv.FormatInt(st.ID, 10)
+	if st.Note != "" {
+		label += " (" + st.Note + ")"
+	}
+	return audit.Entry{Action: audit.StocktakeCommit, TargetType: audit.TargetStocktake, TargetID: st.ID, TargetLabel: label, Before: was, After: now}
+}
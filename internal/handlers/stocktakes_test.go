@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+)
+
+func TestStocktakeSaveAndCommit(t *testing.T) {
+	s := newTestServer(t)
+	q := s.App.Store().Q
+	bartender := s.addUser(t, "bar@example.com", app.RoleBartender)
+	five := int64(5)
+	gin, err := q.CreateProduct(db.CreateProductParams{Name: "Test Gin", Category: "Spirit", IsAvailable: true, StockCount: &five})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := q.CreateStocktake(bartender.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]string{"id": strconv.FormatInt(id, 10)}
+	field := "count_" + strconv.FormatInt(gin, 10)
+	post := func(form url.Values) {
+		s.serve(t, s.StocktakePost, bartender, http.MethodPost, "/bartender/stocktakes/x", params, form)
+	}
+	stock := func() int64 {
+		p, _ := q.GetProductByID(gin)
+		return *p.StockCount
+	}
+
+	post(url.Values{field: {"-1"}, "action": {"commit"}})
+	if lines, _ := q.ListStocktakeLines(id); len(lines) != 0 || stock() != 5 {
+		t.Fatalf("a negative count was saved: %+v, stock %d", lines, stock())
+	}
+	post(url.Values{"action": {"commit"}})
+	if st, _ := q.GetStocktake(id); st.Status != db.StocktakeDraft {
+		t.Fatal("committed a stocktake with nothing counted")
+	}
+
+	post(url.Values{field: {"4"}, "action": {"save"}})
+	lines, _ := q.ListStocktakeLines(id)
+	if len(lines) != 1 || lines[0].Counted != 4 || stock() != 5 {
+		t.Fatalf("saving a draft: %+v, stock %d", lines, stock())
+	}
+	w := s.serve(t, s.StocktakeGet, bartender, http.MethodGet, "/bartender/stocktakes/x", params, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("draft page: %d", w.Code)
+	}
+
+	post(url.Values{field: {"2"}, "action": {"commit"}})
+	if st, _ := q.GetStocktake(id); st.Status != db.StocktakeCommitted || st.NetVariance != -3 {
+		t.Fatalf("after commit: %+v", st)
+	}
+	if stock() != 2 {
+		t.Fatalf("stock after commit = %d, want 2", stock())
+	}
+	entries, _, _ := q.ListAudit(db.AuditFilter{Action: audit.StocktakeCommit})
+	if len(entries) != 1 || entries[0].Before != `{"Test Gin":5}` || entries[0].After != `{"Test Gin":2}` {
+		t.Fatalf("audit = %+v", entries)
+	}
+
+	// committed stocktakes are read-only and can't be discarded
+	post(url.Values{field: {"9"}, "action": {"commit"}})
+	s.serve(t, s.StocktakeDiscardPost, bartender, http.MethodPost, "/bartender/stocktakes/x/discard", params, url.Values{})
+	if st, _ := q.GetStocktake(id); st == nil || stock() != 2 {
+		t.Fatal("a committed stocktake changed")
+	}
+	if w := s.serve(t, s.StocktakeGet, bartender, http.MethodGet, "/bartender/stocktakes/x", params, nil); w.Code != http.StatusOK {
+		t.Fatalf("committed page: %d", w.Code)
+	}
+}
+
+func TestStocktakeDiscardDraft(t *testing.T) {
+	s := newTestServer(t)
+	bartender := s.addUser(t, "bar@example.com", app.RoleBartender)
+	id, _ := s.App.Store().Q.CreateStocktake(bartender.ID, "")
+	s.serve(t, s.StocktakeDiscardPost, bartender, http.MethodPost, "/bartender/stocktakes/x/discard", map[string]string{"id": strconv.FormatInt(id, 10)}, url.Values{})
+	if st, _ := s.App.Store().Q.GetStocktake(id); st != nil {
+		t.Fatal("draft was not discarded")
+	}
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/invites"
+	"house-bartender-go/internal/services/totp"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// totpIssuer labels the entry in the user's authenticator app.
+const totpIssuer = "House Bartender"
+
+// After maxTOTPFailures wrong codes in a row, codes are refused until totpLockout has
+// passed since the last one. Six digits fall quickly to unlimited guessing.
+const (
+	maxTOTPFailures = 5
+	totpLockout     = 15 * time.Minute
+)
+
+type TwoFactorLoginPage struct {
+	DisplayName string
+}
+
+type AccountTwoFactorPage struct {
+	Secret   string // grouped in fours for typing in by hand
+	Required bool
+}
+
+type RecoveryCodesPage struct {
+	Codes []string
+}
+
+// signIn starts a session for u after the first factor, or sends them on to the code
+// challenge when they have an authenticator enrolled.
+func (s *Server) signIn(w http.ResponseWriter, r *http.Request, u *db.User, flash, to string) {
+	if enrolled, err := s.App.Store().Q.HasTOTP(u.ID); err != nil || enrolled {
+		if err != nil || s.App.SetPendingLogin(w, u.ID) != nil {
+			s.App.AddFlash(w, r, app.FlashError, "Sign-in failed.")
+			s.redirect(w, r, "/login")
+			return
+		}
+		s.redirect(w, r, "/login/2fa")
+		return
+	}
+	_ = s.App.SetSessionUser(w, r, u.ID)
+	s.App.AddFlash(w, r, app.FlashSuccess, flash)
+	s.redirect(w, r, to)
+}
+
+// checkSecondFactor accepts a current authenticator code or an unused recovery code, with
+// guesses throttled per user. The message explains a refusal.
+func (s *Server) checkSecondFactor(t *db.TOTP, input string) (ok, recovery bool, msg string) {
+	if t == nil || !t.Confirmed {
+		return false, false, "Two-factor login is not set up."
+	}
+	if t.Failures >= maxTOTPFailures && time.Since(t.LastFailureAt) < totpLockout {
+		return false, false, "Too many wrong codes. Wait 15 minutes, then try again."
+	}
+	q := s.App.Store().Q
+	if totp.IsRecoveryCode(input) {
+		used, err := q.UseRecoveryCode(t.UserID, totp.RecoveryHash(input))
+		if err == nil && used {
+			return true, true, ""
+		}
+	} else if step, match := totp.Verify(t.Secret, input, time.Now()); match {
+		if fresh, err := q.AcceptTOTPStep(t.UserID, step); err == nil && fresh {
+			return true, false, ""
+		}
+		return false, false, "That code was already used. Wait for the next one."
+	}
+	_ = q.RecordTOTPFailure(t.UserID)
+	return false, false, "That code didn't work."
+}
+
+/* ---------------- Login challenge ---------------- */
+
+func (s *Server) TwoFactorLoginGet(w http.ResponseWriter, r *http.Request) {
+	uid, ok := s.App.PendingLogin(r)
+	if !ok {
+		s.redirect(w, r, "/login")
+		return
+	}
+	u, _ := s.App.Store().Q.GetUserByID(uid)
+	if u == nil || !u.IsActive {
+		s.App.ClearPendingLogin(w)
+		s.redirect(w, r, "/login")
+		return
+	}
+	s.renderLayout(w, r, "Two-Factor Login", "login_2fa.html", TwoFactorLoginPage{DisplayName: u.DisplayName})
+}
+
+func (s *Server) TwoFactorLoginPost(w http.ResponseWriter, r *http.Request) {
+	uid, ok := s.App.PendingLogin(r)
+	if !ok {
+		s.App.AddFlash(w, r, app.FlashError, "Sign-in expired. Log in again.")
+		s.redirect(w, r, "/login")
+		return
+	}
+	q := s.App.Store().Q
+	u, _ := q.GetUserByID(uid)
+	t, _ := q.GetTOTP(uid)
+	if u == nil || !u.IsActive {
+		s.App.ClearPendingLogin(w)
+		s.redirect(w, r, "/login")
+		return
+	}
+	if t == nil || !t.Confirmed {
+		// reset by an admin in the meantime
+		s.App.ClearPendingLogin(w)
+		s.App.AddFlash(w, r, app.FlashInfo, "Two-factor login was turned off for your account. Log in again.")
+		s.redirect(w, r, "/login")
+		return
+	}
+	_ = r.ParseForm()
+	ok, recovery, msg := s.checkSecondFactor(t, r.FormValue("code"))
+	if !ok {
+		s.App.AddFlash(w, r, app.FlashError, msg)
+		s.redirect(w, r, "/login/2fa")
+		return
+	}
+
+	s.App.ClearPendingLogin(w)
+	_ = s.App.SetSessionUser(w, r, u.ID)
+	s.App.AddFlash(w, r, app.FlashSuccess, "Welcome back, "+u.DisplayName+"!")
+	if recovery {
+		s.App.Audit().Record(audit.Entry{Actor: u, Action: audit.UserRecoveryUse, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: u.DisplayName})
+		left := t.RecoveryLeft - 1
+		s.App.AddFlash(w, r, app.FlashInfo, "You used a recovery code; "+strconv.Itoa(left)+" left. Make new ones on your account page if you're running low.")
+	}
+	s.redirect(w, r, "/")
+}
+
+/* ---------------- Enrollment ---------------- */
+
+// pendingTOTP returns the user's unfinished enrollment, starting one if there is none, so
+// reloading the page keeps the QR code already scanned.
+func (s *Server) pendingTOTP(u *db.User) (*db.TOTP, error) {
+	q := s.App.Store().Q
+	t, err := q.GetTOTP(u.ID)
+	if err != nil || t != nil {
+		return t, err
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := q.StartTOTP(u.ID, secret); err != nil {
+		return nil, err
+	}
+	return q.GetTOTP(u.ID)
+}
+
+// totpAccount is the account name shown in the authenticator app.
+func totpAccount(u *db.User) string {
+	if isGuestEmail(u.Email) {
+		return u.DisplayName
+	}
+	return u.Email
+}
+
+func groupSecret(secret string) string {
+	var parts []string
+	for len(secret) > 4 {
+		parts = append(parts, secret[:4])
+		secret = secret[4:]
+	}
+	return strings.Join(append(parts, secret), " ")
+}
+
+func (s *Server) AccountTwoFactorGet(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	t, err := s.pendingTOTP(u)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not start two-factor setup.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	if t.Confirmed {
+		s.redirect(w, r, "/account")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	s.renderLayout(w, r, "Two-Factor Login", "account_2fa.html", AccountTwoFactorPage{
+		Secret:   groupSecret(t.Secret),
+		Required: s.App.RoleRequires2FA(u.Role),
+	})
+}
+
+// AccountTwoFactorQRGet renders the pending secret as a QR code for the authenticator app.
+func (s *Server) AccountTwoFactorQRGet(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	t, _ := s.App.Store().Q.GetTOTP(u.ID)
+	if t == nil || t.Confirmed {
+		http.NotFound(w, r)
+		return
+	}
+	svg, err := invites.QRSVG(totp.URI(totpIssuer, totpAccount(u), t.Secret))
+	if err != nil {
+		http.Error(w, "qr failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(svg)
+}
+
+// AccountTwoFactorPost finishes enrollment with a first code from the app and shows the
+// recovery codes, once.
+func (s *Server) AccountTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	q := s.App.Store().Q
+	t, _ := q.GetTOTP(u.ID)
+	if t == nil || t.Confirmed {
+		s.redirect(w, r, "/account")
+		return
+	}
+	_ = r.ParseForm()
+	step, ok := totp.Verify(t.Secret, r.FormValue("code"), time.Now())
+	if !ok {
+		s.App.AddFlash(w, r, app.FlashError, "That code didn't match. Check the time on your phone and try the next one.")
+		s.redirect(w, r, "/account/2fa")
+		return
+	}
+	codes, hashes, err := totp.NewRecoveryCodes()
+	if err == nil {
+		err = q.ConfirmTOTP(u.ID, step, hashes)
+	}
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not turn on two-factor login.")
+		s.redirect(w, r, "/account/2fa")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.UserTwoFactorEnable, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: u.DisplayName})
+
+	w.Header().Set("Cache-Control", "no-store")
+	s.renderLayout(w, r, "Recovery Codes", "account_recovery_codes.html", RecoveryCodesPage{Codes: codes})
+}
+
+// AccountRecoveryCodesPost replaces the recovery codes after checking a current code.
+func (s *Server) AccountRecoveryCodesPost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	q := s.App.Store().Q
+	t, _ := q.GetTOTP(u.ID)
+	_ = r.ParseForm()
+	if ok, _, msg := s.checkSecondFactor(t, r.FormValue("code")); !ok {
+		s.App.AddFlash(w, r, app.FlashError, msg)
+		s.redirect(w, r, "/account")
+		return
+	}
+	codes, hashes, err := totp.NewRecoveryCodes()
+	if err == nil {
+		err = q.ReplaceRecoveryCodes(u.ID, hashes)
+	}
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not make new recovery codes.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.UserRecoveryCodes, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: u.DisplayName})
+
+	w.Header().Set("Cache-Control", "no-store")
+	s.renderLayout(w, r, "Recovery Codes", "account_recovery_codes.html", RecoveryCodesPage{Codes: codes})
+}
+
+// AccountTwoFactorDisablePost turns two-factor login off, unless the user's role requires it.
+func (s *Server) AccountTwoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	u := s.App.CurrentUser(r)
+	if s.App.RoleRequires2FA(u.Role) {
+		s.App.AddFlash(w, r, app.FlashError, "Your role requires two-factor login.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	q := s.App.Store().Q
+	t, _ := q.GetTOTP(u.ID)
+	_ = r.ParseForm()
+	if ok, _, msg := s.checkSecondFactor(t, r.FormValue("code")); !ok {
+		s.App.AddFlash(w, r, app.FlashError, msg)
+		s.redirect(w, r, "/account")
+		return
+	}
+	if err := q.DeleteTOTP(u.ID); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not turn off two-factor login.")
+		s.redirect(w, r, "/account")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.UserTwoFactorDisable, TargetType: audit.TargetUser, TargetID: u.ID, TargetLabel: u.DisplayName})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Two-factor login is off.")
+	s.redirect(w, r, "/account")
+}
+
+/* ---------------- Admin ---------------- */
+
+// AdminUserTwoFactorResetPost removes a user's authenticator and recovery codes, for a lost
+// phone. If their role requires two-factor login they enroll again at their next login.
+func (s *Server) AdminUserTwoFactorResetPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	target, _ := s.App.Store().Q.GetUserByID(id)
+	if target == nil {
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	if s.outranks(r, target.Role) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksUser)
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	if err := s.App.Store().Q.DeleteTOTP(id); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not reset two-factor login.")
+		s.redirect(w, r, "/admin/users")
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.UserTwoFactorReset, TargetType: audit.TargetUser, TargetID: id, TargetLabel: target.DisplayName})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Two-factor login reset for "+target.DisplayName+".")
+	s.redirect(w, r, "/admin/users")
+}
+
+// AdminRoleTwoFactorPost sets whether the role requires two-factor login. Built-in roles
+// can be changed too.
+func (s *Server) AdminRoleTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	existing, _ := s.App.Store().Q.GetRole(name)
+	if existing == nil {
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	if s.outranks(r, name) {
+		s.App.AddFlash(w, r, app.FlashError, flashOutranksRole)
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	_ = r.ParseForm()
+	require := formBool(r, "require")
+	if err := s.App.Store().Q.SetRoleRequire2FA(name, require); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Update failed.")
+		s.redirect(w, r, "/admin/roles")
+		return
+	}
+	_ = s.App.ReloadRoles()
+	updated, _ := s.App.Store().Q.GetRole(name)
+	s.recordAudit(r, audit.Entry{Action: audit.RoleUpdate, TargetType: audit.TargetRole, TargetLabel: name, Before: existing, After: updated})
+
+	if require {
+		s.App.AddFlash(w, r, app.FlashSuccess, "Members of this role now need two-factor login; anyone without it sets it up on their next visit.")
+	} else {
+		s.App.AddFlash(w, r, app.FlashSuccess, "Members of this role no longer need two-factor login.")
+	}
+	s.redirect(w, r, "/admin/roles")
+}
@@ -7,6 +7,7 @@ import (
 
 	"house-bartender-go/internal/app"
 	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/library"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -32,14 +33,29 @@ type CocktailLibraryPage struct {
 type CocktailDetailPage struct {
 	Cocktail    db.Cocktail
 	Ingredients []db.CocktailIngredient
+	Modifiers   []ModifierField
 	TagList     []string
 	IsAvailable bool
 }
 
+type ModifierField struct {
+	Modifier db.CocktailModifier
+	Name     string
+	Choices  []ModifierChoice
+}
+
+type ModifierChoice struct {
+	Value    string
+	Label    string
+	Disabled bool
+	Selected bool
+}
+
 type UserOrdersPage struct {
 	Mode   string // "user"
 	Orders []db.Order
 	Events map[int64][]db.OrderEvent
+	Push   PushCard
 }
 
 func (s *Server) UserHomeGet(w http.ResponseWriter, r *http.Request) {
@@ -49,14 +65,10 @@ func (s *Server) UserHomeGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only USER should use the ordering portal.
-	// Bartenders/Admins should live in their own portals.
-	if u.Role == app.RoleBartender {
-		s.redirect(w, r, "/bartender")
-		return
-	}
-	if u.Role == app.RoleAdmin {
-		s.redirect(w, r, "/admin/users")
+	// Only guests use the ordering portal.
+	// Staff live in the first portal their permissions open.
+	if home := s.App.HomePath(u); home != "/" {
+		s.redirect(w, r, home)
 		return
 	}
 
@@ -69,67 +81,57 @@ func (s *Server) UserCocktailsPartialGet(w http.ResponseWriter, r *http.Request)
 	s.renderPartial(w, r, "library_results.html", page, "/")
 }
 
+// findCocktails returns a page of the library and the number of cocktails matching in all.
+// A search is ranked and paged in SQL, within the cocktails the query's filters leave.
+func (s *Server) findCocktails(snap *library.Snapshot, search string, query library.Query) ([]db.Cocktail, int) {
+	if search == "" {
+		return snap.Find(query)
+	}
+	ids, total, err := s.App.Store().Q.SearchCocktailIDs(search, snap.IDs(query), query.Limit, query.Offset)
+	if err != nil {
+		return nil, 0
+	}
+	return snap.Cocktails(ids), total
+}
+
 func (s *Server) buildCocktailLibraryPage(r *http.Request, onlyAvailable bool) CocktailLibraryPage {
 	q := r.URL.Query()
 	search := strings.TrimSpace(q.Get("q"))
 	spirit := normalizeSpiritFilter(q.Get("spirit"))
 	pageNumber := parsePositiveInt(q.Get("page"), 1)
 
-	cocks, _ := s.App.Store().Q.ListCocktailsComputed(onlyAvailable)
-	var (
-		out             []db.Cocktail
-		ingredientCache = map[int64][]db.CocktailIngredient{}
-	)
-
-	loadIngredients := func(cocktailID int64) []db.CocktailIngredient {
-		if cached, ok := ingredientCache[cocktailID]; ok {
-			return cached
-		}
-		ings, _ := s.App.Store().Q.GetCocktailIngredients(cocktailID)
-		ingredientCache[cocktailID] = ings
-		return ings
+	query := library.Query{
+		SpiritTokens:  spiritTokens(spirit),
+		OnlyAvailable: onlyAvailable,
+		Limit:         cocktailLibraryPageSize,
+		Offset:        (pageNumber - 1) * cocktailLibraryPageSize,
 	}
 
-	for _, c := range cocks {
-		var ings []db.CocktailIngredient
-		if search != "" || spirit != "" {
-			ings = loadIngredients(c.ID)
-		}
-		if !cocktailMatchesSearch(c, ings, search) {
-			continue
-		}
-		if !cocktailMatchesSpirit(c, ings, spirit) {
-			continue
-		}
-		out = append(out, c)
+	var (
+		out        []db.Cocktail
+		totalCount int
+	)
+	snap, err := s.App.Library().Snapshot()
+	if err == nil {
+		out, totalCount = s.findCocktails(snap, search, query)
 	}
 
-	totalCount := len(out)
 	totalPages := 1
 	if totalCount > 0 {
 		totalPages = (totalCount + cocktailLibraryPageSize - 1) / cocktailLibraryPageSize
 	}
 	if pageNumber > totalPages {
+		// Asked past the last page: show the last one instead.
 		pageNumber = totalPages
-	}
-	if pageNumber < 1 {
-		pageNumber = 1
-	}
-
-	start := 0
-	end := totalCount
-	if totalCount > 0 {
-		start = (pageNumber - 1) * cocktailLibraryPageSize
-		if start > totalCount {
-			start = totalCount
+		if snap != nil {
+			query.Offset = (pageNumber - 1) * cocktailLibraryPageSize
+			out, totalCount = s.findCocktails(snap, search, query)
 		}
-		end = start + cocktailLibraryPageSize
-		if end > totalCount {
-			end = totalCount
-		}
-		out = out[start:end]
 	}
 
+	start := (pageNumber - 1) * cocktailLibraryPageSize
+	end := start + len(out)
+
 	pageNumbers := make([]int, 0, totalPages)
 	for i := 1; i <= totalPages; i++ {
 		pageNumbers = append(pageNumbers, i)
@@ -188,9 +190,12 @@ func (s *Server) CocktailDetailGet(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	mods, _ := s.App.Store().Q.GetCocktailModifiers(c.ID)
+
 	page := CocktailDetailPage{
 		Cocktail:    *c,
 		Ingredients: ings,
+		Modifiers:   buildModifierFields(mods),
 		TagList:     splitCSV(c.Tags),
 		IsAvailable: avail,
 	}
@@ -206,6 +211,7 @@ func (s *Server) UserOrdersGet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	page := s.buildUserOrdersPage(u.ID)
+	page.Push = s.pushCard(r, u, "Get a notification on this device when your drink is ready, or if it is cancelled, so you don't need to keep this page open.")
 	s.renderLayout(w, r, "My Orders", "user_orders.html", page)
 }
 
@@ -221,6 +227,7 @@ func (s *Server) UserOrdersPartialGet(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) buildUserOrdersPage(userID int64) UserOrdersPage {
 	orders, _ := s.App.Store().Q.ListOrdersForUser(userID)
+	orders = s.attachOrderModifiers(orders)
 	events := map[int64][]db.OrderEvent{}
 	for _, o := range orders {
 		evs, _ := s.App.Store().Q.ListOrderEvents(o.ID)
@@ -235,6 +242,55 @@ func (s *Server) buildUserOrdersPage(userID int64) UserOrdersPage {
 
 /* ---- helpers ---- */
 
+func modifierFieldName(modifierID int64) string {
+	return "modifier_" + strconv.FormatInt(modifierID, 10)
+}
+
+// modifierChoiceValues lists the stored options of a modifier; the first is the default.
+func modifierChoiceValues(m db.CocktailModifier) []string {
+	if m.Kind == db.ModifierOptionalIngredient {
+		return []string{db.IngredientChoiceRegular, db.IngredientChoiceNone, db.IngredientChoiceExtra}
+	}
+	return splitCSV(m.Options)
+}
+
+// defaultModifierChoice is what a guest gets when they leave a modifier untouched.
+// Optional ingredients fall back to "None" while their product is unavailable.
+func defaultModifierChoice(m db.CocktailModifier) string {
+	if m.Kind == db.ModifierOptionalIngredient && !m.ProductAvail {
+		return db.IngredientChoiceNone
+	}
+	values := modifierChoiceValues(m)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func buildModifierFields(mods []db.CocktailModifier) []ModifierField {
+	fields := make([]ModifierField, 0, len(mods))
+	for _, m := range mods {
+		def := defaultModifierChoice(m)
+		field := ModifierField{Modifier: m, Name: modifierFieldName(m.ID)}
+		for _, v := range modifierChoiceValues(m) {
+			label := v
+			if m.Kind == db.ModifierOptionalIngredient && v != db.IngredientChoiceRegular {
+				label = app.ModifierChoiceLabel(m.Kind, m.Label, v)
+			}
+			field.Choices = append(field.Choices, ModifierChoice{
+				Value:    v,
+				Label:    label,
+				Disabled: m.Kind == db.ModifierOptionalIngredient && !m.ProductAvail && v != db.IngredientChoiceNone,
+				Selected: v == def,
+			})
+		}
+		if len(field.Choices) > 0 {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
 func parseInt64(s string) (int64, bool) {
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -321,33 +377,6 @@ func ingredientMatch(ings []db.CocktailIngredient, q string) bool {
 	return false
 }
 
-func cocktailMatchesSearch(c db.Cocktail, ings []db.CocktailIngredient, search string) bool {
-	search = strings.ToLower(strings.TrimSpace(search))
-	if search == "" {
-		return true
-	}
-
-	fields := []string{
-		c.Name,
-		c.Description,
-		c.Tags,
-		c.Difficulty,
-	}
-	for _, field := range fields {
-		if strings.Contains(strings.ToLower(field), search) {
-			return true
-		}
-	}
-
-	for _, it := range ings {
-		if strings.Contains(strings.ToLower(it.ProductName), search) {
-			return true
-		}
-	}
-
-	return false
-}
-
 func normalizeSpiritFilter(s string) string {
 	switch normalized := strings.TrimSpace(strings.ToLower(s)); normalized {
 	case "", "all", "all-spirits":
@@ -359,31 +388,6 @@ func normalizeSpiritFilter(s string) string {
 	}
 }
 
-func cocktailMatchesSpirit(c db.Cocktail, ings []db.CocktailIngredient, spirit string) bool {
-	spirit = normalizeSpiritFilter(spirit)
-	if spirit == "" {
-		return true
-	}
-
-	text := strings.ToLower(strings.Join([]string{c.Name, c.Description, c.Tags}, " "))
-	for _, token := range spiritTokens(spirit) {
-		if strings.Contains(text, token) {
-			return true
-		}
-	}
-
-	for _, it := range ings {
-		ingredientText := strings.ToLower(strings.Join([]string{it.ProductName, it.ProductCategory}, " "))
-		for _, token := range spiritTokens(spirit) {
-			if strings.Contains(ingredientText, token) {
-				return true
-			}
-		}
-	}
-
-	return false
-}
-
 func spiritTokens(spirit string) []string {
 	switch spirit {
 	case "whiskey":
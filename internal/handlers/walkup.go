@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/invites"
+	"house-bartender-go/internal/services/walkup"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminWalkupPage struct {
+	Current *db.WalkupEvent // nil while walk-up ordering is off
+	Events  []WalkupRow
+	Roles   []db.Role // roles a walk-up guest may get
+	Lengths []InviteLifetime
+	URL     string
+
+	// Table is the table whose QR code is shown, with its link.
+	Table    string
+	TableURL string
+}
+
+type WalkupRow struct {
+	db.WalkupEvent
+	Status string
+}
+
+type WalkupPage struct {
+	Event *db.WalkupEvent // nil while walk-up ordering is off
+	Table string
+}
+
+// walkupLengths are the choices for how long walk-up ordering stays open; the third is
+// the default.
+var walkupLengths = []InviteLifetime{
+	{2, "2 hours"},
+	{4, "4 hours"},
+	{6, "6 hours"},
+	{12, "12 hours"},
+	{24, "1 day"},
+	{48, "2 days"},
+}
+
+// walkupHours reads the length field of the admin forms.
+func walkupHours(r *http.Request) (time.Duration, bool) {
+	hours, err := strconv.Atoi(r.FormValue("hours"))
+	d := time.Duration(hours) * time.Hour
+	if err != nil || d <= 0 || d > walkup.MaxLength {
+		return 0, false
+	}
+	return d, true
+}
+
+/* ---------------- Admin ---------------- */
+
+func (s *Server) AdminWalkupGet(w http.ResponseWriter, r *http.Request) {
+	q := s.App.Store().Q
+	base := s.App.Config().BaseURL
+	out := AdminWalkupPage{Lengths: walkupLengths, URL: walkup.URL(base, "")}
+	out.Current, _ = q.CurrentWalkupEvent()
+
+	list, _ := q.ListWalkupEvents(10)
+	now := time.Now()
+	for _, ev := range list {
+		out.Events = append(out.Events, WalkupRow{WalkupEvent: ev, Status: walkup.Status(ev, now)})
+	}
+	roles, _ := q.ListRoles()
+	for _, role := range roles {
+		if s.App.GuestRoleAllowed(role.Name) {
+			out.Roles = append(out.Roles, role)
+		}
+	}
+	if table := walkup.Clean(r.URL.Query().Get("table")); table != "" {
+		out.Table = table
+		out.TableURL = walkup.URL(base, table)
+	}
+	s.renderLayout(w, r, "Walk-up Ordering", "admin_walkup.html", out)
+}
+
+// AdminWalkupOpenPost opens walk-up ordering, closing whatever was open before.
+func (s *Server) AdminWalkupOpenPost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	name := strings.TrimSpace(r.FormValue("name"))
+	role := strings.TrimSpace(r.FormValue("role"))
+	if role == "" {
+		role = app.RoleUser
+	}
+	if !s.App.RoleExists(role) || !s.App.GuestRoleAllowed(role) {
+		s.App.AddFlash(w, r, app.FlashError, "Walk-up guests need a role that can order and has no staff access or two-factor requirement.")
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	length, ok := walkupHours(r)
+	if !ok {
+		s.App.AddFlash(w, r, app.FlashError, "Choose how long walk-up ordering stays open.")
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	var by int64
+	if u := s.App.CurrentUser(r); u != nil {
+		by = u.ID
+	}
+	q := s.App.Store().Q
+	before, _ := q.CurrentWalkupEvent()
+	id, err := q.OpenWalkupEvent(name, role, time.Now().Add(length), by)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not open walk-up ordering.")
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	if before != nil {
+		_, _ = s.App.Walkup().Run(time.Now())
+	}
+	ev, _ := q.GetWalkupEvent(id)
+	s.recordAudit(r, audit.Entry{Action: audit.WalkupOpen, TargetType: audit.TargetWalkup, TargetID: id, TargetLabel: walkupLabel(ev), After: ev})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Walk-up ordering is open. Share the link or put table QR codes out.")
+	s.redirect(w, r, "/admin/walkup")
+}
+
+// AdminWalkupExtendPost moves the end of the open event to the chosen length from now.
+func (s *Server) AdminWalkupExtendPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	_ = r.ParseForm()
+	length, ok := walkupHours(r)
+	if !ok {
+		s.App.AddFlash(w, r, app.FlashError, "Choose how long walk-up ordering stays open.")
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	q := s.App.Store().Q
+	before, _ := q.GetWalkupEvent(id)
+	if before == nil {
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	if err := q.SetWalkupEventEnd(id, time.Now().Add(length)); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Walk-up ordering has already ended; open it again instead.")
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	after, _ := q.GetWalkupEvent(id)
+	s.recordAudit(r, audit.Entry{Action: audit.WalkupExtend, TargetType: audit.TargetWalkup, TargetID: id, TargetLabel: walkupLabel(before), Before: before, After: after})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Walk-up ordering now closes "+after.EndsAt.Format("Mon 15:04")+".")
+	s.redirect(w, r, "/admin/walkup")
+}
+
+// AdminWalkupClosePost ends walk-up ordering now and signs its guests out.
+func (s *Server) AdminWalkupClosePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	if !ok {
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	q := s.App.Store().Q
+	before, _ := q.GetWalkupEvent(id)
+	if before == nil || !before.ClosedAt.IsZero() {
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	if err := q.CloseWalkupEvent(id); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Close failed.")
+		s.redirect(w, r, "/admin/walkup")
+		return
+	}
+	_, _ = s.App.Walkup().Run(time.Now())
+	after, _ := q.GetWalkupEvent(id)
+	s.recordAudit(r, audit.Entry{Action: audit.WalkupClose, TargetType: audit.TargetWalkup, TargetID: id, TargetLabel: walkupLabel(before), Before: before, After: after})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Walk-up ordering closed; its guests are signed out. Their orders stay in the queue.")
+	s.redirect(w, r, "/admin/walkup")
+}
+
+// AdminWalkupQRGet serves the walk-up link, for a table when ?table= is set, as an SVG QR
+// code to print.
+func (s *Server) AdminWalkupQRGet(w http.ResponseWriter, r *http.Request) {
+	svg, err := invites.QRSVG(walkup.URL(s.App.Config().BaseURL, walkup.Clean(r.URL.Query().Get("table"))))
+	if err != nil {
+		http.Error(w, "qr failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	_, _ = w.Write(svg)
+}
+
+func walkupLabel(ev *db.WalkupEvent) string {
+	if ev == nil {
+		return ""
+	}
+	if ev.Name != "" {
+		return ev.Name
+	}
+	return "Walk-up #" + strconv.FormatInt(ev.ID, 10)
+}
+
+/* ---------------- Guests ---------------- */
+
+// walkupPath is the walk-up page, keeping the table the guest came from.
+func walkupPath(table string) string {
+	if table == "" {
+		return "/walkup"
+	}
+	return "/walkup?table=" + url.QueryEscape(table)
+}
+
+// WalkupGet asks a walk-up guest for their name.
+func (s *Server) WalkupGet(w http.ResponseWriter, r *http.Request) {
+	if s.App.CurrentUser(r) != nil {
+		s.redirect(w, r, "/")
+		return
+	}
+	ev, _ := s.App.Store().Q.CurrentWalkupEvent()
+	s.renderLayout(w, r, "Order", "walkup.html", WalkupPage{Event: ev, Table: walkup.Clean(r.URL.Query().Get("table"))})
+}
+
+// WalkupPost creates a name-only guest and signs this browser in as them until the event
+// ends.
+func (s *Server) WalkupPost(w http.ResponseWriter, r *http.Request) {
+	if s.App.CurrentUser(r) != nil {
+		s.redirect(w, r, "/")
+		return
+	}
+	_ = r.ParseForm()
+	table := walkup.Clean(r.FormValue("table"))
+	back := walkupPath(table)
+	name := walkup.Clean(r.FormValue("display_name"))
+	if name == "" {
+		s.App.AddFlash(w, r, app.FlashError, "Tell us your name.")
+		s.redirect(w, r, back)
+		return
+	}
+
+	q := s.App.Store().Q
+	ev, _ := q.CurrentWalkupEvent()
+	if ev == nil || !s.App.GuestRoleAllowed(ev.Role) {
+		s.App.AddFlash(w, r, app.FlashError, "Walk-up ordering is closed right now.")
+		s.redirect(w, r, back)
+		return
+	}
+	email, err := invites.PlaceholderEmail()
+	var id int64
+	if err == nil {
+		id, err = q.JoinWalkup(ev.ID, email, name, table)
+	}
+	if errors.Is(err, db.ErrWalkupClosed) {
+		s.App.AddFlash(w, r, app.FlashError, "Walk-up ordering is closed right now.")
+		s.redirect(w, r, back)
+		return
+	}
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not start your order. Try again.")
+		s.redirect(w, r, back)
+		return
+	}
+
+	u, _ := q.GetUserByID(id)
+	s.App.Audit().Record(audit.Entry{Actor: u, Action: audit.UserJoin, TargetType: audit.TargetUser, TargetID: id, TargetLabel: name, After: u})
+	s.emitUserCreated(u)
+
+	if err := s.App.SetWalkupGuest(w, id, ev.ID, ev.EndsAt); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not start your order. Try again.")
+		s.redirect(w, r, back)
+		return
+	}
+	s.App.AddFlash(w, r, app.FlashSuccess, "Hi "+name+"! Pick something to drink.")
+	s.redirect(w, r, "/")
+}
+
+// WalkupLeavePost forgets the walk-up guest on this browser, e.g. a phone handed around.
+func (s *Server) WalkupLeavePost(w http.ResponseWriter, r *http.Request) {
+	table := ""
+	if g := s.App.WalkupGuest(r); g != nil {
+		table = g.Table
+	}
+	s.App.ClearWalkupGuest(w)
+	s.App.AddFlash(w, r, app.FlashInfo, "Signed out. Orders already placed are still on their way.")
+	s.redirect(w, r, walkupPath(table))
+}
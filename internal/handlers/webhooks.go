@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"house-bartender-go/internal/app"
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/audit"
+	"house-bartender-go/internal/services/webhooks"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// webhookLogLimit caps the deliveries listed on an endpoint's page.
+const webhookLogLimit = 50
+
+type AdminWebhooksPage struct {
+	Endpoints []db.WebhookEndpoint
+	Events    []string
+}
+
+type AdminWebhookPage struct {
+	Endpoint    db.WebhookEndpoint
+	Events      []string
+	Deliveries  []db.WebhookDelivery
+	MaxAttempts int
+}
+
+// emitOrderWebhook sends the webhooks event with the order as it is now.
+func (s *Server) emitOrderWebhook(event string, orderID int64) {
+	q := s.App.Store().Q
+	o, _ := q.GetOrderByID(orderID)
+	if o == nil {
+		return
+	}
+	if mods, err := q.ListOrderModifiers([]int64{o.ID}); err == nil {
+		o.Modifiers = mods[o.ID]
+	}
+	s.App.EmitWebhook(event, webhooks.OrderData(*o))
+}
+
+// emitUserCreated sends the webhooks a new account. Guests who joined without an email
+// are sent without one.
+func (s *Server) emitUserCreated(u *db.User) {
+	if u == nil {
+		return
+	}
+	data := webhooks.UserData(*u)
+	if isGuestEmail(data.Email) {
+		data.Email = ""
+	}
+	s.App.EmitWebhook(webhooks.UserCreated, data)
+}
+
+// webhookAudit is the endpoint as recorded in the audit log, without the delivery counts
+// that change on their own.
+func webhookAudit(e *db.WebhookEndpoint) *db.WebhookEndpoint {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	c.Pending, c.Failed = 0, 0
+	return &c
+}
+
+func webhookForm(r *http.Request) webhooks.Endpoint {
+	_ = r.ParseForm()
+	return webhooks.Endpoint{
+		Name:    r.FormValue("name"),
+		URL:     r.FormValue("url"),
+		Events:  r.Form["events"],
+		Enabled: formBool(r, "is_enabled"),
+	}
+}
+
+func (s *Server) AdminWebhooksGet(w http.ResponseWriter, r *http.Request) {
+	list, _ := s.App.Store().Q.ListWebhookEndpoints()
+	s.renderLayout(w, r, "Webhooks", "admin_webhooks.html", AdminWebhooksPage{Endpoints: list, Events: webhooks.Events})
+}
+
+func (s *Server) AdminWebhookCreatePost(w http.ResponseWriter, r *http.Request) {
+	form := webhookForm(r)
+	form.Enabled = true
+	e, err := form.Clean()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, webhookFormError(err))
+		s.redirect(w, r, "/admin/webhooks")
+		return
+	}
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create the webhook.")
+		s.redirect(w, r, "/admin/webhooks")
+		return
+	}
+	q := s.App.Store().Q
+	id, err := q.CreateWebhookEndpoint(e.Name, e.URL, secret, e.Events, e.Enabled)
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not create the webhook.")
+		s.redirect(w, r, "/admin/webhooks")
+		return
+	}
+	after, _ := q.GetWebhookEndpoint(id)
+	s.recordAudit(r, audit.Entry{Action: audit.WebhookCreate, TargetType: audit.TargetWebhook, TargetID: id, TargetLabel: e.Name, After: webhookAudit(after)})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Webhook created. Copy its secret into the receiver, then send a test.")
+	s.redirect(w, r, "/admin/webhooks/"+strconv.FormatInt(id, 10))
+}
+
+// webhookFormError turns a rejected form into a flash: "A name is required."
+func webhookFormError(err error) string {
+	if !errors.Is(err, webhooks.ErrInvalidEndpoint) {
+		return "Could not save the webhook."
+	}
+	msg := strings.TrimPrefix(err.Error(), webhooks.ErrInvalidEndpoint.Error()+": ")
+	return strings.ToUpper(msg[:1]) + msg[1:] + "."
+}
+
+// webhookEndpoint loads the endpoint named in the URL, sending the admin back to the
+// list when there is none.
+func (s *Server) webhookEndpoint(w http.ResponseWriter, r *http.Request) *db.WebhookEndpoint {
+	id, ok := parseInt64(chi.URLParam(r, "id"))
+	var e *db.WebhookEndpoint
+	if ok {
+		e, _ = s.App.Store().Q.GetWebhookEndpoint(id)
+	}
+	if e == nil {
+		s.App.AddFlash(w, r, app.FlashError, "Webhook not found.")
+		s.redirect(w, r, "/admin/webhooks")
+	}
+	return e
+}
+
+func webhookPath(id int64) string {
+	return "/admin/webhooks/" + strconv.FormatInt(id, 10)
+}
+
+// AdminWebhookGet shows an endpoint's settings and secret, and its latest deliveries.
+func (s *Server) AdminWebhookGet(w http.ResponseWriter, r *http.Request) {
+	e := s.webhookEndpoint(w, r)
+	if e == nil {
+		return
+	}
+	out := AdminWebhookPage{Endpoint: *e, Events: webhooks.Events, MaxAttempts: webhooks.MaxAttempts()}
+	out.Deliveries, _ = s.App.Store().Q.ListWebhookDeliveries(e.ID, webhookLogLimit)
+	s.renderLayout(w, r, e.Name, "admin_webhook.html", out)
+}
+
+func (s *Server) AdminWebhookUpdatePost(w http.ResponseWriter, r *http.Request) {
+	before := s.webhookEndpoint(w, r)
+	if before == nil {
+		return
+	}
+	e, err := webhookForm(r).Clean()
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, webhookFormError(err))
+		s.redirect(w, r, webhookPath(before.ID))
+		return
+	}
+	q := s.App.Store().Q
+	if err := q.UpdateWebhookEndpoint(before.ID, e.Name, e.URL, e.Events, e.Enabled); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not save the webhook.")
+		s.redirect(w, r, webhookPath(before.ID))
+		return
+	}
+	after, _ := q.GetWebhookEndpoint(before.ID)
+	s.recordAudit(r, audit.Entry{Action: audit.WebhookUpdate, TargetType: audit.TargetWebhook, TargetID: before.ID, TargetLabel: e.Name, Before: webhookAudit(before), After: webhookAudit(after)})
+
+	if e.Enabled && !before.IsEnabled {
+		// deliveries queued while it was off go out now
+		s.App.Webhooks().Wake()
+	}
+	s.App.AddFlash(w, r, app.FlashSuccess, "Webhook saved.")
+	s.redirect(w, r, webhookPath(before.ID))
+}
+
+// AdminWebhookSecretPost gives the endpoint a new signing secret. Deliveries still queued
+// are signed with it when they are sent.
+func (s *Server) AdminWebhookSecretPost(w http.ResponseWriter, r *http.Request) {
+	e := s.webhookEndpoint(w, r)
+	if e == nil {
+		return
+	}
+	secret, err := webhooks.NewSecret()
+	if err == nil {
+		err = s.App.Store().Q.SetWebhookEndpointSecret(e.ID, secret)
+	}
+	if err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Could not rotate the secret.")
+		s.redirect(w, r, webhookPath(e.ID))
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.WebhookSecret, TargetType: audit.TargetWebhook, TargetID: e.ID, TargetLabel: e.Name})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "New secret made. Update the receiver; the old one no longer verifies.")
+	s.redirect(w, r, webhookPath(e.ID))
+}
+
+func (s *Server) AdminWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	e := s.webhookEndpoint(w, r)
+	if e == nil {
+		return
+	}
+	if err := s.App.Store().Q.DeleteWebhookEndpoint(e.ID); err != nil {
+		s.App.AddFlash(w, r, app.FlashError, "Delete failed.")
+		s.redirect(w, r, webhookPath(e.ID))
+		return
+	}
+	s.recordAudit(r, audit.Entry{Action: audit.WebhookDelete, TargetType: audit.TargetWebhook, TargetID: e.ID, TargetLabel: e.Name, Before: webhookAudit(e)})
+
+	s.App.AddFlash(w, r, app.FlashSuccess, "Webhook deleted with its delivery log.")
+	s.redirect(w, r, "/admin/webhooks")
+}
+
+// AdminWebhookPingPost sends the endpoint a ping and reports how it answered.
+func (s *Server) AdminWebhookPingPost(w http.ResponseWriter, r *http.Request) {
+	e := s.webhookEndpoint(w, r)
+	if e == nil {
+		return
+	}
+	d, err := s.App.Webhooks().Ping(e.ID)
+	s.flashDelivery(w, r, "Test", d, err)
+	s.redirect(w, r, webhookPath(e.ID))
+}
+
+// AdminWebhookRedeliverPost sends a logged delivery again, as a new delivery with the same
+// event ID and payload.
+func (s *Server) AdminWebhookRedeliverPost(w http.ResponseWriter, r *http.Request) {
+	e := s.webhookEndpoint(w, r)
+	if e == nil {
+		return
+	}
+	id, _ := parseInt64(chi.URLParam(r, "delivery"))
+	orig, _ := s.App.Store().Q.GetWebhookDelivery(id)
+	if orig == nil || orig.EndpointID != e.ID {
+		s.App.AddFlash(w, r, app.FlashError, "Delivery not found.")
+		s.redirect(w, r, webhookPath(e.ID))
+		return
+	}
+	d, err := s.App.Webhooks().Redeliver(orig.ID)
+	s.flashDelivery(w, r, "Redelivery", d, err)
+	s.redirect(w, r, webhookPath(e.ID))
+}
+
+// flashDelivery reports a delivery sent from the admin screen.
+func (s *Server) flashDelivery(w http.ResponseWriter, r *http.Request, what string, d *db.WebhookDelivery, err error) {
+	switch {
+	case err != nil:
+		s.App.AddFlash(w, r, app.FlashError, what+" could not be queued.")
+	case d.Status == db.WebhookDelivered:
+		s.App.AddFlash(w, r, app.FlashSuccess, what+" delivered ("+strconv.Itoa(d.ResponseStatus)+").")
+	default:
+		s.App.AddFlash(w, r, app.FlashError, what+" failed: "+d.Error+". It is retried while the webhook is enabled.")
+	}
+}
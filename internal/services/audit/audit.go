@@ -0,0 +1,247 @@
+// Package audit records administrative and inventory changes in the append-only audit log
+// and compares the stored snapshots for the admin viewer.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sort"
+
+	"house-bartender-go/internal/db"
+)
+
+// Target types.
+const (
+	TargetUser      = "user"
+	TargetRole      = "role"
+	TargetInvite    = "invite"
+	TargetKiosk     = "kiosk"
+	TargetWalkup    = "walkup"
+	TargetStation   = "station"
+	TargetWebhook   = "webhook"
+	TargetProduct   = "product"
+	TargetCocktail  = "cocktail"
+	TargetStocktake = "stocktake"
+	TargetSystem    = "system"
+)
+
+// Actions, named <target>.<verb>.
+const (
+	UserCreate = "user.create"
+	UserUpdate = "user.update"
+	UserToggle = "user.toggle"
+	UserDuty   = "user.duty"
+	// password changes are recorded without any snapshot
+	UserPassword = "user.password"
+	// a guest registering through an invite or walk-up ordering; the actor is the new account
+	UserJoin = "user.join"
+	// an admin issuing a reset code, and the user redeeming it
+	UserResetIssue = "user.reset_issue"
+	UserReset      = "user.reset"
+	// an existing account linked to an identity provider login by verified email
+	UserLink = "user.link"
+	// two-factor login turned on or off by the user, or reset by an admin for a lost phone;
+	// recovery codes replaced, and one used to log in
+	UserTwoFactorEnable  = "user.2fa_enable"
+	UserTwoFactorDisable = "user.2fa_disable"
+	UserTwoFactorReset   = "user.2fa_reset"
+	UserRecoveryCodes    = "user.recovery_codes"
+	UserRecoveryUse      = "user.recovery_use"
+
+	RoleCreate = "role.create"
+	RoleUpdate = "role.update"
+	RoleDelete = "role.delete"
+
+	InviteCreate = "invite.create"
+	InviteRevoke = "invite.revoke"
+
+	KioskCreate = "kiosk.create"
+	KioskPair   = "kiosk.pair"
+	KioskRevoke = "kiosk.revoke"
+
+	// walk-up ordering opened, its end moved, or closed early
+	WalkupOpen   = "walkup.open"
+	WalkupExtend = "walkup.extend"
+	WalkupClose  = "walkup.close"
+
+	StationCreate = "station.create"
+	StationRename = "station.rename"
+	StationDelete = "station.delete"
+	// who works a station, what a station stocks, and which station makes each cocktail
+	StationStaff  = "station.staff"
+	StationStock  = "station.stock"
+	StationRoutes = "station.routes"
+
+	WebhookCreate = "webhook.create"
+	WebhookUpdate = "webhook.update"
+	WebhookDelete = "webhook.delete"
+	// a webhook endpoint given a new signing secret
+	WebhookSecret = "webhook.secret"
+
+	ProductCreate = "product.create"
+	ProductUpdate = "product.update"
+	ProductToggle = "product.toggle"
+	ProductStock  = "product.stock"
+	ProductScan   = "product.scan"
+	ProductDelete = "product.delete"
+
+	StocktakeCommit = "stocktake.commit"
+
+	CocktailCreate = "cocktail.create"
+	CocktailUpdate = "cocktail.update"
+	CocktailToggle = "cocktail.toggle"
+	CocktailDelete = "cocktail.delete"
+
+	CatalogSeed   = "catalog.seed"
+	MediaGC       = "media.gc"
+	BackupCreate  = "backup.create"
+	BackupRestore = "backup.restore"
+)
+
+// omitted lists snapshot fields that are never stored: secrets, and bookkeeping that would
+// show up as a change on every edit.
+var omitted = map[string]bool{
+	"PasswordHash":  true,
+	"Token":         true, // invite links stay usable until revoked
+	"Secret":        true, // webhook signing secrets
+	"CreatedAt":     true,
+	"UpdatedAt":     true,
+	"ComputedAvail": true,
+}
+
+type Repository interface {
+	InsertAudit(e db.AuditEntry) error
+}
+
+// Log writes audit entries. Recording never fails the action being recorded; write errors
+// are logged instead.
+type Log struct {
+	repo   Repository
+	logger *slog.Logger
+}
+
+func New(repo Repository, logger *slog.Logger) *Log {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Log{repo: repo, logger: logger}
+}
+
+// Entry describes one change. Before and After are snapshotted with Snapshot; leave Before
+// nil for creations and After nil for deletions.
+type Entry struct {
+	Actor       *db.User
+	Action      string
+	TargetType  string
+	TargetID    int64
+	TargetLabel string
+	Before      any
+	After       any
+}
+
+func (l *Log) Record(e Entry) {
+	row := db.AuditEntry{
+		Action:      e.Action,
+		TargetType:  e.TargetType,
+		TargetID:    e.TargetID,
+		TargetLabel: e.TargetLabel,
+		Before:      Snapshot(e.Before),
+		After:       Snapshot(e.After),
+	}
+	if e.Actor != nil {
+		row.ActorID = e.Actor.ID
+		row.ActorName = e.Actor.DisplayName
+	}
+	if err := l.repo.InsertAudit(row); err != nil {
+		l.logger.Error("audit log write failed", "action", e.Action, "target", e.TargetLabel, "err", err)
+	}
+}
+
+// Snapshot encodes v as JSON for storage. Objects lose the omitted fields; a nil v (or a
+// nil pointer) gives "".
+func Snapshot(v any) string {
+	if v == nil {
+		return ""
+	}
+	raw, err := json.Marshal(v)
+	if err != nil || string(raw) == "null" {
+		return ""
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil {
+		return string(raw)
+	}
+	for k := range fields {
+		if omitted[k] {
+			delete(fields, k)
+		}
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// Change is one field that differs between two snapshots, formatted for display.
+type Change struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// Changes compares two snapshots field by field. Either side may be empty, in which case
+// every field of the other side is reported.
+func Changes(before, after string) []Change {
+	b := decodeFields(before)
+	a := decodeFields(after)
+
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var out []Change
+	for _, k := range keys {
+		bv, av := display(b[k]), display(a[k])
+		if bv == av {
+			continue
+		}
+		out = append(out, Change{Field: k, Before: bv, After: av})
+	}
+	return out
+}
+
+func decodeFields(s string) map[string]json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal([]byte(s), &fields) != nil {
+		// Not an object; compare it as a whole.
+		return map[string]json.RawMessage{"value": json.RawMessage(s)}
+	}
+	return fields
+}
+
+func display(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"house-bartender-go/internal/db"
+)
+
+type memRepo struct{ entries []db.AuditEntry }
+
+func (m *memRepo) InsertAudit(e db.AuditEntry) error {
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+func TestSnapshotDropsSecretsAndBookkeeping(t *testing.T) {
+	u := &db.User{ID: 3, Email: "a@x.io", PasswordHash: "$2a$10$secret", Role: "admin", DisplayName: "Ann"}
+	got := Snapshot(u)
+	if strings.Contains(got, "secret") || strings.Contains(got, "PasswordHash") {
+		t.Fatalf("snapshot leaked the password hash: %s", got)
+	}
+	if strings.Contains(got, "UpdatedAt") || !strings.Contains(got, `"Email":"a@x.io"`) {
+		t.Fatalf("snapshot = %s", got)
+	}
+
+	var none *db.User
+	if Snapshot(none) != "" || Snapshot(nil) != "" {
+		t.Fatal("nil snapshots should be empty")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	stock := int64(4)
+	before := Snapshot(db.Product{Name: "Gin", Category: "Spirit", StockCount: &stock})
+	stock2 := int64(1)
+	after := Snapshot(db.Product{Name: "Gin", Category: "Spirit", StockCount: &stock2, Notes: "London dry"})
+
+	got := Changes(before, after)
+	if len(got) != 2 {
+		t.Fatalf("changes = %+v", got)
+	}
+	if got[0] != (Change{Field: "Notes", Before: "", After: "London dry"}) {
+		t.Fatalf("changes[0] = %+v", got[0])
+	}
+	if got[1] != (Change{Field: "StockCount", Before: "4", After: "1"}) {
+		t.Fatalf("changes[1] = %+v", got[1])
+	}
+
+	// A deletion reports every field that had a value.
+	for _, c := range Changes(before, "") {
+		if c.After != "" {
+			t.Fatalf("deletion change %+v has an after value", c)
+		}
+	}
+}
+
+func TestRecordCopiesActor(t *testing.T) {
+	repo := &memRepo{}
+	l := New(repo, nil)
+	l.Record(Entry{
+		Actor:       &db.User{ID: 7, DisplayName: "Bo"},
+		Action:      ProductDelete,
+		TargetType:  TargetProduct,
+		TargetID:    12,
+		TargetLabel: "Rum",
+		Before:      db.Product{ID: 12, Name: "Rum"},
+	})
+	if len(repo.entries) != 1 {
+		t.Fatal("nothing recorded")
+	}
+	e := repo.entries[0]
+	if e.ActorID != 7 || e.ActorName != "Bo" || e.After != "" || !strings.Contains(e.Before, `"Name":"Rum"`) {
+		t.Fatalf("entry = %+v", e)
+	}
+}
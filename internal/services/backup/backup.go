@@ -0,0 +1,469 @@
+// Package backup writes and restores archives of the database, optionally with the uploads
+// directory, and keeps a rolling set of scheduled snapshots.
+//
+// An archive is a gzipped tar holding manifest.json, housebartender.db and, when uploads are
+// included, uploads/<file> for every file in the upload directory.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dbEntry       = "housebartender.db"
+	manifestEntry = "manifest.json"
+	uploadsPrefix = "uploads/"
+
+	// MaxArchiveBytes bounds what a restore will read from an uploaded archive.
+	MaxArchiveBytes = 4 << 30
+)
+
+var (
+	ErrNotFound       = errors.New("backup not found")
+	ErrInvalidArchive = errors.New("not a House Bartender backup archive")
+
+	namePattern = regexp.MustCompile(`^housebartender-\d{8}-\d{6}(-[a-z0-9-]+)?\.tar\.gz$`)
+)
+
+// Store is the database side of a backup; *db.Store implements it.
+type Store interface {
+	BackupTo(path string) error
+	RestoreFrom(path string) error
+}
+
+type Config struct {
+	Dir            string // where archives are written
+	UploadDir      string
+	Keep           int  // archives kept after each new one; 0 keeps all
+	IncludeUploads bool // bundle the upload directory into scheduled and admin backups
+}
+
+// Info describes one archive in the backup directory.
+type Info struct {
+	Name      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+type Manifest struct {
+	CreatedAt      time.Time `json:"created_at"`
+	IncludeUploads bool      `json:"include_uploads"`
+	Uploads        int       `json:"uploads"`
+}
+
+// Result is what the last scheduled or manual backup did.
+type Result struct {
+	At   time.Time
+	Name string
+	Err  string
+}
+
+type Service struct {
+	store Store
+	log   *slog.Logger
+	cfg   Config
+
+	run  sync.Mutex // one backup or restore at a time
+	mu   sync.Mutex
+	last Result
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func New(store Store, logger *slog.Logger, cfg Config) *Service {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Service{store: store, log: logger, cfg: cfg}
+}
+
+func (s *Service) Config() Config { return s.cfg }
+
+// Last reports the most recent backup; At is zero if none has run.
+func (s *Service) Last() Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Start takes a backup every interval until Stop is called.
+func (s *Service) Start(every time.Duration) {
+	if every <= 0 || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-t.C:
+				if _, err := s.Create(time.Now(), s.cfg.IncludeUploads, ""); err != nil {
+					s.log.Warn("scheduled backup failed", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.stop = nil
+}
+
+// Create writes a new archive to the backup directory and prunes old ones. Label, if set,
+// is appended to the file name ("pre-restore").
+func (s *Service) Create(now time.Time, includeUploads bool, label string) (Info, error) {
+	s.run.Lock()
+	defer s.run.Unlock()
+	return s.create(now, includeUploads, label)
+}
+
+func (s *Service) create(now time.Time, includeUploads bool, label string) (Info, error) {
+	info, err := s.createArchive(now, includeUploads, label)
+	res := Result{At: now, Name: info.Name}
+	if err != nil {
+		res.Err = err.Error()
+	}
+	s.mu.Lock()
+	s.last = res
+	s.mu.Unlock()
+	if err != nil {
+		return Info{}, err
+	}
+	s.log.Info("backup written", "name", info.Name, "bytes", info.Size)
+	if err := s.prune(); err != nil {
+		s.log.Warn("backup retention failed", "err", err)
+	}
+	return info, nil
+}
+
+func (s *Service) createArchive(now time.Time, includeUploads bool, label string) (Info, error) {
+	if err := os.MkdirAll(s.cfg.Dir, 0o750); err != nil {
+		return Info{}, err
+	}
+	name := "housebartender-" + now.UTC().Format("20060102-150405")
+	if label != "" {
+		name += "-" + label
+	}
+	name += ".tar.gz"
+	final := filepath.Join(s.cfg.Dir, name)
+	if _, err := os.Stat(final); err == nil {
+		return Info{}, fmt.Errorf("backup %s already exists", name)
+	}
+
+	// The database copy and the archive are written under temporary names so a failed
+	// backup never looks like a good one.
+	dbCopy := filepath.Join(s.cfg.Dir, ".tmp-"+name+".db")
+	defer os.Remove(dbCopy)
+	if err := s.store.BackupTo(dbCopy); err != nil {
+		return Info{}, fmt.Errorf("database backup: %w", err)
+	}
+
+	tmp := filepath.Join(s.cfg.Dir, ".tmp-"+name)
+	if err := s.writeArchive(tmp, dbCopy, now, includeUploads); err != nil {
+		_ = os.Remove(tmp)
+		return Info{}, err
+	}
+	if err := os.Rename(tmp, final); err != nil {
+		_ = os.Remove(tmp)
+		return Info{}, err
+	}
+	st, err := os.Stat(final)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Name: name, Size: st.Size(), CreatedAt: now}, nil
+}
+
+func (s *Service) writeArchive(path, dbCopy string, now time.Time, includeUploads bool) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	var uploads []os.DirEntry
+	if includeUploads {
+		entries, err := os.ReadDir(s.cfg.UploadDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				uploads = append(uploads, e)
+			}
+		}
+	}
+
+	manifest, _ := json.MarshalIndent(Manifest{CreatedAt: now.UTC(), IncludeUploads: includeUploads, Uploads: len(uploads)}, "", "  ")
+	if err := writeEntry(tw, manifestEntry, now, int64(len(manifest)), strings.NewReader(string(manifest))); err != nil {
+		return err
+	}
+	if err := addFile(tw, dbEntry, dbCopy); err != nil {
+		return err
+	}
+	for _, e := range uploads {
+		if err := addFile(tw, uploadsPrefix+e.Name(), filepath.Join(s.cfg.UploadDir, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func addFile(tw *tar.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return writeEntry(tw, name, st.ModTime(), st.Size(), f)
+}
+
+func writeEntry(tw *tar.Writer, name string, mod time.Time, size int64, r io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o640, Size: size, ModTime: mod, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+// List returns the archives in the backup directory, newest first.
+func (s *Service) List() ([]Info, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Info
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !namePattern.MatchString(e.Name()) {
+			continue
+		}
+		st, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, Info{Name: e.Name(), Size: st.Size(), CreatedAt: st.ModTime()})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].Name > out[j].Name
+	})
+	return out, nil
+}
+
+// Path resolves an archive name from a request to its file, refusing anything that is not
+// one of ours.
+func (s *Service) Path(name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", ErrNotFound
+	}
+	p := filepath.Join(s.cfg.Dir, name)
+	if st, err := os.Stat(p); err != nil || !st.Mode().IsRegular() {
+		return "", ErrNotFound
+	}
+	return p, nil
+}
+
+func (s *Service) prune() error {
+	if s.cfg.Keep <= 0 {
+		return nil
+	}
+	list, err := s.List()
+	if err != nil {
+		return err
+	}
+	for i := s.cfg.Keep; i < len(list); i++ {
+		if err := os.Remove(filepath.Join(s.cfg.Dir, list[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore replaces the database, and the uploads if the archive has them, with the contents
+// of the archive at path. The archive is unpacked and the database integrity-checked before
+// anything live is touched, and a pre-restore backup of the current state is written first.
+func (s *Service) Restore(path string) (Manifest, error) {
+	s.run.Lock()
+	defer s.run.Unlock()
+
+	if err := os.MkdirAll(s.cfg.Dir, 0o750); err != nil {
+		return Manifest{}, err
+	}
+	work, err := os.MkdirTemp(s.cfg.Dir, ".restore-")
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer os.RemoveAll(work)
+
+	manifest, err := extract(path, work)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	if _, err := s.create(time.Now(), manifest.IncludeUploads, "pre-restore"); err != nil {
+		return Manifest{}, fmt.Errorf("pre-restore backup: %w", err)
+	}
+	if err := s.store.RestoreFrom(filepath.Join(work, dbEntry)); err != nil {
+		return Manifest{}, fmt.Errorf("restore database: %w", err)
+	}
+	if manifest.IncludeUploads {
+		if err := replaceDir(s.cfg.UploadDir, filepath.Join(work, "uploads")); err != nil {
+			return manifest, fmt.Errorf("restore uploads: %w", err)
+		}
+	}
+	s.log.Info("backup restored", "archive", filepath.Base(path), "uploads", manifest.Uploads)
+	return manifest, nil
+}
+
+// extract unpacks an archive into dir, accepting only the entries a backup contains.
+func extract(path, dir string) (Manifest, error) {
+	var manifest Manifest
+	f, err := os.Open(path)
+	if err != nil {
+		return manifest, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(io.LimitReader(f, MaxArchiveBytes))
+	if err != nil {
+		return manifest, ErrInvalidArchive
+	}
+	defer gz.Close()
+
+	if err := os.MkdirAll(filepath.Join(dir, "uploads"), 0o750); err != nil {
+		return manifest, err
+	}
+	var sawDB, sawManifest bool
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, ErrInvalidArchive
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return manifest, fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, hdr.Name)
+		}
+		var dest string
+		switch {
+		case hdr.Name == manifestEntry:
+			if err := json.NewDecoder(io.LimitReader(tr, 1<<16)).Decode(&manifest); err != nil {
+				return manifest, fmt.Errorf("%w: bad manifest", ErrInvalidArchive)
+			}
+			sawManifest = true
+			continue
+		case hdr.Name == dbEntry:
+			dest = filepath.Join(dir, dbEntry)
+			sawDB = true
+		case strings.HasPrefix(hdr.Name, uploadsPrefix):
+			base := strings.TrimPrefix(hdr.Name, uploadsPrefix)
+			if base == "" || base != filepath.Base(base) || strings.HasPrefix(base, ".") {
+				return manifest, fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, hdr.Name)
+			}
+			dest = filepath.Join(dir, "uploads", base)
+		default:
+			return manifest, fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, hdr.Name)
+		}
+		if err := writeFile(dest, tr); err != nil {
+			return manifest, err
+		}
+	}
+	if !sawDB || !sawManifest {
+		return manifest, ErrInvalidArchive
+	}
+	return manifest, nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// replaceDir makes dst hold exactly the files of src.
+func replaceDir(dst, src string) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	old, err := os.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+	for _, e := range old {
+		if e.Type().IsRegular() {
+			if err := os.Remove(filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	fresh, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range fresh {
+		from, to := filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())
+		if err := os.Rename(from, to); err == nil {
+			continue
+		}
+		// different filesystems
+		in, err := os.Open(from)
+		if err != nil {
+			return err
+		}
+		err = writeFile(to, in)
+		_ = in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+func openStore(t *testing.T, dir string) *db.Store {
+	t.Helper()
+	store, err := db.Open(filepath.Join(dir, "live.sqlite"), db.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := db.Migrate(store.DB); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func productNames(t *testing.T, store *db.Store) string {
+	t.Helper()
+	products, err := store.Q.ListProducts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range products {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestBackupAndRestoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+	uploads := filepath.Join(dir, "uploads")
+	if err := os.MkdirAll(uploads, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(uploads, "1_cocktail_hero.jpg"), []byte("kept"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Q.CreateProduct(db.CreateProductParams{Name: "Gin", Category: "Spirit", IsAvailable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := New(store, nil, Config{Dir: filepath.Join(dir, "backups"), UploadDir: uploads})
+	info, err := svc.Create(time.Now(), true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change everything after the snapshot.
+	if _, err := store.Q.CreateProduct(db.CreateProductParams{Name: "Rum", Category: "Spirit", IsAvailable: true}); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Remove(filepath.Join(uploads, "1_cocktail_hero.jpg"))
+	_ = os.WriteFile(filepath.Join(uploads, "2_cocktail_hero.jpg"), []byte("new"), 0o644)
+
+	path, err := svc.Path(info.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := svc.Restore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manifest.IncludeUploads || manifest.Uploads != 1 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	if got := productNames(t, store); got != "Gin" {
+		t.Fatalf("products after restore = %q, want Gin", got)
+	}
+	entries, _ := os.ReadDir(uploads)
+	if len(entries) != 1 || entries[0].Name() != "1_cocktail_hero.jpg" {
+		t.Fatalf("uploads after restore = %v", entries)
+	}
+
+	list, err := svc.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pre := 0
+	for _, b := range list {
+		if strings.HasSuffix(b.Name, "-pre-restore.tar.gz") {
+			pre++
+		}
+	}
+	if len(list) != 2 || pre != 1 {
+		t.Fatalf("expected the original and a pre-restore backup, got %+v", list)
+	}
+}
+
+func TestRetentionKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+	svc := New(store, nil, Config{Dir: filepath.Join(dir, "backups"), Keep: 2})
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		info, err := svc.Create(start.Add(time.Duration(i)*time.Hour), false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// List orders by file time; make it follow the backup time.
+		at := start.Add(time.Duration(i) * time.Hour)
+		_ = os.Chtimes(filepath.Join(dir, "backups", info.Name), at, at)
+	}
+	list, _ := svc.List()
+	if len(list) != 2 || list[0].Name != "housebartender-20260101-150000.tar.gz" {
+		t.Fatalf("kept %+v", list)
+	}
+}
+
+func TestRestoreRejectsUnsafeArchives(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+	svc := New(store, nil, Config{Dir: filepath.Join(dir, "backups"), UploadDir: filepath.Join(dir, "uploads")})
+
+	evil := filepath.Join(dir, "evil.tar.gz")
+	f, _ := os.Create(evil)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"manifest.json", "uploads/../../escape.txt"} {
+		body := "{}"
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+		_, _ = tw.Write([]byte(body))
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	_ = f.Close()
+
+	if _, err := svc.Restore(evil); !errors.Is(err, ErrInvalidArchive) {
+		t.Fatalf("err = %v, want ErrInvalidArchive", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); err == nil {
+		t.Fatal("archive entry escaped the restore directory")
+	}
+
+	garbage := filepath.Join(dir, "garbage.tar.gz")
+	_ = os.WriteFile(garbage, []byte("not an archive"), 0o644)
+	if _, err := svc.Restore(garbage); !errors.Is(err, ErrInvalidArchive) {
+		t.Fatalf("err = %v, want ErrInvalidArchive", err)
+	}
+}
+
+func TestRestoreRefusesCorruptDatabase(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.db")
+	if err := os.WriteFile(bad, []byte(strings.Repeat("x", 4096)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckIntegrity(bad); err == nil {
+		t.Fatal("a non-database file passed the integrity check")
+	}
+}
+
+func TestPathOnlyServesBackups(t *testing.T) {
+	svc := New(nil, nil, Config{Dir: t.TempDir()})
+	for _, name := range []string{"../live.sqlite", "housebartender-20260101-120000.tar.gz", "notes.txt"} {
+		if _, err := svc.Path(name); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Path(%q) err = %v", name, err)
+		}
+	}
+}
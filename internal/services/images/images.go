@@ -0,0 +1,186 @@
+// Package images validates uploaded photos and re-encodes them into the sizes the templates
+// use. Re-encoding drops everything but pixels, so EXIF (GPS, camera serials) never reaches
+// disk; the EXIF orientation is applied first so phone photos stay upright.
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxUploadBytes caps the size of an uploaded file.
+	MaxUploadBytes = 8 << 20
+	// MaxPixels rejects images that would need huge buffers to decode.
+	MaxPixels = 40_000_000
+)
+
+var (
+	ErrTooLarge    = errors.New("image is larger than 8 MB")
+	ErrUnsupported = errors.New("only JPEG, PNG and WebP images are accepted")
+	ErrDimensions  = errors.New("image dimensions are too large")
+	ErrCorrupt     = errors.New("image could not be read")
+)
+
+// Variant is one stored size. Width is the longest edge allowed; smaller images are not
+// scaled up.
+type Variant struct {
+	Name  string
+	Width int
+}
+
+var (
+	Thumb = Variant{Name: "thumb", Width: 160}
+	Card  = Variant{Name: "card", Width: 640}
+	Hero  = Variant{Name: "hero", Width: 1600}
+
+	Variants = []Variant{Thumb, Card, Hero}
+)
+
+// Sniff reads at most MaxUploadBytes from r and reports the detected content type. The
+// extension or Content-Type the client claimed plays no part.
+func Sniff(r io.Reader) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > MaxUploadBytes {
+		return nil, "", ErrTooLarge
+	}
+	ct := http.DetectContentType(data)
+	switch ct {
+	case "image/jpeg", "image/png", "image/webp":
+		return data, ct, nil
+	}
+	return nil, "", ErrUnsupported
+}
+
+// Decode validates and decodes a sniffed upload, applying its EXIF orientation.
+func Decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return orient(img, jpegOrientation(data)), nil
+}
+
+// Resize scales img so its longest edge is at most max.
+func Resize(img image.Image, max int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return img
+	}
+	if w >= h {
+		h = h * max / w
+		w = max
+	} else {
+		w = w * max / h
+		h = max
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, max1(w), max1(h)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Save writes every variant of img into dir as <base>_<variant>.jpg, or .png when the image
+// has transparency, and returns the hero path relative to dir.
+func Save(dir, base string, img image.Image) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	ext := ".jpg"
+	if !isOpaque(img) {
+		ext = ".png"
+	}
+
+	var written []string
+	for _, v := range Variants {
+		name := base + "_" + v.Name + ext
+		if err := writeFile(filepath.Join(dir, name), Resize(img, v.Width), ext); err != nil {
+			for _, w := range written {
+				_ = os.Remove(filepath.Join(dir, w))
+			}
+			return "", err
+		}
+		written = append(written, name)
+	}
+	return base + "_" + Hero.Name + ext, nil
+}
+
+func writeFile(path string, img image.Image, ext string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if ext == ".png" {
+		err = png.Encode(f, img)
+	} else {
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 82})
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
+
+// VariantPath maps a stored hero path such as /uploads/123_cocktail_hero.jpg to another
+// variant. Paths that are not pipeline uploads come back unchanged.
+func VariantPath(path string, v Variant) string {
+	for _, ext := range []string{".jpg", ".png"} {
+		suffix := "_" + Hero.Name + ext
+		if strings.HasSuffix(path, suffix) {
+			return strings.TrimSuffix(path, suffix) + "_" + v.Name + ext
+		}
+	}
+	return path
+}
+
+// Srcset lists every variant of a pipeline upload for an img srcset attribute, or "" for
+// any other image.
+func Srcset(path string) string {
+	if VariantPath(path, Thumb) == path {
+		return ""
+	}
+	parts := make([]string, 0, len(Variants))
+	for _, v := range Variants {
+		parts = append(parts, fmt.Sprintf("%s %dw", VariantPath(path, v), v.Width))
+	}
+	return strings.Join(parts, ", ")
+}
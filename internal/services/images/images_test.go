@@ -0,0 +1,130 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation splices a minimal little-endian EXIF block after the SOI marker.
+func withOrientation(data []byte, o uint16) []byte {
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0,
+		0x12, 0x01, 3, 0, 1, 0, 0, 0, byte(o), 0, 0, 0,
+		0, 0, 0, 0}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	size := len(payload) + 2
+	seg := append([]byte{0xFF, 0xE1, byte(size >> 8), byte(size)}, payload...)
+	out := append([]byte{}, data[:2]...)
+	out = append(out, seg...)
+	return append(out, data[2:]...)
+}
+
+func TestSniffRejectsNonImagesWhateverTheName(t *testing.T) {
+	if _, _, err := Sniff(bytes.NewReader([]byte("<html><script>alert(1)</script></html>"))); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("html upload: err = %v", err)
+	}
+	big := make([]byte, MaxUploadBytes+1)
+	copy(big, encodeJPEG(t, solid(4, 4, color.White)))
+	if _, _, err := Sniff(bytes.NewReader(big)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversize upload: err = %v", err)
+	}
+	if _, ct, err := Sniff(bytes.NewReader(encodeJPEG(t, solid(4, 4, color.White)))); err != nil || ct != "image/jpeg" {
+		t.Fatalf("jpeg: ct = %q, err = %v", ct, err)
+	}
+}
+
+func TestDecodeRejectsTruncatedImages(t *testing.T) {
+	data := encodeJPEG(t, solid(64, 64, color.White))
+	if _, err := Decode(data[:40]); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestDecodeAppliesExifOrientation(t *testing.T) {
+	data := withOrientation(encodeJPEG(t, solid(40, 20, color.White)), 6)
+	if o := jpegOrientation(data); o != 6 {
+		t.Fatalf("orientation = %d, want 6", o)
+	}
+	img, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Fatalf("rotated bounds = %v, want 20x40", b)
+	}
+}
+
+func TestResizeKeepsAspectAndNeverUpscales(t *testing.T) {
+	if b := Resize(solid(3200, 1600, color.White), Card.Width).Bounds(); b.Dx() != 640 || b.Dy() != 320 {
+		t.Fatalf("landscape = %v", b)
+	}
+	if b := Resize(solid(100, 400, color.White), Card.Width).Bounds(); b.Dx() != 100 || b.Dy() != 400 {
+		t.Fatalf("small image was resized to %v", b)
+	}
+}
+
+func TestSaveWritesEveryVariant(t *testing.T) {
+	dir := t.TempDir()
+	hero, err := Save(dir, "1_cocktail", solid(2000, 1000, color.RGBA{200, 10, 10, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hero != "1_cocktail_hero.jpg" {
+		t.Fatalf("hero = %q", hero)
+	}
+	for _, v := range Variants {
+		if _, err := os.Stat(filepath.Join(dir, "1_cocktail_"+v.Name+".jpg")); err != nil {
+			t.Fatalf("%s variant missing: %v", v.Name, err)
+		}
+	}
+
+	clear := solid(10, 10, color.RGBA{0, 0, 0, 0})
+	if hero, err := Save(dir, "2_cocktail", clear); err != nil || hero != "2_cocktail_hero.png" {
+		t.Fatalf("transparent image saved as %q, %v", hero, err)
+	}
+	f, _ := os.Open(filepath.Join(dir, "2_cocktail_thumb.png"))
+	defer f.Close()
+	if _, err := png.Decode(f); err != nil {
+		t.Fatalf("png variant unreadable: %v", err)
+	}
+}
+
+func TestVariantPathAndSrcset(t *testing.T) {
+	hero := "/uploads/1_cocktail_hero.jpg"
+	if got := VariantPath(hero, Card); got != "/uploads/1_cocktail_card.jpg" {
+		t.Fatalf("card = %q", got)
+	}
+	want := "/uploads/1_cocktail_thumb.jpg 160w, /uploads/1_cocktail_card.jpg 640w, /uploads/1_cocktail_hero.jpg 1600w"
+	if got := Srcset(hero); got != want {
+		t.Fatalf("srcset = %q", got)
+	}
+	legacy := "/uploads/123_cocktail.png"
+	if VariantPath(legacy, Card) != legacy || Srcset(legacy) != "" {
+		t.Fatalf("legacy uploads must pass through unchanged")
+	}
+}
@@ -0,0 +1,100 @@
+// Package invites issues self-registration links and renders them as QR codes.
+package invites
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"house-bartender-go/internal/db"
+
+	"rsc.io/qr"
+)
+
+// Statuses shown on the admin screen.
+const (
+	StatusActive  = "active"
+	StatusExpired = "expired"
+	StatusRevoked = "revoked"
+	StatusUsedUp  = "used up"
+)
+
+// PlaceholderDomain is the mail domain of guests who joined without a password. It is
+// reserved (RFC 2606), so the address can never collide with a real one.
+const PlaceholderDomain = "guests.invalid"
+
+// NewToken returns a random URL-safe token for an invite link.
+func NewToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PlaceholderEmail returns a unique address for a guest who joined without one. Accounts
+// still need a unique email; this one cannot be used to log in with a password.
+func PlaceholderEmail() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "guest-" + hex.EncodeToString(b) + "@" + PlaceholderDomain, nil
+}
+
+// Status reports whether the invite can still be redeemed at now, and if not, why.
+// Revoked wins over expired, which wins over used up.
+func Status(inv db.Invite, now time.Time) string {
+	switch {
+	case !inv.RevokedAt.IsZero():
+		return StatusRevoked
+	case !now.Before(inv.ExpiresAt):
+		return StatusExpired
+	case inv.MaxUses > 0 && inv.Uses >= inv.MaxUses:
+		return StatusUsedUp
+	}
+	return StatusActive
+}
+
+// URL is the public link for token under baseURL.
+func URL(baseURL, token string) string {
+	for len(baseURL) > 0 && baseURL[len(baseURL)-1] == '/' {
+		baseURL = baseURL[:len(baseURL)-1]
+	}
+	return baseURL + "/join/" + token
+}
+
+// quietZone is the blank margin, in modules, the QR spec requires around the code.
+const quietZone = 4
+
+// QRSVG encodes text as a QR code and renders it as a scalable SVG, one unit per module.
+// Medium error correction survives a smudged print-out on the bar.
+func QRSVG(text string) ([]byte, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return nil, err
+	}
+	n := code.Size + 2*quietZone
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			// merge horizontal runs into one rectangle
+			run := 1
+			for x+run < code.Size && code.Black(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+quietZone, y+quietZone, run, run)
+			x += run - 1
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
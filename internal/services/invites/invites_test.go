@@ -0,0 +1,65 @@
+package invites
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+func TestStatus(t *testing.T) {
+	now := time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)
+	open := db.Invite{ExpiresAt: now.Add(time.Hour), MaxUses: 40, Uses: 12}
+
+	cases := []struct {
+		name string
+		edit func(*db.Invite)
+		want string
+	}{
+		{"active", func(*db.Invite) {}, StatusActive},
+		{"unlimited", func(i *db.Invite) { i.MaxUses, i.Uses = 0, 500 }, StatusActive},
+		{"used up", func(i *db.Invite) { i.Uses = 40 }, StatusUsedUp},
+		{"expired at the deadline", func(i *db.Invite) { i.ExpiresAt = now }, StatusExpired},
+		{"expired beats used up", func(i *db.Invite) { i.ExpiresAt, i.Uses = now.Add(-time.Minute), 40 }, StatusExpired},
+		{"revoked beats expired", func(i *db.Invite) { i.ExpiresAt, i.RevokedAt = now.Add(-time.Minute), now }, StatusRevoked},
+	}
+	for _, c := range cases {
+		inv := open
+		c.edit(&inv)
+		if got := Status(inv, now); got != c.want {
+			t.Errorf("%s: Status = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestTokensAndPlaceholdersAreUnique(t *testing.T) {
+	a, _ := NewToken()
+	b, _ := NewToken()
+	if a == b || len(a) < 20 || strings.ContainsAny(a, "+/=") {
+		t.Fatalf("tokens %q, %q", a, b)
+	}
+	e1, _ := PlaceholderEmail()
+	e2, _ := PlaceholderEmail()
+	if e1 == e2 || !strings.HasSuffix(e1, "@"+PlaceholderDomain) {
+		t.Fatalf("placeholders %q, %q", e1, e2)
+	}
+}
+
+func TestURLAndQRSVG(t *testing.T) {
+	if got := URL("https://bar.example/", "abc"); got != "https://bar.example/join/abc" {
+		t.Fatalf("URL = %q", got)
+	}
+	svg, err := QRSVG("https://bar.example/join/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(svg)
+	if !strings.HasPrefix(s, "<svg") || !strings.HasSuffix(s, "</svg>") || !strings.Contains(s, `d="M`) {
+		t.Fatalf("unexpected svg: %.120s", s)
+	}
+	// a version 3 code is 29 modules, plus the quiet zone on both sides
+	if !strings.Contains(s, `viewBox="0 0 37 37"`) {
+		t.Fatalf("unexpected size: %.120s", s)
+	}
+}
@@ -0,0 +1,85 @@
+// Package kiosk pairs shared tablets with the bar and times the guest sessions opened on
+// them. Pairing codes and device tokens are stored only as hashes.
+package kiosk
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+const (
+	// PairLifetime is how long a new kiosk's pairing link stays usable.
+	PairLifetime = time.Hour
+
+	// GuestTTL is how long a guest picked on a kiosk stays signed in.
+	GuestTTL = 10 * time.Minute
+
+	// AfterOrderTTL is what is left of a guest session once they have ordered, enough to
+	// read the confirmation before the kiosk returns to the picker.
+	AfterOrderTTL = 30 * time.Second
+
+	// PINLength is the number of digits in a guest's kiosk PIN.
+	PINLength = 4
+)
+
+// Statuses shown on the admin screen.
+const (
+	StatusPending = "pending"
+	StatusPaired  = "paired"
+	StatusExpired = "expired"
+	StatusRevoked = "revoked"
+)
+
+// NewToken returns a random URL-safe token, used both for pairing links and for the
+// device cookie.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash is the stored form of a token.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PairURL is the link that turns the browser opening it into the kiosk.
+func PairURL(baseURL, code string) string {
+	return strings.TrimRight(baseURL, "/") + "/kiosk/pair/" + code
+}
+
+// ValidPIN reports whether pin is exactly PINLength digits.
+func ValidPIN(pin string) bool {
+	if len(pin) != PINLength {
+		return false
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Status reports where the kiosk is in its life at now. Revoked wins over everything; an
+// unpaired kiosk expires with its pairing link.
+func Status(k db.Kiosk, now time.Time) string {
+	switch {
+	case !k.RevokedAt.IsZero():
+		return StatusRevoked
+	case !k.PairedAt.IsZero():
+		return StatusPaired
+	case !now.Before(k.PairExpiresAt):
+		return StatusExpired
+	}
+	return StatusPending
+}
@@ -0,0 +1,49 @@
+package kiosk
+
+import (
+	"testing"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+func TestValidPIN(t *testing.T) {
+	for pin, want := range map[string]bool{"0420": true, "1234": true, "123": false, "12345": false, "12a4": false, "": false} {
+		if got := ValidPIN(pin); got != want {
+			t.Fatalf("ValidPIN(%q) = %v", pin, got)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	pending := db.Kiosk{PairExpiresAt: now.Add(time.Minute)}
+	if got := Status(pending, now); got != StatusPending {
+		t.Fatalf("pending = %q", got)
+	}
+	if got := Status(pending, now.Add(time.Hour)); got != StatusExpired {
+		t.Fatalf("expired = %q", got)
+	}
+	paired := db.Kiosk{PairExpiresAt: now.Add(-time.Hour), PairedAt: now.Add(-2 * time.Hour)}
+	if got := Status(paired, now); got != StatusPaired {
+		t.Fatalf("paired = %q", got)
+	}
+	paired.RevokedAt = now
+	if got := Status(paired, now); got != StatusRevoked {
+		t.Fatalf("revoked = %q", got)
+	}
+}
+
+func TestTokensAreUniqueAndHashed(t *testing.T) {
+	a, _ := NewToken()
+	b, _ := NewToken()
+	if a == b || len(a) != 43 {
+		t.Fatalf("tokens %q %q", a, b)
+	}
+	if Hash(a) == a || Hash(a) != Hash(a) || Hash(a) == Hash(b) {
+		t.Fatal("hash is not a stable one-way mapping")
+	}
+	if got := PairURL("http://bar.local/", "x"); got != "http://bar.local/kiosk/pair/x" {
+		t.Fatalf("PairURL = %q", got)
+	}
+}
@@ -0,0 +1,187 @@
+// Package library is the read model behind the cocktail library: every cocktail with its
+// ingredients and computed availability, loaded in two queries and kept in memory until the
+// inventory changes.
+package library
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+// Source loads the raw catalog; *db.Queries satisfies it.
+type Source interface {
+	ListCocktailsComputed(onlyAvailable bool) ([]db.Cocktail, error)
+	ListAllCocktailIngredients() ([]db.CocktailIngredient, error)
+}
+
+type Item struct {
+	Cocktail    db.Cocktail
+	Ingredients []db.CocktailIngredient
+}
+
+// Snapshot is an immutable view of the catalog; callers must not modify what it returns.
+type Snapshot struct {
+	LoadedAt time.Time
+	items    []Item // by name
+	byID     map[int64]int
+}
+
+// Build groups ingredients under their cocktails. Cocktails keep the order they were given in.
+func Build(cocktails []db.Cocktail, ingredients []db.CocktailIngredient) *Snapshot {
+	s := &Snapshot{
+		LoadedAt: time.Now(),
+		items:    make([]Item, len(cocktails)),
+		byID:     make(map[int64]int, len(cocktails)),
+	}
+	for i, c := range cocktails {
+		s.items[i].Cocktail = c
+		s.byID[c.ID] = i
+	}
+	for _, ing := range ingredients {
+		if i, ok := s.byID[ing.CocktailID]; ok {
+			s.items[i].Ingredients = append(s.items[i].Ingredients, ing)
+		}
+	}
+	return s
+}
+
+func (s *Snapshot) Len() int { return len(s.items) }
+
+// Items lists every cocktail in name order.
+func (s *Snapshot) Items() []Item { return s.items }
+
+func (s *Snapshot) Get(id int64) (Item, bool) {
+	i, ok := s.byID[id]
+	if !ok {
+		return Item{}, false
+	}
+	return s.items[i], true
+}
+
+// Query selects a page of the library. Searches are ranked and paged by the database; see
+// IDs and Cocktails.
+type Query struct {
+	SpiritTokens  []string // any of these in the cocktail or its ingredients
+	OnlyAvailable bool
+	Limit         int // 0 means no limit
+	Offset        int
+}
+
+func (s *Snapshot) matching(q Query) []db.Cocktail {
+	matched := make([]db.Cocktail, 0, len(s.items))
+	for _, it := range s.items {
+		if q.OnlyAvailable && !it.Cocktail.ComputedAvail {
+			continue
+		}
+		if !matchesSpirit(it, q.SpiritTokens) {
+			continue
+		}
+		matched = append(matched, it.Cocktail)
+	}
+	return matched
+}
+
+// Find returns the requested page, in name order, and the number of cocktails matching in
+// total.
+func (s *Snapshot) Find(q Query) ([]db.Cocktail, int) {
+	matched := s.matching(q)
+	total := len(matched)
+	start := min(max(q.Offset, 0), total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+	return matched[start:end], total
+}
+
+// IDs lists the cocktails that pass q's filters, ignoring its paging, to limit a search to.
+func (s *Snapshot) IDs(q Query) []int64 {
+	matched := s.matching(q)
+	ids := make([]int64, len(matched))
+	for i, c := range matched {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// Cocktails returns the cocktails with the given IDs, in that order, skipping unknown ones.
+func (s *Snapshot) Cocktails(ids []int64) []db.Cocktail {
+	out := make([]db.Cocktail, 0, len(ids))
+	for _, id := range ids {
+		if it, ok := s.Get(id); ok {
+			out = append(out, it.Cocktail)
+		}
+	}
+	return out
+}
+
+func matchesSpirit(it Item, tokens []string) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+
+	c := it.Cocktail
+	text := strings.ToLower(strings.Join([]string{c.Name, c.Description, c.Tags}, " "))
+	for _, token := range tokens {
+		if strings.Contains(text, token) {
+			return true
+		}
+	}
+	for _, ing := range it.Ingredients {
+		ingredientText := strings.ToLower(ing.ProductName + " " + ing.ProductCategory)
+		for _, token := range tokens {
+			if strings.Contains(ingredientText, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Cache holds the current Snapshot. Concurrent readers after an invalidation share a single
+// reload instead of each querying the database.
+type Cache struct {
+	src  Source
+	mu   sync.RWMutex
+	snap *Snapshot
+}
+
+func NewCache(src Source) *Cache {
+	return &Cache{src: src}
+}
+
+func (c *Cache) Snapshot() (*Snapshot, error) {
+	c.mu.RLock()
+	snap := c.snap
+	c.mu.RUnlock()
+	if snap != nil {
+		return snap, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.snap != nil {
+		return c.snap, nil
+	}
+	cocktails, err := c.src.ListCocktailsComputed(false)
+	if err != nil {
+		return nil, err
+	}
+	ingredients, err := c.src.ListAllCocktailIngredients()
+	if err != nil {
+		return nil, err
+	}
+	c.snap = Build(cocktails, ingredients)
+	return c.snap, nil
+}
+
+// Invalidate drops the snapshot so the next reader reloads it. Call it after any write that
+// can change cocktails, ingredients or stock.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	c.snap = nil
+	c.mu.Unlock()
+}
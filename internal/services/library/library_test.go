@@ -0,0 +1,237 @@
+package library
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"house-bartender-go/internal/db"
+)
+
+type fakeSource struct {
+	cocktails   []db.Cocktail
+	ingredients []db.CocktailIngredient
+	loads       atomic.Int64
+}
+
+func (f *fakeSource) ListCocktailsComputed(bool) ([]db.Cocktail, error) {
+	f.loads.Add(1)
+	return f.cocktails, nil
+}
+
+func (f *fakeSource) ListAllCocktailIngredients() ([]db.CocktailIngredient, error) {
+	return f.ingredients, nil
+}
+
+func sampleSource() *fakeSource {
+	return &fakeSource{
+		cocktails: []db.Cocktail{
+			{ID: 3, Name: "Daiquiri", ComputedAvail: true},
+			{ID: 1, Name: "Gin Tonic", ComputedAvail: true},
+			{ID: 2, Name: "Mojito", ComputedAvail: false},
+			{ID: 4, Name: "Whiskey Sour", Tags: "whiskey", ComputedAvail: true},
+		},
+		ingredients: []db.CocktailIngredient{
+			{CocktailID: 1, ProductName: "Gin", ProductCategory: "Spirit"},
+			{CocktailID: 1, ProductName: "Tonic", ProductCategory: "Mixer"},
+			{CocktailID: 2, ProductName: "White Rum", ProductCategory: "Spirit"},
+			{CocktailID: 3, ProductName: "Rum", ProductCategory: "Spirit"},
+			{CocktailID: 99, ProductName: "Orphan"},
+		},
+	}
+}
+
+func names(cs []db.Cocktail) []string {
+	out := make([]string, 0, len(cs))
+	for _, c := range cs {
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+func TestBuildGroupsIngredientsByCocktail(t *testing.T) {
+	src := sampleSource()
+	snap := Build(src.cocktails, src.ingredients)
+
+	it, ok := snap.Get(1)
+	if !ok || len(it.Ingredients) != 2 || it.Ingredients[1].ProductName != "Tonic" {
+		t.Fatalf("Get(1) = %+v, %v", it, ok)
+	}
+	if _, ok := snap.Get(99); ok {
+		t.Fatalf("ingredients of unknown cocktails must not create entries")
+	}
+}
+
+func TestFindFiltersAndPages(t *testing.T) {
+	src := sampleSource()
+	snap := Build(src.cocktails, src.ingredients)
+
+	page, total := snap.Find(Query{OnlyAvailable: true, Limit: 2})
+	if total != 3 || fmt.Sprint(names(page)) != "[Daiquiri Gin Tonic]" {
+		t.Fatalf("first page = %v of %d", names(page), total)
+	}
+	page, _ = snap.Find(Query{OnlyAvailable: true, Limit: 2, Offset: 2})
+	if fmt.Sprint(names(page)) != "[Whiskey Sour]" {
+		t.Fatalf("second page = %v", names(page))
+	}
+	page, total = snap.Find(Query{Offset: 10})
+	if total != 4 || len(page) != 0 {
+		t.Fatalf("past the end = %v of %d", names(page), total)
+	}
+
+	page, _ = snap.Find(Query{SpiritTokens: []string{"rum"}})
+	if fmt.Sprint(names(page)) != "[Daiquiri Mojito]" {
+		t.Fatalf("rum = %v", names(page))
+	}
+}
+
+func TestIDsAndCocktailsForSearch(t *testing.T) {
+	src := sampleSource()
+	snap := Build(src.cocktails, src.ingredients)
+
+	if ids := snap.IDs(Query{OnlyAvailable: true, Limit: 1, Offset: 1}); len(ids) != 3 {
+		t.Fatalf("IDs must ignore paging, got %v", ids)
+	}
+	if ids := snap.IDs(Query{SpiritTokens: []string{"rum"}}); fmt.Sprint(names(snap.Cocktails(ids))) != "[Daiquiri Mojito]" {
+		t.Fatalf("rum = %v", ids)
+	}
+	if page := snap.Cocktails([]int64{4, 99, 3}); fmt.Sprint(names(page)) != "[Whiskey Sour Daiquiri]" {
+		t.Fatalf("ranked = %v", names(page))
+	}
+}
+
+func TestCacheSharesOneLoadAcrossClients(t *testing.T) {
+	src := sampleSource()
+	cache := NewCache(src)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Snapshot(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := src.loads.Load(); n != 1 {
+		t.Fatalf("50 clients caused %d loads, want 1", n)
+	}
+
+	cache.Invalidate()
+	if _, err := cache.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if n := src.loads.Load(); n != 2 {
+		t.Fatalf("loads after invalidation = %d, want 2", n)
+	}
+}
+
+/* ---------------- Benchmarks ---------------- */
+
+// One iteration is one inventory change: the cache is invalidated and 50 connected clients
+// refresh the first library page, as they do on every inventory:updated event.
+
+const (
+	benchCocktails = 200
+	benchClients   = 50
+)
+
+func benchStore(b *testing.B) *db.Store {
+	b.Helper()
+	store, err := db.Open(filepath.Join(b.TempDir(), "bench.sqlite"), db.Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = store.Close() })
+	if err := db.Migrate(store.DB); err != nil {
+		b.Fatal(err)
+	}
+
+	var products []int64
+	for i := 0; i < 40; i++ {
+		stock := int64(i % 5)
+		id, err := store.Q.CreateProduct(db.CreateProductParams{Name: fmt.Sprintf("Product %02d", i), Category: "Spirit", IsAvailable: true, StockCount: &stock})
+		if err != nil {
+			b.Fatal(err)
+		}
+		products = append(products, id)
+	}
+	for i := 0; i < benchCocktails; i++ {
+		id, err := store.Q.CreateCocktail(db.CreateCocktailParams{Name: fmt.Sprintf("Cocktail %03d", i), Difficulty: "easy", IsEnabled: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+		var items []db.IngredientUpsertItem
+		for j := 0; j < 4; j++ {
+			items = append(items, db.IngredientUpsertItem{ProductID: products[(i+j*7)%len(products)], Required: j < 3})
+		}
+		if err := store.Q.ReplaceCocktailIngredients(id, items); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return store
+}
+
+// BenchmarkRefreshPerCocktailQueries is the old render path: every client lists the cocktails
+// and then queries each one's ingredients.
+func BenchmarkRefreshPerCocktailQueries(b *testing.B) {
+	store := benchStore(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for c := 0; c < benchClients; c++ {
+			cocktails, err := store.Q.ListCocktailsComputed(false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, ck := range cocktails {
+				if _, err := store.Q.GetCocktailIngredients(ck.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkRefreshBatchedUncached loads the read model per client without sharing it.
+func BenchmarkRefreshBatchedUncached(b *testing.B) {
+	store := benchStore(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for c := 0; c < benchClients; c++ {
+			cache := NewCache(store.Q)
+			snap, err := cache.Snapshot()
+			if err != nil {
+				b.Fatal(err)
+			}
+			snap.Find(Query{Limit: 10})
+		}
+	}
+}
+
+// BenchmarkRefreshCached is the current path: one reload shared by all clients.
+func BenchmarkRefreshCached(b *testing.B) {
+	store := benchStore(b)
+	cache := NewCache(store.Q)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Invalidate()
+		var wg sync.WaitGroup
+		for c := 0; c < benchClients; c++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				snap, err := cache.Snapshot()
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				snap.Find(Query{Limit: 10})
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,223 @@
+package makeable
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"house-bartender-go/internal/db"
+)
+
+type Product struct {
+	ID       int64
+	Name     string
+	Category string
+}
+
+type Cocktail struct {
+	ID         int64
+	Name       string
+	Enabled    bool
+	Popularity int64
+	Missing    []Product
+}
+
+// Unlock describes what buying a single product would make orderable again.
+type Unlock struct {
+	Product    Product
+	Cocktails  []Cocktail
+	Popularity int64
+}
+
+type ShoppingItem struct {
+	Product Product
+	// NeededBy lists the near-miss cocktails that are missing this product.
+	NeededBy []Cocktail
+	// Unlocks counts cocktails for which this is the only missing product.
+	Unlocks int
+	// Weight ranks the list: each cocktail spreads (1 + drinks ordered) over its missing products.
+	Weight float64
+}
+
+type Report struct {
+	Makeable      []Cocktail
+	ReadyToEnable []Cocktail
+	NearMisses    []Cocktail
+	Unlocks       []Unlock
+	ShoppingList  []ShoppingItem
+}
+
+// BestUnlock is the single purchase that makes the most cocktails makeable, if any.
+func (r Report) BestUnlock() *Unlock {
+	if len(r.Unlocks) == 0 {
+		return nil
+	}
+	return &r.Unlocks[0]
+}
+
+// Analyze groups cocktails by what stands between them and the queue. Near misses are
+// ranked by fewest missing required ingredients, then by historical popularity.
+func Analyze(reqs []db.CocktailRequirement, popularity map[int64]int64) Report {
+	byID := map[int64]*Cocktail{}
+	var order []int64
+	for _, it := range reqs {
+		c, ok := byID[it.CocktailID]
+		if !ok {
+			c = &Cocktail{
+				ID:         it.CocktailID,
+				Name:       it.CocktailName,
+				Enabled:    it.CocktailEnabled,
+				Popularity: popularity[it.CocktailID],
+			}
+			byID[it.CocktailID] = c
+			order = append(order, it.CocktailID)
+		}
+		if it.ProductID != nil && !it.ProductAvail {
+			c.Missing = append(c.Missing, Product{ID: *it.ProductID, Name: it.ProductName, Category: it.ProductCategory})
+		}
+	}
+
+	var rep Report
+	for _, id := range order {
+		c := *byID[id]
+		switch {
+		case len(c.Missing) > 0:
+			rep.NearMisses = append(rep.NearMisses, c)
+		case c.Enabled:
+			rep.Makeable = append(rep.Makeable, c)
+		default:
+			rep.ReadyToEnable = append(rep.ReadyToEnable, c)
+		}
+	}
+
+	sort.SliceStable(rep.NearMisses, func(i, j int) bool {
+		a, b := rep.NearMisses[i], rep.NearMisses[j]
+		if len(a.Missing) != len(b.Missing) {
+			return len(a.Missing) < len(b.Missing)
+		}
+		if a.Popularity != b.Popularity {
+			return a.Popularity > b.Popularity
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	sortByPopularity(rep.ReadyToEnable)
+
+	unlocks := map[int64]*Unlock{}
+	items := map[int64]*ShoppingItem{}
+	for _, c := range rep.NearMisses {
+		share := float64(1+c.Popularity) / float64(len(c.Missing))
+		for _, p := range c.Missing {
+			it, ok := items[p.ID]
+			if !ok {
+				it = &ShoppingItem{Product: p}
+				items[p.ID] = it
+			}
+			it.NeededBy = append(it.NeededBy, c)
+			it.Weight += share
+		}
+		if len(c.Missing) == 1 {
+			p := c.Missing[0]
+			u, ok := unlocks[p.ID]
+			if !ok {
+				u = &Unlock{Product: p}
+				unlocks[p.ID] = u
+			}
+			u.Cocktails = append(u.Cocktails, c)
+			u.Popularity += c.Popularity
+			items[p.ID].Unlocks++
+		}
+	}
+
+	for _, u := range unlocks {
+		rep.Unlocks = append(rep.Unlocks, *u)
+	}
+	sort.Slice(rep.Unlocks, func(i, j int) bool {
+		a, b := rep.Unlocks[i], rep.Unlocks[j]
+		if len(a.Cocktails) != len(b.Cocktails) {
+			return len(a.Cocktails) > len(b.Cocktails)
+		}
+		if a.Popularity != b.Popularity {
+			return a.Popularity > b.Popularity
+		}
+		return strings.ToLower(a.Product.Name) < strings.ToLower(b.Product.Name)
+	})
+
+	for _, it := range items {
+		rep.ShoppingList = append(rep.ShoppingList, *it)
+	}
+	sort.Slice(rep.ShoppingList, func(i, j int) bool {
+		a, b := rep.ShoppingList[i], rep.ShoppingList[j]
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return strings.ToLower(a.Product.Name) < strings.ToLower(b.Product.Name)
+	})
+	return rep
+}
+
+func sortByPopularity(items []Cocktail) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Popularity != items[j].Popularity {
+			return items[i].Popularity > items[j].Popularity
+		}
+		return strings.ToLower(items[i].Name) < strings.ToLower(items[j].Name)
+	})
+}
+
+func cocktailNames(items []Cocktail) []string {
+	names := make([]string, 0, len(items))
+	for _, c := range items {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// WriteText renders the shopping list as a plain checklist.
+func WriteText(w io.Writer, items []ShoppingItem) error {
+	if _, err := fmt.Fprintln(w, "Shopping list"); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		_, err := fmt.Fprintln(w, "Nothing missing - every cocktail is makeable.")
+		return err
+	}
+	for _, it := range items {
+		line := "[ ] " + it.Product.Name
+		if it.Product.Category != "" {
+			line += " (" + it.Product.Category + ")"
+		}
+		line += " - for " + strings.Join(cocktailNames(it.NeededBy), ", ")
+		if it.Unlocks > 0 {
+			line += fmt.Sprintf("; unlocks %d", it.Unlocks)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV renders the shopping list with one row per product.
+func WriteCSV(w io.Writer, items []ShoppingItem) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"product", "category", "needed_by", "unlocks", "weight", "cocktails"}); err != nil {
+		return err
+	}
+	for _, it := range items {
+		if err := cw.Write([]string{
+			it.Product.Name,
+			it.Product.Category,
+			strconv.Itoa(len(it.NeededBy)),
+			strconv.Itoa(it.Unlocks),
+			strconv.FormatFloat(it.Weight, 'f', 2, 64),
+			strings.Join(cocktailNames(it.NeededBy), "; "),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,136 @@
+package makeable
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"house-bartender-go/internal/db"
+)
+
+type reqBuilder struct {
+	items []db.CocktailRequirement
+}
+
+func (b *reqBuilder) cocktail(id int64, name string, enabled bool, products ...db.CocktailRequirement) {
+	if len(products) == 0 {
+		b.items = append(b.items, db.CocktailRequirement{CocktailID: id, CocktailName: name, CocktailEnabled: enabled, ProductAvail: true})
+		return
+	}
+	for _, p := range products {
+		p.CocktailID = id
+		p.CocktailName = name
+		p.CocktailEnabled = enabled
+		b.items = append(b.items, p)
+	}
+}
+
+func product(id int64, name string, avail bool) db.CocktailRequirement {
+	return db.CocktailRequirement{ProductID: &id, ProductName: name, ProductAvail: avail}
+}
+
+func sampleRequirements() []db.CocktailRequirement {
+	var b reqBuilder
+	b.cocktail(1, "Mojito", true, product(10, "Rum", true), product(11, "Mint", false))
+	b.cocktail(2, "Daiquiri", true, product(10, "Rum", true), product(12, "Lime", false))
+	b.cocktail(3, "Caipirinha", true, product(13, "Cachaca", false), product(12, "Lime", false))
+	b.cocktail(4, "Gimlet", true, product(14, "Gin", true), product(12, "Lime", false))
+	b.cocktail(5, "Gin Tonic", true, product(14, "Gin", true))
+	b.cocktail(6, "Negroni", false, product(14, "Gin", true))
+	b.cocktail(7, "Water", true)
+	return b.items
+}
+
+func TestAnalyzeGroupsAndRanksNearMisses(t *testing.T) {
+	rep := Analyze(sampleRequirements(), map[int64]int64{1: 9, 2: 1, 4: 3})
+
+	if got := cocktailNames(rep.Makeable); strings.Join(got, ",") != "Gin Tonic,Water" {
+		t.Fatalf("Makeable = %v", got)
+	}
+	if got := cocktailNames(rep.ReadyToEnable); strings.Join(got, ",") != "Negroni" {
+		t.Fatalf("ReadyToEnable = %v", got)
+	}
+	// one missing ingredient first (by popularity), then two missing
+	if got := cocktailNames(rep.NearMisses); strings.Join(got, ",") != "Mojito,Gimlet,Daiquiri,Caipirinha" {
+		t.Fatalf("NearMisses = %v", got)
+	}
+}
+
+func TestAnalyzeBestUnlockCountsSingleMissingProduct(t *testing.T) {
+	rep := Analyze(sampleRequirements(), map[int64]int64{1: 9, 2: 1, 4: 3})
+
+	best := rep.BestUnlock()
+	if best == nil {
+		t.Fatal("BestUnlock() = nil")
+	}
+	if best.Product.Name != "Lime" || len(best.Cocktails) != 2 {
+		t.Fatalf("BestUnlock() = %s unlocking %d, want Lime unlocking 2", best.Product.Name, len(best.Cocktails))
+	}
+	if best.Popularity != 4 {
+		t.Fatalf("BestUnlock().Popularity = %d, want 4", best.Popularity)
+	}
+}
+
+func TestAnalyzeShoppingListWeightedByPopularity(t *testing.T) {
+	rep := Analyze(sampleRequirements(), map[int64]int64{1: 9, 2: 1, 4: 3})
+
+	if len(rep.ShoppingList) != 3 {
+		t.Fatalf("len(ShoppingList) = %d, want 3", len(rep.ShoppingList))
+	}
+	// Mint: 10 (Mojito). Lime: 2 + 4 + 0.5 = 6.5. Cachaca: 0.5.
+	want := []string{"Mint", "Lime", "Cachaca"}
+	for i, name := range want {
+		if rep.ShoppingList[i].Product.Name != name {
+			t.Fatalf("ShoppingList[%d] = %s, want %s", i, rep.ShoppingList[i].Product.Name, name)
+		}
+	}
+	lime := rep.ShoppingList[1]
+	if lime.Weight != 6.5 || lime.Unlocks != 2 || len(lime.NeededBy) != 3 {
+		t.Fatalf("Lime item = weight %.2f unlocks %d needed by %d", lime.Weight, lime.Unlocks, len(lime.NeededBy))
+	}
+}
+
+func TestAnalyzeWithNothingMissing(t *testing.T) {
+	var b reqBuilder
+	b.cocktail(1, "Gin Tonic", true, product(14, "Gin", true))
+
+	rep := Analyze(b.items, nil)
+	if rep.BestUnlock() != nil || len(rep.ShoppingList) != 0 || len(rep.NearMisses) != 0 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, rep.ShoppingList); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Nothing missing") {
+		t.Fatalf("WriteText() = %q", buf.String())
+	}
+}
+
+func TestShoppingListExports(t *testing.T) {
+	rep := Analyze(sampleRequirements(), map[int64]int64{1: 9, 2: 1, 4: 3})
+
+	var txt bytes.Buffer
+	if err := WriteText(&txt, rep.ShoppingList); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(txt.String(), "[ ] Lime - for Gimlet, Daiquiri, Caipirinha; unlocks 2") {
+		t.Fatalf("WriteText() = %q", txt.String())
+	}
+
+	var out bytes.Buffer
+	if err := WriteCSV(&out, rep.ShoppingList); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("WriteCSV() lines = %d, want 4", len(lines))
+	}
+	if lines[0] != "product,category,needed_by,unlocks,weight,cocktails" {
+		t.Fatalf("header = %q", lines[0])
+	}
+	if lines[1] != "Mint,,1,1,10.00,Mojito" {
+		t.Fatalf("first row = %q", lines[1])
+	}
+}
@@ -0,0 +1,231 @@
+// Package media tracks uploaded images and garbage-collects the files no cocktail uses.
+package media
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/images"
+)
+
+// URLPrefix is how stored image paths refer to the upload directory.
+const URLPrefix = "/uploads/"
+
+type Repository interface {
+	ListCocktailImagePaths() ([]string, error)
+	ListMedia() ([]db.Media, error)
+	DeleteMediaByPath(path string) error
+}
+
+type Config struct {
+	Dir string // upload directory on disk
+	// Grace keeps unreferenced files this long after the last cocktail stopped using them,
+	// or after they were written if none ever did, so an image removed from one cocktail
+	// can still be picked for another before it is collected.
+	Grace time.Duration
+}
+
+// Result is what one collection did.
+type Result struct {
+	At        time.Time
+	Removed   int   // files deleted
+	Reclaimed int64 // bytes freed
+	Forgotten int   // library entries dropped because their files are gone
+	Err       string
+}
+
+type Collector struct {
+	repo Repository
+	log  *slog.Logger
+	cfg  Config
+
+	run  sync.Mutex // one collection at a time
+	mu   sync.Mutex
+	last Result
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewCollector(repo Repository, logger *slog.Logger, cfg Config) *Collector {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Collector{repo: repo, log: logger, cfg: cfg}
+}
+
+// Start collects every interval until Stop is called.
+func (c *Collector) Start(every time.Duration) {
+	if every <= 0 || c.stop != nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-t.C:
+				if _, err := c.Run(time.Now()); err != nil {
+					c.log.Warn("media gc failed", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+func (c *Collector) Stop() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	c.wg.Wait()
+	c.stop = nil
+}
+
+// Last reports the most recent collection; At is zero if none has run.
+func (c *Collector) Last() Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+func (c *Collector) Grace() time.Duration { return c.cfg.Grace }
+
+// Run deletes files in the upload directory that no cocktail has referenced for the grace
+// period, then drops library entries whose files no longer exist. Nothing is deleted if the
+// references cannot be loaded.
+func (c *Collector) Run(now time.Time) (Result, error) {
+	c.run.Lock()
+	defer c.run.Unlock()
+
+	res, err := c.collect(now)
+	if err != nil {
+		res.Err = err.Error()
+	}
+	c.mu.Lock()
+	c.last = res
+	c.mu.Unlock()
+	if res.Removed > 0 || res.Forgotten > 0 {
+		c.log.Info("media gc", "removed", res.Removed, "reclaimed_bytes", res.Reclaimed, "forgotten", res.Forgotten)
+	}
+	return res, err
+}
+
+func (c *Collector) collect(now time.Time) (Result, error) {
+	res := Result{At: now}
+	if strings.TrimSpace(c.cfg.Dir) == "" {
+		return res, errors.New("media gc: no upload directory configured")
+	}
+	refs, err := c.repo.ListCocktailImagePaths()
+	if err != nil {
+		return res, err
+	}
+	keep := map[string]bool{}
+	for _, ref := range refs {
+		for _, name := range Files(ref) {
+			keep[name] = true
+		}
+	}
+
+	library, err := c.repo.ListMedia()
+	if err != nil {
+		return res, err
+	}
+	unreferenced := map[string]time.Time{}
+	for _, m := range library {
+		for _, name := range Files(m.Path) {
+			unreferenced[name] = m.UnreferencedAt
+		}
+	}
+
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return res, nil
+		}
+		return res, err
+	}
+	cutoff := now.Add(-c.cfg.Grace)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || keep[name] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		// the grace period runs from the write or the last use, whichever came later
+		if info.ModTime().After(cutoff) || unreferenced[name].After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.cfg.Dir, name)); err != nil {
+			c.log.Warn("media gc: remove failed", "file", name, "err", err)
+			continue
+		}
+		res.Removed++
+		res.Reclaimed += info.Size()
+	}
+
+	for _, m := range library {
+		if m.RefCount > 0 || c.exists(m.Path) {
+			continue
+		}
+		if err := c.repo.DeleteMediaByPath(m.Path); err != nil {
+			return res, err
+		}
+		res.Forgotten++
+	}
+	return res, nil
+}
+
+func (c *Collector) exists(p string) bool {
+	files := Files(p)
+	if len(files) == 0 {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(c.cfg.Dir, files[0]))
+	return err == nil
+}
+
+// Files lists the file names in the upload directory that back a stored image path: every
+// variant of a re-encoded upload, or the single file of an older one. Paths outside the
+// upload directory have none.
+func Files(p string) []string {
+	if !strings.HasPrefix(p, URLPrefix) {
+		return nil
+	}
+	if images.Srcset(p) == "" {
+		return []string{path.Base(p)}
+	}
+	out := []string{path.Base(p)}
+	for _, v := range images.Variants {
+		if name := path.Base(images.VariantPath(p, v)); name != out[0] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// Size sums the bytes on disk of every file behind a stored image path.
+func Size(dir, p string) int64 {
+	var n int64
+	for _, name := range Files(p) {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			n += info.Size()
+		}
+	}
+	return n
+}
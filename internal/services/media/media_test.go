@@ -0,0 +1,145 @@
+package media
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+type fakeRepo struct {
+	refs    []string
+	refsErr error
+	media   []db.Media
+	deleted []string
+}
+
+func (f *fakeRepo) ListCocktailImagePaths() ([]string, error) { return f.refs, f.refsErr }
+func (f *fakeRepo) ListMedia() ([]db.Media, error)            { return f.media, nil }
+func (f *fakeRepo) DeleteMediaByPath(p string) error {
+	f.deleted = append(f.deleted, p)
+	return nil
+}
+
+func touch(t *testing.T, dir, name string, size int, age time.Duration) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mt := time.Now().Add(-age)
+	if err := os.Chtimes(p, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Name())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestFilesCoversEveryVariant(t *testing.T) {
+	got := Files("/uploads/1_cocktail_hero.jpg")
+	if len(got) != 3 || got[0] != "1_cocktail_hero.jpg" {
+		t.Fatalf("Files(hero) = %v", got)
+	}
+	if got := Files("/uploads/9_cocktail.png"); len(got) != 1 || got[0] != "9_cocktail.png" {
+		t.Fatalf("Files(legacy) = %v", got)
+	}
+	if got := Files("/media/art/cocktails/negroni.svg"); got != nil {
+		t.Fatalf("bundled art must not map to uploads, got %v", got)
+	}
+}
+
+func TestRunRemovesOnlyOldUnreferencedFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := 48 * time.Hour
+	for _, v := range []string{"hero", "card", "thumb"} {
+		touch(t, dir, "1_cocktail_"+v+".jpg", 100, old) // referenced
+		touch(t, dir, "2_cocktail_"+v+".jpg", 100, old) // orphaned
+		touch(t, dir, "3_cocktail_"+v+".jpg", 100, time.Hour)
+	}
+	touch(t, dir, "4_cocktail.png", 50, old) // legacy, referenced
+	touch(t, dir, "5_cocktail.png", 50, old) // legacy, orphaned
+	touch(t, dir, ".gitkeep", 0, old)
+
+	repo := &fakeRepo{
+		refs: []string{"/uploads/1_cocktail_hero.jpg", "/uploads/4_cocktail.png"},
+		media: []db.Media{
+			{Path: "/uploads/1_cocktail_hero.jpg", RefCount: 1},
+			{Path: "/uploads/2_cocktail_hero.jpg"},
+			{Path: "/uploads/3_cocktail_hero.jpg"},
+		},
+	}
+	c := NewCollector(repo, nil, Config{Dir: dir, Grace: 24 * time.Hour})
+	res, err := c.Run(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 4 || res.Reclaimed != 350 {
+		t.Fatalf("removed %d files, %d bytes; want 4, 350", res.Removed, res.Reclaimed)
+	}
+	want := []string{".gitkeep",
+		"1_cocktail_card.jpg", "1_cocktail_hero.jpg", "1_cocktail_thumb.jpg",
+		"3_cocktail_card.jpg", "3_cocktail_hero.jpg", "3_cocktail_thumb.jpg",
+		"4_cocktail.png"}
+	if got := listDir(t, dir); len(got) != len(want) {
+		t.Fatalf("left %v, want %v", got, want)
+	}
+	if res.Forgotten != 1 || len(repo.deleted) != 1 || repo.deleted[0] != "/uploads/2_cocktail_hero.jpg" {
+		t.Fatalf("forgotten %v", repo.deleted)
+	}
+	if c.Last().Removed != 4 {
+		t.Fatalf("Last() = %+v", c.Last())
+	}
+}
+
+func TestRunKeepsOldFilesUnusedOnlyRecently(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	touch(t, dir, "1_cocktail.png", 10, 30*24*time.Hour) // dropped an hour ago
+	touch(t, dir, "2_cocktail.png", 10, 30*24*time.Hour) // dropped two days ago
+	repo := &fakeRepo{
+		refs: []string{"/uploads/other.png"},
+		media: []db.Media{
+			{Path: "/uploads/1_cocktail.png", UnreferencedAt: now.Add(-time.Hour)},
+			{Path: "/uploads/2_cocktail.png", UnreferencedAt: now.Add(-48 * time.Hour)},
+		},
+	}
+	c := NewCollector(repo, nil, Config{Dir: dir, Grace: 24 * time.Hour})
+	if _, err := c.Run(now); err != nil {
+		t.Fatal(err)
+	}
+	if got := listDir(t, dir); len(got) != 1 || got[0] != "1_cocktail.png" {
+		t.Fatalf("left %v, want only the image dropped an hour ago", got)
+	}
+}
+
+func TestRunDeletesNothingWithoutReferences(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir, "1_cocktail_hero.jpg", 10, 48*time.Hour)
+	repo := &fakeRepo{refsErr: errors.New("database is locked")}
+	c := NewCollector(repo, nil, Config{Dir: dir, Grace: time.Hour})
+	if _, err := c.Run(time.Now()); err == nil {
+		t.Fatal("expected the reference error")
+	}
+	if got := listDir(t, dir); len(got) != 1 {
+		t.Fatalf("files were removed: %v", got)
+	}
+	if c.Last().Err == "" {
+		t.Fatal("the failure should be recorded")
+	}
+}
@@ -0,0 +1,17 @@
+// Package names tidies the short labels people type in: guest names, table labels and
+// station names.
+package names
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Clean drops surrounding and repeated spaces from s and cuts it to max characters.
+func Clean(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:max]))
+}
@@ -0,0 +1,18 @@
+package names
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	if got := Clean("  Beer   &  Wine \n", 40); got != "Beer & Wine" {
+		t.Fatalf("Clean = %q", got)
+	}
+	if got := Clean(strings.Repeat("ä", 45), 40); got != strings.Repeat("ä", 40) {
+		t.Fatalf("Clean kept %d characters", len([]rune(got)))
+	}
+	if got := Clean("ab  cd", 3); got != "ab" {
+		t.Fatalf("Clean left trailing space: %q", got)
+	}
+}
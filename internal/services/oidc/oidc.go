@@ -0,0 +1,414 @@
+// Package oidc signs users in through an OpenID Connect provider using the authorization
+// code flow with PKCE, and maps a claim from the provider onto House Bartender roles.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients; PKCE protects the exchange either way
+	RedirectURL  string
+	Scopes       []string // "openid" is always requested
+
+	// RoleClaim names the claim (string or list of strings) RoleMap is matched against,
+	// e.g. "groups".
+	RoleClaim string
+	RoleMap   []RoleRule
+
+	HTTPClient *http.Client
+}
+
+// RoleRule maps one value of the role claim to a role name.
+type RoleRule struct {
+	Value string
+	Role  string
+}
+
+// ParseRoleMap reads "bar-admins=ADMIN,bar-staff=BARTENDER". Earlier rules win when a
+// user matches several.
+func ParseRoleMap(s string) ([]RoleRule, error) {
+	var out []RoleRule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		value, role, ok := strings.Cut(part, "=")
+		value, role = strings.TrimSpace(value), strings.ToUpper(strings.TrimSpace(role))
+		if !ok || value == "" || role == "" {
+			return nil, fmt.Errorf("role map entry %q: want claim-value=ROLE", part)
+		}
+		out = append(out, RoleRule{Value: value, Role: role})
+	}
+	return out, nil
+}
+
+// Identity is what the provider vouched for.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	RoleValues    []string // values of the configured role claim
+}
+
+// DisplayName picks the friendliest name the provider sent.
+func (id Identity) DisplayName() string {
+	if id.Name != "" {
+		return id.Name
+	}
+	if i := strings.IndexByte(id.Email, '@'); i > 0 {
+		return id.Email[:i]
+	}
+	return id.Subject
+}
+
+// metadata is the part of the discovery document the flow needs.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jwksRefetch limits how often an unknown key id triggers a JWKS download.
+const jwksRefetch = time.Minute
+
+type Provider struct {
+	cfg Config
+	hc  *http.Client
+
+	mu        sync.Mutex
+	meta      *metadata
+	keys      map[string]any
+	keysFetch time.Time
+}
+
+func New(cfg Config) *Provider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	hc := cfg.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{cfg: cfg, hc: hc}
+}
+
+/* ---------------- Flow values ---------------- */
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Flow is the per-login state kept by the browser between the redirect to the provider
+// and the callback.
+type Flow struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+}
+
+func NewFlow() (Flow, error) {
+	var f Flow
+	var err error
+	if f.State, err = randomString(16); err != nil {
+		return f, err
+	}
+	if f.Nonce, err = randomString(16); err != nil {
+		return f, err
+	}
+	f.Verifier, err = randomString(32)
+	return f, err
+}
+
+// Challenge is the S256 PKCE challenge for the flow's verifier.
+func (f Flow) Challenge() string {
+	sum := sha256.Sum256([]byte(f.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+/* ---------------- Discovery ---------------- */
+
+func (p *Provider) getJSON(ctx context.Context, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// discover loads the provider metadata once; a failure is retried on the next login.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var m metadata
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &m); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(m.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", m.Issuer, p.cfg.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete metadata")
+	}
+	p.meta = &m
+	return p.meta, nil
+}
+
+// AuthURL is where to send the browser to start the login.
+func (p *Provider) AuthURL(ctx context.Context, f Flow) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := []string{"openid"}
+	for _, s := range p.cfg.Scopes {
+		if s != "openid" && s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {f.State},
+		"nonce":                 {f.Nonce},
+		"code_challenge":        {f.Challenge()},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+/* ---------------- Code exchange ---------------- */
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	ErrorDesc   string `json:"error_description"`
+}
+
+// Exchange trades the callback code for tokens, verifies the ID token against the flow's
+// nonce, and fills in missing claims from the userinfo endpoint.
+func (p *Provider) Exchange(ctx context.Context, code string, f Flow) (*Identity, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {f.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token: %w", err)
+	}
+	defer resp.Body.Close()
+	var tok tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc token: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return nil, fmt.Errorf("oidc token: %s %s %s", resp.Status, tok.Error, tok.ErrorDesc)
+	}
+	if tok.IDToken == "" {
+		return nil, errors.New("oidc token: no id_token in response")
+	}
+
+	claims, err := p.verifyIDToken(ctx, m, tok.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := claims["nonce"].(string); n != f.Nonce {
+		return nil, errors.New("oidc: nonce mismatch")
+	}
+	id := p.identity(claims)
+	if id.Subject == "" {
+		return nil, errors.New("oidc: id_token has no subject")
+	}
+
+	// Some providers keep email and groups out of the ID token.
+	if (id.Email == "" || (p.cfg.RoleClaim != "" && id.RoleValues == nil)) && m.UserinfoEndpoint != "" && tok.AccessToken != "" {
+		if info, err := p.userinfo(ctx, m, tok.AccessToken); err == nil {
+			if sub, _ := info["sub"].(string); sub == id.Subject {
+				for k, v := range info {
+					if _, ok := claims[k]; !ok {
+						claims[k] = v
+					}
+				}
+				id = p.identity(claims)
+			}
+		}
+	}
+	return &id, nil
+}
+
+func (p *Provider) userinfo(ctx context.Context, m *metadata, accessToken string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc userinfo: %s", resp.Status)
+	}
+	var out map[string]any
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out)
+	return out, err
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, m *metadata, raw string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, m, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256"}),
+		jwt.WithIssuer(m.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc id_token: %w", err)
+	}
+	return claims, nil
+}
+
+// key returns the signing key with id kid, downloading the JWKS again when it is unknown
+// (the provider may have rotated keys).
+func (p *Provider) key(ctx context.Context, m *metadata, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k := pickKey(p.keys, kid); k != nil {
+		return k, nil
+	}
+	if p.keys != nil && time.Since(p.keysFetch) < jwksRefetch {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	var set jwkSet
+	if err := p.getJSON(ctx, m.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	p.keys = set.publicKeys()
+	p.keysFetch = time.Now()
+	if k := pickKey(p.keys, kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// pickKey finds kid, or the only key when the token names none.
+func pickKey(keys map[string]any, kid string) any {
+	if kid != "" {
+		return keys[kid]
+	}
+	if len(keys) == 1 {
+		for _, k := range keys {
+			return k
+		}
+	}
+	return nil
+}
+
+func (p *Provider) identity(c jwt.MapClaims) Identity {
+	id := Identity{}
+	id.Issuer, _ = c["iss"].(string)
+	id.Subject, _ = c["sub"].(string)
+	id.Email, _ = c["email"].(string)
+	id.Email = strings.ToLower(strings.TrimSpace(id.Email))
+	switch v := c["email_verified"].(type) {
+	case bool:
+		id.EmailVerified = v
+	case string: // some providers send "true"
+		id.EmailVerified = v == "true"
+	}
+	id.Name, _ = c["name"].(string)
+	if id.Name == "" {
+		id.Name, _ = c["preferred_username"].(string)
+	}
+	if p.cfg.RoleClaim != "" {
+		switch v := c[p.cfg.RoleClaim].(type) {
+		case string:
+			id.RoleValues = strings.Fields(v)
+		case []any:
+			id.RoleValues = []string{}
+			for _, x := range v {
+				if s, ok := x.(string); ok {
+					id.RoleValues = append(id.RoleValues, s)
+				}
+			}
+		}
+	}
+	return id
+}
+
+// MapRole returns the role for the first rule matching one of the identity's claim values,
+// or "" when none match.
+func (p *Provider) MapRole(id Identity) string {
+	for _, rule := range p.cfg.RoleMap {
+		for _, v := range id.RoleValues {
+			if v == rule.Value {
+				return rule.Role
+			}
+		}
+	}
+	return ""
+}
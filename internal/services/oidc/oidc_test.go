@@ -0,0 +1,133 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"house-bartender-go/internal/services/oidc/oidctest"
+)
+
+const redirect = "http://bar.test/auth/oidc/callback"
+
+func newProvider(stub *oidctest.Provider, rules []RoleRule) *Provider {
+	return New(Config{
+		Issuer:       stub.URL + "/",
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirect,
+		Scopes:       []string{"openid", "email", "profile"},
+		RoleClaim:    "groups",
+		RoleMap:      rules,
+	})
+}
+
+// authorize follows the login redirect to the stub and returns the callback code.
+func authorize(t *testing.T, p *Provider, f Flow) string {
+	t.Helper()
+	u, err := p.AuthURL(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noFollow.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: %s %v", resp.Status, err)
+	}
+	if loc.Query().Get("state") != f.State {
+		t.Fatalf("state not echoed: %s", loc)
+	}
+	return loc.Query().Get("code")
+}
+
+func TestCodeFlowWithPKCEAndRoleMapping(t *testing.T) {
+	stub := oidctest.New()
+	defer stub.Close()
+	stub.SetUser(map[string]any{
+		"sub": "u-1", "email": "Ann@Example.com", "email_verified": true, "name": "Ann",
+		"groups": []string{"family", "bar-staff"},
+	})
+	rules, err := ParseRoleMap("bar-admins=ADMIN, bar-staff=bartender")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProvider(stub, rules)
+
+	f, _ := NewFlow()
+	id, err := p.Exchange(context.Background(), authorize(t, p, f), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "u-1" || id.Email != "ann@example.com" || !id.EmailVerified || id.DisplayName() != "Ann" {
+		t.Fatalf("identity = %+v", id)
+	}
+	if id.Issuer != stub.URL {
+		t.Fatalf("issuer = %q", id.Issuer)
+	}
+	if got := p.MapRole(*id); got != "BARTENDER" {
+		t.Fatalf("MapRole = %q", got)
+	}
+
+	// the code is single-use
+	if _, err := p.Exchange(context.Background(), "reused", f); err == nil {
+		t.Fatal("unknown code accepted")
+	}
+}
+
+func TestExchangeRejectsWrongNonceAndVerifier(t *testing.T) {
+	stub := oidctest.New()
+	defer stub.Close()
+	stub.SetUser(map[string]any{"sub": "u-2"})
+	p := newProvider(stub, nil)
+
+	f, _ := NewFlow()
+	code := authorize(t, p, f)
+	other := f
+	other.Nonce = "replayed"
+	if _, err := p.Exchange(context.Background(), code, other); err == nil {
+		t.Fatal("nonce mismatch accepted")
+	}
+
+	f, _ = NewFlow()
+	code = authorize(t, p, f)
+	other = f
+	other.Verifier = "not-the-verifier-not-the-verifier-xx"
+	if _, err := p.Exchange(context.Background(), code, other); err == nil {
+		t.Fatal("wrong PKCE verifier accepted")
+	}
+}
+
+func TestUserinfoFillsMissingClaims(t *testing.T) {
+	stub := oidctest.New()
+	defer stub.Close()
+	stub.UserinfoOnly = []string{"email", "email_verified", "groups"}
+	stub.SetUser(map[string]any{"sub": "u-3", "email": "kim@example.com", "email_verified": "true", "groups": "bar-admins"})
+	p := newProvider(stub, []RoleRule{{Value: "bar-admins", Role: "ADMIN"}})
+
+	f, _ := NewFlow()
+	id, err := p.Exchange(context.Background(), authorize(t, p, f), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Email != "kim@example.com" || !id.EmailVerified || p.MapRole(*id) != "ADMIN" {
+		t.Fatalf("identity = %+v", id)
+	}
+	if id.DisplayName() != "kim" {
+		t.Fatalf("DisplayName = %q", id.DisplayName())
+	}
+}
+
+func TestParseRoleMap(t *testing.T) {
+	if rules, err := ParseRoleMap(""); err != nil || rules != nil {
+		t.Fatalf("empty map: %v %v", rules, err)
+	}
+	if _, err := ParseRoleMap("bar-admins"); err == nil {
+		t.Fatal("entry without a role accepted")
+	}
+}
@@ -0,0 +1,155 @@
+package push
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+// Alert is a kind of notification. Devices choose which bartender alerts they get; a
+// guest's own order updates are always sent.
+type Alert int
+
+const (
+	AlertNewOrder Alert = iota
+	AlertOverdue
+	AlertLowStock
+	AlertOrderStatus
+)
+
+// QuietTimeLayout is how quiet hours are written.
+const QuietTimeLayout = "15:04"
+
+// validatePreferences checks the quiet hours, which are either both set or both empty, and
+// their time zone.
+func validatePreferences(p db.PushPreferences) error {
+	if p.QuietZone != "" {
+		if _, err := time.LoadLocation(p.QuietZone); err != nil {
+			return fmt.Errorf("%w: unknown time zone %q", ErrInvalidPreferences, p.QuietZone)
+		}
+	}
+	if p.QuietStart == "" && p.QuietEnd == "" {
+		return nil
+	}
+	if p.QuietStart == "" || p.QuietEnd == "" {
+		return fmt.Errorf("%w: quiet hours need a start and an end", ErrInvalidPreferences)
+	}
+	for _, v := range []string{p.QuietStart, p.QuietEnd} {
+		if _, err := time.Parse(QuietTimeLayout, v); err != nil {
+			return fmt.Errorf("%w: %q is not a time of day", ErrInvalidPreferences, v)
+		}
+	}
+	return nil
+}
+
+// InQuietHours reports whether now falls in the quiet hours, read in their zone, or in now's
+// own location when they have none. Quiet hours whose end is at or before their start run
+// past midnight; equal ones are none.
+func InQuietHours(p db.PushPreferences, now time.Time) bool {
+	if p.QuietZone != "" {
+		loc, err := time.LoadLocation(p.QuietZone)
+		if err != nil {
+			return false
+		}
+		now = now.In(loc)
+	}
+	start, err := time.Parse(QuietTimeLayout, p.QuietStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(QuietTimeLayout, p.QuietEnd)
+	if err != nil {
+		return false
+	}
+	from := start.Hour()*60 + start.Minute()
+	until := end.Hour()*60 + end.Minute()
+	at := now.Hour()*60 + now.Minute()
+	switch {
+	case from == until:
+		return false
+	case from < until:
+		return at >= from && at < until
+	default:
+		return at >= from || at < until
+	}
+}
+
+// Wants reports whether sub should get alert at now. order is the order the alert is
+// about, nil for stock alerts.
+func Wants(sub db.PushSubscription, alert Alert, order *db.Order, now time.Time) bool {
+	if InQuietHours(sub.Prefs, now) {
+		return false
+	}
+	switch alert {
+	case AlertNewOrder:
+		if !sub.Prefs.NewOrders {
+			return false
+		}
+	case AlertOverdue:
+		if !sub.Prefs.Overdue {
+			return false
+		}
+	case AlertLowStock:
+		return sub.Prefs.LowStock
+	default:
+		return true
+	}
+	if !sub.Prefs.OnlyAssigned {
+		return true
+	}
+	return order != nil && order.AssignedBartenderID != nil && *order.AssignedBartenderID == sub.UserID
+}
+
+func filterSubscriptions(subs []db.PushSubscription, alert Alert, order *db.Order, now time.Time) []db.PushSubscription {
+	var out []db.PushSubscription
+	for _, sub := range subs {
+		if Wants(sub, alert, order, now) {
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+// DeviceName is what a device is called in notifications and lists: its label, or failing
+// that the browser and system guessed from its user agent.
+func DeviceName(sub db.PushSubscription) string {
+	if label := strings.TrimSpace(sub.DeviceLabel); label != "" {
+		return label
+	}
+	ua := sub.UserAgent
+	browser := ""
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+	system := ""
+	switch {
+	case strings.Contains(ua, "Android"):
+		system = "Android"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		system = "iOS"
+	case strings.Contains(ua, "Mac OS X"):
+		system = "macOS"
+	case strings.Contains(ua, "Windows"):
+		system = "Windows"
+	case strings.Contains(ua, "Linux"):
+		system = "Linux"
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return "Unnamed device"
+}
@@ -6,17 +6,32 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
 	"house-bartender-go/internal/db"
 )
 
 var ErrNotConfigured = errors.New("push notifications are not configured")
 var ErrInvalidSubscription = errors.New("invalid push subscription")
+var ErrInvalidPreferences = errors.New("invalid notification preferences")
+var ErrSubscriptionNotFound = errors.New("push subscription not found")
+
+// OverdueEvery is how often open orders are checked for being overdue.
+const OverdueEvery = time.Minute
 
 type Config struct {
 	PublicKey  string
 	PrivateKey string
 	Subject    string
+
+	// NotifyAccepted also tells guests when a bartender takes their order, not only when it
+	// is ready or cancelled.
+	NotifyAccepted bool
+
+	// OverdueAfter is how long an order can wait before bartenders are alerted that it is
+	// overdue; zero or less never alerts.
+	OverdueAfter time.Duration
 }
 
 type Service struct {
@@ -25,6 +40,12 @@ type Service struct {
 	sender    Sender
 	enabled   bool
 	publicKey string
+
+	notifyAccepted bool
+	overdueAfter   time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
 }
 
 type Repository interface {
@@ -32,6 +53,11 @@ type Repository interface {
 	DisablePushSubscriptionForUser(userID int64, endpoint string) error
 	GetOrderByID(id int64) (*db.Order, error)
 	ListPushSubscriptionsForOnDutyBartenders() ([]db.PushSubscription, error)
+	ListEnabledPushSubscriptionsForUser(userID int64) ([]db.PushSubscription, error)
+	GetPushSubscription(id int64) (*db.PushSubscription, error)
+	UpdatePushSubscriptionForUser(userID, id int64, label string, prefs db.PushPreferences) error
+	DeletePushSubscriptionForUser(userID, id int64) error
+	ClaimOverdueOrders(before time.Time) ([]int64, error)
 	MarkPushSubscriptionSuccess(endpoint string) error
 	MarkPushSubscriptionFailure(endpoint string) error
 	DisablePushSubscriptionByEndpoint(endpoint string) error
@@ -103,6 +129,9 @@ func newService(repo Repository, logger *slog.Logger, cfg Config, sender Sender)
 		sender:    sender,
 		enabled:   true,
 		publicKey: publicKey,
+
+		notifyAccepted: cfg.NotifyAccepted,
+		overdueAfter:   cfg.OverdueAfter,
 	}, nil
 }
 
@@ -122,25 +151,25 @@ func (s *Service) SaveSubscription(userID int64, userAgent string, input Subscri
 		return ErrNotConfigured
 	}
 	if userID <= 0 {
-		return fmt.Errorf("%w: bartender user is required", ErrInvalidSubscription)
+		return fmt.Errorf("%w: user is required", ErrInvalidSubscription)
 	}
 	if err := validateSubscription(input); err != nil {
 		return err
 	}
 
 	return s.repo.UpsertPushSubscription(db.UpsertPushSubscriptionParams{
-		BartenderUserID: userID,
-		Endpoint:        strings.TrimSpace(input.Endpoint),
-		P256DH:          strings.TrimSpace(input.P256DH),
-		Auth:            strings.TrimSpace(input.Auth),
-		UserAgent:       strings.TrimSpace(userAgent),
-		DeviceLabel:     strings.TrimSpace(input.DeviceLabel),
+		UserID:      userID,
+		Endpoint:    strings.TrimSpace(input.Endpoint),
+		P256DH:      strings.TrimSpace(input.P256DH),
+		Auth:        strings.TrimSpace(input.Auth),
+		UserAgent:   strings.TrimSpace(userAgent),
+		DeviceLabel: strings.TrimSpace(input.DeviceLabel),
 	})
 }
 
 func (s *Service) DisableSubscription(userID int64, endpoint string) error {
 	if userID <= 0 {
-		return fmt.Errorf("%w: bartender user is required", ErrInvalidSubscription)
+		return fmt.Errorf("%w: user is required", ErrInvalidSubscription)
 	}
 	endpoint = strings.TrimSpace(endpoint)
 	if endpoint == "" {
@@ -168,6 +197,7 @@ func (s *Service) NotifyNewOrder(orderID int64) error {
 		s.log.Error("push notify: load subscriptions failed", "order_id", orderID, "err", err)
 		return err
 	}
+	subscriptions = filterSubscriptions(subscriptions, AlertNewOrder, order, time.Now())
 	if len(subscriptions) == 0 {
 		return nil
 	}
@@ -179,44 +209,296 @@ func (s *Service) NotifyNewOrder(orderID int64) error {
 	}
 
 	s.log.Info("push notify: dispatching", "order_id", orderID, "subscription_count", len(subscriptions))
+	successes, failures := s.deliver(subscriptions, payload, "order_id", orderID)
+	s.log.Info("push notify: completed", "order_id", orderID, "successes", successes, "failures", failures)
+	return nil
+}
+
+// NotifyAssigned tells a bartender that someone else assigned them the order, on the
+// devices that get new orders.
+func (s *Service) NotifyAssigned(orderID, by int64) error {
+	if !s.Enabled() {
+		return nil
+	}
+	order, err := s.repo.GetOrderByID(orderID)
+	if err != nil {
+		s.log.Error("push assigned: load order failed", "order_id", orderID, "err", err)
+		return err
+	}
+	if order == nil || order.AssignedBartenderID == nil || *order.AssignedBartenderID == by {
+		return nil
+	}
+
+	subscriptions, err := s.repo.ListEnabledPushSubscriptionsForUser(*order.AssignedBartenderID)
+	if err != nil {
+		s.log.Error("push assigned: load subscriptions failed", "order_id", orderID, "err", err)
+		return err
+	}
+	subscriptions = filterSubscriptions(subscriptions, AlertNewOrder, order, time.Now())
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	payload := buildNotificationPayload(*order)
+	payload.Title = "Order assigned to you"
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.log.Error("push assigned: encode payload failed", "order_id", orderID, "err", err)
+		return err
+	}
+	successes, failures := s.deliver(subscriptions, body, "order_id", orderID)
+	s.log.Info("push assigned: completed", "order_id", orderID, "successes", successes, "failures", failures)
+	return nil
+}
+
+// NotifyOrderStatus tells the guest who placed the order about its new status on every
+// device they turned notifications on for. Only statuses worth a buzz are sent: ready and
+// cancelled, and accepted when configured.
+func (s *Service) NotifyOrderStatus(orderID int64) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	order, err := s.repo.GetOrderByID(orderID)
+	if err != nil {
+		s.log.Error("push order status: load order failed", "order_id", orderID, "err", err)
+		return err
+	}
+	if order == nil {
+		return nil
+	}
+	payload, ok := buildOrderStatusPayload(*order, s.notifyAccepted)
+	if !ok {
+		return nil
+	}
 
+	subscriptions, err := s.repo.ListEnabledPushSubscriptionsForUser(order.UserID)
+	if err != nil {
+		s.log.Error("push order status: load subscriptions failed", "order_id", orderID, "err", err)
+		return err
+	}
+	subscriptions = filterSubscriptions(subscriptions, AlertOrderStatus, order, time.Now())
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.log.Error("push order status: encode payload failed", "order_id", orderID, "err", err)
+		return err
+	}
+
+	successes, failures := s.deliver(subscriptions, body, "order_id", orderID, "status", order.Status)
+	s.log.Info("push order status: completed", "order_id", orderID, "status", order.Status, "successes", successes, "failures", failures)
+	return nil
+}
+
+// NotifyLowStock tells on-duty bartenders which products just fell to their reorder level.
+func (s *Service) NotifyLowStock(products []db.Product) error {
+	if !s.Enabled() || len(products) == 0 {
+		return nil
+	}
+
+	subscriptions, err := s.repo.ListPushSubscriptionsForOnDutyBartenders()
+	if err != nil {
+		s.log.Error("push low stock: load subscriptions failed", "err", err)
+		return err
+	}
+	subscriptions = filterSubscriptions(subscriptions, AlertLowStock, nil, time.Now())
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(buildLowStockPayload(products))
+	if err != nil {
+		s.log.Error("push low stock: encode payload failed", "err", err)
+		return err
+	}
+
+	successes, failures := s.deliver(subscriptions, payload, "product_count", len(products))
+	s.log.Info("push low stock: completed", "product_count", len(products), "successes", successes, "failures", failures)
+	return nil
+}
+
+// NotifyOverdue alerts on-duty bartenders about each open order placed before before. An
+// order is only ever alerted about once.
+func (s *Service) NotifyOverdue(before, now time.Time) (int, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+	ids, err := s.repo.ClaimOverdueOrders(before)
+	if err != nil {
+		s.log.Error("push overdue: claim orders failed", "err", err)
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	subscriptions, err := s.repo.ListPushSubscriptionsForOnDutyBartenders()
+	if err != nil {
+		s.log.Error("push overdue: load subscriptions failed", "err", err)
+		return 0, err
+	}
+	for _, id := range ids {
+		order, err := s.repo.GetOrderByID(id)
+		if err != nil || order == nil {
+			continue
+		}
+		targets := filterSubscriptions(subscriptions, AlertOverdue, order, now)
+		if len(targets) == 0 {
+			continue
+		}
+		payload, err := json.Marshal(buildOverduePayload(*order, now))
+		if err != nil {
+			continue
+		}
+		successes, failures := s.deliver(targets, payload, "order_id", id)
+		s.log.Info("push overdue: completed", "order_id", id, "successes", successes, "failures", failures)
+	}
+	return len(ids), nil
+}
+
+// Start checks for overdue orders every interval until Stop is called.
+func (s *Service) Start(every time.Duration) {
+	if !s.Enabled() || every <= 0 || s.overdueAfter <= 0 || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-t.C:
+				_, _ = s.NotifyOverdue(now.Add(-s.overdueAfter), now)
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	if s == nil || s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.stop = nil
+}
+
+// device loads subscription id, which has to be one of the user's.
+func (s *Service) device(userID, id int64) (*db.PushSubscription, error) {
+	sub, err := s.repo.GetPushSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil || sub.UserID != userID {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+// UpdateDevice renames one of the user's devices and sets what it is sent.
+func (s *Service) UpdateDevice(userID, id int64, label string, prefs db.PushPreferences) error {
+	label = strings.TrimSpace(label)
+	prefs.QuietStart = strings.TrimSpace(prefs.QuietStart)
+	prefs.QuietEnd = strings.TrimSpace(prefs.QuietEnd)
+	prefs.QuietZone = strings.TrimSpace(prefs.QuietZone)
+	if len(label) > 255 {
+		return fmt.Errorf("%w: device name is too long", ErrInvalidPreferences)
+	}
+	if err := validatePreferences(prefs); err != nil {
+		return err
+	}
+	if _, err := s.device(userID, id); err != nil {
+		return err
+	}
+	return s.repo.UpdatePushSubscriptionForUser(userID, id, label, prefs)
+}
+
+// RemoveDevice forgets one of the user's devices. The browser keeps its subscription until
+// notifications are turned off on it, but nothing is sent there any more.
+func (s *Service) RemoveDevice(userID, id int64) error {
+	if _, err := s.device(userID, id); err != nil {
+		return err
+	}
+	return s.repo.DeletePushSubscriptionForUser(userID, id)
+}
+
+// SendTest sends a test notification to one of the user's devices, whatever its
+// preferences and quiet hours, and returns what the push gateway answered.
+func (s *Service) SendTest(userID, id int64) (DeliveryResult, error) {
+	if !s.Enabled() {
+		return DeliveryResult{}, ErrNotConfigured
+	}
+	sub, err := s.device(userID, id)
+	if err != nil {
+		return DeliveryResult{}, err
+	}
+	payload, err := json.Marshal(NotificationPayload{
+		Title:     "Test notification",
+		Body:      "Notifications reach " + DeviceName(*sub) + ".",
+		URL:       "/bartender",
+		Tag:       fmt.Sprintf("push-test-%d", sub.ID),
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return DeliveryResult{}, err
+	}
+	result, err := s.send(*sub, payload, s.log.With("subscription_id", sub.ID))
+	s.log.Info("push test: completed", "subscription_id", sub.ID, "status_code", result.StatusCode, "err", err)
+	return result, err
+}
+
+// deliver sends one payload to every subscription, keeping subscription health up to date.
+// attrs are added to the delivery log lines.
+func (s *Service) deliver(subscriptions []db.PushSubscription, payload []byte, attrs ...any) (int, int) {
+	log := s.log.With(attrs...)
 	successes := 0
 	failures := 0
 	for _, sub := range subscriptions {
-		result, sendErr := s.sender.Send(sub, payload)
-		if sendErr != nil {
+		if _, err := s.send(sub, payload, log); err != nil {
 			failures++
-			if result.StatusCode == 404 || result.StatusCode == 410 {
-				_ = s.repo.DisablePushSubscriptionByEndpoint(sub.Endpoint)
-				s.log.Warn("push notify: subscription disabled after invalid response",
-					"order_id", orderID,
-					"bartender_user_id", sub.BartenderUserID,
-					"endpoint", sub.Endpoint,
-					"status_code", result.StatusCode,
-					"details", result.Details,
-					"err", sendErr,
-				)
-				continue
-			}
-
-			_ = s.repo.MarkPushSubscriptionFailure(sub.Endpoint)
-			s.log.Warn("push notify: delivery failed",
-				"order_id", orderID,
-				"bartender_user_id", sub.BartenderUserID,
-				"endpoint", sub.Endpoint,
-				"status_code", result.StatusCode,
-				"details", result.Details,
-				"err", sendErr,
-			)
 			continue
 		}
-
 		successes++
+	}
+	return successes, failures
+}
+
+// send delivers payload to one subscription and records how it went: a device the gateway
+// no longer knows is disabled.
+func (s *Service) send(sub db.PushSubscription, payload []byte, log *slog.Logger) (DeliveryResult, error) {
+	result, sendErr := s.sender.Send(sub, payload)
+	if sendErr == nil {
 		_ = s.repo.MarkPushSubscriptionSuccess(sub.Endpoint)
+		return result, nil
+	}
+	if result.StatusCode == 404 || result.StatusCode == 410 {
+		_ = s.repo.DisablePushSubscriptionByEndpoint(sub.Endpoint)
+		log.Warn("push notify: subscription disabled after invalid response",
+			"user_id", sub.UserID,
+			"endpoint", sub.Endpoint,
+			"status_code", result.StatusCode,
+			"details", result.Details,
+			"err", sendErr,
+		)
+		return result, sendErr
 	}
 
-	s.log.Info("push notify: completed", "order_id", orderID, "successes", successes, "failures", failures)
-	return nil
+	_ = s.repo.MarkPushSubscriptionFailure(sub.Endpoint)
+	log.Warn("push notify: delivery failed",
+		"user_id", sub.UserID,
+		"endpoint", sub.Endpoint,
+		"status_code", result.StatusCode,
+		"details", result.Details,
+		"err", sendErr,
+	)
+	return result, sendErr
 }
 
 func validateSubscription(input SubscriptionInput) error {
@@ -256,6 +538,47 @@ func buildNotificationPayload(order db.Order) NotificationPayload {
 	}
 }
 
+// buildOrderStatusPayload words the guest's notification for the order's current status,
+// or reports false when the status isn't one guests are told about.
+func buildOrderStatusPayload(order db.Order, withAccepted bool) (NotificationPayload, bool) {
+	drink := strings.TrimSpace(order.CocktailName)
+	if drink == "" {
+		drink = "drink"
+	}
+	if order.Quantity > 1 {
+		drink = fmt.Sprintf("%dx %s", order.Quantity, drink)
+	}
+
+	var title, body string
+	switch order.Status {
+	case "READY":
+		title = "Your " + drink + " is ready"
+		body = "Pick it up at the bar."
+		if location := strings.TrimSpace(order.Location); location != "" {
+			body = "It's on its way to " + location + "."
+		}
+	case "CANCELLED":
+		title = "Your " + drink + " was cancelled"
+		body = "The bar couldn't make it this time. Pick something else from the menu."
+	case "ACCEPTED":
+		if !withAccepted {
+			return NotificationPayload{}, false
+		}
+		title = "Your " + drink + " is up next"
+		body = "A bartender has taken your order."
+	default:
+		return NotificationPayload{}, false
+	}
+	return NotificationPayload{
+		Title:     title,
+		Body:      body,
+		OrderID:   order.ID,
+		URL:       fmt.Sprintf("/orders#order-%d", order.ID),
+		Tag:       fmt.Sprintf("my-order-%d", order.ID),
+		Timestamp: time.Now().UnixMilli(),
+	}, true
+}
+
 func formatOrderBody(order db.Order) string {
 	var parts []string
 	if location := strings.TrimSpace(order.Location); location != "" {
@@ -275,3 +598,40 @@ func formatOrderBody(order db.Order) string {
 
 	return strings.Join(parts, " - ")
 }
+
+func buildOverduePayload(order db.Order, now time.Time) NotificationPayload {
+	waiting := now.Sub(order.CreatedAt).Round(time.Minute)
+	return NotificationPayload{
+		Title:     fmt.Sprintf("Order #%d is overdue", order.ID),
+		Body:      fmt.Sprintf("%s, waiting %d min", formatOrderBody(order), int(waiting.Minutes())),
+		OrderID:   order.ID,
+		URL:       fmt.Sprintf("/bartender/orders?highlight=%d#order-%d", order.ID, order.ID),
+		Tag:       fmt.Sprintf("order-overdue-%d", order.ID),
+		Timestamp: now.UnixMilli(),
+	}
+}
+
+func buildLowStockPayload(products []db.Product) NotificationPayload {
+	parts := make([]string, 0, len(products))
+	for _, p := range products {
+		if p.StockCount != nil {
+			parts = append(parts, fmt.Sprintf("%s: %d left", p.Name, *p.StockCount))
+		} else {
+			parts = append(parts, p.Name)
+		}
+	}
+
+	title := "Low stock"
+	tag := "inventory-low"
+	if len(products) == 1 {
+		title = "Low stock: " + products[0].Name
+		tag = fmt.Sprintf("inventory-low-%d", products[0].ID)
+	}
+	return NotificationPayload{
+		Title:     title,
+		Body:      strings.Join(parts, ", "),
+		URL:       "/bartender/products?status=low",
+		Tag:       tag,
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
@@ -188,6 +188,339 @@ func TestNotifyNewOrderDisablesGoneSubscriptions(t *testing.T) {
 	}
 }
 
+func TestNotifyLowStockTargetsOnDutyBartenders(t *testing.T) {
+	repo := newFakeRepo()
+	onDuty := repo.addUser("BARTENDER", true, true)
+	offDuty := repo.addUser("BARTENDER", true, false)
+
+	repo.mustUpsertSub(t, onDuty, "https://push.example/on", "on", "on-auth")
+	repo.mustUpsertSub(t, offDuty, "https://push.example/off", "off", "off-auth")
+
+	sender := &fakeSender{}
+	service, err := newService(repo, testLogger(), Config{
+		PublicKey:  "public",
+		PrivateKey: "private",
+		Subject:    "mailto:test@example.com",
+	}, sender)
+	if err != nil {
+		t.Fatalf("newService() error = %v", err)
+	}
+
+	stock := int64(2)
+	if err := service.NotifyLowStock([]db.Product{{ID: 7, Name: "Lime", StockCount: &stock}}); err != nil {
+		t.Fatalf("NotifyLowStock() error = %v", err)
+	}
+
+	if len(sender.sent) != 1 || sender.sent[0] != "https://push.example/on" {
+		t.Fatalf("expected one send to on-duty device, got %v", sender.sent)
+	}
+	if got := string(sender.payload); !containsAll(got, `"title":"Low stock: Lime"`, `"body":"Lime: 2 left"`, `"tag":"inventory-low-7"`) {
+		t.Fatalf("unexpected payload %q", got)
+	}
+}
+
+func TestNotifyOrderStatusTargetsOrderOwner(t *testing.T) {
+	repo := newFakeRepo()
+	guest := repo.addUser("USER", true, false)
+	other := repo.addUser("USER", true, false)
+	bartender := repo.addUser("BARTENDER", true, true)
+
+	repo.mustUpsertSub(t, guest, "https://push.example/guest-phone", "guest-phone", "guest-phone-auth")
+	repo.mustUpsertSub(t, guest, "https://push.example/guest-old", "guest-old", "guest-old-auth")
+	repo.mustUpsertSub(t, other, "https://push.example/other", "other", "other-auth")
+	repo.mustUpsertSub(t, bartender, "https://push.example/bartender", "bartender", "bartender-auth")
+	if err := repo.DisablePushSubscriptionForUser(guest, "https://push.example/guest-old"); err != nil {
+		t.Fatalf("DisablePushSubscriptionForUser() error = %v", err)
+	}
+
+	order := db.Order{ID: 3, UserID: guest, Quantity: 1, Location: "Patio", CocktailName: "Mojito", Status: "READY"}
+	repo.addOrder(order)
+
+	sender := &fakeSender{}
+	service, err := newService(repo, testLogger(), Config{
+		PublicKey:  "public",
+		PrivateKey: "private",
+		Subject:    "mailto:test@example.com",
+	}, sender)
+	if err != nil {
+		t.Fatalf("newService() error = %v", err)
+	}
+
+	if err := service.NotifyOrderStatus(order.ID); err != nil {
+		t.Fatalf("NotifyOrderStatus() error = %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "https://push.example/guest-phone" {
+		t.Fatalf("expected one send to the guest's enabled device, got %v", sender.sent)
+	}
+	if got := string(sender.payload); !containsAll(got, `"title":"Your Mojito is ready"`, `Patio`, `"url":"/orders#order-3"`, `"tag":"my-order-3"`) {
+		t.Fatalf("unexpected payload %q", got)
+	}
+
+	for _, status := range []string{"PLACED", "ACCEPTED", "IN_PROGRESS", "DELIVERED"} {
+		sender.sent = nil
+		order.Status = status
+		repo.addOrder(order)
+		if err := service.NotifyOrderStatus(order.ID); err != nil {
+			t.Fatalf("NotifyOrderStatus(%s) error = %v", status, err)
+		}
+		if len(sender.sent) != 0 {
+			t.Fatalf("expected no send for %s, got %v", status, sender.sent)
+		}
+	}
+
+	order.Status = "CANCELLED"
+	repo.addOrder(order)
+	if err := service.NotifyOrderStatus(order.ID); err != nil {
+		t.Fatalf("NotifyOrderStatus(CANCELLED) error = %v", err)
+	}
+	if len(sender.sent) != 1 || !containsAll(string(sender.payload), `"title":"Your Mojito was cancelled"`) {
+		t.Fatalf("expected cancelled notice, got %v %q", sender.sent, sender.payload)
+	}
+}
+
+func TestNotifyOrderStatusAcceptedWhenConfigured(t *testing.T) {
+	repo := newFakeRepo()
+	guest := repo.addUser("USER", true, false)
+	repo.mustUpsertSub(t, guest, "https://push.example/guest", "guest", "guest-auth")
+	repo.addOrder(db.Order{ID: 1, UserID: guest, Quantity: 1, CocktailName: "Negroni", Status: "ACCEPTED"})
+
+	sender := &fakeSender{}
+	service, err := newService(repo, testLogger(), Config{
+		PublicKey:      "public",
+		PrivateKey:     "private",
+		Subject:        "mailto:test@example.com",
+		NotifyAccepted: true,
+	}, sender)
+	if err != nil {
+		t.Fatalf("newService() error = %v", err)
+	}
+
+	if err := service.NotifyOrderStatus(1); err != nil {
+		t.Fatalf("NotifyOrderStatus() error = %v", err)
+	}
+	if len(sender.sent) != 1 || !containsAll(string(sender.payload), `"title":"Your Negroni is up next"`) {
+		t.Fatalf("expected accepted notice, got %v %q", sender.sent, sender.payload)
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 14, h, m, 0, 0, time.UTC) }
+	cases := []struct {
+		start, end string
+		now        time.Time
+		want       bool
+	}{
+		{"", "", at(3, 0), false},
+		{"09:00", "17:00", at(8, 59), false},
+		{"09:00", "17:00", at(9, 0), true},
+		{"09:00", "17:00", at(17, 0), false},
+		{"23:00", "07:00", at(23, 30), true},
+		{"23:00", "07:00", at(6, 59), true},
+		{"23:00", "07:00", at(12, 0), false},
+		{"08:00", "08:00", at(8, 0), false},
+		{"nope", "07:00", at(3, 0), false},
+	}
+	for _, c := range cases {
+		got := InQuietHours(db.PushPreferences{QuietStart: c.start, QuietEnd: c.end}, c.now)
+		if got != c.want {
+			t.Errorf("InQuietHours(%q-%q, %s) = %v, want %v", c.start, c.end, c.now.Format("15:04"), got, c.want)
+		}
+	}
+
+	// quiet hours in a zone are read on that zone's clock: 05:30 UTC is 22:30 in Los Angeles
+	// in March, and 06:30 in Berlin
+	night := db.PushPreferences{QuietStart: "22:00", QuietEnd: "23:00", QuietZone: "America/Los_Angeles"}
+	if !InQuietHours(night, at(5, 30)) {
+		t.Error("quiet hours ignored their time zone")
+	}
+	night.QuietZone = "Europe/Berlin"
+	if InQuietHours(night, at(5, 30)) {
+		t.Error("quiet hours in Berlin matched the Los Angeles clock")
+	}
+	night.QuietZone = "Nowhere/Special"
+	if InQuietHours(night, at(22, 30)) {
+		t.Error("an unknown zone should mean no quiet hours")
+	}
+}
+
+func TestWantsFollowsPreferences(t *testing.T) {
+	me, other := int64(1), int64(2)
+	now := time.Date(2026, 3, 14, 20, 0, 0, 0, time.UTC)
+	mine := &db.Order{ID: 1, AssignedBartenderID: &me}
+	theirs := &db.Order{ID: 2, AssignedBartenderID: &other}
+	unassigned := &db.Order{ID: 3}
+
+	all := db.PushSubscription{UserID: me, Prefs: db.PushPreferences{NewOrders: true, Overdue: true, LowStock: true}}
+	if !Wants(all, AlertNewOrder, unassigned, now) || !Wants(all, AlertOverdue, theirs, now) || !Wants(all, AlertLowStock, nil, now) {
+		t.Fatalf("expected a device with everything on to get every alert")
+	}
+
+	quiet := all
+	quiet.Prefs.QuietStart, quiet.Prefs.QuietEnd = "19:00", "21:00"
+	if Wants(quiet, AlertNewOrder, unassigned, now) || Wants(quiet, AlertOrderStatus, mine, now) {
+		t.Fatalf("expected nothing during quiet hours")
+	}
+
+	assigned := all
+	assigned.Prefs.OnlyAssigned = true
+	if !Wants(assigned, AlertOverdue, mine, now) {
+		t.Fatalf("expected overdue alert for own order")
+	}
+	if Wants(assigned, AlertOverdue, theirs, now) || Wants(assigned, AlertNewOrder, unassigned, now) {
+		t.Fatalf("expected only own orders with only-assigned on")
+	}
+	if !Wants(assigned, AlertLowStock, nil, now) {
+		t.Fatalf("expected only-assigned to leave stock alerts alone")
+	}
+
+	none := db.PushSubscription{UserID: me}
+	if Wants(none, AlertNewOrder, mine, now) || Wants(none, AlertOverdue, mine, now) || Wants(none, AlertLowStock, nil, now) {
+		t.Fatalf("expected a device with everything off to get no bartender alerts")
+	}
+	if !Wants(none, AlertOrderStatus, mine, now) {
+		t.Fatalf("expected own order updates regardless of bartender alerts")
+	}
+}
+
+func TestNotifyNewOrderSkipsDevicesThatOptedOut(t *testing.T) {
+	repo := newFakeRepo()
+	guest := repo.addUser("USER", true, false)
+	bartender := repo.addUser("BARTENDER", true, true)
+	repo.mustUpsertSub(t, bartender, "https://push.example/phone", "phone", "phone-auth")
+	repo.mustUpsertSub(t, bartender, "https://push.example/tablet", "tablet", "tablet-auth")
+	tablet := repo.subs["https://push.example/tablet"]
+	if err := repo.UpdatePushSubscriptionForUser(bartender, tablet.ID, "Tablet", db.PushPreferences{LowStock: true}); err != nil {
+		t.Fatalf("UpdatePushSubscriptionForUser() error = %v", err)
+	}
+	orderID := repo.addOrder(db.Order{ID: 1, UserID: guest, Quantity: 1, CocktailName: "Gimlet", Status: "PLACED", CreatedAt: time.Now()})
+
+	sender := &fakeSender{}
+	service, err := newService(repo, testLogger(), Config{
+		PublicKey:  "public",
+		PrivateKey: "private",
+		Subject:    "mailto:test@example.com",
+	}, sender)
+	if err != nil {
+		t.Fatalf("newService() error = %v", err)
+	}
+
+	if err := service.NotifyNewOrder(orderID); err != nil {
+		t.Fatalf("NotifyNewOrder() error = %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "https://push.example/phone" {
+		t.Fatalf("expected only the phone to get the new order, got %v", sender.sent)
+	}
+}
+
+func TestNotifyOverdueAlertsOncePerOrder(t *testing.T) {
+	repo := newFakeRepo()
+	guest := repo.addUser("USER", true, false)
+	bartender := repo.addUser("BARTENDER", true, true)
+	repo.mustUpsertSub(t, bartender, "https://push.example/phone", "phone", "phone-auth")
+
+	now := time.Now()
+	repo.addOrder(db.Order{ID: 1, UserID: guest, Quantity: 1, CocktailName: "Sazerac", Location: "Bar", Status: "ACCEPTED", CreatedAt: now.Add(-20 * time.Minute)})
+	repo.addOrder(db.Order{ID: 2, UserID: guest, Quantity: 1, CocktailName: "Martini", Status: "PLACED", CreatedAt: now.Add(-5 * time.Minute)})
+	repo.addOrder(db.Order{ID: 3, UserID: guest, Quantity: 1, CocktailName: "Spritz", Status: "READY", CreatedAt: now.Add(-30 * time.Minute)})
+
+	sender := &fakeSender{}
+	service, err := newService(repo, testLogger(), Config{
+		PublicKey:    "public",
+		PrivateKey:   "private",
+		Subject:      "mailto:test@example.com",
+		OverdueAfter: 15 * time.Minute,
+	}, sender)
+	if err != nil {
+		t.Fatalf("newService() error = %v", err)
+	}
+
+	n, err := service.NotifyOverdue(now.Add(-15*time.Minute), now)
+	if err != nil {
+		t.Fatalf("NotifyOverdue() error = %v", err)
+	}
+	if n != 1 || len(sender.sent) != 1 {
+		t.Fatalf("expected one overdue alert, got %d orders and sends %v", n, sender.sent)
+	}
+	if got := string(sender.payload); !containsAll(got, `"title":"Order #1 is overdue"`, `Bar - Sazerac, waiting 20 min`, `"tag":"order-overdue-1"`) {
+		t.Fatalf("unexpected payload %q", got)
+	}
+
+	sender.sent = nil
+	if n, _ := service.NotifyOverdue(now.Add(-15*time.Minute), now); n != 0 || len(sender.sent) != 0 {
+		t.Fatalf("expected no repeat alert, got %d orders and sends %v", n, sender.sent)
+	}
+}
+
+func TestSendTestReportsGatewayResult(t *testing.T) {
+	repo := newFakeRepo()
+	bartender := repo.addUser("BARTENDER", true, true)
+	other := repo.addUser("BARTENDER", true, true)
+	repo.mustUpsertSub(t, bartender, "https://push.example/good", "good", "good-auth")
+	repo.mustUpsertSub(t, bartender, "https://push.example/gone", "gone", "gone-auth")
+	good, gone := repo.subs["https://push.example/good"], repo.subs["https://push.example/gone"]
+	if err := repo.UpdatePushSubscriptionForUser(bartender, good.ID, "Bar phone", db.PushPreferences{QuietStart: "00:00", QuietEnd: "23:59"}); err != nil {
+		t.Fatalf("UpdatePushSubscriptionForUser() error = %v", err)
+	}
+
+	sender := &fakeSender{
+		results: map[string]DeliveryResult{"https://push.example/gone": {StatusCode: 410, Details: "expired"}},
+		errs:    map[string]error{"https://push.example/gone": errors.New("push gateway returned 410 Gone")},
+	}
+	service, err := newService(repo, testLogger(), Config{
+		PublicKey:  "public",
+		PrivateKey: "private",
+		Subject:    "mailto:test@example.com",
+	}, sender)
+	if err != nil {
+		t.Fatalf("newService() error = %v", err)
+	}
+
+	result, err := service.SendTest(bartender, good.ID)
+	if err != nil || result.StatusCode != 201 {
+		t.Fatalf("SendTest(good) = %+v, %v", result, err)
+	}
+	if got := string(sender.payload); !containsAll(got, `"title":"Test notification"`, `Bar phone`) {
+		t.Fatalf("unexpected payload %q", got)
+	}
+
+	result, err = service.SendTest(bartender, gone.ID)
+	if err == nil || result.StatusCode != 410 {
+		t.Fatalf("SendTest(gone) = %+v, %v", result, err)
+	}
+	if repo.subs["https://push.example/gone"].Enabled {
+		t.Fatalf("expected gone device to be disabled")
+	}
+
+	if _, err := service.SendTest(other, good.ID); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Fatalf("expected someone else's device to be not found, got %v", err)
+	}
+}
+
+func TestUpdateDeviceValidatesQuietHours(t *testing.T) {
+	repo := newFakeRepo()
+	bartender := repo.addUser("BARTENDER", true, true)
+	repo.mustUpsertSub(t, bartender, "https://push.example/phone", "phone", "phone-auth")
+	id := repo.subs["https://push.example/phone"].ID
+
+	service, err := newService(repo, testLogger(), Config{}, nil)
+	if err != nil {
+		t.Fatalf("newService() error = %v", err)
+	}
+
+	for _, prefs := range []db.PushPreferences{{QuietStart: "22:00"}, {QuietStart: "22:00", QuietEnd: "7am"}, {QuietStart: "22:00", QuietEnd: "07:00", QuietZone: "Mars/Olympus"}} {
+		if err := service.UpdateDevice(bartender, id, "Phone", prefs); !errors.Is(err, ErrInvalidPreferences) {
+			t.Fatalf("UpdateDevice(%+v) error = %v, want invalid", prefs, err)
+		}
+	}
+	if err := service.UpdateDevice(bartender, id, " Phone ", db.PushPreferences{Overdue: true, QuietStart: "22:00", QuietEnd: "07:00", QuietZone: " Europe/Dublin "}); err != nil {
+		t.Fatalf("UpdateDevice() error = %v", err)
+	}
+	sub := repo.subs["https://push.example/phone"]
+	if sub.DeviceLabel != "Phone" || sub.Prefs.NewOrders || !sub.Prefs.Overdue || sub.Prefs.QuietEnd != "07:00" || sub.Prefs.QuietZone != "Europe/Dublin" {
+		t.Fatalf("unexpected device after update: %+v", sub)
+	}
+}
+
 func testLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(io.Discard, nil))
 }
@@ -207,6 +540,7 @@ type fakeRepo struct {
 	orders     map[int64]db.Order
 	users      map[int64]fakeUser
 	subs       map[string]db.PushSubscription
+	overdue    map[int64]bool
 }
 
 type fakeUser struct {
@@ -217,9 +551,10 @@ type fakeUser struct {
 
 func newFakeRepo() *fakeRepo {
 	return &fakeRepo{
-		orders: map[int64]db.Order{},
-		users:  map[int64]fakeUser{},
-		subs:   map[string]db.PushSubscription{},
+		orders:  map[int64]db.Order{},
+		users:   map[int64]fakeUser{},
+		subs:    map[string]db.PushSubscription{},
+		overdue: map[int64]bool{},
 	}
 }
 
@@ -237,7 +572,7 @@ func (r *fakeRepo) addOrder(order db.Order) int64 {
 func (r *fakeRepo) listPushSubscriptionsForUser(userID int64) []db.PushSubscription {
 	var out []db.PushSubscription
 	for _, sub := range r.subs {
-		if sub.BartenderUserID == userID {
+		if sub.UserID == userID {
 			out = append(out, sub)
 		}
 	}
@@ -250,11 +585,11 @@ func (r *fakeRepo) listPushSubscriptionsForUser(userID int64) []db.PushSubscript
 func (r *fakeRepo) mustUpsertSub(t *testing.T, userID int64, endpoint, p256dh, auth string) {
 	t.Helper()
 	if err := r.UpsertPushSubscription(db.UpsertPushSubscriptionParams{
-		BartenderUserID: userID,
-		Endpoint:        endpoint,
-		P256DH:          p256dh,
-		Auth:            auth,
-		UserAgent:       "android-chrome",
+		UserID:    userID,
+		Endpoint:  endpoint,
+		P256DH:    p256dh,
+		Auth:      auth,
+		UserAgent: "android-chrome",
 	}); err != nil {
 		t.Fatalf("UpsertPushSubscription(%s) error = %v", endpoint, err)
 	}
@@ -267,8 +602,9 @@ func (r *fakeRepo) UpsertPushSubscription(p db.UpsertPushSubscriptionParams) err
 		r.nextSubID++
 		sub.ID = r.nextSubID
 		sub.CreatedAt = now
+		sub.Prefs = db.PushPreferences{NewOrders: true, Overdue: true, LowStock: true}
 	}
-	sub.BartenderUserID = p.BartenderUserID
+	sub.UserID = p.UserID
 	sub.Endpoint = p.Endpoint
 	sub.P256DH = p.P256DH
 	sub.Auth = p.Auth
@@ -289,7 +625,7 @@ func (r *fakeRepo) UpsertPushSubscription(p db.UpsertPushSubscriptionParams) err
 
 func (r *fakeRepo) DisablePushSubscriptionForUser(userID int64, endpoint string) error {
 	sub, ok := r.subs[endpoint]
-	if !ok || sub.BartenderUserID != userID {
+	if !ok || sub.UserID != userID {
 		return nil
 	}
 	sub.Enabled = false
@@ -310,7 +646,7 @@ func (r *fakeRepo) GetOrderByID(id int64) (*db.Order, error) {
 func (r *fakeRepo) ListPushSubscriptionsForOnDutyBartenders() ([]db.PushSubscription, error) {
 	var out []db.PushSubscription
 	for _, sub := range r.subs {
-		user := r.users[sub.BartenderUserID]
+		user := r.users[sub.UserID]
 		if !sub.Enabled || user.role != "BARTENDER" || !user.active || !user.onDuty {
 			continue
 		}
@@ -322,6 +658,64 @@ func (r *fakeRepo) ListPushSubscriptionsForOnDutyBartenders() ([]db.PushSubscrip
 	return out, nil
 }
 
+func (r *fakeRepo) ListEnabledPushSubscriptionsForUser(userID int64) ([]db.PushSubscription, error) {
+	var out []db.PushSubscription
+	for _, sub := range r.listPushSubscriptionsForUser(userID) {
+		if sub.Enabled && r.users[userID].active {
+			out = append(out, sub)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepo) GetPushSubscription(id int64) (*db.PushSubscription, error) {
+	for _, sub := range r.subs {
+		if sub.ID == id {
+			copy := sub
+			return &copy, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRepo) UpdatePushSubscriptionForUser(userID, id int64, label string, prefs db.PushPreferences) error {
+	for endpoint, sub := range r.subs {
+		if sub.ID == id && sub.UserID == userID {
+			sub.DeviceLabel = label
+			sub.Prefs = prefs
+			r.subs[endpoint] = sub
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) DeletePushSubscriptionForUser(userID, id int64) error {
+	for endpoint, sub := range r.subs {
+		if sub.ID == id && sub.UserID == userID {
+			delete(r.subs, endpoint)
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) ClaimOverdueOrders(before time.Time) ([]int64, error) {
+	var ids []int64
+	for id, order := range r.orders {
+		switch order.Status {
+		case "PLACED", "ACCEPTED", "IN_PROGRESS":
+		default:
+			continue
+		}
+		if r.overdue[id] || !order.CreatedAt.Before(before) {
+			continue
+		}
+		r.overdue[id] = true
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
 func (r *fakeRepo) MarkPushSubscriptionSuccess(endpoint string) error {
 	sub, ok := r.subs[endpoint]
 	if !ok {
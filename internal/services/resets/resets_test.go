@@ -0,0 +1,47 @@
+package resets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewCodeShape(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		code, err := NewCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 11 || code[5] != '-' {
+			t.Fatalf("code %q", code)
+		}
+		if strings.ContainsAny(code, "01IO") {
+			t.Fatalf("code %q uses an ambiguous character", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashIgnoresFormatting(t *testing.T) {
+	want := Hash("K7QM2-XW9RT")
+	for _, in := range []string{"k7qm2-xw9rt", "K7QM2XW9RT", " k7qm2 xw9rt "} {
+		if got := Hash(strings.TrimSpace(in)); got != want {
+			t.Errorf("Hash(%q) differs", in)
+		}
+	}
+	if Hash("K7QM2-XW9RA") == want {
+		t.Fatal("different codes share a hash")
+	}
+	if strings.Contains(want, "K7QM2") || len(want) != 64 {
+		t.Fatalf("hash %q", want)
+	}
+}
+
+func TestURL(t *testing.T) {
+	if got := URL("http://bar.local:8080/", "K7QM2-XW9RT"); got != "http://bar.local:8080/reset/K7QM2-XW9RT" {
+		t.Fatalf("URL = %q", got)
+	}
+}
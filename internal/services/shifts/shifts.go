@@ -0,0 +1,201 @@
+// Package shifts turns on/off-duty changes into a shift log: who worked when, and what
+// they did with the orders while on shift.
+package shifts
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+const (
+	// TouchEvery spaces out the writes that keep a shift alive.
+	TouchEvery = time.Minute
+
+	// SweepEvery is how often idle shifts are looked for.
+	SweepEvery = time.Minute
+)
+
+// Length is how long sh ran, up to now while it is still running.
+func Length(sh db.Shift, now time.Time) time.Duration {
+	end := sh.EndedAt
+	if end.IsZero() {
+		end = now
+	}
+	if end.Before(sh.StartedAt) {
+		return 0
+	}
+	return end.Sub(sh.StartedAt)
+}
+
+// Summary is what a bartender did with the orders during one shift. An order counts as
+// handled when they changed its status at least once; completion time runs from the
+// order being placed to them delivering it.
+type Summary struct {
+	Handled          int
+	Delivered        int
+	Cancelled        int
+	MedianCompletion time.Duration
+}
+
+// Summarize groups events by shift.
+func Summarize(events []db.ShiftOrderEvent) map[int64]Summary {
+	type acc struct {
+		orders      map[int64]bool
+		delivered   int
+		cancelled   int
+		completions []time.Duration
+	}
+	byShift := map[int64]*acc{}
+	for _, e := range events {
+		a := byShift[e.ShiftID]
+		if a == nil {
+			a = &acc{orders: map[int64]bool{}}
+			byShift[e.ShiftID] = a
+		}
+		a.orders[e.OrderID] = true
+		switch e.ToStatus {
+		case "DELIVERED":
+			a.delivered++
+			if d := e.At.Sub(e.OrderPlacedAt); d >= 0 {
+				a.completions = append(a.completions, d)
+			}
+		case "CANCELLED":
+			a.cancelled++
+		}
+	}
+	out := make(map[int64]Summary, len(byShift))
+	for id, a := range byShift {
+		out[id] = Summary{
+			Handled:          len(a.orders),
+			Delivered:        a.delivered,
+			Cancelled:        a.cancelled,
+			MedianCompletion: Median(a.completions),
+		}
+	}
+	return out
+}
+
+// Median returns the middle duration, averaging the two middle ones for an even count, or
+// zero for none. ds is not modified.
+func Median(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	s := append([]time.Duration(nil), ds...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	mid := len(s) / 2
+	if len(s)%2 == 1 {
+		return s[mid]
+	}
+	return (s[mid-1] + s[mid]) / 2
+}
+
+// Total adds up one person's shifts.
+type Total struct {
+	UserID    int64
+	Name      string
+	Shifts    int
+	Worked    time.Duration
+	Handled   int
+	Delivered int
+	Cancelled int
+}
+
+// Totals adds up the shifts per person, most time worked first.
+func Totals(list []db.Shift, summaries map[int64]Summary, now time.Time) []Total {
+	byUser := map[int64]*Total{}
+	var order []int64
+	for _, sh := range list {
+		t := byUser[sh.UserID]
+		if t == nil {
+			t = &Total{UserID: sh.UserID, Name: sh.UserName}
+			byUser[sh.UserID] = t
+			order = append(order, sh.UserID)
+		}
+		sum := summaries[sh.ID]
+		t.Shifts++
+		t.Worked += Length(sh, now)
+		t.Handled += sum.Handled
+		t.Delivered += sum.Delivered
+		t.Cancelled += sum.Cancelled
+	}
+	out := make([]Total, 0, len(order))
+	for _, id := range order {
+		out = append(out, *byUser[id])
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Worked > out[j].Worked })
+	return out
+}
+
+type Store interface {
+	// CloseIdleShifts ends the shifts last seen before before, takes their bartenders off
+	// duty and returns how many it closed.
+	CloseIdleShifts(before time.Time) (int64, error)
+}
+
+// Sweeper closes shifts whose bartender has been idle longer than the timeout.
+type Sweeper struct {
+	store Store
+	idle  time.Duration
+	log   *slog.Logger
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSweeper returns a sweeper for the idle timeout; zero or less never closes a shift.
+func NewSweeper(store Store, idle time.Duration, logger *slog.Logger) *Sweeper {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Sweeper{store: store, idle: idle, log: logger}
+}
+
+// Run sweeps once.
+func (s *Sweeper) Run(now time.Time) (int64, error) {
+	if s.idle <= 0 {
+		return 0, nil
+	}
+	n, err := s.store.CloseIdleShifts(now.Add(-s.idle))
+	if err == nil && n > 0 {
+		s.log.Info("idle shifts closed", "count", n)
+	}
+	return n, err
+}
+
+// Start sweeps every interval until Stop is called.
+func (s *Sweeper) Start(every time.Duration) {
+	if every <= 0 || s.idle <= 0 || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-t.C:
+				if _, err := s.Run(time.Now()); err != nil {
+					s.log.Warn("shift sweep failed", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+func (s *Sweeper) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.stop = nil
+}
@@ -0,0 +1,91 @@
+package shifts
+
+import (
+	"testing"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+func TestMedian(t *testing.T) {
+	for _, tc := range []struct {
+		in   []time.Duration
+		want time.Duration
+	}{
+		{nil, 0},
+		{[]time.Duration{5}, 5},
+		{[]time.Duration{9, 1, 5}, 5},
+		{[]time.Duration{8, 2, 4, 6}, 5},
+	} {
+		if got := Median(tc.in); got != tc.want {
+			t.Fatalf("Median(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+	in := []time.Duration{3, 1, 2}
+	Median(in)
+	if in[0] != 3 {
+		t.Fatalf("Median sorted its input: %v", in)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	placed := time.Unix(1700000000, 0)
+	events := []db.ShiftOrderEvent{
+		{ShiftID: 1, OrderID: 10, ToStatus: "ACCEPTED", At: placed.Add(time.Minute), OrderPlacedAt: placed},
+		{ShiftID: 1, OrderID: 10, ToStatus: "DELIVERED", At: placed.Add(4 * time.Minute), OrderPlacedAt: placed},
+		{ShiftID: 1, OrderID: 11, ToStatus: "DELIVERED", At: placed.Add(8 * time.Minute), OrderPlacedAt: placed},
+		{ShiftID: 1, OrderID: 12, ToStatus: "CANCELLED", At: placed.Add(time.Minute), OrderPlacedAt: placed},
+		{ShiftID: 2, OrderID: 13, ToStatus: "MAKING", At: placed, OrderPlacedAt: placed},
+	}
+	got := Summarize(events)
+	want := Summary{Handled: 3, Delivered: 2, Cancelled: 1, MedianCompletion: 6 * time.Minute}
+	if got[1] != want {
+		t.Fatalf("shift 1 = %+v, want %+v", got[1], want)
+	}
+	if got[2] != (Summary{Handled: 1}) {
+		t.Fatalf("shift 2 = %+v", got[2])
+	}
+}
+
+func TestTotals(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	now := start.Add(5 * time.Hour)
+	list := []db.Shift{
+		{ID: 1, UserID: 7, UserName: "Ann", StartedAt: start, EndedAt: start.Add(time.Hour)},
+		{ID: 2, UserID: 8, UserName: "Bo", StartedAt: start},
+		{ID: 3, UserID: 7, UserName: "Ann", StartedAt: start.Add(2 * time.Hour), EndedAt: start.Add(3 * time.Hour)},
+	}
+	sums := map[int64]Summary{1: {Handled: 2, Delivered: 2}, 3: {Handled: 1, Cancelled: 1}}
+	got := Totals(list, sums, now)
+	if len(got) != 2 || got[0].Name != "Bo" || got[0].Worked != 5*time.Hour {
+		t.Fatalf("Totals = %+v", got)
+	}
+	ann := got[1]
+	if ann.Shifts != 2 || ann.Worked != 2*time.Hour || ann.Handled != 3 || ann.Delivered != 2 || ann.Cancelled != 1 {
+		t.Fatalf("Ann = %+v", ann)
+	}
+}
+
+type fakeStore struct {
+	before time.Time
+	n      int64
+}
+
+func (f *fakeStore) CloseIdleShifts(before time.Time) (int64, error) {
+	f.before = before
+	return f.n, nil
+}
+
+func TestSweeperRun(t *testing.T) {
+	st := &fakeStore{n: 2}
+	now := time.Unix(1700000000, 0)
+	n, err := NewSweeper(st, 30*time.Minute, nil).Run(now)
+	if err != nil || n != 2 || !st.before.Equal(now.Add(-30*time.Minute)) {
+		t.Fatalf("Run = %d, %v; closed before %v", n, err, st.before)
+	}
+
+	off := &fakeStore{n: 2}
+	if n, _ := NewSweeper(off, 0, nil).Run(now); n != 0 || !off.before.IsZero() {
+		t.Fatalf("disabled sweeper closed %d shifts", n)
+	}
+}
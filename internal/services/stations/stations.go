@@ -0,0 +1,43 @@
+// Package stations holds the rules for splitting a party across bar stations: which
+// station's queue a bartender sees and what a station may be called.
+package stations
+
+import (
+	"strconv"
+	"strings"
+
+	"house-bartender-go/internal/services/names"
+)
+
+// MaxNameLen bounds station names, in characters.
+const MaxNameLen = 40
+
+// Values of the queue's ?station= filter besides a station id. Mine, the empty filter,
+// is the bartender's own stations.
+const (
+	FilterMine = ""
+	FilterAll  = "all"
+)
+
+// Queue resolves the queue filter to the stations whose orders are shown, nil meaning
+// every station, and the filter actually applied. Mine falls back to all for a bartender
+// who works no station in particular, and an unknown station id to Mine.
+func Queue(filter string, assigned []int64, exists func(int64) bool) ([]int64, string) {
+	filter = strings.TrimSpace(filter)
+	if filter == FilterAll {
+		return nil, FilterAll
+	}
+	if id, err := strconv.ParseInt(filter, 10, 64); err == nil && id > 0 && exists(id) {
+		return []int64{id}, filter
+	}
+	if len(assigned) == 0 {
+		return nil, FilterAll
+	}
+	return assigned, FilterMine
+}
+
+// Clean tidies a typed station name: surrounding and repeated spaces go, and it is cut to
+// MaxNameLen characters.
+func Clean(s string) string {
+	return names.Clean(s, MaxNameLen)
+}
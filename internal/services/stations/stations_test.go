@@ -0,0 +1,39 @@
+package stations
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	exists := func(id int64) bool { return id == 1 || id == 2 }
+	for _, tc := range []struct {
+		filter   string
+		assigned []int64
+		ids      []int64
+		applied  string
+	}{
+		{"", nil, nil, FilterAll},
+		{"", []int64{2}, []int64{2}, FilterMine},
+		{"all", []int64{2}, nil, FilterAll},
+		{"1", []int64{2}, []int64{1}, "1"},
+		{"9", []int64{2}, []int64{2}, FilterMine},
+		{"9", nil, nil, FilterAll},
+		{"-1", nil, nil, FilterAll},
+	} {
+		ids, applied := Queue(tc.filter, tc.assigned, exists)
+		if !reflect.DeepEqual(ids, tc.ids) || applied != tc.applied {
+			t.Fatalf("Queue(%q, %v) = %v %q, want %v %q", tc.filter, tc.assigned, ids, applied, tc.ids, tc.applied)
+		}
+	}
+}
+
+func TestClean(t *testing.T) {
+	if got := Clean("  Beer   &  Wine "); got != "Beer & Wine" {
+		t.Fatalf("Clean = %q", got)
+	}
+	if got := Clean(strings.Repeat("ä", MaxNameLen+5)); len([]rune(got)) != MaxNameLen {
+		t.Fatalf("Clean kept %d characters", len([]rune(got)))
+	}
+}
@@ -0,0 +1,119 @@
+// Package totp implements the time-based one-time passwords (RFC 6238) used as a second
+// login factor, with the defaults every authenticator app understands: SHA-1, six digits,
+// 30-second steps. It also issues the one-time recovery codes for a lost phone.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"house-bartender-go/internal/services/resets"
+)
+
+const (
+	Period = 30 * time.Second
+	Digits = 6
+
+	// Skew is how many steps either side of now are accepted, for phones with a drifting
+	// clock and codes typed in just as they roll over.
+	Skew = 1
+
+	// RecoveryCodes is how many recovery codes a user gets at a time.
+	RecoveryCodes = 10
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160-bit secret, base32 encoded as authenticator apps expect.
+func NewSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Step is the time step t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code is the code for secret at step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("totp: bad secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	m := hmac.New(sha1.New, key)
+	m.Write(msg[:])
+	sum := m.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, n%1000000), nil
+}
+
+// Verify checks code against secret around now and returns the step it matched. Callers
+// must refuse a step at or before the last one accepted, so a code works only once.
+func Verify(secret, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+	cur := Step(now)
+	for step := cur - Skew; step <= cur+Skew; step++ {
+		want, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI is the otpauth:// link behind the enrollment QR code.
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period/time.Second)))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// NewRecoveryCodes returns a fresh set of recovery codes and their stored hashes. They
+// share the reset-code format, so they read aloud and type in the same way.
+func NewRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < RecoveryCodes; i++ {
+		code, err := resets.NewCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, RecoveryHash(code))
+	}
+	return codes, hashes, nil
+}
+
+// RecoveryHash is the stored form of a recovery code.
+func RecoveryHash(code string) string {
+	return resets.Hash(code)
+}
+
+// IsRecoveryCode tells a typed recovery code apart from an authenticator code.
+func IsRecoveryCode(input string) bool {
+	return len(resets.Normalize(input)) > Digits
+}
@@ -0,0 +1,77 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA-1 rows, truncated to six digits.
+func TestCodeMatchesRFCVectors(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		got, err := Code(secret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Fatalf("t=%d: code %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyAllowsOneStepOfSkew(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	prev, _ := Code(secret, Step(now)-1)
+	if step, ok := Verify(secret, prev[:3]+" "+prev[3:], now); !ok || step != Step(now)-1 {
+		t.Fatalf("previous step rejected: %v %d", ok, step)
+	}
+	old, _ := Code(secret, Step(now)-2)
+	if _, ok := Verify(secret, old, now); ok {
+		t.Fatal("code two steps old accepted")
+	}
+	if _, ok := Verify(secret, "12345", now); ok {
+		t.Fatal("short code accepted")
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("House Bartender", "ann@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(got, "otpauth://totp/House%20Bartender:ann@example.com?") {
+		t.Fatalf("URI = %s", got)
+	}
+	for _, part := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=House+Bartender", "digits=6", "period=30"} {
+		if !strings.Contains(got, part) {
+			t.Fatalf("URI %s lacks %s", got, part)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodes || len(hashes) != RecoveryCodes {
+		t.Fatalf("got %d codes, %d hashes", len(codes), len(hashes))
+	}
+	if RecoveryHash(strings.ToLower(codes[0])) != hashes[0] {
+		t.Fatal("hash depends on case")
+	}
+	if !IsRecoveryCode(codes[0]) || IsRecoveryCode("123 456") {
+		t.Fatal("IsRecoveryCode misclassifies")
+	}
+}
@@ -0,0 +1,125 @@
+// Package walkup runs walk-up ordering: while an admin has it open, anyone can order with
+// just a name. The throwaway accounts this creates are switched off when it ends.
+package walkup
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"house-bartender-go/internal/db"
+	"house-bartender-go/internal/services/names"
+)
+
+const (
+	// DefaultLength is how long walk-up ordering stays open unless the admin says otherwise;
+	// MaxLength caps it, so a forgotten party doesn't leave it open for good.
+	DefaultLength = 6 * time.Hour
+	MaxLength     = 48 * time.Hour
+
+	// MaxNameLen bounds guest names and table labels, in characters.
+	MaxNameLen = 40
+
+	// SweepEvery is how often guests of ended events are switched off.
+	SweepEvery = time.Minute
+)
+
+// Statuses shown on the admin screen. Closed means an admin ended it early.
+const (
+	StatusOpen   = "open"
+	StatusEnded  = "ended"
+	StatusClosed = "closed"
+)
+
+// Status reports whether guests can still join and order in ev at now.
+func Status(ev db.WalkupEvent, now time.Time) string {
+	switch {
+	case !ev.ClosedAt.IsZero():
+		return StatusClosed
+	case !now.Before(ev.EndsAt):
+		return StatusEnded
+	}
+	return StatusOpen
+}
+
+// URL is the public walk-up link, for a table when table is set. Printed as a QR code on
+// the table, it fills in where to bring the drinks.
+func URL(baseURL, table string) string {
+	u := strings.TrimRight(baseURL, "/") + "/walkup"
+	if table != "" {
+		u += "?table=" + url.QueryEscape(table)
+	}
+	return u
+}
+
+// Clean tidies a typed guest name or table label: surrounding and repeated spaces go, and
+// it is cut to MaxNameLen characters.
+func Clean(s string) string {
+	return names.Clean(s, MaxNameLen)
+}
+
+type Store interface {
+	// ExpireWalkupGuests deactivates the guests of events that have ended or were closed by
+	// now, and returns how many it switched off.
+	ExpireWalkupGuests(now time.Time) (int64, error)
+}
+
+// Sweeper switches off guests once their event is over. Their orders stay.
+type Sweeper struct {
+	store Store
+	log   *slog.Logger
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewSweeper(store Store, logger *slog.Logger) *Sweeper {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Sweeper{store: store, log: logger}
+}
+
+// Run sweeps once.
+func (s *Sweeper) Run(now time.Time) (int64, error) {
+	n, err := s.store.ExpireWalkupGuests(now)
+	if err == nil && n > 0 {
+		s.log.Info("walk-up guests expired", "count", n)
+	}
+	return n, err
+}
+
+// Start sweeps every interval until Stop is called.
+func (s *Sweeper) Start(every time.Duration) {
+	if every <= 0 || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-t.C:
+				if _, err := s.Run(time.Now()); err != nil {
+					s.log.Warn("walk-up sweep failed", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+func (s *Sweeper) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.stop = nil
+}
@@ -0,0 +1,64 @@
+package walkup
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+func TestStatus(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct {
+		ev   db.WalkupEvent
+		want string
+	}{
+		{db.WalkupEvent{EndsAt: now.Add(time.Minute)}, StatusOpen},
+		{db.WalkupEvent{EndsAt: now}, StatusEnded},
+		{db.WalkupEvent{EndsAt: now.Add(time.Hour), ClosedAt: now.Add(-time.Minute)}, StatusClosed},
+		{db.WalkupEvent{EndsAt: now.Add(-time.Hour), ClosedAt: now.Add(-time.Minute)}, StatusClosed},
+	} {
+		if got := Status(tc.ev, now); got != tc.want {
+			t.Fatalf("Status(%+v) = %s, want %s", tc.ev, got, tc.want)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	if got := URL("https://bar.example/", ""); got != "https://bar.example/walkup" {
+		t.Fatalf("URL = %s", got)
+	}
+	if got := URL("https://bar.example", "Patio 2"); got != "https://bar.example/walkup?table=Patio+2" {
+		t.Fatalf("URL = %s", got)
+	}
+}
+
+func TestClean(t *testing.T) {
+	if got := Clean("  Ann   Lee \n"); got != "Ann Lee" {
+		t.Fatalf("Clean = %q", got)
+	}
+	long := strings.Repeat("é", MaxNameLen+5)
+	if got := Clean(long); got != strings.Repeat("é", MaxNameLen) {
+		t.Fatalf("Clean kept %d characters", len([]rune(got)))
+	}
+}
+
+type fakeStore struct {
+	at time.Time
+	n  int64
+}
+
+func (f *fakeStore) ExpireWalkupGuests(now time.Time) (int64, error) {
+	f.at = now
+	return f.n, nil
+}
+
+func TestSweeperRun(t *testing.T) {
+	st := &fakeStore{n: 3}
+	now := time.Unix(1700000000, 0)
+	n, err := NewSweeper(st, nil).Run(now)
+	if err != nil || n != 3 || !st.at.Equal(now) {
+		t.Fatalf("Run = %d, %v; swept at %v", n, err, st.at)
+	}
+}
@@ -0,0 +1,570 @@
+// Package webhooks sends bar events to admin-configured HTTP endpoints. Every event is
+// queued in the database, one delivery per subscribed endpoint, and sent in the
+// background as a signed JSON POST, retried with backoff until the endpoint answers 2xx.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"house-bartender-go/internal/db"
+)
+
+// Event types an endpoint can subscribe to. They match the live-update events the app
+// sends its own pages.
+const (
+	OrderCreated     = "order:created"
+	OrderUpdated     = "order:updated"
+	InventoryUpdated = "inventory:updated"
+	UserCreated      = "user:created"
+
+	// Ping is sent by the test button whatever the endpoint subscribes to.
+	Ping = "ping"
+)
+
+// Events lists the subscribable event types in the order they are offered.
+var Events = []string{OrderCreated, OrderUpdated, InventoryUpdated, UserCreated}
+
+// Request headers. The signature is "sha256=" and the hex HMAC-SHA256, keyed with the
+// endpoint's secret, of the timestamp header, a dot and the raw body.
+const (
+	HeaderEvent     = "X-HouseBartender-Event"
+	HeaderDelivery  = "X-HouseBartender-Delivery"
+	HeaderTimestamp = "X-HouseBartender-Timestamp"
+	HeaderSignature = "X-HouseBartender-Signature"
+)
+
+const (
+	// RunEvery is how often the queue is checked when nothing wakes it sooner.
+	RunEvery = 15 * time.Second
+
+	// Timeout bounds one attempt, connecting to reading the response.
+	Timeout = 10 * time.Second
+
+	// KeepFor is how long finished deliveries stay in the log.
+	KeepFor = 30 * 24 * time.Hour
+
+	// MaxResponseBody is how much of an endpoint's response is logged, in bytes.
+	MaxResponseBody = 1024
+
+	// batchSize caps the deliveries sent in one run; the rest wait for the next.
+	batchSize = 50
+
+	pruneEvery = time.Hour
+)
+
+// Backoff is the wait after each failed attempt. A delivery that fails once more after
+// the last wait is given up as FAILED.
+var Backoff = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour, 6 * time.Hour}
+
+// MaxAttempts is how many times a delivery is tried before it fails.
+func MaxAttempts() int { return len(Backoff) + 1 }
+
+var (
+	ErrInvalidEndpoint  = errors.New("invalid webhook endpoint")
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// MaxNameLen bounds endpoint names, in characters.
+const MaxNameLen = 60
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header for body sent at ts.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is Sign(secret, ts, body). Receivers written in Go can
+// use it as is; it is also what the tests check deliveries with.
+func Verify(secret string, ts int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+// Endpoint is an endpoint as submitted by an admin.
+type Endpoint struct {
+	Name    string
+	URL     string
+	Events  []string
+	Enabled bool
+}
+
+// Clean tidies e and checks it: a name, an absolute http(s) URL and known event types,
+// kept in the order Events lists them.
+func (e Endpoint) Clean() (Endpoint, error) {
+	e.Name = strings.Join(strings.Fields(e.Name), " ")
+	if utf8.RuneCountInString(e.Name) > MaxNameLen {
+		e.Name = strings.TrimSpace(string([]rune(e.Name)[:MaxNameLen]))
+	}
+	if e.Name == "" {
+		return e, fmt.Errorf("%w: a name is required", ErrInvalidEndpoint)
+	}
+	e.URL = strings.TrimSpace(e.URL)
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return e, fmt.Errorf("%w: the URL must start with http:// or https://", ErrInvalidEndpoint)
+	}
+	chosen := map[string]bool{}
+	for _, ev := range e.Events {
+		chosen[strings.TrimSpace(ev)] = true
+	}
+	e.Events = nil
+	for _, ev := range Events {
+		if chosen[ev] {
+			e.Events = append(e.Events, ev)
+		}
+	}
+	return e, nil
+}
+
+// Envelope is the JSON body of every delivery.
+type Envelope struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	CreatedAt int64  `json:"created_at"`
+	Data      any    `json:"data"`
+}
+
+// Order is the data of the order events.
+type Order struct {
+	ID          int64    `json:"id"`
+	Status      string   `json:"status"`
+	CocktailID  int64    `json:"cocktail_id"`
+	Cocktail    string   `json:"cocktail"`
+	Quantity    int64    `json:"quantity"`
+	Modifiers   []string `json:"modifiers"`
+	Notes       string   `json:"notes"`
+	Location    string   `json:"location"`
+	GuestID     int64    `json:"guest_id"`
+	Guest       string   `json:"guest"`
+	StationID   int64    `json:"station_id"`
+	Station     string   `json:"station"`
+	BartenderID *int64   `json:"bartender_id"`
+	Bartender   string   `json:"bartender"`
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
+}
+
+// OrderData is the event data for o.
+func OrderData(o db.Order) Order {
+	out := Order{
+		ID:          o.ID,
+		Status:      o.Status,
+		CocktailID:  o.CocktailID,
+		Cocktail:    o.CocktailName,
+		Quantity:    o.Quantity,
+		Modifiers:   []string{},
+		Notes:       o.Notes,
+		Location:    o.Location,
+		GuestID:     o.UserID,
+		Guest:       o.UserDisplayName,
+		StationID:   o.StationID,
+		Station:     o.StationName,
+		BartenderID: o.AssignedBartenderID,
+		Bartender:   o.AssignedBartenderName,
+		CreatedAt:   o.CreatedAt.Unix(),
+		UpdatedAt:   o.UpdatedAt.Unix(),
+	}
+	for _, m := range o.Modifiers {
+		if !m.IsDefault {
+			out.Modifiers = append(out.Modifiers, m.Label+": "+m.Choice)
+		}
+	}
+	return out
+}
+
+// User is the data of user:created.
+type User struct {
+	ID          int64  `json:"id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Role        string `json:"role"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// UserData is the event data for u.
+func UserData(u db.User) User {
+	return User{ID: u.ID, Email: u.Email, DisplayName: u.DisplayName, Role: u.Role, CreatedAt: u.CreatedAt.Unix()}
+}
+
+// Inventory is the data of inventory:updated: what is out after the change.
+type Inventory struct {
+	OutOfStock         []Product `json:"out_of_stock"`
+	LowStock           []Product `json:"low_stock"`
+	AvailableCocktails int       `json:"available_cocktails"`
+}
+
+type Product struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Category   string `json:"category"`
+	StockCount *int64 `json:"stock_count"`
+}
+
+// InventoryData is the event data for the main bar's products and the number of
+// cocktails that can be ordered.
+func InventoryData(products []db.Product, availableCocktails int) Inventory {
+	out := Inventory{OutOfStock: []Product{}, LowStock: []Product{}, AvailableCocktails: availableCocktails}
+	for _, p := range products {
+		item := Product{ID: p.ID, Name: p.Name, Category: p.Category, StockCount: p.StockCount}
+		switch {
+		case !p.ComputedAvail:
+			out.OutOfStock = append(out.OutOfStock, item)
+		case p.IsLow():
+			out.LowStock = append(out.LowStock, item)
+		}
+	}
+	return out
+}
+
+type Store interface {
+	GetWebhookEndpoint(id int64) (*db.WebhookEndpoint, error)
+	ListWebhookEndpointsForEvent(event string) ([]db.WebhookEndpoint, error)
+	CreateWebhookDeliveries(endpointIDs []int64, eventID, event, payload string) ([]int64, error)
+	GetWebhookDelivery(id int64) (*db.WebhookDelivery, error)
+	ListDueWebhookDeliveries(now time.Time, limit int) ([]db.WebhookDelivery, error)
+	RecordWebhookAttempt(id int64, a db.WebhookAttempt) error
+	RedeliverWebhookDelivery(id int64) (int64, error)
+	PruneWebhookDeliveries(before time.Time) (int64, error)
+}
+
+// Service queues events and works the delivery queue.
+type Service struct {
+	store  Store
+	log    *slog.Logger
+	client *http.Client
+	now    func() time.Time
+
+	// mu guards the claims below; it is never held across a request. A delivery is sent
+	// only by whoever claimed it, so it is never sent twice at once, and the channel is
+	// closed once its attempt is recorded.
+	mu        sync.Mutex
+	inflight  map[int64]chan struct{}
+	lastPrune time.Time
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func New(store Store, logger *slog.Logger) *Service {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Service{
+		store: store,
+		log:   logger,
+		client: &http.Client{
+			Timeout: Timeout,
+			// a redirect is an answer, not a delivery
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		now:      time.Now,
+		inflight: map[int64]chan struct{}{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// Emit queues event for every enabled endpoint subscribed to it and wakes the sender.
+// It returns how many deliveries were queued.
+func (s *Service) Emit(event string, data any) (int, error) {
+	endpoints, err := s.store.ListWebhookEndpointsForEvent(event)
+	if err != nil || len(endpoints) == 0 {
+		return 0, err
+	}
+	ids := make([]int64, len(endpoints))
+	for i, e := range endpoints {
+		ids[i] = e.ID
+	}
+	queued, err := s.queue(ids, event, data)
+	return len(queued), err
+}
+
+func (s *Service) queue(endpointIDs []int64, event string, data any) ([]int64, error) {
+	env := Envelope{ID: newEventID(), Type: event, CreatedAt: s.now().Unix(), Data: data}
+	body, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.store.CreateWebhookDeliveries(endpointIDs, env.ID, event, string(body))
+	if err != nil {
+		return nil, err
+	}
+	s.Wake()
+	return ids, nil
+}
+
+// Wake asks the background sender to look at the queue now.
+func (s *Service) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends the deliveries due at now and returns how many were attempted. Each
+// endpoint's deliveries go in order, and endpoints are sent to side by side, so one slow
+// endpoint holds up only its own. Finished deliveries older than KeepFor are pruned at
+// most once an hour.
+func (s *Service) Run(now time.Time) (int, error) {
+	s.mu.Lock()
+	prune := now.Sub(s.lastPrune) >= pruneEvery
+	if prune {
+		s.lastPrune = now
+	}
+	s.mu.Unlock()
+	if prune {
+		if n, err := s.store.PruneWebhookDeliveries(now.Add(-KeepFor)); err != nil {
+			s.log.Warn("webhook log prune failed", "err", err)
+		} else if n > 0 {
+			s.log.Info("webhook deliveries pruned", "count", n)
+		}
+	}
+
+	// Listing and claiming together means a delivery sent and released meanwhile is
+	// listed as it was left, never as the stale copy of one already sent.
+	s.mu.Lock()
+	due, err := s.store.ListDueWebhookDeliveries(now, batchSize)
+	if err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	due = s.claimLocked(due...)
+	s.mu.Unlock()
+
+	var order []int64
+	byEndpoint := map[int64][]db.WebhookDelivery{}
+	for _, d := range due {
+		if _, seen := byEndpoint[d.EndpointID]; !seen {
+			order = append(order, d.EndpointID)
+		}
+		byEndpoint[d.EndpointID] = append(byEndpoint[d.EndpointID], d)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(order))
+	for i, eid := range order {
+		wg.Add(1)
+		go func(i int, list []db.WebhookDelivery) {
+			defer wg.Done()
+			defer s.release(list...)
+			e, err := s.store.GetWebhookEndpoint(list[0].EndpointID)
+			if err != nil || e == nil {
+				errs[i] = err
+				return
+			}
+			for _, d := range list {
+				if errs[i] = s.attempt(*e, d); errs[i] != nil {
+					return
+				}
+			}
+		}(i, byEndpoint[eid])
+	}
+	wg.Wait()
+	return len(due), errors.Join(errs...)
+}
+
+// claimLocked marks the deliveries not already being sent as the caller's to send, and
+// returns them. Each must be released once its attempt is recorded. s.mu must be held.
+func (s *Service) claimLocked(deliveries ...db.WebhookDelivery) []db.WebhookDelivery {
+	out := deliveries[:0:0]
+	for _, d := range deliveries {
+		if _, busy := s.inflight[d.ID]; busy {
+			continue
+		}
+		s.inflight[d.ID] = make(chan struct{})
+		out = append(out, d)
+	}
+	return out
+}
+
+func (s *Service) release(deliveries ...db.WebhookDelivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range deliveries {
+		if done, ok := s.inflight[d.ID]; ok {
+			close(done)
+			delete(s.inflight, d.ID)
+		}
+	}
+}
+
+// Send tries one pending delivery now, whatever its schedule, and returns it as it was
+// left. It is how the admin screen's test and redeliver buttons show a result right away.
+// A delivery the background sender is already sending is waited for instead.
+func (s *Service) Send(deliveryID int64) (*db.WebhookDelivery, error) {
+	s.mu.Lock()
+	if busy, sending := s.inflight[deliveryID]; sending {
+		s.mu.Unlock()
+		<-busy
+		return s.store.GetWebhookDelivery(deliveryID)
+	}
+	claimed := db.WebhookDelivery{ID: deliveryID}
+	s.claimLocked(claimed)
+	s.mu.Unlock()
+	defer s.release(claimed)
+
+	d, err := s.store.GetWebhookDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrDeliveryNotFound
+	}
+	if d.Status != db.WebhookPending {
+		return d, nil
+	}
+	e, err := s.store.GetWebhookEndpoint(d.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEndpointNotFound
+	}
+	if err := s.attempt(*e, *d); err != nil {
+		return nil, err
+	}
+	return s.store.GetWebhookDelivery(deliveryID)
+}
+
+// attempt posts d to e once and records the outcome; only a failure to record it is
+// returned.
+func (s *Service) attempt(e db.WebhookEndpoint, d db.WebhookDelivery) error {
+	now := s.now()
+	status, body, err := s.post(e, d, now)
+	a := db.WebhookAttempt{Status: db.WebhookDelivered, NextAttemptAt: now, ResponseStatus: status, ResponseBody: body}
+	if err == nil && (status < 200 || status > 299) {
+		err = fmt.Errorf("endpoint answered %d", status)
+	}
+	if err != nil {
+		a.Error = err.Error()
+		if tried := d.Attempts + 1; tried < MaxAttempts() {
+			a.Status = db.WebhookPending
+			a.NextAttemptAt = now.Add(Backoff[tried-1])
+		} else {
+			a.Status = db.WebhookFailed
+		}
+		s.log.Warn("webhook delivery failed", "endpoint", e.Name, "delivery", d.ID, "event", d.Event, "attempt", d.Attempts+1, "err", err)
+	}
+	return s.store.RecordWebhookAttempt(d.ID, a)
+}
+
+func (s *Service) post(e db.WebhookEndpoint, d db.WebhookDelivery, now time.Time) (int, string, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "HouseBartender-Webhooks/1")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(e.Secret, ts, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseBody))
+	return resp.StatusCode, strings.ToValidUTF8(string(b), ""), nil
+}
+
+// Ping queues a ping to the endpoint and sends it straight away.
+func (s *Service) Ping(endpointID int64) (*db.WebhookDelivery, error) {
+	e, err := s.store.GetWebhookEndpoint(endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEndpointNotFound
+	}
+	ids, err := s.queue([]int64{e.ID}, Ping, map[string]any{"endpoint_id": e.ID, "name": e.Name})
+	if err != nil {
+		return nil, err
+	}
+	return s.Send(ids[0])
+}
+
+// Redeliver queues a copy of a delivery, same event ID and payload, and sends it
+// straight away. The original is left as it was.
+func (s *Service) Redeliver(deliveryID int64) (*db.WebhookDelivery, error) {
+	id, err := s.store.RedeliverWebhookDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		return nil, ErrDeliveryNotFound
+	}
+	return s.Send(id)
+}
+
+// Start works the queue every interval, and whenever an event is queued, until Stop is
+// called.
+func (s *Service) Start(every time.Duration) {
+	if every <= 0 || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-t.C:
+			case <-s.wake:
+			}
+			if _, err := s.Run(s.now()); err != nil {
+				s.log.Warn("webhook run failed", "err", err)
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.stop = nil
+}
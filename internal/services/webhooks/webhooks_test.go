@@ -0,0 +1,430 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"house-bartender-go/internal/db"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	sig := Sign("whsec_test", 1700000000, body)
+	// echo -n '1700000000.{"id":"evt_1"}' | openssl dgst -sha256 -hmac whsec_test
+	if sig != "sha256=c89214b5b5da833daed6f0b8c5bb6bd58cea9022bd80ccc78230f3942d632925" {
+		t.Fatalf("Sign = %q", sig)
+	}
+	if !Verify("whsec_test", 1700000000, body, sig) {
+		t.Fatal("Verify rejected its own signature")
+	}
+	if Verify("whsec_other", 1700000000, body, sig) || Verify("whsec_test", 1700000001, body, sig) ||
+		Verify("whsec_test", 1700000000, []byte(`{"id":"evt_2"}`), sig) {
+		t.Fatal("Verify accepted a changed secret, timestamp or body")
+	}
+}
+
+func TestEndpointClean(t *testing.T) {
+	e, err := Endpoint{Name: "  Home   hub ", URL: " http://127.0.0.1:9000/hook ", Events: []string{UserCreated, "nope", OrderCreated}}.Clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Name != "Home hub" || e.URL != "http://127.0.0.1:9000/hook" {
+		t.Fatalf("Clean = %+v", e)
+	}
+	if len(e.Events) != 2 || e.Events[0] != OrderCreated || e.Events[1] != UserCreated {
+		t.Fatalf("events = %v", e.Events)
+	}
+	for _, bad := range []Endpoint{
+		{Name: "", URL: "https://example.com"},
+		{Name: "x", URL: "ftp://example.com"},
+		{Name: "x", URL: "/relative"},
+		{Name: "x", URL: "https://"},
+	} {
+		if _, err := bad.Clean(); !errors.Is(err, ErrInvalidEndpoint) {
+			t.Fatalf("Clean(%+v) err = %v", bad, err)
+		}
+	}
+}
+
+func TestInventoryData(t *testing.T) {
+	one, five, reorder := int64(1), int64(5), int64(2)
+	got := InventoryData([]db.Product{
+		{ID: 1, Name: "Gin", ComputedAvail: false},
+		{ID: 2, Name: "Lime", ComputedAvail: true, StockCount: &one, ReorderLevel: &reorder},
+		{ID: 3, Name: "Rum", ComputedAvail: true, StockCount: &five, ReorderLevel: &reorder},
+	}, 4)
+	if len(got.OutOfStock) != 1 || got.OutOfStock[0].Name != "Gin" || len(got.LowStock) != 1 || got.LowStock[0].Name != "Lime" || got.AvailableCocktails != 4 {
+		t.Fatalf("InventoryData = %+v", got)
+	}
+}
+
+type fakeStore struct {
+	mu         sync.Mutex
+	endpoints  map[int64]*db.WebhookEndpoint
+	deliveries []*db.WebhookDelivery
+}
+
+func (f *fakeStore) GetWebhookEndpoint(id int64) (*db.WebhookEndpoint, error) {
+	if e, ok := f.endpoints[id]; ok {
+		c := *e
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeStore) ListWebhookEndpointsForEvent(event string) ([]db.WebhookEndpoint, error) {
+	var out []db.WebhookEndpoint
+	for id := int64(1); id <= int64(len(f.endpoints)); id++ {
+		if e := f.endpoints[id]; e != nil && e.IsEnabled && e.Subscribes(event) {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) CreateWebhookDeliveries(endpointIDs []int64, eventID, event, payload string) ([]int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []int64
+	for _, eid := range endpointIDs {
+		d := &db.WebhookDelivery{ID: int64(len(f.deliveries) + 1), EndpointID: eid, EventID: eventID, Event: event, Payload: payload, Status: db.WebhookPending}
+		f.deliveries = append(f.deliveries, d)
+		ids = append(ids, d.ID)
+	}
+	return ids, nil
+}
+
+func (f *fakeStore) GetWebhookDelivery(id int64) (*db.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id < 1 || id > int64(len(f.deliveries)) {
+		return nil, nil
+	}
+	c := *f.deliveries[id-1]
+	return &c, nil
+}
+
+func (f *fakeStore) ListDueWebhookDeliveries(now time.Time, limit int) ([]db.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []db.WebhookDelivery
+	for _, d := range f.deliveries {
+		if d.Status == db.WebhookPending && !d.NextAttemptAt.After(now) && f.endpoints[d.EndpointID].IsEnabled && len(out) < limit {
+			out = append(out, *d)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) RecordWebhookAttempt(id int64, a db.WebhookAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.deliveries[id-1]
+	d.Attempts++
+	d.Status, d.NextAttemptAt, d.ResponseStatus, d.ResponseBody, d.Error = a.Status, a.NextAttemptAt, a.ResponseStatus, a.ResponseBody, a.Error
+	return nil
+}
+
+func (f *fakeStore) RedeliverWebhookDelivery(id int64) (int64, error) {
+	d, _ := f.GetWebhookDelivery(id)
+	if d == nil {
+		return 0, nil
+	}
+	ids, err := f.CreateWebhookDeliveries([]int64{d.EndpointID}, d.EventID, d.Event, d.Payload)
+	return ids[0], err
+}
+
+func (f *fakeStore) PruneWebhookDeliveries(time.Time) (int64, error) { return 0, nil }
+
+// receiver records the requests a test endpoint gets and answers with status. With hold
+// set, it reports each request on arrived and answers only once hold is closed.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+
+	arrived chan struct{}
+	hold    chan struct{}
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, b)
+	status := rc.status
+	rc.mu.Unlock()
+	if rc.hold != nil {
+		rc.arrived <- struct{}{}
+		<-rc.hold
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte("thanks"))
+}
+
+func newTestService(t *testing.T, status int, events ...string) (*Service, *fakeStore, *receiver, *time.Time) {
+	t.Helper()
+	rc := &receiver{status: status}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	st := &fakeStore{endpoints: map[int64]*db.WebhookEndpoint{
+		1: {ID: 1, Name: "Hub", URL: srv.URL + "/hook", Secret: "whsec_test", Events: events, IsEnabled: true},
+	}}
+	now := time.Unix(1700000000, 0)
+	s := New(st, nil)
+	s.now = func() time.Time { return now }
+	return s, st, rc, &now
+}
+
+func TestEmitSendsSignedDelivery(t *testing.T) {
+	s, st, rc, now := newTestService(t, http.StatusNoContent, OrderCreated)
+
+	if n, err := s.Emit(UserCreated, UserData(db.User{ID: 3})); err != nil || n != 0 {
+		t.Fatalf("unsubscribed Emit = %d, %v", n, err)
+	}
+	if n, err := s.Emit(OrderCreated, OrderData(db.Order{ID: 9, Status: "PLACED", CocktailName: "Negroni"})); err != nil || n != 1 {
+		t.Fatalf("Emit = %d, %v", n, err)
+	}
+	if n, err := s.Run(*now); err != nil || n != 1 {
+		t.Fatalf("Run = %d, %v", n, err)
+	}
+
+	if len(rc.requests) != 1 {
+		t.Fatalf("receiver got %d requests", len(rc.requests))
+	}
+	r, body := rc.requests[0], rc.bodies[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/hook" || r.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("request = %s %s %v", r.Method, r.URL.Path, r.Header)
+	}
+	if r.Header.Get(HeaderEvent) != OrderCreated || r.Header.Get(HeaderDelivery) != "1" {
+		t.Fatalf("headers = %v", r.Header)
+	}
+	ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if ts != now.Unix() || !Verify("whsec_test", ts, body, r.Header.Get(HeaderSignature)) {
+		t.Fatalf("bad signature %q at %d", r.Header.Get(HeaderSignature), ts)
+	}
+	var env struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data Order  `json:"data"`
+	}
+	if err := json.Unmarshal(body, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.ID == "" || env.Type != OrderCreated || env.Data.ID != 9 || env.Data.Cocktail != "Negroni" {
+		t.Fatalf("envelope = %+v", env)
+	}
+
+	d := st.deliveries[0]
+	if d.Status != db.WebhookDelivered || d.Attempts != 1 || d.ResponseStatus != http.StatusNoContent || d.EventID != env.ID {
+		t.Fatalf("delivery = %+v", d)
+	}
+}
+
+func TestFailedDeliveryBacksOffThenGivesUp(t *testing.T) {
+	s, st, rc, now := newTestService(t, http.StatusInternalServerError, InventoryUpdated)
+	if _, err := s.Emit(InventoryUpdated, InventoryData(nil, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Run(*now); err != nil {
+		t.Fatal(err)
+	}
+	d := st.deliveries[0]
+	if d.Status != db.WebhookPending || d.Attempts != 1 || !d.NextAttemptAt.Equal(now.Add(Backoff[0])) || d.ResponseBody != "thanks" || d.Error == "" {
+		t.Fatalf("after one failure = %+v", d)
+	}
+	if n, _ := s.Run(*now); n != 0 {
+		t.Fatalf("retried before its backoff: %d", n)
+	}
+
+	for i := 1; i < MaxAttempts(); i++ {
+		*now = d.NextAttemptAt
+		if _, err := s.Run(*now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d.Status != db.WebhookFailed || d.Attempts != MaxAttempts() || len(rc.requests) != MaxAttempts() {
+		t.Fatalf("after %d failures = %+v, %d requests", MaxAttempts(), d, len(rc.requests))
+	}
+	*now = now.Add(24 * time.Hour)
+	if n, _ := s.Run(*now); n != 0 {
+		t.Fatalf("failed delivery retried")
+	}
+}
+
+func TestRedirectIsNotDelivered(t *testing.T) {
+	s, st, _, _ := newTestService(t, http.StatusFound, UserCreated)
+	if _, err := s.Emit(UserCreated, UserData(db.User{ID: 1})); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Send(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != db.WebhookPending || got.ResponseStatus != http.StatusFound || st.deliveries[0].Attempts != 1 {
+		t.Fatalf("redirected delivery = %+v", got)
+	}
+}
+
+func TestPingAndRedeliver(t *testing.T) {
+	s, st, rc, _ := newTestService(t, http.StatusOK)
+
+	d, err := s.Ping(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Event != Ping || d.Status != db.WebhookDelivered || len(rc.requests) != 1 {
+		t.Fatalf("ping = %+v, %d requests", d, len(rc.requests))
+	}
+	if _, err := s.Ping(2); !errors.Is(err, ErrEndpointNotFound) {
+		t.Fatalf("ping unknown endpoint err = %v", err)
+	}
+
+	again, err := s.Redeliver(d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID == d.ID || again.EventID != d.EventID || again.Payload != d.Payload || again.Status != db.WebhookDelivered {
+		t.Fatalf("redelivery = %+v", again)
+	}
+	if len(st.deliveries) != 2 || len(rc.requests) != 2 || rc.requests[1].Header.Get(HeaderDelivery) != strconv.FormatInt(again.ID, 10) {
+		t.Fatalf("redelivery not sent as a new delivery")
+	}
+	if _, err := s.Redeliver(99); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Fatalf("redeliver unknown err = %v", err)
+	}
+}
+
+func TestDisabledEndpointWaits(t *testing.T) {
+	s, st, rc, now := newTestService(t, http.StatusOK, OrderUpdated)
+	if _, err := s.Emit(OrderUpdated, OrderData(db.Order{ID: 1})); err != nil {
+		t.Fatal(err)
+	}
+	st.endpoints[1].IsEnabled = false
+	if n, _ := s.Emit(OrderUpdated, OrderData(db.Order{ID: 1})); n != 0 {
+		t.Fatalf("queued for a disabled endpoint")
+	}
+	if n, _ := s.Run(*now); n != 0 || len(rc.requests) != 0 {
+		t.Fatalf("sent to a disabled endpoint")
+	}
+	st.endpoints[1].IsEnabled = true
+	if n, _ := s.Run(*now); n != 1 {
+		t.Fatalf("queued delivery not sent once re-enabled")
+	}
+}
+
+// newSlowEndpoint makes endpoint 1 hold every request until release is called, and adds
+// a prompt endpoint 2.
+func newSlowEndpoint(t *testing.T, st *fakeStore, rc *receiver) (fast *receiver, release func()) {
+	t.Helper()
+	rc.arrived, rc.hold = make(chan struct{}, 10), make(chan struct{})
+	var once sync.Once
+	release = func() { once.Do(func() { close(rc.hold) }) }
+	t.Cleanup(release)
+
+	fast = &receiver{status: http.StatusOK}
+	srv := httptest.NewServer(fast)
+	t.Cleanup(srv.Close)
+	st.endpoints[2] = &db.WebhookEndpoint{ID: 2, Name: "Fast", URL: srv.URL, Secret: "whsec_fast", Events: []string{OrderCreated}, IsEnabled: true}
+	return fast, release
+}
+
+func within(t *testing.T, what string, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s is stuck behind a slow endpoint", what)
+	}
+}
+
+func TestSlowEndpointHoldsUpOnlyItself(t *testing.T) {
+	s, st, rc, now := newTestService(t, http.StatusOK, OrderCreated)
+	fast, release := newSlowEndpoint(t, st, rc)
+	if n, _ := s.Emit(OrderCreated, OrderData(db.Order{ID: 1})); n != 2 {
+		t.Fatalf("queued %d deliveries, want 2", n)
+	}
+
+	ran := make(chan int)
+	go func() {
+		n, _ := s.Run(*now)
+		ran <- n
+	}()
+	<-rc.arrived
+
+	within(t, "the other endpoint", func() {
+		for {
+			if d, _ := st.GetWebhookDelivery(2); d.Status == db.WebhookDelivered {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+	within(t, "Ping", func() {
+		d, err := s.Ping(2)
+		if err != nil || d.Status != db.WebhookDelivered {
+			t.Errorf("ping = %+v, %v", d, err)
+		}
+	})
+	within(t, "Redeliver", func() {
+		d, err := s.Redeliver(2)
+		if err != nil || d.Status != db.WebhookDelivered {
+			t.Errorf("redelivery = %+v, %v", d, err)
+		}
+	})
+	if n, _ := s.Run(*now); n != 0 {
+		t.Fatalf("a second run took %d deliveries already being sent", n)
+	}
+
+	release()
+	if n := <-ran; n != 2 {
+		t.Fatalf("Run attempted %d deliveries, want 2", n)
+	}
+	if len(fast.requests) != 3 || len(rc.requests) != 1 {
+		t.Fatalf("requests: %d fast, %d slow", len(fast.requests), len(rc.requests))
+	}
+}
+
+func TestSendWaitsForADeliveryBeingSent(t *testing.T) {
+	s, st, rc, now := newTestService(t, http.StatusOK, OrderCreated)
+	_, release := newSlowEndpoint(t, st, rc)
+	delete(st.endpoints, 2)
+	if _, err := s.Emit(OrderCreated, OrderData(db.Order{ID: 1})); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _, _ = s.Run(*now) }()
+	<-rc.arrived
+	sent := make(chan *db.WebhookDelivery)
+	go func() {
+		d, _ := s.Send(1)
+		sent <- d
+	}()
+	select {
+	case d := <-sent:
+		t.Fatalf("Send returned %+v while the delivery was still being sent", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	if d := <-sent; d == nil || d.Status != db.WebhookDelivered || d.Attempts != 1 {
+		t.Fatalf("Send = %+v, want the one delivered attempt", d)
+	}
+	if len(rc.requests) != 1 {
+		t.Fatalf("the delivery was sent %d times", len(rc.requests))
+	}
+}
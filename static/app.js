@@ -323,6 +323,11 @@
       url.searchParams.set("q", String(searchInput.value).trim());
     }
 
+    const statusInput = scope ? qs("[data-inventory-status]:checked", scope) : null;
+    if (statusInput && statusInput.value) {
+      url.searchParams.set("status", statusInput.value);
+    }
+
     return url.pathname + url.search;
   }
 
@@ -523,6 +528,59 @@
     });
   }
 
+  function wireModifierEditors(root = document) {
+    collect("[data-modifier-editor]", root).forEach((editor) => {
+      if (editor.dataset.editorBound === "1") {
+        return;
+      }
+      editor.dataset.editorBound = "1";
+
+      const list = qs("[data-modifier-list]", editor);
+      const template = qs("template[data-modifier-template]", editor);
+      const addButton = qs("[data-modifier-add]", editor);
+      if (!list || !template) {
+        return;
+      }
+
+      function wireRemoveButtons(scope) {
+        collect("[data-modifier-remove]", scope).forEach((button) => {
+          if (button.dataset.bound === "1") {
+            return;
+          }
+          button.dataset.bound = "1";
+
+          button.addEventListener("click", () => {
+            const row = button.closest("[data-modifier-row]");
+            if (!row) {
+              return;
+            }
+            if (qsa("[data-modifier-row]", list).length === 1) {
+              clearIngredientRow(row);
+              return;
+            }
+            row.remove();
+          });
+        });
+      }
+
+      wireRemoveButtons(editor);
+
+      if (addButton) {
+        addButton.addEventListener("click", () => {
+          list.appendChild(template.content.cloneNode(true));
+          const row = list.lastElementChild;
+          if (row) {
+            wireRemoveButtons(row);
+            const kindField = qs("select[name='modifier_kind']", row);
+            if (kindField) {
+              kindField.focus();
+            }
+          }
+        });
+      }
+    });
+  }
+
   async function refreshPartial(kind) {
     const path = document.body.getAttribute("data-path") || window.location.pathname;
 
@@ -558,7 +616,7 @@
         }
 
         if (path.startsWith("/bartender")) {
-          const url = path === "/bartender" ? "/partials/bartender/orders?view=dashboard" : "/partials/bartender/orders";
+          const url = path === "/bartender" ? "/partials/bartender/orders?view=dashboard" : "/partials/bartender/orders" + stationQuery();
           const resp = await hxFetch(url);
           ordersList.innerHTML = await resp.text();
           initUI(ordersList);
@@ -587,17 +645,28 @@
     } catch (err) {}
   }
 
+  // stationQuery keeps the queue's station tab for live updates and refreshes.
+  function stationQuery() {
+    const host = qs("[data-station-queue]");
+    const station = host ? host.getAttribute("data-station-queue") : "";
+    return station ? "?station=" + encodeURIComponent(station) : "";
+  }
+
+  function userPermissions() {
+    return (document.body.getAttribute("data-perms") || "").split(" ").filter(Boolean);
+  }
+
   function wireSSE() {
     const userId = document.body.getAttribute("data-user");
     if (!userId) {
       return;
     }
 
-    const role = document.body.getAttribute("data-role") || "";
-    const es = new EventSource("/sse");
+    const perms = userPermissions();
+    const es = new EventSource("/sse" + stationQuery());
 
     es.addEventListener("order:created", () => {
-      if (role === "BARTENDER" || role === "ADMIN") {
+      if (perms.includes("orders.manage")) {
         beep();
         refreshPartial("orders");
       }
@@ -611,6 +680,16 @@
       refreshPartial("inventory");
     });
 
+    es.addEventListener("inventory:low", (evt) => {
+      if (!perms.includes("inventory.edit")) {
+        return;
+      }
+      try {
+        const data = JSON.parse(evt.data || "{}");
+        showFlash("Low stock: " + data.name + " (" + data.stock + " left, reorder at " + data.reorder_level + ")");
+      } catch (err) {}
+    });
+
     es.addEventListener("hello", () => {});
     es.onerror = () => {};
   }
@@ -650,9 +729,8 @@
   }
 
   function isBartenderPushContext() {
-    const role = document.body.getAttribute("data-role") || "";
     const path = document.body.getAttribute("data-path") || window.location.pathname;
-    return role === "BARTENDER" && path.startsWith("/bartender");
+    return userPermissions().includes("orders.manage") && path.startsWith("/bartender");
   }
 
   function urlBase64ToUint8Array(base64String) {
@@ -743,7 +821,7 @@
     if (!("Notification" in window) || !("PushManager" in window) || !("serviceWorker" in navigator)) {
       return {
         label: "Unsupported",
-        message: "This browser does not support the Web Push features required for bartender alerts.",
+        message: "This browser does not support the Web Push features required for notifications.",
         tone: "warn",
         canEnable: false,
         canDisable: false,
@@ -808,6 +886,13 @@
     }
   }
 
+  // Cards next to a device list reload the page so the list shows the change.
+  function reloadForPushCard(card) {
+    if (card.hasAttribute("data-push-reload")) {
+      window.location.reload();
+    }
+  }
+
   async function enablePushNotifications(card) {
     renderPushCard(card, {
       label: "Enabling...",
@@ -839,7 +924,7 @@
         });
       }
 
-      const response = await jsonFetch("/bartender/notifications/subscribe", {
+      const response = await jsonFetch("/notifications/subscribe", {
         method: "POST",
         body: JSON.stringify(subscription.toJSON()),
       });
@@ -854,6 +939,7 @@
       }
 
       await syncPushCard(card, "Notifications are enabled on this device.");
+      reloadForPushCard(card);
     } catch (err) {
       renderPushCard(card, {
         label: "Could not enable",
@@ -868,7 +954,7 @@
   async function disablePushNotifications(card) {
     renderPushCard(card, {
       label: "Disabling...",
-      message: "Removing this device from bartender alerts.",
+      message: "Removing this device from notifications.",
       tone: "muted",
       canEnable: false,
       canDisable: false,
@@ -881,7 +967,7 @@
       let serverWarning = "";
 
       if (subscription) {
-        const response = await jsonFetch("/bartender/notifications/unsubscribe", {
+        const response = await jsonFetch("/notifications/unsubscribe", {
           method: "POST",
           body: JSON.stringify({ endpoint: subscription.endpoint }),
         });
@@ -903,6 +989,7 @@
       }
 
       await syncPushCard(card, serverWarning || "Notifications are disabled on this device.");
+      reloadForPushCard(card);
     } catch (err) {
       renderPushCard(card, {
         label: "Could not disable",
@@ -939,6 +1026,30 @@
     });
   }
 
+  // Device forms without a saved time zone take this browser's, so quiet hours follow the
+  // bartender's clock rather than the server's.
+  function wireTimeZoneFields(root = document) {
+    let zone = "";
+    try {
+      zone = Intl.DateTimeFormat().resolvedOptions().timeZone || "";
+    } catch (err) {
+      return;
+    }
+    if (!zone) {
+      return;
+    }
+    collect("[data-time-zone]", root).forEach((input) => {
+      if (input.value) {
+        return;
+      }
+      input.value = zone;
+      const label = input.parentElement ? qs("[data-time-zone-label]", input.parentElement) : null;
+      if (label) {
+        label.textContent = zone;
+      }
+    });
+  }
+
   function wirePushSupport() {
     if (!isBartenderPushContext() || !isSecureContextLike() || !("serviceWorker" in navigator)) {
       return;
@@ -962,6 +1073,250 @@
     }
   }
 
+  function wireStocktakes(root = document) {
+    collect("[data-stocktake]", root).forEach((form) => {
+      if (form.dataset.bound === "1") {
+        return;
+      }
+      form.dataset.bound = "1";
+
+      const rows = qsa("[data-stocktake-row]", form);
+      const search = qs("[data-stocktake-search]", form);
+
+      function syncVariance(row) {
+        const input = qs("[data-stocktake-count]", row);
+        const cell = qs("[data-stocktake-variance]", row);
+        const expected = row.getAttribute("data-expected");
+        if (!input || !cell) {
+          return;
+        }
+        const raw = String(input.value || "").trim();
+        if (raw === "" || expected === null) {
+          cell.textContent = raw === "" ? "" : "-";
+          cell.className = cell.className.replace(/ ?text-(error|emerald-700)/g, "");
+          return;
+        }
+        const diff = Number(raw) - Number(expected);
+        cell.textContent = (diff > 0 ? "+" : "") + diff;
+        cell.classList.toggle("text-error", diff < 0);
+        cell.classList.toggle("text-emerald-700", diff > 0);
+      }
+
+      function visibleRows() {
+        return rows.filter((row) => !row.hidden);
+      }
+
+      rows.forEach((row) => {
+        syncVariance(row);
+        const input = qs("[data-stocktake-count]", row);
+        if (!input) {
+          return;
+        }
+        input.addEventListener("input", () => syncVariance(row));
+        input.addEventListener("keydown", (evt) => {
+          if (evt.key !== "Enter") {
+            return;
+          }
+          // Enter jumps back to search so the next product can be found without the mouse.
+          evt.preventDefault();
+          if (search) {
+            search.focus();
+            search.select();
+          }
+        });
+      });
+
+      if (!search) {
+        return;
+      }
+      search.addEventListener("input", () => {
+        const query = String(search.value || "").trim().toLowerCase();
+        rows.forEach((row) => {
+          const haystack = String(row.getAttribute("data-search") || "").toLowerCase();
+          row.hidden = query !== "" && !haystack.includes(query);
+        });
+      });
+      search.addEventListener("keydown", (evt) => {
+        if (evt.key !== "Enter") {
+          return;
+        }
+        evt.preventDefault();
+        const first = visibleRows()[0];
+        const input = first ? qs("[data-stocktake-count]", first) : null;
+        if (input) {
+          input.focus();
+          input.select();
+        }
+      });
+    });
+  }
+
+  function wireBarcodeScanner(root = document) {
+    collect("[data-barcode-scanner]", root).forEach((card) => {
+      if (card.dataset.bound === "1") {
+        return;
+      }
+      card.dataset.bound = "1";
+
+      const form = qs("[data-barcode-form]", card);
+      const input = qs("[data-barcode-input]", card);
+      const result = qs("[data-barcode-result]", card);
+      const video = qs("[data-barcode-video]", card);
+      const cameraBtn = qs("[data-barcode-camera]", card);
+      let lastCode = "";
+      let lastAt = 0;
+      let stream = null;
+
+      function currentDelta() {
+        const picked = qs("[data-barcode-mode]:checked", card);
+        return picked ? Number(picked.value) : 0;
+      }
+
+      function showResult(text, createUrl) {
+        if (!result) {
+          return;
+        }
+        result.textContent = text;
+        if (createUrl) {
+          const link = document.createElement("a");
+          link.className = "ml-2 font-semibold text-primary border-b border-primary/20 hover:border-primary";
+          link.href = createUrl;
+          link.textContent = "Create ingredient";
+          result.appendChild(link);
+        }
+      }
+
+      async function submit(code) {
+        code = String(code || "").replace(/\s+/g, "");
+        if (!code) {
+          return;
+        }
+        // Cameras report the same code many times a second; only count it once.
+        const now = Date.now();
+        if (code === lastCode && now - lastAt < 2000) {
+          return;
+        }
+        lastCode = code;
+        lastAt = now;
+
+        try {
+          const resp = await jsonFetch("/bartender/products/scan", {
+            method: "POST",
+            body: JSON.stringify({ code, delta: currentDelta() }),
+          });
+          const data = await resp.json();
+          if (!resp.ok || !data.ok) {
+            showResult(data.error || "Scan failed.");
+            return;
+          }
+          if (!data.found) {
+            showResult("No ingredient has barcode " + data.code + ".", data.create_url);
+            return;
+          }
+          const stock = data.product.stock === null ? "untracked" : data.product.stock + " in stock";
+          showResult(data.product.name + ": " + stock + ".");
+          await refreshInventoryResults();
+        } catch (err) {
+          showResult("Scan failed.");
+        }
+      }
+
+      if (form && input) {
+        form.addEventListener("submit", (evt) => {
+          evt.preventDefault();
+          lastCode = "";
+          submit(input.value);
+          input.value = "";
+          input.focus();
+        });
+      }
+
+      if (!cameraBtn || !video || !("BarcodeDetector" in window) || !navigator.mediaDevices) {
+        return;
+      }
+      cameraBtn.classList.remove("hidden");
+
+      async function scanFrames(detector) {
+        if (!stream) {
+          return;
+        }
+        try {
+          const codes = await detector.detect(video);
+          if (codes.length > 0) {
+            await submit(codes[0].rawValue);
+          }
+        } catch (err) {
+          // Frames can fail to decode while the camera warms up; keep polling.
+        }
+        window.setTimeout(() => scanFrames(detector), 250);
+      }
+
+      function stopCamera() {
+        if (stream) {
+          stream.getTracks().forEach((track) => track.stop());
+        }
+        stream = null;
+        video.classList.add("hidden");
+        cameraBtn.textContent = "Use Camera";
+      }
+
+      cameraBtn.addEventListener("click", async () => {
+        if (stream) {
+          stopCamera();
+          return;
+        }
+        try {
+          stream = await navigator.mediaDevices.getUserMedia({ video: { facingMode: "environment" } });
+        } catch (err) {
+          showResult("Camera access was denied.");
+          return;
+        }
+        video.srcObject = stream;
+        video.classList.remove("hidden");
+        await video.play();
+        cameraBtn.textContent = "Stop Camera";
+        scanFrames(new window.BarcodeDetector());
+      });
+    });
+  }
+
+  // showFlash mirrors the server-rendered flash markup for notices that arrive over SSE.
+  function showFlash(message) {
+    let container = qs("[data-flash-container]");
+    if (!container) {
+      const wrapper = document.createElement("div");
+      wrapper.className = "fixed inset-x-0 top-4 z-[80] flex justify-center px-4";
+      wrapper.setAttribute("role", "status");
+      wrapper.setAttribute("aria-live", "polite");
+      container = document.createElement("div");
+      container.className = "w-full max-w-[520px] space-y-3";
+      container.setAttribute("data-flash-container", "");
+      wrapper.appendChild(container);
+      document.body.appendChild(wrapper);
+    }
+
+    const item = document.createElement("div");
+    item.className = "flex items-start gap-3 rounded-lg border px-4 py-3 shadow-sm backdrop-blur-xl border-black/10 bg-white/95 text-[#191a28]";
+    item.setAttribute("data-flash-item", "");
+
+    const icon = document.createElement("span");
+    icon.className = "material-symbols-outlined text-[18px] mt-0.5";
+    icon.textContent = "inventory_2";
+    const text = document.createElement("span");
+    text.className = "text-sm font-medium leading-6 flex-1";
+    text.textContent = message;
+    const close = document.createElement("button");
+    close.className = "shrink-0 rounded-full p-1 hover:bg-black/5 transition-colors";
+    close.type = "button";
+    close.setAttribute("data-flash-dismiss", "");
+    close.setAttribute("aria-label", "Dismiss notification");
+    close.innerHTML = '<span class="material-symbols-outlined text-[18px]">close</span>';
+
+    item.append(icon, text, close);
+    container.appendChild(item);
+    wireFlashes(container);
+  }
+
   function wireFlashes(root = document) {
     collect("[data-flash-item]", root).forEach((item) => {
       if (item.dataset.bound === "1") {
@@ -1008,6 +1363,18 @@
       searchInput.addEventListener("input", handleSearch);
       searchInput.addEventListener("search", handleSearch);
     }
+
+    collect("[data-inventory-status]", controls).forEach((input) => {
+      if (input.dataset.bound === "1") {
+        return;
+      }
+      input.dataset.bound = "1";
+      input.addEventListener("change", () => {
+        refreshInventoryResults().catch((err) => {
+          console.warn("inventory filter error", err);
+        });
+      });
+    });
   }
 
   function applyShellSearch() {
@@ -1095,11 +1462,38 @@
     syncSelectProxyButtons();
   }
 
+  // Kiosk guests get a visible countdown; when it runs out the kiosk goes back to its
+  // guest picker, which also ends the session server-side.
+  function wireKioskTimer() {
+    const host = qs("[data-kiosk-expires]");
+    if (!host) {
+      return;
+    }
+    const expires = Number(host.getAttribute("data-kiosk-expires")) * 1000;
+    const label = qs("[data-kiosk-countdown]", host);
+    const tick = () => {
+      const left = Math.max(0, Math.round((expires - Date.now()) / 1000));
+      if (label) {
+        label.textContent = Math.floor(left / 60) + ":" + String(left % 60).padStart(2, "0");
+      }
+      if (left === 0) {
+        window.location.assign("/kiosk");
+        return;
+      }
+      window.setTimeout(tick, 1000);
+    };
+    tick();
+  }
+
   function initUI(root = document) {
     wireHX(root);
     wireCocktailViewToggle(root);
     wireIngredientEditors(root);
+    wireModifierEditors(root);
+    wireStocktakes(root);
+    wireBarcodeScanner(root);
     wirePushCard(root);
+    wireTimeZoneFields(root);
     wireFlashes(root);
     wireInventoryFilters(root);
     wireShellSearch();
@@ -1111,6 +1505,7 @@
     wireThemeToggle();
     initUI(document);
     wireSSE();
+    wireKioskTimer();
     applyHighlightedOrder(document, true);
 
     if (systemThemeQuery) {